@@ -0,0 +1,247 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"nft/auth"
+	"nft/config"
+	"nft/db"
+	"nft/imx"
+	"nft/imx/fake"
+	"nft/models"
+	"os"
+
+	"github.com/google/uuid"
+	"golang.org/x/exp/slog"
+	"gopkg.in/yaml.v3"
+)
+
+const adminUsage = `Usage: nft-imx admin <action> [flags]
+
+Actions:
+  register-signer    register the platform signer with IMX, required before creating a project
+  create-project     create the IMX project of the platform, and print its PROJECT_ID
+  create-collection  create a collection and its metadata schema described by a YAML file,
+                     see collection.example.yml
+  promote            make a user admin, and print the admin key to request the admin scope with
+  demote             revoke the admin scope of a user
+
+Flags:
+`
+
+// collectionFile describes a collection to create, with the fields of the collection request.
+type collectionFile struct {
+	// ProjectID defaults to the configured one.
+	ProjectID       int32                 `yaml:"project_id"`
+	ContractAddress string                `yaml:"contract_address"`
+	CollectionName  string                `yaml:"collection_name"`
+	MetadataUrl     string                `yaml:"metadata_url"`
+	Fields          []collectionFieldFile `yaml:"fields"`
+}
+
+type collectionFieldFile struct {
+	Name string `yaml:"name"`
+	Type string `yaml:"type"`
+}
+
+// runAdmin runs the admin command, args are what follows it. The results are printed to stdout, as the
+// lines to add to the configuration.
+func runAdmin(settings *config.Settings, args []string) error {
+	flags := flag.NewFlagSet("admin", flag.ExitOnError)
+	flags.Usage = func() {
+		fmt.Fprint(flags.Output(), adminUsage)
+		flags.PrintDefaults()
+	}
+	email := flags.String("email", "", "the contact email of the project and the signer")
+	projectName := flags.String("name", "", "the name of the project, for create-project")
+	companyName := flags.String("company", "", "the company name of the project, for create-project")
+	file := flags.String("file", "collection.yml", "the YAML file describing the collection, for create-collection")
+	userID := flags.String("user", "", "the ID of the user, for promote and demote")
+
+	if len(args) == 0 {
+		flags.Usage()
+		os.Exit(2)
+	}
+	action := args[0]
+	_ = flags.Parse(args[1:])
+
+	switch action {
+	case "promote", "demote":
+		id, err := uuid.Parse(*userID)
+		if err != nil {
+			return fmt.Errorf("%s expects the -user ID: %w", action, err)
+		}
+		return setUserAdmin(settings, id, action == "promote")
+	}
+
+	var run func(ctx context.Context, admin imx.Admin) error
+	switch action {
+	case "register-signer":
+		if len(*email) == 0 {
+			return errors.New("register-signer expects the -email of the signer")
+		}
+		run = func(ctx context.Context, admin imx.Admin) error {
+			signer, err := admin.RegisterSigner(ctx, *email)
+			if err != nil {
+				return err
+			}
+			fmt.Printf("address=%s\nstark_key=%s\n", signer.Address, signer.StarkKey)
+			return nil
+		}
+	case "create-project":
+		if len(*projectName) == 0 || len(*companyName) == 0 || len(*email) == 0 {
+			return errors.New("create-project expects the -name, -company and -email of the project")
+		}
+		run = func(ctx context.Context, admin imx.Admin) error {
+			projectID, err := admin.CreateProject(ctx, &imx.ProjectInformation{
+				ProjectName:  *projectName,
+				CompanyName:  *companyName,
+				ContactEmail: *email,
+			})
+			if err != nil {
+				return err
+			}
+			fmt.Printf("PROJECT_ID=%d\n", projectID)
+			return nil
+		}
+	case "create-collection":
+		collection, err := readCollectionFile(*file)
+		if err != nil {
+			return err
+		}
+		if collection.ProjectID == 0 {
+			collection.ProjectID = settings.ProjectID
+		}
+		if collection.ProjectID <= 0 {
+			return errors.New("missing imx project id, set project_id in the file or PROJECT_ID")
+		}
+		run = func(ctx context.Context, admin imx.Admin) error {
+			return createCollection(ctx, admin, collection)
+		}
+	default:
+		fmt.Fprintf(os.Stderr, "unknown admin action %q\n\n", action)
+		flags.Usage()
+		os.Exit(2)
+	}
+
+	admin, err := newAdmin(settings)
+	if err != nil {
+		return fmt.Errorf("error configuring imx: %w", err)
+	}
+	defer admin.Close()
+
+	return run(context.Background(), admin)
+}
+
+// setUserAdmin grants or revokes the admin scope. A new admin key is printed on each promotion, the previous
+// one no longer works.
+func setUserAdmin(settings *config.Settings, userID uuid.UUID, admin bool) error {
+	newDB, err := db.NewDB(settings.DSN)
+	if err != nil {
+		return fmt.Errorf("error configuring DB: %w", err)
+	}
+
+	user, err := newDB.GetUser(userID)
+	if err != nil {
+		return err
+	}
+	if user == nil {
+		return fmt.Errorf("user not found: %s", userID)
+	}
+
+	var adminKey, adminKeyHash string
+	action := "user.demote"
+	if admin {
+		adminKey, adminKeyHash, err = auth.NewAdminKey()
+		if err != nil {
+			return err
+		}
+		action = "user.promote"
+	}
+
+	if err := newDB.SetUserAdmin(userID, adminKeyHash); err != nil {
+		return err
+	}
+
+	event := &models.AuditEvent{
+		ID:         uuid.New(),
+		Action:     action,
+		TargetType: "user",
+		TargetID:   userID.String(),
+		Outcome:    models.AuditSuccess,
+		Details:    "admin command",
+	}
+	if err := newDB.CreateAuditEvent(event); err != nil {
+		slog.Error("error saving audit event", "action", action, "err", err)
+	}
+
+	if admin {
+		fmt.Printf("admin_key=%s\n", adminKey)
+	}
+	return nil
+}
+
+func newAdmin(settings *config.Settings) (imx.Admin, error) {
+	if settings.IMXFake {
+		slog.Warn("using the in-memory IMX, nothing is created on IMX")
+		return fake.New(fake.PlatformAddress), nil
+	}
+	return imx.NewAdmin(settings.IMXSettings())
+}
+
+func readCollectionFile(path string) (*collectionFile, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	collection := &collectionFile{}
+	if err := yaml.Unmarshal(content, collection); err != nil {
+		return nil, fmt.Errorf("invalid collection file %s: %w", path, err)
+	}
+
+	if len(collection.ContractAddress) == 0 || len(collection.CollectionName) == 0 {
+		return nil, fmt.Errorf("invalid collection file %s: missing contract_address or collection_name", path)
+	}
+
+	for _, f := range collection.Fields {
+		if len(f.Name) == 0 || len(f.Type) == 0 {
+			return nil, fmt.Errorf("invalid collection file %s: fields need a name and a type", path)
+		}
+	}
+
+	return collection, nil
+}
+
+func createCollection(ctx context.Context, admin imx.Admin, collection *collectionFile) error {
+	info := imx.CollectionInformation{
+		ProjectID:       collection.ProjectID,
+		ContractAddress: collection.ContractAddress,
+		CollectionName:  collection.CollectionName,
+		MetadataUrl:     collection.MetadataUrl,
+	}
+
+	if err := admin.CreateCollection(ctx, &info); err != nil {
+		return fmt.Errorf("error creating collection: %w", err)
+	}
+
+	if len(collection.Fields) > 0 {
+		metadataInfo := imx.MetadataInformation{
+			ContractAddress: collection.ContractAddress,
+		}
+
+		for _, f := range collection.Fields {
+			field := imx.MetadataFieldInformation{Name: f.Name, Type: f.Type}
+			metadataInfo.Fields = append(metadataInfo.Fields, field)
+		}
+
+		if err := admin.CreateMetadata(ctx, &metadataInfo); err != nil {
+			return fmt.Errorf("error creating metadata: %w", err)
+		}
+	}
+
+	fmt.Printf("project_id=%d\ncontract_address=%s\n", info.ProjectID, info.ContractAddress)
+	return nil
+}
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"nft/server"
+
+	"golang.org/x/exp/slog"
+)
+
+// api serves the HTTP API.
+type api struct {
+	httpServer *http.Server
+}
+
+func newAPI(a *app) *api {
+	newServer := server.NewServer(a.settings, a.db, a.imx, a.asynqClient, a.metrics)
+	newServer.Configure()
+
+	httpServer := &http.Server{Addr: ":" + a.settings.Port, Handler: newServer.Router}
+	httpServer.RegisterOnShutdown(newServer.CloseStreams)
+	return &api{httpServer}
+}
+
+func (s *api) Start(errs chan<- error) error {
+	go func() {
+		slog.Info("serving the API", "addr", s.httpServer.Addr)
+		if err := s.httpServer.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			errs <- err
+		}
+	}()
+	return nil
+}
+
+// Shutdown stops accepting requests, ends the event streams and waits for the pending ones.
+func (s *api) Shutdown(ctx context.Context) error {
+	return s.httpServer.Shutdown(ctx)
+}
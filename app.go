@@ -0,0 +1,97 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"nft/config"
+	"nft/db"
+	"nft/imx"
+	"nft/imx/fake"
+	"nft/metrics"
+	"nft/tracing"
+
+	"github.com/hibiken/asynq"
+	"golang.org/x/exp/slog"
+)
+
+// app holds the dependencies shared by the API and the workers.
+type app struct {
+	settings    *config.Settings
+	db          *db.DB
+	imx         imx.Client
+	metrics     *metrics.Metrics
+	asynqClient *asynq.Client
+	redis       asynq.RedisClientOpt
+
+	shutdownTracing func(context.Context) error
+}
+
+// newApp connects to the dependencies, applying the pending migrations first when migrate is set.
+func newApp(settings *config.Settings, migrate bool) (*app, error) {
+	shutdownTracing, err := tracing.Setup(context.Background(), settings.TracingSettings())
+	if err != nil {
+		return nil, fmt.Errorf("error configuring tracing: %w", err)
+	}
+
+	a := &app{
+		settings:        settings,
+		metrics:         metrics.New(),
+		redis:           asynq.RedisClientOpt{Addr: settings.RedisUrl},
+		shutdownTracing: shutdownTracing,
+	}
+
+	if migrate {
+		if err := db.NewMigrations(settings.DSN).Up(context.Background()); err != nil {
+			return nil, fmt.Errorf("error applying migrations: %w", err)
+		}
+	}
+
+	a.db, err = db.NewDB(settings.DSN)
+	if err != nil {
+		return nil, fmt.Errorf("error configuring DB: %w", err)
+	}
+
+	sqlDB, err := a.db.SQL()
+	if err != nil {
+		return nil, fmt.Errorf("error configuring DB: %w", err)
+	}
+	if err := a.metrics.RegisterDB(sqlDB, "nft"); err != nil {
+		return nil, fmt.Errorf("error configuring metrics: %w", err)
+	}
+
+	var imxClient imx.Client
+	if settings.IMXFake {
+		slog.Warn("using the in-memory IMX, its state is lost on restart")
+		imxClient = fake.New(fake.PlatformAddress)
+	} else {
+		imxClient, err = imx.NewIMX(settings.IMXSettings())
+		if err != nil {
+			return nil, fmt.Errorf("error configuring imx: %w", err)
+		}
+	}
+	a.imx = tracing.InstrumentIMX(imx.NewResilient(a.metrics.InstrumentIMX(imxClient), settings.IMXPolicy()))
+
+	a.asynqClient = asynq.NewClient(a.redis)
+	if err := a.metrics.RegisterQueues(asynq.NewInspector(a.redis)); err != nil {
+		return nil, fmt.Errorf("error configuring metrics: %w", err)
+	}
+
+	return a, nil
+}
+
+// close releases the clients once the services are shut down.
+func (a *app) close() {
+	if a.imx != nil {
+		a.imx.Close()
+	}
+
+	if a.asynqClient != nil {
+		if err := a.asynqClient.Close(); err != nil {
+			slog.Error("error closing asynq client", "err", err)
+		}
+	}
+
+	if err := a.shutdownTracing(context.Background()); err != nil {
+		slog.Error("error flushing spans", "err", err)
+	}
+}
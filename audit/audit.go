@@ -0,0 +1,163 @@
+// Package audit records an append-only audit event for each operation changing the state of the platform,
+// whether requested through the API or run by a task. Handlers and tasks describe the operation in the
+// context they were given, the middlewares record it with its outcome once done.
+package audit
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"nft/auth"
+	"nft/db"
+	"nft/internal/operation"
+	"nft/models"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/google/uuid"
+	"github.com/hibiken/asynq"
+	"golang.org/x/exp/slog"
+)
+
+// Store persists the audit events.
+type Store interface {
+	CreateAuditEvent(event *models.AuditEvent) error
+}
+
+var _ Store = (*db.DB)(nil)
+
+type Recorder struct {
+	store Store
+}
+
+func NewRecorder(store Store) *Recorder {
+	return &Recorder{store}
+}
+
+type contextKey struct{}
+
+// entry is the event being described, along with whether it was.
+type entry struct {
+	event     models.AuditEvent
+	described bool
+}
+
+func fromContext(ctx context.Context) *entry {
+	e, _ := ctx.Value(contextKey{}).(*entry)
+	return e
+}
+
+// Describe names the operation and its target in the event recorded for ctx. Requests which do not change
+// anything are only recorded when described. Handlers describe the operation as soon as they parsed it and
+// again when the resource they create gets its ID, so failures are recorded with the parent resource.
+func Describe(ctx context.Context, action string, targetType string, targetID string) {
+	if e := fromContext(ctx); e != nil {
+		e.event.Action = action
+		e.event.TargetType = targetType
+		e.event.TargetID = targetID
+		e.described = true
+	}
+}
+
+// SetActor sets the user the operation is performed for, when it is not the authenticated one.
+func SetActor(ctx context.Context, actorID uuid.UUID) {
+	if e := fromContext(ctx); e != nil {
+		e.event.ActorID = &actorID
+	}
+}
+
+// SetDetails adds a description of the operation, such as a secondary target.
+func SetDetails(ctx context.Context, details string) {
+	if e := fromContext(ctx); e != nil {
+		e.event.Details = details
+	}
+}
+
+// SetIMXID sets the ID IMX gave to the operation.
+func SetIMXID(ctx context.Context, imxID string) {
+	if e := fromContext(ctx); e != nil {
+		e.event.IMXID = imxID
+	}
+}
+
+// Middleware records the mutating requests and the described ones. The outcome is the status of the
+// response, failures keep the status as details. It must run after authorization, the authenticated user
+// is the actor.
+func (rec *Recorder) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		e := &entry{event: models.AuditEvent{RequestID: middleware.GetReqID(r.Context())}}
+		if userID, err := auth.GetUserID(r.Context()); err == nil {
+			e.event.ActorID = &userID
+		}
+
+		ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+		next.ServeHTTP(ww, r.WithContext(context.WithValue(r.Context(), contextKey{}, e)))
+
+		if !e.described && !operation.IsMutating(r.Method) {
+			return
+		}
+
+		if !e.described {
+			e.event.Action = r.Method + " " + r.URL.Path
+			if rctx := chi.RouteContext(r.Context()); rctx != nil && len(rctx.RoutePattern()) > 0 {
+				e.event.Action = r.Method + " " + rctx.RoutePattern()
+			}
+		}
+
+		status := ww.Status()
+		if status == 0 {
+			status = http.StatusOK
+		}
+
+		e.event.Outcome = models.AuditSuccess
+		if status >= http.StatusBadRequest {
+			e.event.Outcome = models.AuditFailure
+			e.event.Details = appendDetails(e.event.Details, fmt.Sprintf("%d %s", status, http.StatusText(status)))
+		}
+
+		rec.record(r.Context(), &e.event)
+	})
+}
+
+// TaskMiddleware records the described tasks once they succeed or fail for good, the attempts to be
+// retried are not recorded.
+func (rec *Recorder) TaskMiddleware(next asynq.Handler) asynq.Handler {
+	return asynq.HandlerFunc(func(ctx context.Context, task *asynq.Task) error {
+		e := &entry{}
+		err := next.ProcessTask(context.WithValue(ctx, contextKey{}, e), task)
+
+		if !e.described {
+			return err
+		}
+
+		switch {
+		case err == nil:
+			e.event.Outcome = models.AuditSuccess
+		case errors.Is(err, asynq.SkipRetry) || operation.IsLastAttempt(ctx):
+			e.event.Outcome = models.AuditFailure
+			e.event.Details = appendDetails(e.event.Details, err.Error())
+		default:
+			return err
+		}
+
+		rec.record(ctx, &e.event)
+		return err
+	})
+}
+
+// record saves the event once the request or task it describes has run. A failure to save it is logged,
+// the response was already written and the task outcome must not depend on the audit log.
+func (rec *Recorder) record(ctx context.Context, event *models.AuditEvent) {
+	event.ID = uuid.New()
+	if err := rec.store.CreateAuditEvent(event); err != nil {
+		slog.ErrorContext(ctx, "error saving audit event", "action", event.Action, "err", err)
+	}
+}
+
+func appendDetails(details string, more string) string {
+	if len(details) == 0 {
+		return more
+	}
+	return details + "; " + more
+}
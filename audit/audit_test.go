@@ -0,0 +1,150 @@
+package audit
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"nft/models"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/go-chi/oauth"
+	"github.com/google/uuid"
+	"github.com/hibiken/asynq"
+	"github.com/stretchr/testify/suite"
+)
+
+type memoryStore struct {
+	events []models.AuditEvent
+}
+
+func (m *memoryStore) CreateAuditEvent(event *models.AuditEvent) error {
+	m.events = append(m.events, *event)
+	return nil
+}
+
+type UnitTestSuite struct {
+	suite.Suite
+	store    *memoryStore
+	recorder *Recorder
+	router   *chi.Mux
+	userID   uuid.UUID
+}
+
+func (s *UnitTestSuite) SetupTest() {
+	s.store = &memoryStore{}
+	s.recorder = NewRecorder(s.store)
+	s.userID = uuid.New()
+
+	s.router = chi.NewRouter()
+	s.router.Use(middleware.RequestID)
+	s.router.Use(func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx := context.WithValue(r.Context(), oauth.CredentialContext, s.userID.String())
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	})
+	s.router.Use(s.recorder.Middleware)
+}
+
+func (s *UnitTestSuite) serve(method string, path string) {
+	s.router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(method, path, nil))
+}
+
+func (s *UnitTestSuite) TestDescribedRequest() {
+	s.router.Post("/tokens", func(w http.ResponseWriter, r *http.Request) {
+		Describe(r.Context(), "token.create", "token", "1")
+		SetIMXID(r.Context(), "42")
+		w.WriteHeader(http.StatusAccepted)
+	})
+
+	s.serve(http.MethodPost, "/tokens")
+
+	s.Require().Len(s.store.events, 1)
+	event := s.store.events[0]
+	s.NotEqual(uuid.Nil, event.ID)
+	s.Equal(&s.userID, event.ActorID)
+	s.Equal("token.create", event.Action)
+	s.Equal("token", event.TargetType)
+	s.Equal("1", event.TargetID)
+	s.Equal("42", event.IMXID)
+	s.Equal(models.AuditSuccess, event.Outcome)
+	s.NotEmpty(event.RequestID)
+}
+
+func (s *UnitTestSuite) TestFailedRequest() {
+	s.router.Delete("/webhooks/{webhookID}", func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "not found", http.StatusNotFound)
+	})
+
+	s.serve(http.MethodDelete, "/webhooks/1")
+
+	s.Require().Len(s.store.events, 1)
+	event := s.store.events[0]
+	s.Equal("DELETE /webhooks/{webhookID}", event.Action)
+	s.Equal(models.AuditFailure, event.Outcome)
+	s.Equal("404 Not Found", event.Details)
+}
+
+func (s *UnitTestSuite) TestReadsAreOnlyRecordedWhenDescribed() {
+	s.router.Get("/webhooks", func(w http.ResponseWriter, r *http.Request) {})
+	s.router.Get("/admin/users", func(w http.ResponseWriter, r *http.Request) {
+		Describe(r.Context(), "users.list", "", "")
+	})
+
+	s.serve(http.MethodGet, "/webhooks")
+	s.Empty(s.store.events)
+
+	s.serve(http.MethodGet, "/admin/users")
+	s.Require().Len(s.store.events, 1)
+	s.Equal("users.list", s.store.events[0].Action)
+}
+
+func (s *UnitTestSuite) TestTask() {
+	actorID := uuid.New()
+	handler := s.recorder.TaskMiddleware(asynq.HandlerFunc(func(ctx context.Context, task *asynq.Task) error {
+		Describe(ctx, "token.mint", "token", "1")
+		SetActor(ctx, actorID)
+		return nil
+	}))
+
+	err := handler.ProcessTask(context.Background(), asynq.NewTask("token:mint", nil))
+	s.Nil(err)
+
+	s.Require().Len(s.store.events, 1)
+	s.Equal(&actorID, s.store.events[0].ActorID)
+	s.Equal(models.AuditSuccess, s.store.events[0].Outcome)
+}
+
+func (s *UnitTestSuite) TestTaskRetriesAreNotRecorded() {
+	failure := errors.New("imx unavailable")
+	handler := s.recorder.TaskMiddleware(asynq.HandlerFunc(func(ctx context.Context, task *asynq.Task) error {
+		Describe(ctx, "token.mint", "token", "1")
+		return failure
+	}))
+
+	err := handler.ProcessTask(context.Background(), asynq.NewTask("token:mint", nil))
+	s.ErrorIs(err, failure)
+	s.Empty(s.store.events)
+}
+
+func (s *UnitTestSuite) TestTaskFailure() {
+	handler := s.recorder.TaskMiddleware(asynq.HandlerFunc(func(ctx context.Context, task *asynq.Task) error {
+		Describe(ctx, "token.mint", "token", "1")
+		return fmt.Errorf("collection not exists: %w", asynq.SkipRetry)
+	}))
+
+	err := handler.ProcessTask(context.Background(), asynq.NewTask("token:mint", nil))
+	s.ErrorIs(err, asynq.SkipRetry)
+
+	s.Require().Len(s.store.events, 1)
+	s.Equal(models.AuditFailure, s.store.events[0].Outcome)
+	s.Contains(s.store.events[0].Details, "collection not exists")
+}
+
+func TestUnitTestSuite(t *testing.T) {
+	suite.Run(t, new(UnitTestSuite))
+}
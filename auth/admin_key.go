@@ -0,0 +1,35 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+)
+
+// NewAdminKey returns a random admin key and its hash, only the hash is stored. Admins request the admin
+// scope with the key as client secret, so a leaked api key does not grant it.
+func NewAdminKey() (string, string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", "", err
+	}
+
+	key := hex.EncodeToString(b)
+	return key, HashAdminKey(key), nil
+}
+
+// HashAdminKey returns the hash stored for the admin key.
+func HashAdminKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+// checkAdminKey reports whether key is the admin key hashed as hash.
+func checkAdminKey(key string, hash string) bool {
+	if len(hash) == 0 {
+		return false
+	}
+
+	return subtle.ConstantTimeCompare([]byte(HashAdminKey(key)), []byte(hash)) == 1
+}
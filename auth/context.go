@@ -0,0 +1,68 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"nft/db"
+	"nft/logging"
+
+	"github.com/go-chi/oauth"
+	"github.com/google/uuid"
+)
+
+var ErrUnauthenticated = errors.New("unauthenticated request")
+
+// GetUserID returns the ID of the user authenticated by the authorization middleware.
+func GetUserID(ctx context.Context) (uuid.UUID, error) {
+	credential, ok := ctx.Value(oauth.CredentialContext).(string)
+	if !ok || len(credential) == 0 {
+		return uuid.Nil, ErrUnauthenticated
+	}
+
+	return uuid.Parse(credential)
+}
+
+// LogUser adds the ID of the authenticated user to the logs of the request, it must run after the
+// authorization middleware.
+func LogUser(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if userID, err := GetUserID(r.Context()); err == nil {
+			r = r.WithContext(logging.WithUserID(r.Context(), userID))
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// RequireActiveUser returns a middleware rejecting the requests of users disabled, or no longer admin for
+// admin tokens, since their token was issued. It must run after the authorization middleware.
+func RequireActiveUser(db *db.DB) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			userID, err := GetUserID(r.Context())
+			if err != nil {
+				http.Error(w, "Not authorized: invalid user", http.StatusUnauthorized)
+				return
+			}
+
+			user, err := db.WithContext(r.Context()).GetUser(userID)
+			if err != nil {
+				http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+				return
+			}
+
+			if user == nil || user.Disabled {
+				http.Error(w, "Not authorized: disabled user", http.StatusUnauthorized)
+				return
+			}
+
+			if HasScope(r.Context(), AdminScope) && !user.Admin {
+				http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -0,0 +1,53 @@
+package auth
+
+import (
+	"context"
+	"testing"
+
+	"github.com/go-chi/oauth"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/suite"
+)
+
+type UnitTestSuite struct {
+	suite.Suite
+}
+
+func (s *UnitTestSuite) TestGetUserID() {
+	id := uuid.New()
+	ctx := context.WithValue(context.Background(), oauth.CredentialContext, id.String())
+	userID, err := GetUserID(ctx)
+	s.Assertions.Nil(err)
+	s.Assertions.Equal(id, userID)
+}
+
+func (s *UnitTestSuite) TestGetUserIDWithoutCredentialShouldFail() {
+	_, err := GetUserID(context.Background())
+	s.Assertions.ErrorIs(err, ErrUnauthenticated)
+}
+
+func (s *UnitTestSuite) TestGetUserIDWithInvalidCredentialShouldFail() {
+	ctx := context.WithValue(context.Background(), oauth.CredentialContext, "invalid")
+	_, err := GetUserID(ctx)
+	s.Assertions.NotNil(err)
+}
+
+func (s *UnitTestSuite) TestHasScope() {
+	ctx := context.WithValue(context.Background(), oauth.ScopeContext, "read admin")
+	s.Assertions.True(HasScope(ctx, AdminScope))
+	s.Assertions.False(HasScope(ctx, "write"))
+	s.Assertions.False(HasScope(context.Background(), AdminScope))
+}
+
+func (s *UnitTestSuite) TestAdminKey() {
+	key, hash, err := NewAdminKey()
+	s.Assertions.Nil(err)
+	s.Assertions.NotEqual(key, hash)
+	s.Assertions.True(checkAdminKey(key, hash))
+	s.Assertions.False(checkAdminKey(key+"0", hash))
+	s.Assertions.False(checkAdminKey(key, ""))
+}
+
+func TestUnitTestSuite(t *testing.T) {
+	suite.Run(t, new(UnitTestSuite))
+}
@@ -0,0 +1,45 @@
+package auth
+
+import (
+	"context"
+	"net/http"
+	"strings"
+
+	"github.com/go-chi/oauth"
+)
+
+const AdminScope = "admin"
+
+// HasScope reports whether the token used to authenticate the request was granted the scope.
+func HasScope(ctx context.Context, scope string) bool {
+	scopes, ok := ctx.Value(oauth.ScopeContext).(string)
+	if !ok {
+		return false
+	}
+
+	return containsScope(scopes, scope)
+}
+
+// RequireScope returns a middleware rejecting requests whose token was not granted the scope.
+func RequireScope(scope string) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !HasScope(r.Context(), scope) {
+				http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+func containsScope(scopes string, scope string) bool {
+	for _, s := range strings.Fields(scopes) {
+		if s == scope {
+			return true
+		}
+	}
+
+	return false
+}
@@ -40,10 +40,19 @@ func (u *UserVerifier) ValidateClient(clientID, clientSecret, scope string, r *h
 		return errors.New("wrong client")
 	}
 
-	if subtle.ConstantTimeCompare([]byte(user.ApiKey), []byte(clientSecret)) == 0 {
+	// the admin scope is granted for the admin key only, the api key is handed to the user on creation
+	if containsScope(scope, AdminScope) {
+		if !user.Admin || !checkAdminKey(clientSecret, user.AdminKeyHash) {
+			return errors.New("scope not allowed")
+		}
+	} else if subtle.ConstantTimeCompare([]byte(user.ApiKey), []byte(clientSecret)) == 0 {
 		return errors.New("wrong client")
 	}
 
+	if user.Disabled {
+		return errors.New("disabled client")
+	}
+
 	return nil
 }
 
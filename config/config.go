@@ -1,15 +1,26 @@
 package config
 
 import (
-	"log"
+	"errors"
+	"nft/imx"
+	"nft/logging"
+	"nft/tracing"
+	"os"
+	"time"
 
 	"github.com/jinzhu/configor"
+	"golang.org/x/exp/slog"
 )
 
 type Settings struct {
 	Port                 string `default:"4000" env:"PORT"`
 	AuthSecret           string `default:"" env:"AUTH_SECRET"`
-	DebugMode            bool   `default:"false" env:"DEBUG"`
+	DebugAuth            bool   `default:"false" env:"DEBUG_AUTH"`
+	IMXEnvironment       string `default:"sandbox" env:"IMX_ENVIRONMENT"`
+	IMXFake              bool   `default:"false" env:"IMX_FAKE"`
+	ChainID              int64  `default:"0" env:"CHAIN_ID"`
+	IMXAPIURL            string `default:"" env:"IMX_API_URL"`
+	L1RPCURL             string `default:"" env:"L1_RPC_URL"`
 	AlchemyAPIKey        string `default:"" env:"ALCHEMY_API_KEY"`
 	L1SignerPrivateKey   string `default:"" env:"L1_SIGNER_PRIVATE_KEY"`
 	StarkPrivateKey      string `default:"" env:"STARK_PRIVATE_KEY"`
@@ -17,13 +28,105 @@ type Settings struct {
 	ProjectID            int32  `default:"0" env:"PROJECT_ID"`
 	TokenDurationSeconds int64  `default:"120" env:"TOKEN_DURATION_SECONDS"`
 	RedisUrl             string `default:"127.0.0.1:6379" env:"REDIS_URL"`
+	RateLimitEnabled     bool   `default:"false" env:"RATE_LIMIT_ENABLED"`
+	RateLimits           []RateLimitSettings
+	// ReconcileSchedule is the cron spec of the collection reconciliation with IMX, empty disables it.
+	ReconcileSchedule string `default:"@hourly" env:"RECONCILE_SCHEDULE"`
+	// IMXTimeoutSeconds bounds each call to IMX, IMXL1TimeoutSeconds the ones sending L1 transactions.
+	IMXTimeoutSeconds   int `default:"30" env:"IMX_TIMEOUT_SECONDS"`
+	IMXL1TimeoutSeconds int `default:"120" env:"IMX_L1_TIMEOUT_SECONDS"`
+	// IMXRetries is the number of retries of the reads failing because IMX is unavailable or rate limits.
+	IMXRetries int `default:"2" env:"IMX_RETRIES"`
+	// IMXBreakerThreshold consecutive unavailable failures make the IMX calls fail fast for
+	// IMXBreakerCooldownSeconds, 0 disables the circuit breaker.
+	IMXBreakerThreshold       int `default:"5" env:"IMX_BREAKER_THRESHOLD"`
+	IMXBreakerCooldownSeconds int `default:"30" env:"IMX_BREAKER_COOLDOWN_SECONDS"`
+	// TracingExporter exports the spans to "stdout" or "otlp" (to TracingOTLPEndpoint, or per the standard
+	// OTEL_EXPORTER_OTLP_* variables when empty). Empty disables tracing.
+	TracingExporter     string  `default:"" env:"TRACING_EXPORTER"`
+	TracingOTLPEndpoint string  `default:"" env:"TRACING_OTLP_ENDPOINT"`
+	TracingSampleRatio  float64 `default:"1" env:"TRACING_SAMPLE_RATIO"`
+	// LogLevel is the minimum level of the logs: "debug", "info", "warn" or "error".
+	LogLevel string `default:"info" env:"LOG_LEVEL"`
+	LogJSON  bool   `default:"false" env:"LOG_JSON"`
+	// WorkerPort serves the metrics and the probes of the worker command, which serves no API on Port.
+	WorkerPort string `default:"4001" env:"WORKER_PORT"`
+	// ShutdownTimeoutSeconds bounds the graceful shutdown, the process exits anyway once elapsed.
+	ShutdownTimeoutSeconds int `default:"30" env:"SHUTDOWN_TIMEOUT_SECONDS"`
+	// AutoMigrate applies the pending migrations when the all command starts, disable it to run them with the
+	// migrate command instead.
+	AutoMigrate bool `default:"true" env:"AUTO_MIGRATE"`
+}
+
+// RateLimitSettings allows Requests every PeriodSeconds per client on the requests matching Route
+// (e.g. "POST /tokens") and Scope. Empty Route or Scope match every request.
+type RateLimitSettings struct {
+	Route         string
+	Scope         string
+	Requests      int
+	PeriodSeconds int
+}
+
+// Validate checks the settings that are unsafe together. The debug authentication lets anyone act as any
+// user, it is refused on mainnet.
+func (s *Settings) Validate() error {
+	if s.DebugAuth && s.IMXEnvironment == imx.EnvironmentMainnet {
+		return errors.New("debug auth cannot be enabled on mainnet")
+	}
+
+	return nil
+}
+
+// IMXSettings returns the settings of the IMX client.
+func (s *Settings) IMXSettings() *imx.Settings {
+	return &imx.Settings{
+		Environment:        s.IMXEnvironment,
+		ChainID:            s.ChainID,
+		APIURL:             s.IMXAPIURL,
+		L1RPCURL:           s.L1RPCURL,
+		AlchemyAPIKey:      s.AlchemyAPIKey,
+		L1SignerPrivateKey: s.L1SignerPrivateKey,
+		StarkPrivateKey:    s.StarkPrivateKey,
+		ProjectID:          s.ProjectID,
+	}
+}
+
+// IMXPolicy returns the timeouts, retries and circuit breaker applied to the IMX calls.
+func (s *Settings) IMXPolicy() imx.Policy {
+	policy := imx.DefaultPolicy()
+	policy.Timeout = time.Duration(s.IMXTimeoutSeconds) * time.Second
+	for operation := range policy.Timeouts {
+		policy.Timeouts[operation] = time.Duration(s.IMXL1TimeoutSeconds) * time.Second
+	}
+	policy.Retries = s.IMXRetries
+	policy.BreakerThreshold = s.IMXBreakerThreshold
+	policy.BreakerCooldown = time.Duration(s.IMXBreakerCooldownSeconds) * time.Second
+	return policy
+}
+
+// TracingSettings returns where the spans are exported.
+func (s *Settings) TracingSettings() tracing.Settings {
+	return tracing.Settings{
+		Exporter:     s.TracingExporter,
+		OTLPEndpoint: s.TracingOTLPEndpoint,
+		SampleRatio:  s.TracingSampleRatio,
+	}
+}
+
+// LoggingSettings returns the level and format of the logs.
+func (s *Settings) LoggingSettings() logging.Settings {
+	return logging.Settings{
+		Level: s.LogLevel,
+		JSON:  s.LogJSON,
+	}
 }
 
 var config = Settings{}
 
 func init() {
 	if err := configor.Load(&config, "config.yml"); err != nil {
-		log.Fatal(err.Error())
+		slog.Error("error loading config", "err", err)
+		os.Exit(1)
 	}
 }
 
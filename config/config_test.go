@@ -0,0 +1,27 @@
+package config
+
+import (
+	"nft/imx"
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type UnitTestSuite struct {
+	suite.Suite
+}
+
+func (s *UnitTestSuite) TestValidateRefusesDebugAuthOnMainnet() {
+	settings := Settings{DebugAuth: true, IMXEnvironment: imx.EnvironmentMainnet}
+	s.Assertions.Error(settings.Validate())
+
+	settings.DebugAuth = false
+	s.Assertions.NoError(settings.Validate())
+
+	settings = Settings{DebugAuth: true, IMXEnvironment: imx.EnvironmentSandbox}
+	s.Assertions.NoError(settings.Validate())
+}
+
+func TestUnitTestSuite(t *testing.T) {
+	suite.Run(t, new(UnitTestSuite))
+}
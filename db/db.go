@@ -1,11 +1,16 @@
 package db
 
 import (
+	"context"
+	"database/sql"
+	"nft/logging"
 	"nft/models"
+	"nft/tracing"
 
 	"github.com/google/uuid"
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 type DB struct {
@@ -14,15 +19,39 @@ type DB struct {
 
 func NewDB(dsn string) (*DB, error) {
 	var err error
-	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{})
+	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{Logger: logging.GormLogger{}})
 
 	if err != nil {
 		return nil, err
 	}
 
+	if err = db.Use(tracing.GormPlugin{}); err != nil {
+		return nil, err
+	}
+
 	return &DB{db}, nil
 }
 
+// WithContext returns a DB running its queries with ctx, which carries the trace of their caller.
+func (d *DB) WithContext(ctx context.Context) *DB {
+	return &DB{d.db.WithContext(ctx)}
+}
+
+// SQL returns the connection pool under gorm.
+func (d *DB) SQL() (*sql.DB, error) {
+	return d.db.DB()
+}
+
+// Ping checks the database answers, for the readiness probe.
+func (d *DB) Ping(ctx context.Context) error {
+	sqlDB, err := d.db.DB()
+	if err != nil {
+		return err
+	}
+
+	return sqlDB.PingContext(ctx)
+}
+
 func (d *DB) CreateUser(user *models.User) error {
 	//save database
 	return d.db.Transaction(func(tx *gorm.DB) error {
@@ -34,6 +63,16 @@ func (d *DB) CreateUser(user *models.User) error {
 	})
 }
 
+// CreateUserUnlessExists stores the user unless one already has its mail, in which case it returns false.
+func (d *DB) CreateUserUnlessExists(user *models.User) (bool, error) {
+	result := d.db.Clauses(clause.OnConflict{DoNothing: true}).Create(&user)
+	if result.Error != nil {
+		return false, result.Error
+	}
+
+	return result.RowsAffected == 1, nil
+}
+
 func (d *DB) UpdateUser(user *models.User) error {
 	//save database
 	return d.db.Transaction(func(tx *gorm.DB) error {
@@ -59,6 +98,21 @@ func (d *DB) GetUser(id uuid.UUID) (*models.User, error) {
 	return &user, nil
 }
 
+// GetUserByAddress returns the user of the L1 address, whatever its case.
+func (d *DB) GetUserByAddress(address string) (*models.User, error) {
+	var user models.User
+	if err := d.db.Where("lower(address) = lower(?)", address).First(&user).Error; err != nil {
+		switch err {
+		case gorm.ErrRecordNotFound:
+			return nil, nil
+		default:
+			return nil, err
+		}
+	}
+
+	return &user, nil
+}
+
 func (d *DB) GetUserByMail(mail string) (*models.User, error) {
 	var user models.User
 	if err := d.db.Where("mail = ?", mail).First(&user).Error; err != nil {
@@ -73,6 +127,33 @@ func (d *DB) GetUserByMail(mail string) (*models.User, error) {
 	return &user, nil
 }
 
+// CreateUserChallenge saves the challenge, and deletes the challenges expired before expiredBefore.
+func (d *DB) CreateUserChallenge(challenge *models.UserChallenge, expiredBefore int64) error {
+	//save database
+	return d.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("expires_at < ?", expiredBefore).Delete(&models.UserChallenge{}).Error; err != nil {
+			return err
+		}
+
+		return tx.Create(&challenge).Error
+	})
+}
+
+// ConsumeUserChallenge deletes the challenge and returns it, or nil if it does not exist or was consumed
+// already.
+func (d *DB) ConsumeUserChallenge(id uuid.UUID) (*models.UserChallenge, error) {
+	var challenges []models.UserChallenge
+	if err := d.db.Clauses(clause.Returning{}).Where("id = ?", id).Delete(&challenges).Error; err != nil {
+		return nil, err
+	}
+
+	if len(challenges) == 0 {
+		return nil, nil
+	}
+
+	return &challenges[0], nil
+}
+
 func (d *DB) CreateCollection(collection *models.Collection) error {
 	//save database
 	return d.db.Transaction(func(tx *gorm.DB) error {
@@ -98,17 +179,34 @@ func (d *DB) GetCollection(id uuid.UUID) (*models.Collection, error) {
 	return &collection, nil
 }
 
-func (d *DB) CreateToken(token *models.Token) error {
+// CreateToken saves the token together with the outbox messages of the tasks processing it.
+func (d *DB) CreateToken(token *models.Token, messages ...*models.OutboxMessage) error {
 	//save database
 	return d.db.Transaction(func(tx *gorm.DB) error {
 		if err := tx.Create(&token).Error; err != nil {
 			return err
 		}
 
-		return nil
+		return createOutboxMessages(tx, messages)
 	})
 }
 
+// CreateTokenUnlessExists stores the token and its messages unless the collection already has a token with its
+// token ID, in which case it returns false.
+func (d *DB) CreateTokenUnlessExists(token *models.Token, messages ...*models.OutboxMessage) (bool, error) {
+	created := false
+	err := d.db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&token)
+		if result.Error != nil || result.RowsAffected == 0 {
+			return result.Error
+		}
+
+		created = true
+		return createOutboxMessages(tx, messages)
+	})
+	return created, err
+}
+
 func (d *DB) GetToken(id uuid.UUID) (*models.Token, error) {
 	var token models.Token
 	if err := d.db.First(&token, id).Error; err != nil {
@@ -122,3 +220,732 @@ func (d *DB) GetToken(id uuid.UUID) (*models.Token, error) {
 
 	return &token, nil
 }
+
+// ResetFailedToken sets a failed token back to pending, so its mint can run again. It returns false if the
+// token had not failed.
+func (d *DB) ResetFailedToken(id uuid.UUID) (bool, error) {
+	result := d.db.Model(&models.Token{}).
+		Where("id = ? AND status = ?", id, models.TokenFailed).
+		Update("status", models.TokenPending)
+	return result.RowsAffected == 1, result.Error
+}
+
+func (d *DB) SetTokenOwner(id uuid.UUID, owner string) error {
+	return d.db.Model(&models.Token{ID: id}).Update("owner", owner).Error
+}
+
+// SetTokenStatus saves the status of the token together with the outbox messages of its events.
+func (d *DB) SetTokenStatus(id uuid.UUID, status string, messages ...*models.OutboxMessage) error {
+	//save database
+	return d.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&models.Token{ID: id}).Update("status", status).Error; err != nil {
+			return err
+		}
+
+		return createOutboxMessages(tx, messages)
+	})
+}
+
+func (d *DB) CreateSignatureRequest(request *models.SignatureRequest) error {
+	//save database
+	return d.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&request).Error; err != nil {
+			return err
+		}
+
+		return nil
+	})
+}
+
+// UpdateSignatureRequest saves the request together with the outbox messages of its events.
+func (d *DB) UpdateSignatureRequest(request *models.SignatureRequest, messages ...*models.OutboxMessage) error {
+	//save database
+	return d.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Updates(&request).Error; err != nil {
+			return err
+		}
+
+		return createOutboxMessages(tx, messages)
+	})
+}
+
+// ClaimSignatureRequest marks the request as being submitted if it is pending and not expired at now. It
+// returns false otherwise, so a request is only submitted once.
+func (d *DB) ClaimSignatureRequest(id uuid.UUID, now int64) (bool, error) {
+	result := d.db.Model(&models.SignatureRequest{}).
+		Where("id = ? AND status = ? AND expires_at > ?", id, models.SignatureRequestPending, now).
+		Update("status", models.SignatureRequestSubmitting)
+	return result.RowsAffected == 1, result.Error
+}
+
+func (d *DB) GetSignatureRequest(id uuid.UUID) (*models.SignatureRequest, error) {
+	var request models.SignatureRequest
+	if err := d.db.First(&request, id).Error; err != nil {
+		switch err {
+		case gorm.ErrRecordNotFound:
+			return nil, nil
+		default:
+			return nil, err
+		}
+	}
+
+	return &request, nil
+}
+
+// CreateOrganization saves the organization making the given user its first owner.
+func (d *DB) CreateOrganization(organization *models.Organization, ownerID uuid.UUID) error {
+	//save database
+	return d.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&organization).Error; err != nil {
+			return err
+		}
+
+		member := models.OrganizationMember{
+			OrganizationID: organization.ID,
+			UserID:         ownerID,
+			Role:           models.RoleOwner,
+		}
+		if err := tx.Create(&member).Error; err != nil {
+			return err
+		}
+
+		return nil
+	})
+}
+
+func (d *DB) GetOrganizationMember(organizationID uuid.UUID, userID uuid.UUID) (*models.OrganizationMember, error) {
+	var member models.OrganizationMember
+	if err := d.db.Where("organization_id = ? AND user_id = ?", organizationID, userID).First(&member).Error; err != nil {
+		switch err {
+		case gorm.ErrRecordNotFound:
+			return nil, nil
+		default:
+			return nil, err
+		}
+	}
+
+	return &member, nil
+}
+
+// SaveOrganizationMember adds the member to the organization or updates its role if already present.
+func (d *DB) SaveOrganizationMember(member *models.OrganizationMember) error {
+	//save database
+	return d.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where(models.OrganizationMember{OrganizationID: member.OrganizationID, UserID: member.UserID}).
+			Assign(models.OrganizationMember{Role: member.Role}).
+			FirstOrCreate(member).Error; err != nil {
+			return err
+		}
+
+		return nil
+	})
+}
+
+func (d *DB) DeleteOrganizationMember(member *models.OrganizationMember) error {
+	//save database
+	return d.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Delete(&member).Error; err != nil {
+			return err
+		}
+
+		return nil
+	})
+}
+
+// SaveOrganizationMemberUnlessLastOwner saves the member like SaveOrganizationMember, unless that
+// demotes the last owner of the organization, and reports whether it was saved.
+func (d *DB) SaveOrganizationMemberUnlessLastOwner(member *models.OrganizationMember) (bool, error) {
+	saved := false
+	//save database
+	err := d.db.Transaction(func(tx *gorm.DB) error {
+		if member.Role != models.RoleOwner {
+			last, err := isLastOrganizationOwner(tx, member.OrganizationID, member.UserID)
+			if err != nil || last {
+				return err
+			}
+		}
+
+		if err := tx.Where(models.OrganizationMember{OrganizationID: member.OrganizationID, UserID: member.UserID}).
+			Assign(models.OrganizationMember{Role: member.Role}).
+			FirstOrCreate(member).Error; err != nil {
+			return err
+		}
+
+		saved = true
+		return nil
+	})
+	return saved, err
+}
+
+// DeleteOrganizationMemberUnlessLastOwner deletes the member unless it is the last owner of the
+// organization, and reports whether it was deleted.
+func (d *DB) DeleteOrganizationMemberUnlessLastOwner(member *models.OrganizationMember) (bool, error) {
+	deleted := false
+	//save database
+	err := d.db.Transaction(func(tx *gorm.DB) error {
+		last, err := isLastOrganizationOwner(tx, member.OrganizationID, member.UserID)
+		if err != nil || last {
+			return err
+		}
+
+		if err := tx.Delete(&member).Error; err != nil {
+			return err
+		}
+
+		deleted = true
+		return nil
+	})
+	return deleted, err
+}
+
+// isLastOrganizationOwner locks the owners of the organization until the end of the transaction,
+// so concurrent changes cannot remove them all, and reports whether the user is the only one.
+func isLastOrganizationOwner(tx *gorm.DB, organizationID uuid.UUID, userID uuid.UUID) (bool, error) {
+	var owners []models.OrganizationMember
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("organization_id = ? AND role = ?", organizationID, models.RoleOwner).
+		Find(&owners).Error; err != nil {
+		return false, err
+	}
+
+	return len(owners) == 1 && owners[0].UserID == userID, nil
+}
+
+func (d *DB) CountOrganizationOwners(organizationID uuid.UUID) (int64, error) {
+	var count int64
+	err := d.db.Model(&models.OrganizationMember{}).
+		Where("organization_id = ? AND role = ?", organizationID, models.RoleOwner).
+		Count(&count).Error
+	return count, err
+}
+
+func (d *DB) ListUsers(offset int, limit int) ([]models.User, error) {
+	var users []models.User
+	if err := d.db.Order("created_at").Offset(offset).Limit(limit).Find(&users).Error; err != nil {
+		return nil, err
+	}
+
+	return users, nil
+}
+
+func (d *DB) SetUserDisabled(id uuid.UUID, disabled bool) error {
+	//save database
+	return d.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&models.User{ID: id}).Update("disabled", disabled).Error; err != nil {
+			return err
+		}
+
+		return nil
+	})
+}
+
+// SetUserAdmin grants the admin scope to the holder of the admin key hashed as adminKeyHash, or revokes it when
+// adminKeyHash is empty.
+func (d *DB) SetUserAdmin(id uuid.UUID, adminKeyHash string) error {
+	//save database
+	return d.db.Transaction(func(tx *gorm.DB) error {
+		updates := map[string]interface{}{"admin": len(adminKeyHash) > 0, "admin_key_hash": adminKeyHash}
+		if err := tx.Model(&models.User{ID: id}).Updates(updates).Error; err != nil {
+			return err
+		}
+
+		return nil
+	})
+}
+
+func (d *DB) SetCollectionHidden(id uuid.UUID, hidden bool) error {
+	//save database
+	return d.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&models.Collection{ID: id}).Update("hidden", hidden).Error; err != nil {
+			return err
+		}
+
+		return nil
+	})
+}
+
+// AuditFilter selects the audit events of an actor, of a target and within a time range, each part
+// ignored when empty. From and To are unix milliseconds, To excluded.
+type AuditFilter struct {
+	ActorID    *uuid.UUID
+	TargetType string
+	TargetID   string
+	From       int64
+	To         int64
+}
+
+// ListAuditEvents returns the events matching the filter, most recent first.
+func (d *DB) ListAuditEvents(filter AuditFilter, offset int, limit int) ([]models.AuditEvent, error) {
+	var events []models.AuditEvent
+	query := d.db.Order("created_at desc").Offset(offset).Limit(limit)
+	if filter.ActorID != nil {
+		query = query.Where("actor_id = ?", *filter.ActorID)
+	}
+	if len(filter.TargetType) > 0 {
+		query = query.Where("target_type = ?", filter.TargetType)
+	}
+	if len(filter.TargetID) > 0 {
+		query = query.Where("target_id = ?", filter.TargetID)
+	}
+	if filter.From > 0 {
+		query = query.Where("created_at >= ?", filter.From)
+	}
+	if filter.To > 0 {
+		query = query.Where("created_at < ?", filter.To)
+	}
+
+	if err := query.Find(&events).Error; err != nil {
+		return nil, err
+	}
+
+	return events, nil
+}
+
+func (d *DB) CreateAuditEvent(event *models.AuditEvent) error {
+	//save database
+	return d.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&event).Error; err != nil {
+			return err
+		}
+
+		return nil
+	})
+}
+
+// CreateIdempotencyKey stores the key unless the client already used it, in which case it returns false. A key
+// completed before completedBefore, or left in progress since before startedBefore, is replaced.
+func (d *DB) CreateIdempotencyKey(key *models.IdempotencyKey, completedBefore int64, startedBefore int64) (bool, error) {
+	created := false
+	//save database
+	err := d.db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Where("client_id = ? AND key = ?", key.ClientID, key.Key).
+			Where(expiredIdempotencyKeys, completedBefore, startedBefore).
+			Delete(&models.IdempotencyKey{}).Error
+		if err != nil {
+			return err
+		}
+
+		result := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&key)
+		created = result.RowsAffected == 1
+		return result.Error
+	})
+
+	return created, err
+}
+
+// expiredIdempotencyKeys matches the keys completed before the first argument or started before the second.
+const expiredIdempotencyKeys = "((completed AND updated_at < ?) OR (NOT completed AND updated_at < ?))"
+
+// DeleteExpiredIdempotencyKeys deletes the keys CreateIdempotencyKey would replace, and returns how many were.
+func (d *DB) DeleteExpiredIdempotencyKeys(completedBefore int64, startedBefore int64) (int64, error) {
+	result := d.db.Where(expiredIdempotencyKeys, completedBefore, startedBefore).Delete(&models.IdempotencyKey{})
+	return result.RowsAffected, result.Error
+}
+
+func (d *DB) GetIdempotencyKey(clientID uuid.UUID, key string) (*models.IdempotencyKey, error) {
+	var idempotencyKey models.IdempotencyKey
+	if err := d.db.Where("client_id = ? AND key = ?", clientID, key).First(&idempotencyKey).Error; err != nil {
+		switch err {
+		case gorm.ErrRecordNotFound:
+			return nil, nil
+		default:
+			return nil, err
+		}
+	}
+
+	return &idempotencyKey, nil
+}
+
+func (d *DB) CompleteIdempotencyKey(key *models.IdempotencyKey) error {
+	//save database
+	return d.db.Transaction(func(tx *gorm.DB) error {
+		key.Completed = true
+		if err := tx.Model(&key).Select("completed", "status_code", "content_type", "response_body", "updated_at").Updates(&key).Error; err != nil {
+			return err
+		}
+
+		return nil
+	})
+}
+
+func (d *DB) DeleteIdempotencyKey(key *models.IdempotencyKey) error {
+	//save database
+	return d.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Delete(&key).Error; err != nil {
+			return err
+		}
+
+		return nil
+	})
+}
+
+// CreateWithdrawal saves the withdrawal together with the outbox messages of the tasks processing it.
+func (d *DB) CreateWithdrawal(withdrawal *models.Withdrawal, messages ...*models.OutboxMessage) error {
+	//save database
+	return d.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&withdrawal).Error; err != nil {
+			return err
+		}
+
+		return createOutboxMessages(tx, messages)
+	})
+}
+
+// StartWithdrawal saves a withdrawal about to be created on IMX together with the outbox messages of the tasks
+// processing it. It returns false if the user has a withdrawal being created already, whose outcome is not
+// known yet.
+func (d *DB) StartWithdrawal(withdrawal *models.Withdrawal, messages ...*models.OutboxMessage) (bool, error) {
+	started := false
+	err := d.db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&withdrawal)
+		if result.Error != nil || result.RowsAffected == 0 {
+			return result.Error
+		}
+
+		started = true
+		return createOutboxMessages(tx, messages)
+	})
+	return started, err
+}
+
+// SetWithdrawalCreated saves the IMX ID of a withdrawal being created, which is then pending.
+func (d *DB) SetWithdrawalCreated(id uuid.UUID, withdrawalID int32) error {
+	return d.db.Model(&models.Withdrawal{}).
+		Where("id = ? AND status = ?", id, models.WithdrawalCreating).
+		Updates(map[string]interface{}{"withdrawal_id": withdrawalID, "status": models.WithdrawalPending}).Error
+}
+
+// DeleteWithdrawal deletes a withdrawal IMX refused to create, together with the outbox messages of its tasks
+// that were not sent yet.
+func (d *DB) DeleteWithdrawal(id uuid.UUID, messages ...*models.OutboxMessage) error {
+	//save database
+	return d.db.Transaction(func(tx *gorm.DB) error {
+		for _, message := range messages {
+			if err := tx.Where("id = ? AND sent_at IS NULL", message.ID).Delete(&models.OutboxMessage{}).Error; err != nil {
+				return err
+			}
+		}
+
+		return tx.Delete(&models.Withdrawal{}, id).Error
+	})
+}
+
+func (d *DB) GetWithdrawal(id uuid.UUID) (*models.Withdrawal, error) {
+	var withdrawal models.Withdrawal
+	if err := d.db.First(&withdrawal, id).Error; err != nil {
+		switch err {
+		case gorm.ErrRecordNotFound:
+			return nil, nil
+		default:
+			return nil, err
+		}
+	}
+
+	return &withdrawal, nil
+}
+
+// SaveSubmittedWithdrawal saves the signature request once the withdrawal it signed is submitted, together
+// with the withdrawal.
+func (d *DB) SaveSubmittedWithdrawal(request *models.SignatureRequest, withdrawal *models.Withdrawal) error {
+	//save database
+	return d.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Updates(&request).Error; err != nil {
+			return err
+		}
+
+		return tx.Create(&withdrawal).Error
+	})
+}
+
+// ListPendingWithdrawals returns the withdrawals the platform has yet to complete on L1, oldest first. Those
+// still being created are included, their IMX ID is missing if it could not be saved.
+func (d *DB) ListPendingWithdrawals(offset int, limit int) ([]models.Withdrawal, error) {
+	var withdrawals []models.Withdrawal
+	err := d.db.Where("status IN ?", []string{models.WithdrawalCreating, models.WithdrawalPending}).Order("created_at").Offset(offset).Limit(limit).Find(&withdrawals).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return withdrawals, nil
+}
+
+// SetWithdrawalStatus saves the status of the withdrawal together with the outbox messages of its events.
+func (d *DB) SetWithdrawalStatus(withdrawalID int32, status string, messages ...*models.OutboxMessage) error {
+	//save database
+	return d.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&models.Withdrawal{}).Where("withdrawal_id = ?", withdrawalID).Update("status", status).Error; err != nil {
+			return err
+		}
+
+		return createOutboxMessages(tx, messages)
+	})
+}
+
+// CreateOutboxMessages saves messages of tasks not tied to a row saved in the same transaction.
+func (d *DB) CreateOutboxMessages(messages ...*models.OutboxMessage) error {
+	//save database
+	return d.db.Transaction(func(tx *gorm.DB) error {
+		return createOutboxMessages(tx, messages)
+	})
+}
+
+// createOutboxMessages saves the messages, skipping those already saved so a task retried after saving its
+// messages can save them again.
+func createOutboxMessages(tx *gorm.DB, messages []*models.OutboxMessage) error {
+	for _, message := range messages {
+		if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(message).Error; err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// ListUnsentOutboxMessages returns the oldest messages not enqueued yet, without claiming them. It only inspects
+// the outbox, the relay enqueues the messages it claims with ClaimOutboxMessages.
+func (d *DB) ListUnsentOutboxMessages(limit int) ([]models.OutboxMessage, error) {
+	var messages []models.OutboxMessage
+	if err := d.db.Where("sent_at IS NULL").Order("created_at").Limit(limit).Find(&messages).Error; err != nil {
+		return nil, err
+	}
+
+	return messages, nil
+}
+
+// GetUnsentOutboxMessage returns the message of the task with the ID if it is not enqueued yet, nil otherwise.
+func (d *DB) GetUnsentOutboxMessage(taskID string) (*models.OutboxMessage, error) {
+	var message models.OutboxMessage
+	if err := d.db.Where("sent_at IS NULL AND task_id = ?", taskID).First(&message).Error; err != nil {
+		switch err {
+		case gorm.ErrRecordNotFound:
+			return nil, nil
+		default:
+			return nil, err
+		}
+	}
+
+	return &message, nil
+}
+
+// ClaimOutboxMessages returns the oldest messages not sent yet and due at now, and holds them until
+// claimedUntil. Rows claimed by another relay are skipped rather than waited for, and are claimed again
+// once their claim expires if that relay stopped before marking them.
+func (d *DB) ClaimOutboxMessages(limit int, now int64, claimedUntil int64) ([]models.OutboxMessage, error) {
+	var messages []models.OutboxMessage
+	//save database
+	err := d.db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("sent_at IS NULL AND next_attempt_at <= ?", now).
+			Order("created_at").Limit(limit).Find(&messages).Error
+		if err != nil || len(messages) == 0 {
+			return err
+		}
+
+		ids := make([]uuid.UUID, len(messages))
+		for i := range messages {
+			ids[i] = messages[i].ID
+		}
+
+		return tx.Model(&models.OutboxMessage{}).Where("id IN ?", ids).Update("next_attempt_at", claimedUntil).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return messages, nil
+}
+
+// SetOutboxMessageFailed records a failed enqueue, the message is claimed again at nextAttemptAt.
+func (d *DB) SetOutboxMessageFailed(id uuid.UUID, nextAttemptAt int64, lastError string) error {
+	//save database
+	return d.db.Transaction(func(tx *gorm.DB) error {
+		return tx.Model(&models.OutboxMessage{ID: id}).Updates(map[string]interface{}{
+			"attempts":        gorm.Expr("attempts + 1"),
+			"next_attempt_at": nextAttemptAt,
+			"last_error":      lastError,
+		}).Error
+	})
+}
+
+// DeleteSentOutboxMessages deletes the messages sent before sentBefore and returns how many were.
+func (d *DB) DeleteSentOutboxMessages(sentBefore int64) (int64, error) {
+	result := d.db.Where("sent_at IS NOT NULL AND sent_at < ?", sentBefore).Delete(&models.OutboxMessage{})
+	return result.RowsAffected, result.Error
+}
+
+func (d *DB) SetOutboxMessageSent(id uuid.UUID, sentAt int64) error {
+	//save database
+	return d.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&models.OutboxMessage{ID: id}).Update("sent_at", sentAt).Error; err != nil {
+			return err
+		}
+
+		return nil
+	})
+}
+
+func (d *DB) CreateWebhook(webhook *models.Webhook) error {
+	//save database
+	return d.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&webhook).Error; err != nil {
+			return err
+		}
+
+		return nil
+	})
+}
+
+func (d *DB) GetWebhook(id uuid.UUID) (*models.Webhook, error) {
+	var webhook models.Webhook
+	if err := d.db.First(&webhook, id).Error; err != nil {
+		switch err {
+		case gorm.ErrRecordNotFound:
+			return nil, nil
+		default:
+			return nil, err
+		}
+	}
+
+	return &webhook, nil
+}
+
+func (d *DB) ListWebhooks(userID uuid.UUID) ([]models.Webhook, error) {
+	var webhooks []models.Webhook
+	if err := d.db.Where("user_id = ?", userID).Order("created_at").Find(&webhooks).Error; err != nil {
+		return nil, err
+	}
+
+	return webhooks, nil
+}
+
+func (d *DB) DeleteWebhook(id uuid.UUID) error {
+	//save database
+	return d.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Delete(&models.Webhook{ID: id}).Error; err != nil {
+			return err
+		}
+
+		return nil
+	})
+}
+
+func (d *DB) CreateWebhookDelivery(delivery *models.WebhookDelivery) error {
+	//save database
+	return d.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&delivery).Error; err != nil {
+			return err
+		}
+
+		return nil
+	})
+}
+
+// ListWebhookDeliveries returns the delivery log of the webhook, newest first.
+func (d *DB) ListWebhookDeliveries(webhookID uuid.UUID, offset int, limit int) ([]models.WebhookDelivery, error) {
+	var deliveries []models.WebhookDelivery
+	if err := d.db.Where("webhook_id = ?", webhookID).Order("created_at desc").Offset(offset).Limit(limit).Find(&deliveries).Error; err != nil {
+		return nil, err
+	}
+
+	return deliveries, nil
+}
+
+// CreateOrder saves the order together with the outbox messages of its events.
+func (d *DB) CreateOrder(order *models.Order, messages ...*models.OutboxMessage) error {
+	//save database
+	return d.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&order).Error; err != nil {
+			return err
+		}
+
+		return createOutboxMessages(tx, messages)
+	})
+}
+
+func (d *DB) ListCollections() ([]models.Collection, error) {
+	var collections []models.Collection
+	if err := d.db.Order("created_at").Find(&collections).Error; err != nil {
+		return nil, err
+	}
+
+	return collections, nil
+}
+
+// GetOrderByOrderID returns the order with the IMX order ID, or nil if it was not listed through the platform.
+func (d *DB) GetOrderByOrderID(orderID int32) (*models.Order, error) {
+	var order models.Order
+	if err := d.db.Where("order_id = ?", orderID).First(&order).Error; err != nil {
+		switch err {
+		case gorm.ErrRecordNotFound:
+			return nil, nil
+		default:
+			return nil, err
+		}
+	}
+
+	return &order, nil
+}
+
+func (d *DB) ListTokensByCollection(collectionID uuid.UUID) ([]models.Token, error) {
+	var tokens []models.Token
+	if err := d.db.Where("collection_id = ?", collectionID).Find(&tokens).Error; err != nil {
+		return nil, err
+	}
+
+	return tokens, nil
+}
+
+func (d *DB) ListOrdersByCollection(collectionID uuid.UUID) ([]models.Order, error) {
+	var orders []models.Order
+	if err := d.db.Where("collection_id = ?", collectionID).Find(&orders).Error; err != nil {
+		return nil, err
+	}
+
+	return orders, nil
+}
+
+// SaveReconciliation applies the fixes of a collection reconciliation and replaces its previous
+// discrepancy report with the new one.
+func (d *DB) SaveReconciliation(collectionID uuid.UUID, tokens []models.Token, orders []models.Order, discrepancies []models.Discrepancy) error {
+	//save database
+	return d.db.Transaction(func(tx *gorm.DB) error {
+		for _, token := range tokens {
+			if err := tx.Model(&models.Token{ID: token.ID}).Updates(map[string]interface{}{"status": token.Status, "owner": token.Owner}).Error; err != nil {
+				return err
+			}
+		}
+
+		for _, order := range orders {
+			if err := tx.Model(&models.Order{ID: order.ID}).Update("status", order.Status).Error; err != nil {
+				return err
+			}
+		}
+
+		if err := tx.Where("collection_id = ?", collectionID).Delete(&models.Discrepancy{}).Error; err != nil {
+			return err
+		}
+
+		if len(discrepancies) > 0 {
+			if err := tx.Create(&discrepancies).Error; err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+// ListDiscrepancies returns the latest discrepancies, of every collection when collectionID is nil.
+func (d *DB) ListDiscrepancies(collectionID *uuid.UUID, offset int, limit int) ([]models.Discrepancy, error) {
+	var discrepancies []models.Discrepancy
+	query := d.db.Order("created_at desc").Offset(offset).Limit(limit)
+	if collectionID != nil {
+		query = query.Where("collection_id = ?", *collectionID)
+	}
+
+	if err := query.Find(&discrepancies).Error; err != nil {
+		return nil, err
+	}
+
+	return discrepancies, nil
+}
@@ -4,10 +4,16 @@ import (
 	"context"
 	"database/sql"
 	"embed"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
 
-	"github.com/ethereum/go-ethereum/log"
 	_ "github.com/lib/pq" //required for sql library
 	"github.com/pressly/goose/v3"
+	"golang.org/x/exp/slog"
 )
 
 //go:embed migrations/*.sql
@@ -28,15 +34,50 @@ func NewMigrations(dsn string) *Migrations {
 
 // Up Migrate the DB to the most recent version available
 func (a Migrations) Up(ctx context.Context) error {
+	slog.InfoContext(ctx, "applying migrations...")
 	return a.executeFunc(ctx, goose.Up)
 }
 
 // Down Rollback one migration
 func (a Migrations) Down(ctx context.Context) error {
+	slog.InfoContext(ctx, "rolling back migrations...")
 	return a.executeFunc(ctx, goose.Down)
 }
 
+// DownTo Rollback the migrations applied after version
+func (a Migrations) DownTo(ctx context.Context, version int64) error {
+	slog.InfoContext(ctx, "rolling back migrations...", "version", version)
+	return a.executeFunc(ctx, func(db *sql.DB, dir string, opts ...goose.OptionsFunc) error {
+		return goose.DownTo(db, dir, version, opts...)
+	})
+}
+
+// Status Print whether each migration is applied, and when
+func (a Migrations) Status(ctx context.Context) error {
+	return a.executeFunc(ctx, goose.Status)
+}
+
+// Version Get the version of the most recent migration applied
+func (a Migrations) Version(ctx context.Context) (int64, error) {
+	var version int64
+	err := a.executeFunc(ctx, func(db *sql.DB, dir string, opts ...goose.OptionsFunc) error {
+		var err error
+		version, err = goose.EnsureDBVersion(db)
+		return err
+	})
+	return version, err
+}
+
+// Reset Rollback every migration
 func (a Migrations) Reset(ctx context.Context) error {
+	slog.InfoContext(ctx, "rolling back migrations...")
+	return a.executeFunc(ctx, func(db *sql.DB, dir string, opts ...goose.OptionsFunc) error {
+		return goose.DownTo(db, dir, 0, opts...)
+	})
+}
+
+func (a Migrations) executeFunc(ctx context.Context, funcToExecute gooseFunc) error {
+	//goose.SetLogger(utils.Logger(ctx))
 	goose.SetBaseFS(migrations)
 
 	db, err := sql.Open("postgres", a.dsn)
@@ -48,65 +89,72 @@ func (a Migrations) Reset(ctx context.Context) error {
 		return err
 	}
 
-	log.Info("acquiring lock to run migrations")
+	slog.InfoContext(ctx, "acquiring lock to run migrations")
 	if _, err = db.Exec("select pg_advisory_lock($1)", migrationLock); err != nil {
 		return err
 	}
-	log.Info("migration lock acquired")
+	slog.InfoContext(ctx, "migration lock acquired")
 
 	defer func() {
 		if _, err = db.Exec("select pg_advisory_unlock($1)", migrationLock); err != nil {
 			panic(err)
 		}
-		log.Info("migration lock released")
+		slog.InfoContext(ctx, "migration lock released")
 		//close connection used for applying migrations
 		if err := db.Close(); err != nil {
 			panic(err)
 		}
 	}()
 
-	log.Info("applying migrations...")
-	if err := goose.DownTo(db, "migrations", 0); err != nil {
+	if err := funcToExecute(db, "migrations"); err != nil {
 		return err
 	}
 
 	return nil
 }
 
-func (a Migrations) executeFunc(ctx context.Context, funcToExecute gooseFunc) error {
-	//goose.SetLogger(utils.Logger(ctx))
-	goose.SetBaseFS(migrations)
-
-	db, err := sql.Open("postgres", a.dsn)
+// CreateMigration writes a blank SQL migration to dir, numbered after the last one there, and returns its path.
+// The migrations are embedded, dir must be the migrations directory of the source tree.
+func CreateMigration(dir string, name string) (string, error) {
+	entries, err := os.ReadDir(dir)
 	if err != nil {
-		return err
+		return "", err
 	}
 
-	if err := goose.SetDialect("postgres"); err != nil {
-		return err
+	var last int64
+	for _, entry := range entries {
+		prefix, _, found := strings.Cut(entry.Name(), "_")
+		if !found || !strings.HasSuffix(entry.Name(), ".sql") {
+			continue
+		}
+		if version, err := strconv.ParseInt(prefix, 10, 64); err == nil && version > last {
+			last = version
+		}
 	}
 
-	log.Info("acquiring lock to run migrations")
-	if _, err = db.Exec("select pg_advisory_lock($1)", migrationLock); err != nil {
-		return err
+	name = strings.ToLower(strings.Join(strings.Fields(name), "_"))
+	if len(name) == 0 {
+		return "", errors.New("empty migration name")
 	}
-	log.Info("migration lock acquired")
-
-	defer func() {
-		if _, err = db.Exec("select pg_advisory_unlock($1)", migrationLock); err != nil {
-			panic(err)
-		}
-		log.Info("migration lock released")
-		//close connection used for applying migrations
-		if err := db.Close(); err != nil {
-			panic(err)
-		}
-	}()
 
-	log.Info("applying migrations...")
-	if err := funcToExecute(db, "migrations"); err != nil {
-		return err
+	path := filepath.Join(dir, fmt.Sprintf("%04d_%s.sql", last+1, name))
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+	if err != nil {
+		return "", err
 	}
+	defer f.Close()
 
-	return nil
+	if _, err := f.WriteString(migrationTemplate); err != nil {
+		return "", err
+	}
+	return path, nil
 }
+
+const migrationTemplate = `-- +goose Up
+-- +goose StatementBegin
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+-- +goose StatementEnd
+`
@@ -4,7 +4,11 @@ import (
 	"context"
 	"nft/models"
 	"nft/test"
+	"os"
+	"path/filepath"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/stretchr/testify/suite"
@@ -110,7 +114,7 @@ func (s *UnitTestSuite) TestCreateCollection() {
 	s.Assertions.Nil(collection)
 
 	userID := uuid.New()
-	newCollection := test.CreateDummyCollection(id, userID, "test address")
+	newCollection := test.CreateDummyCollection(id, userID, uuid.New(), "test address")
 
 	err = s.db.CreateCollection(newCollection)
 	s.Assertions.Nil(err)
@@ -139,6 +143,439 @@ func (s *UnitTestSuite) TestCreateToken() {
 	s.Assertions.Equal(token, newToken)
 }
 
+func (s *UnitTestSuite) TestSetTokenStatus() {
+	id := uuid.New()
+	newToken := test.CreateDummyToken(id, uuid.New(), "1")
+	newToken.Status = models.TokenPending
+
+	err := s.db.CreateToken(newToken)
+	s.Assertions.Nil(err)
+
+	err = s.db.SetTokenStatus(id, models.TokenMinted)
+	s.Assertions.Nil(err)
+
+	token, err := s.db.GetToken(id)
+	s.Assertions.Nil(err)
+	s.Assertions.Equal(models.TokenMinted, token.Status)
+}
+
+func (s *UnitTestSuite) TestResetFailedToken() {
+	id := uuid.New()
+	newToken := test.CreateDummyToken(id, uuid.New(), "1")
+
+	err := s.db.CreateToken(newToken)
+	s.Assertions.Nil(err)
+
+	// a minted token is not reset
+	reset, err := s.db.ResetFailedToken(id)
+	s.Assertions.Nil(err)
+	s.Assertions.False(reset)
+
+	err = s.db.SetTokenStatus(id, models.TokenFailed)
+	s.Assertions.Nil(err)
+
+	reset, err = s.db.ResetFailedToken(id)
+	s.Assertions.Nil(err)
+	s.Assertions.True(reset)
+
+	token, err := s.db.GetToken(id)
+	s.Assertions.Nil(err)
+	s.Assertions.Equal(models.TokenPending, token.Status)
+}
+
+func (s *UnitTestSuite) TestCreateWithdrawalWithOutboxMessage() {
+	withdrawalID := int32(10)
+	withdrawal := &models.Withdrawal{
+		ID:           uuid.New(),
+		UserID:       uuid.New(),
+		WithdrawalID: &withdrawalID,
+		AmountWei:    "1000",
+		Status:       models.WithdrawalPending,
+	}
+	message := &models.OutboxMessage{ID: uuid.New(), TaskType: "withdrawal:complete", Payload: []byte("{}")}
+
+	err := s.db.CreateWithdrawal(withdrawal, message)
+	s.Assertions.Nil(err)
+
+	messages, err := s.db.ListUnsentOutboxMessages(10)
+	s.Assertions.Nil(err)
+	s.Assertions.Len(messages, 1)
+	s.Assertions.Equal(message.ID, messages[0].ID)
+
+	err = s.db.SetOutboxMessageSent(message.ID, 1)
+	s.Assertions.Nil(err)
+
+	messages, err = s.db.ListUnsentOutboxMessages(10)
+	s.Assertions.Nil(err)
+	s.Assertions.Empty(messages)
+}
+
+func (s *UnitTestSuite) TestCreateWithdrawalRollsBackOutboxMessage() {
+	message := &models.OutboxMessage{ID: uuid.New(), TaskType: "withdrawal:complete", Payload: []byte("{}")}
+	withdrawalID := int32(11)
+	withdrawal := &models.Withdrawal{ID: uuid.New(), UserID: uuid.New(), WithdrawalID: &withdrawalID, AmountWei: "1000", Status: models.WithdrawalPending}
+	err := s.db.CreateWithdrawal(withdrawal)
+	s.Assertions.Nil(err)
+
+	duplicate := &models.Withdrawal{ID: uuid.New(), UserID: uuid.New(), WithdrawalID: &withdrawalID, AmountWei: "1000", Status: models.WithdrawalPending}
+	err = s.db.CreateWithdrawal(duplicate, message)
+	s.Assertions.NotNil(err)
+
+	messages, err := s.db.ListUnsentOutboxMessages(10)
+	s.Assertions.Nil(err)
+	s.Assertions.Empty(messages)
+}
+
+func (s *UnitTestSuite) TestClaimOutboxMessages() {
+	first := &models.OutboxMessage{ID: uuid.New(), TaskType: "withdrawal:complete", Payload: []byte("{}")}
+	second := &models.OutboxMessage{ID: uuid.New(), TaskType: "withdrawal:complete", Payload: []byte("{}")}
+	err := s.db.CreateOutboxMessages(first, second)
+	s.Assertions.Nil(err)
+
+	messages, err := s.db.ClaimOutboxMessages(10, 1000, 2000)
+	s.Assertions.Nil(err)
+	s.Assertions.Len(messages, 2)
+
+	// held by the first claim
+	messages, err = s.db.ClaimOutboxMessages(10, 1500, 2500)
+	s.Assertions.Nil(err)
+	s.Assertions.Empty(messages)
+
+	err = s.db.SetOutboxMessageSent(first.ID, 1500)
+	s.Assertions.Nil(err)
+	err = s.db.SetOutboxMessageFailed(second.ID, 3000, "redis down")
+	s.Assertions.Nil(err)
+
+	messages, err = s.db.ClaimOutboxMessages(10, 2500, 3500)
+	s.Assertions.Nil(err)
+	s.Assertions.Empty(messages)
+
+	messages, err = s.db.ClaimOutboxMessages(10, 3000, 4000)
+	s.Assertions.Nil(err)
+	s.Assertions.Len(messages, 1)
+	s.Assertions.Equal(second.ID, messages[0].ID)
+	s.Assertions.Equal(1, messages[0].Attempts)
+	s.Assertions.Equal("redis down", messages[0].LastError)
+}
+
+func (s *UnitTestSuite) TestDeleteSentOutboxMessages() {
+	sent := &models.OutboxMessage{ID: uuid.New(), TaskType: "withdrawal:complete", Payload: []byte("{}")}
+	unsent := &models.OutboxMessage{ID: uuid.New(), TaskType: "withdrawal:complete", Payload: []byte("{}")}
+	err := s.db.CreateOutboxMessages(sent, unsent)
+	s.Assertions.Nil(err)
+	err = s.db.SetOutboxMessageSent(sent.ID, 1000)
+	s.Assertions.Nil(err)
+
+	deleted, err := s.db.DeleteSentOutboxMessages(2000)
+	s.Assertions.Nil(err)
+	s.Assertions.Equal(int64(1), deleted)
+
+	messages, err := s.db.ListUnsentOutboxMessages(10)
+	s.Assertions.Nil(err)
+	s.Assertions.Len(messages, 1)
+	s.Assertions.Equal(unsent.ID, messages[0].ID)
+}
+
+func (s *UnitTestSuite) TestGetUserByAddress() {
+	user := test.CreateDummyUser(uuid.New(), "test")
+	user.Address = "0x18b1ceDC9803096D970f52260D1835F07D7e448C"
+	err := s.db.CreateUser(user)
+	s.Assertions.Nil(err)
+
+	saved, err := s.db.GetUserByAddress("0x18b1cedc9803096d970f52260d1835f07d7e448c")
+	s.Assertions.Nil(err)
+	s.Assertions.NotNil(saved)
+	s.Assertions.Equal(user.ID, saved.ID)
+
+	saved, err = s.db.GetUserByAddress("0x0000000000000000000000000000000000000000")
+	s.Assertions.Nil(err)
+	s.Assertions.Nil(saved)
+}
+
+func (s *UnitTestSuite) TestSaveReconciliation() {
+	collectionID := uuid.New()
+	token := test.CreateDummyToken(uuid.New(), collectionID, "1")
+	token.Status = models.TokenPending
+	err := s.db.CreateToken(token)
+	s.Assertions.Nil(err)
+	order := &models.Order{ID: uuid.New(), CollectionID: collectionID, TokenID: token.ID, OrderID: 1, Amount: "10", Status: models.OrderActive}
+	err = s.db.CreateOrder(order)
+	s.Assertions.Nil(err)
+
+	err = s.db.SaveReconciliation(collectionID, nil, nil, []models.Discrepancy{
+		{ID: uuid.New(), CollectionID: collectionID, Kind: models.DiscrepancyTokenMissingLocally, Reference: "2"},
+	})
+	s.Assertions.Nil(err)
+
+	token.Status = models.TokenMinted
+	token.Owner = "0xabc"
+	order.Status = "filled"
+	err = s.db.SaveReconciliation(collectionID, []models.Token{*token}, []models.Order{*order}, []models.Discrepancy{
+		{ID: uuid.New(), CollectionID: collectionID, Kind: models.DiscrepancyTokenStatus, Reference: "1", Resolved: true},
+	})
+	s.Assertions.Nil(err)
+
+	saved, err := s.db.GetToken(token.ID)
+	s.Assertions.Nil(err)
+	s.Assertions.Equal(models.TokenMinted, saved.Status)
+	s.Assertions.Equal("0xabc", saved.Owner)
+
+	orders, err := s.db.ListOrdersByCollection(collectionID)
+	s.Assertions.Nil(err)
+	s.Assertions.Equal("filled", orders[0].Status)
+
+	discrepancies, err := s.db.ListDiscrepancies(&collectionID, 0, 10)
+	s.Assertions.Nil(err)
+	s.Assertions.Len(discrepancies, 1)
+	s.Assertions.Equal(models.DiscrepancyTokenStatus, discrepancies[0].Kind)
+}
+
+func (s *UnitTestSuite) TestUpdateSignatureRequest() {
+	id := uuid.New()
+	request, err := s.db.GetSignatureRequest(id)
+	s.Assertions.Nil(err)
+	s.Assertions.Nil(request)
+
+	newRequest := &models.SignatureRequest{
+		ID:              id,
+		UserID:          uuid.New(),
+		Type:            models.SignatureRequestTrade,
+		Status:          models.SignatureRequestPending,
+		OrderID:         10,
+		SignableMessage: "message",
+		PayloadHash:     "hash",
+		Payload:         "{}",
+	}
+
+	err = s.db.CreateSignatureRequest(newRequest)
+	s.Assertions.Nil(err)
+
+	request, err = s.db.GetSignatureRequest(id)
+	s.Assertions.Nil(err)
+	s.Assertions.NotNil(request)
+	s.Assertions.Equal(request, newRequest)
+
+	request.Status = models.SignatureRequestSubmitted
+	err = s.db.UpdateSignatureRequest(request)
+	s.Assertions.Nil(err)
+
+	request, err = s.db.GetSignatureRequest(id)
+	s.Assertions.Nil(err)
+	s.Assertions.Equal(models.SignatureRequestSubmitted, request.Status)
+}
+
+func (s *UnitTestSuite) TestClaimSignatureRequest() {
+	request := &models.SignatureRequest{
+		ID:              uuid.New(),
+		UserID:          uuid.New(),
+		Type:            models.SignatureRequestTrade,
+		Status:          models.SignatureRequestPending,
+		OrderID:         10,
+		SignableMessage: "message",
+		PayloadHash:     "hash",
+		Payload:         "{}",
+		ExpiresAt:       2000,
+	}
+	err := s.db.CreateSignatureRequest(request)
+	s.Assertions.Nil(err)
+
+	// expired
+	claimed, err := s.db.ClaimSignatureRequest(request.ID, 2000)
+	s.Assertions.Nil(err)
+	s.Assertions.False(claimed)
+
+	claimed, err = s.db.ClaimSignatureRequest(request.ID, 1000)
+	s.Assertions.Nil(err)
+	s.Assertions.True(claimed)
+
+	// already claimed
+	claimed, err = s.db.ClaimSignatureRequest(request.ID, 1000)
+	s.Assertions.Nil(err)
+	s.Assertions.False(claimed)
+
+	request, err = s.db.GetSignatureRequest(request.ID)
+	s.Assertions.Nil(err)
+	s.Assertions.Equal(models.SignatureRequestSubmitting, request.Status)
+}
+
+func (s *UnitTestSuite) TestSaveOrganizationMember() {
+	organization := test.CreateDummyOrganization(uuid.New())
+	ownerID := uuid.New()
+	err := s.db.CreateOrganization(organization, ownerID)
+	s.Assertions.Nil(err)
+
+	member, err := s.db.GetOrganizationMember(organization.ID, ownerID)
+	s.Assertions.Nil(err)
+	s.Assertions.NotNil(member)
+	s.Assertions.Equal(models.RoleOwner, member.Role)
+
+	userID := uuid.New()
+	err = s.db.SaveOrganizationMember(&models.OrganizationMember{OrganizationID: organization.ID, UserID: userID, Role: models.RoleViewer})
+	s.Assertions.Nil(err)
+	err = s.db.SaveOrganizationMember(&models.OrganizationMember{OrganizationID: organization.ID, UserID: userID, Role: models.RoleOwner})
+	s.Assertions.Nil(err)
+
+	member, err = s.db.GetOrganizationMember(organization.ID, userID)
+	s.Assertions.Nil(err)
+	s.Assertions.Equal(models.RoleOwner, member.Role)
+
+	owners, err := s.db.CountOrganizationOwners(organization.ID)
+	s.Assertions.Nil(err)
+	s.Assertions.Equal(int64(2), owners)
+
+	err = s.db.DeleteOrganizationMember(member)
+	s.Assertions.Nil(err)
+
+	member, err = s.db.GetOrganizationMember(organization.ID, userID)
+	s.Assertions.Nil(err)
+	s.Assertions.Nil(member)
+}
+
+func (s *UnitTestSuite) TestLastOrganizationOwnerIsKept() {
+	organization := test.CreateDummyOrganization(uuid.New())
+	ownerID := uuid.New()
+	err := s.db.CreateOrganization(organization, ownerID)
+	s.Assertions.Nil(err)
+
+	saved, err := s.db.SaveOrganizationMemberUnlessLastOwner(&models.OrganizationMember{OrganizationID: organization.ID, UserID: ownerID, Role: models.RoleViewer})
+	s.Assertions.Nil(err)
+	s.Assertions.False(saved)
+
+	otherID := uuid.New()
+	saved, err = s.db.SaveOrganizationMemberUnlessLastOwner(&models.OrganizationMember{OrganizationID: organization.ID, UserID: otherID, Role: models.RoleOwner})
+	s.Assertions.Nil(err)
+	s.Assertions.True(saved)
+
+	// Both owners demote themselves at once; only one of them can succeed.
+	var wg sync.WaitGroup
+	results := make([]bool, 2)
+	for i, userID := range []uuid.UUID{ownerID, otherID} {
+		wg.Add(1)
+		go func(i int, userID uuid.UUID) {
+			defer wg.Done()
+			saved, err := s.db.SaveOrganizationMemberUnlessLastOwner(&models.OrganizationMember{OrganizationID: organization.ID, UserID: userID, Role: models.RoleViewer})
+			s.Assertions.Nil(err)
+			results[i] = saved
+		}(i, userID)
+	}
+	wg.Wait()
+	s.Assertions.NotEqual(results[0], results[1])
+
+	owners, err := s.db.CountOrganizationOwners(organization.ID)
+	s.Assertions.Nil(err)
+	s.Assertions.Equal(int64(1), owners)
+
+	lastID := otherID
+	if results[1] {
+		lastID = ownerID
+	}
+	member, err := s.db.GetOrganizationMember(organization.ID, lastID)
+	s.Assertions.Nil(err)
+	s.Assertions.Equal(models.RoleOwner, member.Role)
+
+	deleted, err := s.db.DeleteOrganizationMemberUnlessLastOwner(member)
+	s.Assertions.Nil(err)
+	s.Assertions.False(deleted)
+}
+
+func (s *UnitTestSuite) TestIdempotencyKey() {
+	key := &models.IdempotencyKey{ClientID: uuid.New(), Key: "key", RequestHash: "hash"}
+
+	created, err := s.db.CreateIdempotencyKey(key, 0, 0)
+	s.Assertions.Nil(err)
+	s.Assertions.True(created)
+
+	created, err = s.db.CreateIdempotencyKey(&models.IdempotencyKey{ClientID: key.ClientID, Key: "key", RequestHash: "other"}, 0, 0)
+	s.Assertions.Nil(err)
+	s.Assertions.False(created)
+
+	key.StatusCode = 201
+	key.ContentType = "application/json"
+	key.ResponseBody = []byte("{}")
+	err = s.db.CompleteIdempotencyKey(key)
+	s.Assertions.Nil(err)
+
+	stored, err := s.db.GetIdempotencyKey(key.ClientID, "key")
+	s.Assertions.Nil(err)
+	s.Assertions.True(stored.Completed)
+	s.Assertions.Equal("hash", stored.RequestHash)
+	s.Assertions.Equal(201, stored.StatusCode)
+	s.Assertions.Equal([]byte("{}"), stored.ResponseBody)
+
+	err = s.db.DeleteIdempotencyKey(key)
+	s.Assertions.Nil(err)
+
+	stored, err = s.db.GetIdempotencyKey(key.ClientID, "key")
+	s.Assertions.Nil(err)
+	s.Assertions.Nil(stored)
+}
+
+func (s *UnitTestSuite) TestIdempotencyKeyExpires() {
+	key := &models.IdempotencyKey{ClientID: uuid.New(), Key: "key", RequestHash: "hash"}
+	created, err := s.db.CreateIdempotencyKey(key, 0, 0)
+	s.Assertions.Nil(err)
+	s.Assertions.True(created)
+
+	// in progress, until its lease is over
+	future := time.Now().Add(time.Hour).UnixMilli()
+	created, err = s.db.CreateIdempotencyKey(&models.IdempotencyKey{ClientID: key.ClientID, Key: "key", RequestHash: "other"}, future, 0)
+	s.Assertions.Nil(err)
+	s.Assertions.False(created)
+	created, err = s.db.CreateIdempotencyKey(&models.IdempotencyKey{ClientID: key.ClientID, Key: "key", RequestHash: "other"}, 0, future)
+	s.Assertions.Nil(err)
+	s.Assertions.True(created)
+
+	key.RequestHash = "other"
+	err = s.db.CompleteIdempotencyKey(key)
+	s.Assertions.Nil(err)
+
+	deleted, err := s.db.DeleteExpiredIdempotencyKeys(0, future)
+	s.Assertions.Nil(err)
+	s.Assertions.Equal(int64(0), deleted)
+	deleted, err = s.db.DeleteExpiredIdempotencyKeys(future, 0)
+	s.Assertions.Nil(err)
+	s.Assertions.Equal(int64(1), deleted)
+}
+
+func (s *UnitTestSuite) TestMigrationsVersion() {
+	latest, err := s.migrations.Version(context.Background())
+	s.Assertions.Nil(err)
+	s.Assertions.Greater(latest, int64(1))
+
+	err = s.migrations.DownTo(context.Background(), 1)
+	s.Assertions.Nil(err)
+
+	version, err := s.migrations.Version(context.Background())
+	s.Assertions.Nil(err)
+	s.Assertions.Equal(int64(1), version)
+
+	err = s.migrations.Up(context.Background())
+	s.Assertions.Nil(err)
+
+	version, err = s.migrations.Version(context.Background())
+	s.Assertions.Nil(err)
+	s.Assertions.Equal(latest, version)
+}
+
+func (s *UnitTestSuite) TestCreateMigration() {
+	dir := s.T().TempDir()
+
+	path, err := CreateMigration(dir, "Add Users")
+	s.Assertions.Nil(err)
+	s.Assertions.Equal(filepath.Join(dir, "0001_add_users.sql"), path)
+
+	path, err = CreateMigration(dir, "drop_users")
+	s.Assertions.Nil(err)
+	s.Assertions.Equal(filepath.Join(dir, "0002_drop_users.sql"), path)
+
+	content, err := os.ReadFile(path)
+	s.Assertions.Nil(err)
+	s.Assertions.Equal(migrationTemplate, string(content))
+
+	_, err = CreateMigration(dir, " ")
+	s.Assertions.NotNil(err)
+}
+
 func TestUnitTestSuite(t *testing.T) {
 	suite.Run(t, new(UnitTestSuite))
 }
@@ -0,0 +1,26 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE public.users ADD COLUMN external boolean NOT NULL DEFAULT false;
+
+CREATE TABLE public.signature_requests
+(
+    id                  uuid  NOT NULL,
+    user_id             uuid  NOT NULL,
+    "type"              text  NOT NULL,
+    status              text  NOT NULL,
+    order_id            int4  NULL,
+    signable_message    text  NOT NULL,
+    payload_hash        text  NOT NULL,
+    payload             text  NOT NULL,
+    created_at          int8  NULL,
+    updated_at          int8  NULL,
+    CONSTRAINT signature_requests_pkey PRIMARY KEY (id)
+);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE public.signature_requests;
+
+ALTER TABLE public.users DROP COLUMN external;
+-- +goose StatementEnd
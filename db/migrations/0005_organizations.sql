@@ -0,0 +1,53 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE public.organizations
+(
+    id                  uuid  NOT NULL,
+    name                text  NOT NULL,
+    created_at          int8  NULL,
+    updated_at          int8  NULL,
+    CONSTRAINT organizations_pkey PRIMARY KEY (id)
+);
+
+CREATE TABLE public.organization_members
+(
+    organization_id     uuid  NOT NULL,
+    user_id             uuid  NOT NULL,
+    "role"              text  NOT NULL,
+    created_at          int8  NULL,
+    updated_at          int8  NULL,
+    CONSTRAINT organization_members_pkey PRIMARY KEY (organization_id, user_id)
+);
+
+ALTER TABLE public.collections ADD COLUMN organization_id uuid NULL;
+
+-- every user owning collections gets a personal organization holding them
+CREATE TEMPORARY TABLE collection_owners ON COMMIT DROP AS
+SELECT DISTINCT c.user_id, gen_random_uuid() AS organization_id
+FROM public.collections c;
+
+INSERT INTO public.organizations (id, name, created_at, updated_at)
+SELECT o.organization_id, COALESCE(u.mail, o.user_id::text), (extract(epoch from now()) * 1000)::int8, (extract(epoch from now()) * 1000)::int8
+FROM collection_owners o
+LEFT JOIN public.users u ON u.id = o.user_id;
+
+INSERT INTO public.organization_members (organization_id, user_id, "role", created_at, updated_at)
+SELECT o.organization_id, o.user_id, 'owner', (extract(epoch from now()) * 1000)::int8, (extract(epoch from now()) * 1000)::int8
+FROM collection_owners o;
+
+UPDATE public.collections c
+SET organization_id = o.organization_id
+FROM collection_owners o
+WHERE o.user_id = c.user_id;
+
+ALTER TABLE public.collections ALTER COLUMN organization_id SET NOT NULL;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE public.collections DROP COLUMN organization_id;
+
+DROP TABLE public.organization_members;
+
+DROP TABLE public.organizations;
+-- +goose StatementEnd
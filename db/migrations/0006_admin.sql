@@ -0,0 +1,29 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE public.users ADD COLUMN "admin" boolean NOT NULL DEFAULT false;
+ALTER TABLE public.users ADD COLUMN disabled boolean NOT NULL DEFAULT false;
+
+ALTER TABLE public.collections ADD COLUMN hidden boolean NOT NULL DEFAULT false;
+
+CREATE TABLE public.audit_events
+(
+    id                  uuid  NOT NULL,
+    actor_id            uuid  NOT NULL,
+    "action"            text  NOT NULL,
+    target_type         text  NULL,
+    target_id           text  NULL,
+    details             text  NULL,
+    created_at          int8  NULL,
+    CONSTRAINT audit_events_pkey PRIMARY KEY (id)
+);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE public.audit_events;
+
+ALTER TABLE public.collections DROP COLUMN hidden;
+
+ALTER TABLE public.users DROP COLUMN disabled;
+ALTER TABLE public.users DROP COLUMN "admin";
+-- +goose StatementEnd
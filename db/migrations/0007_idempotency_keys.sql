@@ -0,0 +1,21 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE public.idempotency_keys
+(
+    client_id           uuid  NOT NULL,
+    "key"               text  NOT NULL,
+    request_hash        text  NOT NULL,
+    completed           boolean NOT NULL DEFAULT false,
+    status_code         int4  NULL,
+    content_type        text  NULL,
+    response_body       bytea NULL,
+    created_at          int8  NULL,
+    updated_at          int8  NULL,
+    CONSTRAINT idempotency_keys_pkey PRIMARY KEY (client_id, "key")
+);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE public.idempotency_keys;
+-- +goose StatementEnd
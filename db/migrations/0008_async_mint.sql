@@ -0,0 +1,11 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE public.tokens ADD COLUMN blueprint text NULL;
+ALTER TABLE public.tokens ADD COLUMN status text NOT NULL DEFAULT 'minted';
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE public.tokens DROP COLUMN status;
+ALTER TABLE public.tokens DROP COLUMN blueprint;
+-- +goose StatementEnd
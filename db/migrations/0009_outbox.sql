@@ -0,0 +1,38 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE public.outbox_messages
+(
+    id                  uuid  NOT NULL,
+    task_type           text  NOT NULL,
+    payload             bytea NOT NULL,
+    task_id             text  NULL,
+    process_at          int8  NOT NULL DEFAULT 0,
+    retention_seconds   int8  NOT NULL DEFAULT 0,
+    sent_at             int8  NULL,
+    created_at          int8  NULL,
+    updated_at          int8  NULL,
+    CONSTRAINT outbox_messages_pkey PRIMARY KEY (id)
+);
+
+CREATE INDEX outbox_messages_unsent_idx ON public.outbox_messages (created_at) WHERE sent_at IS NULL;
+
+CREATE TABLE public.withdrawals
+(
+    id                  uuid  NOT NULL,
+    user_id             uuid  NOT NULL,
+    withdrawal_id       int4  NOT NULL,
+    amount_wei          text  NOT NULL,
+    status              text  NOT NULL,
+    created_at          int8  NULL,
+    updated_at          int8  NULL,
+    CONSTRAINT withdrawals_pkey PRIMARY KEY (id),
+    CONSTRAINT withdrawals_withdrawal_id_key UNIQUE (withdrawal_id)
+);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE public.withdrawals;
+
+DROP TABLE public.outbox_messages;
+-- +goose StatementEnd
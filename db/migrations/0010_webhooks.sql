@@ -0,0 +1,44 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE public.webhooks
+(
+    id                  uuid  NOT NULL,
+    user_id             uuid  NOT NULL,
+    url                 text  NOT NULL,
+    secret              text  NOT NULL,
+    events              text  NOT NULL,
+    created_at          int8  NULL,
+    updated_at          int8  NULL,
+    CONSTRAINT webhooks_pkey PRIMARY KEY (id)
+);
+
+CREATE INDEX webhooks_user_id_idx ON public.webhooks (user_id);
+
+CREATE TABLE public.webhook_deliveries
+(
+    id                  uuid  NOT NULL,
+    webhook_id          uuid  NOT NULL,
+    event_id            uuid  NOT NULL,
+    event               text  NOT NULL,
+    attempt             int4  NOT NULL,
+    status_code         int4  NULL,
+    error               text  NULL,
+    success             boolean NOT NULL DEFAULT false,
+    created_at          int8  NULL,
+    CONSTRAINT webhook_deliveries_pkey PRIMARY KEY (id),
+    CONSTRAINT webhook_deliveries_webhook_id_fkey FOREIGN KEY (webhook_id) REFERENCES public.webhooks (id) ON DELETE CASCADE
+);
+
+CREATE INDEX webhook_deliveries_webhook_id_idx ON public.webhook_deliveries (webhook_id, created_at);
+
+ALTER TABLE public.outbox_messages ADD COLUMN max_retry int4 NOT NULL DEFAULT 0;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE public.outbox_messages DROP COLUMN max_retry;
+
+DROP TABLE public.webhook_deliveries;
+
+DROP TABLE public.webhooks;
+-- +goose StatementEnd
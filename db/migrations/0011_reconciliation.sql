@@ -0,0 +1,43 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE public.orders
+(
+    id                  uuid  NOT NULL,
+    collection_id       uuid  NOT NULL,
+    token_id            uuid  NOT NULL,
+    order_id            int4  NOT NULL,
+    amount              text  NOT NULL,
+    status              text  NOT NULL,
+    created_at          int8  NULL,
+    updated_at          int8  NULL,
+    CONSTRAINT orders_pkey PRIMARY KEY (id),
+    CONSTRAINT orders_order_id_key UNIQUE (order_id)
+);
+
+CREATE INDEX orders_collection_id_idx ON public.orders (collection_id);
+
+CREATE INDEX tokens_collection_id_idx ON public.tokens (collection_id);
+
+CREATE TABLE public.discrepancies
+(
+    id                  uuid  NOT NULL,
+    collection_id       uuid  NOT NULL,
+    kind                text  NOT NULL,
+    reference           text  NOT NULL,
+    details             text  NULL,
+    resolved            boolean NOT NULL DEFAULT false,
+    created_at          int8  NULL,
+    CONSTRAINT discrepancies_pkey PRIMARY KEY (id)
+);
+
+CREATE INDEX discrepancies_collection_id_idx ON public.discrepancies (collection_id, created_at);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE public.discrepancies;
+
+DROP INDEX public.tokens_collection_id_idx;
+
+DROP TABLE public.orders;
+-- +goose StatementEnd
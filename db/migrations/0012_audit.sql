@@ -0,0 +1,36 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE public.audit_events ALTER COLUMN actor_id DROP NOT NULL;
+ALTER TABLE public.audit_events ADD COLUMN request_id text NULL;
+ALTER TABLE public.audit_events ADD COLUMN outcome text NOT NULL DEFAULT 'success';
+ALTER TABLE public.audit_events ADD COLUMN imx_id text NULL;
+
+CREATE INDEX audit_events_created_at_idx ON public.audit_events (created_at);
+CREATE INDEX audit_events_actor_id_idx ON public.audit_events (actor_id, created_at);
+CREATE INDEX audit_events_target_idx ON public.audit_events (target_type, target_id, created_at);
+
+CREATE FUNCTION public.audit_events_append_only() RETURNS trigger AS $$
+BEGIN
+    RAISE EXCEPTION 'audit events can not be changed';
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER audit_events_append_only BEFORE UPDATE OR DELETE ON public.audit_events
+    FOR EACH ROW EXECUTE FUNCTION public.audit_events_append_only();
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TRIGGER audit_events_append_only ON public.audit_events;
+DROP FUNCTION public.audit_events_append_only();
+
+DROP INDEX public.audit_events_target_idx;
+DROP INDEX public.audit_events_actor_id_idx;
+DROP INDEX public.audit_events_created_at_idx;
+
+ALTER TABLE public.audit_events DROP COLUMN imx_id;
+ALTER TABLE public.audit_events DROP COLUMN outcome;
+ALTER TABLE public.audit_events DROP COLUMN request_id;
+DELETE FROM public.audit_events WHERE actor_id IS NULL;
+ALTER TABLE public.audit_events ALTER COLUMN actor_id SET NOT NULL;
+-- +goose StatementEnd
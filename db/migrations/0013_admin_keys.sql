@@ -0,0 +1,11 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE public.users ADD COLUMN admin_key_hash text NULL;
+ALTER TABLE public.users ADD CONSTRAINT users_mail_key UNIQUE (mail);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE public.users DROP CONSTRAINT users_mail_key;
+ALTER TABLE public.users DROP COLUMN admin_key_hash;
+-- +goose StatementEnd
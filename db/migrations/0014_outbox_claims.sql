@@ -0,0 +1,17 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE public.outbox_messages ADD COLUMN attempts int4 NOT NULL DEFAULT 0;
+ALTER TABLE public.outbox_messages ADD COLUMN next_attempt_at int8 NOT NULL DEFAULT 0;
+ALTER TABLE public.outbox_messages ADD COLUMN last_error text NULL;
+
+CREATE INDEX outbox_messages_sent_idx ON public.outbox_messages (sent_at) WHERE sent_at IS NOT NULL;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX public.outbox_messages_sent_idx;
+
+ALTER TABLE public.outbox_messages DROP COLUMN last_error;
+ALTER TABLE public.outbox_messages DROP COLUMN next_attempt_at;
+ALTER TABLE public.outbox_messages DROP COLUMN attempts;
+-- +goose StatementEnd
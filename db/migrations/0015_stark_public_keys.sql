@@ -0,0 +1,14 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE public.users ADD COLUMN stark_public_key text NULL;
+
+-- external users only ever gave their public stark key
+UPDATE public.users SET stark_public_key = stark_key, stark_key = NULL WHERE external;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+UPDATE public.users SET stark_key = stark_public_key WHERE external;
+
+ALTER TABLE public.users DROP COLUMN stark_public_key;
+-- +goose StatementEnd
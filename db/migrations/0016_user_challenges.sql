@@ -0,0 +1,17 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE public.user_challenges
+(
+    id                  uuid  NOT NULL,
+    address             text  NOT NULL,
+    message             text  NOT NULL,
+    expires_at          int8  NOT NULL,
+    created_at          int8  NULL,
+    CONSTRAINT user_challenges_pkey PRIMARY KEY (id)
+);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE public.user_challenges;
+-- +goose StatementEnd
@@ -0,0 +1,11 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE public.signature_requests ADD COLUMN expires_at int8 NOT NULL DEFAULT 0;
+
+UPDATE public.signature_requests SET expires_at = created_at + 900000 WHERE created_at IS NOT NULL;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE public.signature_requests DROP COLUMN expires_at;
+-- +goose StatementEnd
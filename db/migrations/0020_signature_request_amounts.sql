@@ -0,0 +1,9 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE public.signature_requests ADD COLUMN amount_wei text NULL;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE public.signature_requests DROP COLUMN amount_wei;
+-- +goose StatementEnd
@@ -0,0 +1,9 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE public.tokens ADD CONSTRAINT tokens_collection_id_token_id_key UNIQUE (collection_id, token_id);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE public.tokens DROP CONSTRAINT tokens_collection_id_token_id_key;
+-- +goose StatementEnd
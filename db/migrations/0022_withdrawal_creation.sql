@@ -0,0 +1,12 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE public.withdrawals ALTER COLUMN withdrawal_id DROP NOT NULL;
+CREATE UNIQUE INDEX withdrawals_creating_user_idx ON public.withdrawals (user_id) WHERE status = 'creating';
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX public.withdrawals_creating_user_idx;
+DELETE FROM public.withdrawals WHERE withdrawal_id IS NULL;
+ALTER TABLE public.withdrawals ALTER COLUMN withdrawal_id SET NOT NULL;
+-- +goose StatementEnd
@@ -0,0 +1,30 @@
+package events
+
+// Data sent with each event type. token.minted sends the minted models.Token.
+
+type Transfer struct {
+	CollectionID    string `json:"collection_id"`
+	TokenID         string `json:"token_id"`
+	ReceiverAddress string `json:"receiver_address"`
+}
+
+type Order struct {
+	OrderID      int32  `json:"order_id"`
+	CollectionID string `json:"collection_id"`
+	TokenID      string `json:"token_id"`
+	Amount       string `json:"amount"`
+}
+
+type Trade struct {
+	TradeID int32 `json:"trade_id"`
+	OrderID int32 `json:"order_id"`
+}
+
+type Withdrawal struct {
+	WithdrawalID int32 `json:"withdrawal_id"`
+}
+
+type Deposit struct {
+	TransactionHash string `json:"transaction_hash"`
+	AmountWei       string `json:"amount_wei"`
+}
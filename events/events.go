@@ -0,0 +1,58 @@
+package events
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"strconv"
+
+	"github.com/google/uuid"
+)
+
+const (
+	TokenMinted         = "token.minted"
+	TransferCompleted   = "transfer.completed"
+	OrderCreated        = "order.created"
+	TradeCompleted      = "trade.completed"
+	WithdrawalCompleted = "withdrawal.completed"
+	DepositConfirmed    = "deposit.confirmed"
+	DepositFailed       = "deposit.failed"
+)
+
+// Types lists every event users can subscribe to.
+var Types = []string{
+	TokenMinted,
+	TransferCompleted,
+	OrderCreated,
+	TradeCompleted,
+	WithdrawalCompleted,
+	DepositConfirmed,
+	DepositFailed,
+}
+
+func IsValidType(eventType string) bool {
+	for _, t := range Types {
+		if t == eventType {
+			return true
+		}
+	}
+	return false
+}
+
+// Event is what happened to the resources of a user, as delivered to their webhooks.
+type Event struct {
+	ID        uuid.UUID       `json:"id"`
+	Type      string          `json:"type"`
+	CreatedAt int64           `json:"created_at"`
+	Data      json.RawMessage `json:"data"`
+}
+
+// Sign returns the hex encoded HMAC-SHA256 of "timestamp.body" with the webhook secret. Receivers
+// compute the same value to check the delivery comes from us and was not replayed later.
+func Sign(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10) + "."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
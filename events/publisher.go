@@ -0,0 +1,126 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"nft/db"
+	"nft/models"
+	"nft/tracing"
+	"time"
+
+	"github.com/google/uuid"
+	"golang.org/x/exp/slog"
+)
+
+const (
+	TypeDeliverWebhook = "webhook:deliver"
+	TypePublishEvent   = "event:publish"
+
+	// deliveryMaxRetry bounds the retries of a failing webhook. With the asynq exponential backoff
+	// the last attempt happens about a day after the event.
+	deliveryMaxRetry = 12
+)
+
+type DeliveryPayload struct {
+	WebhookID uuid.UUID
+	Event     Event
+}
+
+type PublishPayload struct {
+	UserID uuid.UUID
+	Event  Event
+}
+
+// Publisher publishes the events of a user to the webhooks subscribed to them and to the event
+// stream. Webhook deliveries are saved in the outbox and sent by the webhook task.
+type Publisher struct {
+	db     *db.DB
+	stream *Stream
+}
+
+func NewPublisher(db *db.DB, stream *Stream) *Publisher {
+	return &Publisher{db, stream}
+}
+
+// NewMessage returns the outbox message of the task publishing the event. Saved in the transaction
+// changing what the event is about, the event is published if and only if the change is saved.
+func NewMessage(ctx context.Context, userID uuid.UUID, eventType string, data interface{}) (*models.OutboxMessage, error) {
+	event, err := newEvent(eventType, data)
+	if err != nil {
+		return nil, err
+	}
+
+	payload, err := json.Marshal(PublishPayload{userID, *event})
+	if err != nil {
+		return nil, err
+	}
+
+	return &models.OutboxMessage{
+		ID:       uuid.New(),
+		TaskType: TypePublishEvent,
+		Payload:  tracing.InjectPayload(ctx, payload),
+	}, nil
+}
+
+func newEvent(eventType string, data interface{}) (*Event, error) {
+	raw, err := json.Marshal(data)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Event{
+		ID:        uuid.New(),
+		Type:      eventType,
+		CreatedAt: time.Now().UnixMilli(),
+		Data:      raw,
+	}, nil
+}
+
+func (p *Publisher) Publish(ctx context.Context, userID uuid.UUID, eventType string, data interface{}) error {
+	event, err := newEvent(eventType, data)
+	if err != nil {
+		return err
+	}
+
+	return p.Deliver(ctx, userID, event)
+}
+
+// Deliver streams the event and saves its webhook deliveries. It may be called again for the same
+// event, the deliveries already saved are kept and not duplicated.
+func (p *Publisher) Deliver(ctx context.Context, userID uuid.UUID, event *Event) error {
+	if err := p.stream.Publish(ctx, userID, event); err != nil {
+		// webhooks are still delivered, stream clients only miss this event
+		slog.ErrorContext(ctx, "error streaming event", "err", err)
+	}
+
+	webhooks, err := p.db.ListWebhooks(userID)
+	if err != nil {
+		return err
+	}
+
+	messages := make([]*models.OutboxMessage, 0, len(webhooks))
+	for i := range webhooks {
+		if !webhooks[i].Subscribes(event.Type) {
+			continue
+		}
+
+		payload, err := json.Marshal(DeliveryPayload{webhooks[i].ID, *event})
+		if err != nil {
+			return err
+		}
+
+		messages = append(messages, &models.OutboxMessage{
+			// the same for each attempt at delivering the event
+			ID:       uuid.NewSHA1(event.ID, webhooks[i].ID[:]),
+			TaskType: TypeDeliverWebhook,
+			Payload:  tracing.InjectPayload(ctx, payload),
+			MaxRetry: deliveryMaxRetry,
+		})
+	}
+
+	if len(messages) == 0 {
+		return nil
+	}
+
+	return p.db.CreateOutboxMessages(messages...)
+}
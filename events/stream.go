@@ -0,0 +1,189 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+	"golang.org/x/exp/slog"
+)
+
+const (
+	// historyLength and historyTTL bound the events kept per user to resume a stream.
+	historyLength = 100
+	historyTTL    = 24 * time.Hour
+)
+
+var streamIDPattern = regexp.MustCompile(`^\d+-\d+$`)
+
+// Message is an event as sent through the stream. ID orders the messages of a user and is used to
+// resume the stream after it.
+type Message struct {
+	ID    string `json:"id"`
+	Event Event  `json:"event"`
+}
+
+// Stream fans the events of each user out through Redis pub/sub, keeping a short history of them
+// in a Redis stream so clients can resume after reconnecting.
+type Stream struct {
+	client    *redis.Client
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+func NewStream(client *redis.Client) *Stream {
+	return &Stream{client: client, done: make(chan struct{})}
+}
+
+// Close ends the subscriptions, open and future ones, so the requests streaming them return when the server
+// shuts down.
+func (s *Stream) Close() {
+	s.closeOnce.Do(func() { close(s.done) })
+}
+
+func historyKey(userID uuid.UUID) string {
+	return "events:" + userID.String() + ":history"
+}
+
+func channel(userID uuid.UUID) string {
+	return "events:" + userID.String()
+}
+
+func (s *Stream) Publish(ctx context.Context, userID uuid.UUID, event *Event) error {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	id, err := s.client.XAdd(ctx, &redis.XAddArgs{
+		Stream: historyKey(userID),
+		MaxLen: historyLength,
+		Approx: true,
+		Values: map[string]interface{}{"event": data},
+	}).Result()
+	if err != nil {
+		return err
+	}
+
+	if err = s.client.Expire(ctx, historyKey(userID), historyTTL).Err(); err != nil {
+		return err
+	}
+
+	message, err := json.Marshal(Message{id, *event})
+	if err != nil {
+		return err
+	}
+
+	return s.client.Publish(ctx, channel(userID), message).Err()
+}
+
+// Subscribe streams the events of the user until the context is done or the stream is closed. When lastID is a valid message
+// ID, the events still in the history after it are sent first.
+func (s *Stream) Subscribe(ctx context.Context, userID uuid.UUID, lastID string) (<-chan Message, error) {
+	pubsub := s.client.Subscribe(ctx, channel(userID))
+	// wait for the subscription, so no event is lost between reading the history and listening
+	if _, err := pubsub.Receive(ctx); err != nil {
+		_ = pubsub.Close()
+		return nil, err
+	}
+
+	var history []Message
+	if streamIDPattern.MatchString(lastID) {
+		entries, err := s.client.XRange(ctx, historyKey(userID), "("+lastID, "+").Result()
+		if err != nil {
+			_ = pubsub.Close()
+			return nil, err
+		}
+
+		for _, entry := range entries {
+			var event Event
+			data, _ := entry.Values["event"].(string)
+			if err = json.Unmarshal([]byte(data), &event); err != nil {
+				slog.ErrorContext(ctx, "error reading event history", "err", err)
+				continue
+			}
+			history = append(history, Message{entry.ID, event})
+		}
+	} else {
+		lastID = ""
+	}
+
+	messages := make(chan Message)
+	go func() {
+		defer close(messages)
+		defer pubsub.Close()
+
+		for _, message := range history {
+			select {
+			case messages <- message:
+				lastID = message.ID
+			case <-ctx.Done():
+				return
+			case <-s.done:
+				return
+			}
+		}
+
+		live := pubsub.Channel()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-s.done:
+				return
+			case m, ok := <-live:
+				if !ok {
+					return
+				}
+
+				var message Message
+				if err := json.Unmarshal([]byte(m.Payload), &message); err != nil {
+					slog.ErrorContext(ctx, "error reading event", "err", err)
+					continue
+				}
+
+				// already sent from the history
+				if len(lastID) > 0 && !isAfter(message.ID, lastID) {
+					continue
+				}
+
+				select {
+				case messages <- message:
+					lastID = message.ID
+				case <-ctx.Done():
+					return
+				case <-s.done:
+					return
+				}
+			}
+		}
+	}()
+
+	return messages, nil
+}
+
+// isAfter compares two Redis stream IDs, formatted as "milliseconds-sequence".
+func isAfter(id string, other string) bool {
+	ms, seq := parseStreamID(id)
+	otherMs, otherSeq := parseStreamID(other)
+	if ms != otherMs {
+		return ms > otherMs
+	}
+	return seq > otherSeq
+}
+
+func parseStreamID(id string) (uint64, uint64) {
+	parts := strings.SplitN(id, "-", 2)
+	ms, _ := strconv.ParseUint(parts[0], 10, 64)
+	var seq uint64
+	if len(parts) == 2 {
+		seq, _ = strconv.ParseUint(parts[1], 10, 64)
+	}
+	return ms, seq
+}
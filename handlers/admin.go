@@ -0,0 +1,589 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"nft/audit"
+	"nft/db"
+	"nft/models"
+	"nft/tasks"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/render"
+	"github.com/google/uuid"
+	"github.com/hibiken/asynq"
+	"golang.org/x/exp/slog"
+)
+
+const (
+	defaultPageSize = 50
+	maxPageSize     = 500
+)
+
+// AdminHandler serves the platform operator endpoints. Every action, listings included, is described
+// to be recorded as an audit event.
+type AdminHandler struct {
+	db          *db.DB
+	inspector   *asynq.Inspector
+	asynqClient *asynq.Client
+}
+
+func NewAdminHandler(db *db.DB, inspector *asynq.Inspector, asynqClient *asynq.Client) *AdminHandler {
+	return &AdminHandler{db, inspector, asynqClient}
+}
+
+func (h *AdminHandler) ListUsers(w http.ResponseWriter, r *http.Request) {
+	offset, limit, err := getPagination(r)
+	if err != nil {
+		err = render.Render(w, r, ErrInvalidRequest(err))
+		if err != nil {
+			slog.ErrorContext(r.Context(), "error rendering response", "err", err)
+		}
+		return
+	}
+
+	users, err := h.db.WithContext(r.Context()).ListUsers(offset, limit)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "error listing users", "err", err)
+		err = render.Render(w, r, ErrServer(err))
+		if err != nil {
+			slog.ErrorContext(r.Context(), "error rendering response", "err", err)
+		}
+		return
+	}
+
+	audit.Describe(r.Context(), "users.list", "", "")
+
+	list := make([]render.Renderer, 0, len(users))
+	for i := range users {
+		list = append(list, NewAdminUserResponse(&users[i]))
+	}
+
+	err = render.RenderList(w, r, list)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "error rendering response", "err", err)
+	}
+}
+
+func (h *AdminHandler) DisableUser(w http.ResponseWriter, r *http.Request) {
+	userID, err := uuid.Parse(chi.URLParam(r, "userID"))
+	if err != nil {
+		err = render.Render(w, r, ErrInvalidRequest(err))
+		if err != nil {
+			slog.ErrorContext(r.Context(), "error rendering response", "err", err)
+		}
+		return
+	}
+	audit.Describe(r.Context(), "user.disable", "user", userID.String())
+
+	user, err := h.db.WithContext(r.Context()).GetUser(userID)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "error getting user", "err", err)
+		err = render.Render(w, r, ErrServer(err))
+		if err != nil {
+			slog.ErrorContext(r.Context(), "error rendering response", "err", err)
+		}
+		return
+	}
+
+	if user == nil {
+		err = render.Render(w, r, ErrNotFound)
+		if err != nil {
+			slog.ErrorContext(r.Context(), "error rendering response", "err", err)
+		}
+		return
+	}
+
+	err = h.db.WithContext(r.Context()).SetUserDisabled(userID, true)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "error disabling user", "err", err)
+		err = render.Render(w, r, ErrServer(err))
+		if err != nil {
+			slog.ErrorContext(r.Context(), "error rendering response", "err", err)
+		}
+		return
+	}
+
+	user.Disabled = true
+	err = render.Render(w, r, NewAdminUserResponse(user))
+	if err != nil {
+		slog.ErrorContext(r.Context(), "error rendering response", "err", err)
+	}
+}
+
+func (h *AdminHandler) HideCollection(w http.ResponseWriter, r *http.Request) {
+	collectionID, err := uuid.Parse(chi.URLParam(r, "collectionID"))
+	if err != nil {
+		err = render.Render(w, r, ErrInvalidRequest(err))
+		if err != nil {
+			slog.ErrorContext(r.Context(), "error rendering response", "err", err)
+		}
+		return
+	}
+	audit.Describe(r.Context(), "collection.hide", "collection", collectionID.String())
+
+	collection, err := h.db.WithContext(r.Context()).GetCollection(collectionID)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "error getting collection", "err", err)
+		err = render.Render(w, r, ErrServer(err))
+		if err != nil {
+			slog.ErrorContext(r.Context(), "error rendering response", "err", err)
+		}
+		return
+	}
+
+	if collection == nil {
+		err = render.Render(w, r, ErrNotFound)
+		if err != nil {
+			slog.ErrorContext(r.Context(), "error rendering response", "err", err)
+		}
+		return
+	}
+
+	err = h.db.WithContext(r.Context()).SetCollectionHidden(collectionID, true)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "error hiding collection", "err", err)
+		err = render.Render(w, r, ErrServer(err))
+		if err != nil {
+			slog.ErrorContext(r.Context(), "error rendering response", "err", err)
+		}
+		return
+	}
+
+	collection.Hidden = true
+	err = render.Render(w, r, NewAdminCollectionResponse(collection))
+	if err != nil {
+		slog.ErrorContext(r.Context(), "error rendering response", "err", err)
+	}
+}
+
+// ListPendingWithdrawals lists the withdrawals the platform has yet to complete on L1. The ones external
+// users complete themselves are not listed.
+func (h *AdminHandler) ListPendingWithdrawals(w http.ResponseWriter, r *http.Request) {
+	offset, limit, err := getPagination(r)
+	if err != nil {
+		err = render.Render(w, r, ErrInvalidRequest(err))
+		if err != nil {
+			slog.ErrorContext(r.Context(), "error rendering response", "err", err)
+		}
+		return
+	}
+
+	withdrawals, err := h.db.WithContext(r.Context()).ListPendingWithdrawals(offset, limit)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "error listing withdrawals", "err", err)
+		err = render.Render(w, r, ErrServer(err))
+		if err != nil {
+			slog.ErrorContext(r.Context(), "error rendering response", "err", err)
+		}
+		return
+	}
+
+	audit.Describe(r.Context(), "withdrawals.list_pending", "", "")
+
+	list := make([]render.Renderer, 0, len(withdrawals))
+	for i := range withdrawals {
+		list = append(list, NewAdminWithdrawalResponse(&withdrawals[i]))
+	}
+
+	err = render.RenderList(w, r, list)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "error rendering response", "err", err)
+	}
+}
+
+// ListFailedTasks lists the tasks that ran out of retries and were archived by asynq.
+func (h *AdminHandler) ListFailedTasks(w http.ResponseWriter, r *http.Request) {
+	infos, err := h.inspector.ListArchivedTasks(tasks.QueueDefault, asynq.PageSize(maxPageSize))
+	if err != nil {
+		slog.ErrorContext(r.Context(), "error listing tasks", "err", err)
+		err = render.Render(w, r, ErrServer(err))
+		if err != nil {
+			slog.ErrorContext(r.Context(), "error rendering response", "err", err)
+		}
+		return
+	}
+
+	audit.Describe(r.Context(), "tasks.list_failed", "", "")
+
+	list := make([]render.Renderer, 0, len(infos))
+	for _, info := range infos {
+		list = append(list, NewTaskResponse(info))
+	}
+
+	err = render.RenderList(w, r, list)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "error rendering response", "err", err)
+	}
+}
+
+func (h *AdminHandler) RequeueTask(w http.ResponseWriter, r *http.Request) {
+	taskID := chi.URLParam(r, "taskID")
+	audit.Describe(r.Context(), "task.requeue", "task", taskID)
+
+	info, err := h.inspector.GetTaskInfo(tasks.QueueDefault, taskID)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "error getting task", "err", err)
+		switch {
+		case errors.Is(err, asynq.ErrTaskNotFound):
+			err = render.Render(w, r, ErrNotFound)
+		default:
+			err = render.Render(w, r, ErrServer(err))
+		}
+		if err != nil {
+			slog.ErrorContext(r.Context(), "error rendering response", "err", err)
+		}
+		return
+	}
+
+	// a mint only runs for pending tokens, the token it failed must be pending again
+	var resetToken *uuid.UUID
+	if info.Type == tasks.TypeMintToken {
+		resetToken, err = h.resetFailedMint(r.Context(), info)
+		if err != nil {
+			slog.ErrorContext(r.Context(), "error resetting token", "err", err)
+			err = render.Render(w, r, ErrServer(err))
+			if err != nil {
+				slog.ErrorContext(r.Context(), "error rendering response", "err", err)
+			}
+			return
+		}
+	}
+
+	err = h.inspector.RunTask(tasks.QueueDefault, taskID)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "error requeuing task", "err", err)
+		if resetToken != nil {
+			if statusErr := h.db.WithContext(r.Context()).SetTokenStatus(*resetToken, models.TokenFailed); statusErr != nil {
+				slog.ErrorContext(r.Context(), "error restoring token status", "err", statusErr)
+			}
+		}
+		switch {
+		case errors.Is(err, asynq.ErrTaskNotFound):
+			err = render.Render(w, r, ErrNotFound)
+		default:
+			err = render.Render(w, r, ErrInvalidRequest(err))
+		}
+		if err != nil {
+			slog.ErrorContext(r.Context(), "error rendering response", "err", err)
+		}
+		return
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// resetFailedMint sets the token of a mint task back to pending if it failed. It returns the ID of the token
+// it reset, if any.
+func (h *AdminHandler) resetFailedMint(ctx context.Context, info *asynq.TaskInfo) (*uuid.UUID, error) {
+	var p tasks.MintTokenPayload
+	if err := json.Unmarshal(info.Payload, &p); err != nil {
+		return nil, err
+	}
+
+	reset, err := h.db.WithContext(ctx).ResetFailedToken(p.TokenID)
+	if err != nil || !reset {
+		return nil, err
+	}
+	return &p.TokenID, nil
+}
+
+// ListDiscrepancies returns the latest reconciliation report, optionally filtered by collection_id.
+func (h *AdminHandler) ListDiscrepancies(w http.ResponseWriter, r *http.Request) {
+	offset, limit, err := getPagination(r)
+	if err != nil {
+		err = render.Render(w, r, ErrInvalidRequest(err))
+		if err != nil {
+			slog.ErrorContext(r.Context(), "error rendering response", "err", err)
+		}
+		return
+	}
+
+	var collectionID *uuid.UUID
+	if value := r.URL.Query().Get("collection_id"); len(value) > 0 {
+		id, err := uuid.Parse(value)
+		if err != nil {
+			err = render.Render(w, r, ErrInvalidRequest(err))
+			if err != nil {
+				slog.ErrorContext(r.Context(), "error rendering response", "err", err)
+			}
+			return
+		}
+		collectionID = &id
+	}
+
+	discrepancies, err := h.db.WithContext(r.Context()).ListDiscrepancies(collectionID, offset, limit)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "error listing discrepancies", "err", err)
+		err = render.Render(w, r, ErrServer(err))
+		if err != nil {
+			slog.ErrorContext(r.Context(), "error rendering response", "err", err)
+		}
+		return
+	}
+
+	audit.Describe(r.Context(), "discrepancies.list", "", "")
+
+	list := make([]render.Renderer, 0, len(discrepancies))
+	for i := range discrepancies {
+		list = append(list, NewDiscrepancyResponse(&discrepancies[i]))
+	}
+
+	err = render.RenderList(w, r, list)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "error rendering response", "err", err)
+	}
+}
+
+// ListAuditEvents returns the audit events, most recent first, optionally filtered by actor_id, target_type,
+// target_id and the from and to RFC 3339 times.
+func (h *AdminHandler) ListAuditEvents(w http.ResponseWriter, r *http.Request) {
+	offset, limit, err := getPagination(r)
+	if err != nil {
+		err = render.Render(w, r, ErrInvalidRequest(err))
+		if err != nil {
+			slog.ErrorContext(r.Context(), "error rendering response", "err", err)
+		}
+		return
+	}
+
+	filter, err := getAuditFilter(r)
+	if err != nil {
+		err = render.Render(w, r, ErrInvalidRequest(err))
+		if err != nil {
+			slog.ErrorContext(r.Context(), "error rendering response", "err", err)
+		}
+		return
+	}
+
+	events, err := h.db.WithContext(r.Context()).ListAuditEvents(filter, offset, limit)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "error listing audit events", "err", err)
+		err = render.Render(w, r, ErrServer(err))
+		if err != nil {
+			slog.ErrorContext(r.Context(), "error rendering response", "err", err)
+		}
+		return
+	}
+
+	audit.Describe(r.Context(), "audit.list", "", "")
+
+	list := make([]render.Renderer, 0, len(events))
+	for i := range events {
+		list = append(list, NewAuditEventResponse(&events[i]))
+	}
+
+	err = render.RenderList(w, r, list)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "error rendering response", "err", err)
+	}
+}
+
+// ReconcileCollection starts the reconciliation of a collection without waiting for the schedule.
+func (h *AdminHandler) ReconcileCollection(w http.ResponseWriter, r *http.Request) {
+	collectionID, err := uuid.Parse(chi.URLParam(r, "collectionID"))
+	if err != nil {
+		err = render.Render(w, r, ErrInvalidRequest(err))
+		if err != nil {
+			slog.ErrorContext(r.Context(), "error rendering response", "err", err)
+		}
+		return
+	}
+	audit.Describe(r.Context(), "collection.reconcile", "collection", collectionID.String())
+
+	collection, err := h.db.WithContext(r.Context()).GetCollection(collectionID)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "error getting collection", "err", err)
+		err = render.Render(w, r, ErrServer(err))
+		if err != nil {
+			slog.ErrorContext(r.Context(), "error rendering response", "err", err)
+		}
+		return
+	}
+
+	if collection == nil {
+		err = render.Render(w, r, ErrNotFound)
+		if err != nil {
+			slog.ErrorContext(r.Context(), "error rendering response", "err", err)
+		}
+		return
+	}
+
+	task, err := tasks.NewReconcileCollectionTask(collectionID)
+	if err != nil {
+		err = render.Render(w, r, ErrServer(err))
+		if err != nil {
+			slog.ErrorContext(r.Context(), "error rendering response", "err", err)
+		}
+		return
+	}
+
+	_, err = h.asynqClient.Enqueue(task)
+	if err != nil && !errors.Is(err, asynq.ErrDuplicateTask) {
+		slog.ErrorContext(r.Context(), "error enqueuing reconciliation", "err", err)
+		err = render.Render(w, r, ErrServer(err))
+		if err != nil {
+			slog.ErrorContext(r.Context(), "error rendering response", "err", err)
+		}
+		return
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+}
+
+func getPagination(r *http.Request) (int, int, error) {
+	offset, limit := 0, defaultPageSize
+	var err error
+
+	if value := r.URL.Query().Get("offset"); len(value) > 0 {
+		offset, err = strconv.Atoi(value)
+		if err != nil || offset < 0 {
+			return 0, 0, errors.New("invalid offset")
+		}
+	}
+
+	if value := r.URL.Query().Get("limit"); len(value) > 0 {
+		limit, err = strconv.Atoi(value)
+		if err != nil || limit <= 0 || limit > maxPageSize {
+			return 0, 0, errors.New("invalid limit")
+		}
+	}
+
+	return offset, limit, nil
+}
+
+func getAuditFilter(r *http.Request) (db.AuditFilter, error) {
+	query := r.URL.Query()
+	filter := db.AuditFilter{
+		TargetType: query.Get("target_type"),
+		TargetID:   query.Get("target_id"),
+	}
+
+	if value := query.Get("actor_id"); len(value) > 0 {
+		actorID, err := uuid.Parse(value)
+		if err != nil {
+			return filter, errors.New("invalid actor_id")
+		}
+		filter.ActorID = &actorID
+	}
+
+	if value := query.Get("from"); len(value) > 0 {
+		from, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			return filter, errors.New("invalid from")
+		}
+		filter.From = from.UnixMilli()
+	}
+
+	if value := query.Get("to"); len(value) > 0 {
+		to, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			return filter, errors.New("invalid to")
+		}
+		filter.To = to.UnixMilli()
+	}
+
+	return filter, nil
+}
+
+type AdminUserResponse struct {
+	*models.User
+	// api keys are credentials and must not leak through the admin listing
+	ApiKey   string `json:"api_key,omitempty"`
+	Admin    bool   `json:"admin"`
+	Disabled bool   `json:"disabled"`
+}
+
+func NewAdminUserResponse(user *models.User) *AdminUserResponse {
+	resp := &AdminUserResponse{User: user, Admin: user.Admin, Disabled: user.Disabled}
+	return resp
+}
+
+func (rd *AdminUserResponse) Render(w http.ResponseWriter, r *http.Request) error {
+	return nil
+}
+
+type AdminCollectionResponse struct {
+	*models.Collection
+}
+
+func NewAdminCollectionResponse(collection *models.Collection) *AdminCollectionResponse {
+	resp := &AdminCollectionResponse{Collection: collection}
+	return resp
+}
+
+func (rd *AdminCollectionResponse) Render(w http.ResponseWriter, r *http.Request) error {
+	return nil
+}
+
+type AdminWithdrawalResponse struct {
+	*models.Withdrawal
+}
+
+func NewAdminWithdrawalResponse(withdrawal *models.Withdrawal) *AdminWithdrawalResponse {
+	resp := &AdminWithdrawalResponse{Withdrawal: withdrawal}
+	return resp
+}
+
+func (rd *AdminWithdrawalResponse) Render(w http.ResponseWriter, r *http.Request) error {
+	return nil
+}
+
+type DiscrepancyResponse struct {
+	*models.Discrepancy
+}
+
+func NewDiscrepancyResponse(discrepancy *models.Discrepancy) *DiscrepancyResponse {
+	resp := &DiscrepancyResponse{Discrepancy: discrepancy}
+	return resp
+}
+
+func (rd *DiscrepancyResponse) Render(w http.ResponseWriter, r *http.Request) error {
+	return nil
+}
+
+type AuditEventResponse struct {
+	*models.AuditEvent
+}
+
+func NewAuditEventResponse(event *models.AuditEvent) *AuditEventResponse {
+	resp := &AuditEventResponse{AuditEvent: event}
+	return resp
+}
+
+func (rd *AuditEventResponse) Render(w http.ResponseWriter, r *http.Request) error {
+	return nil
+}
+
+type TaskResponse struct {
+	ID            string          `json:"id"`
+	Type          string          `json:"type"`
+	State         string          `json:"state"`
+	Payload       json.RawMessage `json:"payload"`
+	Retried       int             `json:"retried"`
+	LastError     string          `json:"last_error,omitempty"`
+	NextProcessAt *time.Time      `json:"next_process_at,omitempty"`
+}
+
+func NewTaskResponse(info *asynq.TaskInfo) *TaskResponse {
+	resp := &TaskResponse{
+		ID:        info.ID,
+		Type:      info.Type,
+		State:     info.State.String(),
+		Payload:   info.Payload,
+		Retried:   info.Retried,
+		LastError: info.LastErr,
+	}
+	if !info.NextProcessAt.IsZero() {
+		resp.NextProcessAt = &info.NextProcessAt
+	}
+	return resp
+}
+
+func (rd *TaskResponse) Render(w http.ResponseWriter, r *http.Request) error {
+	return nil
+}
@@ -3,13 +3,14 @@ package handlers
 import (
 	"errors"
 	"net/http"
+	"nft/audit"
+	"nft/auth"
 	"nft/imx"
 	"nft/models"
 
-	"github.com/ethereum/go-ethereum/log"
-	"github.com/go-chi/oauth"
 	"github.com/go-chi/render"
 	"github.com/google/uuid"
+	"golang.org/x/exp/slog"
 )
 
 func (h *Handler) CreateCollection(w http.ResponseWriter, r *http.Request) {
@@ -17,7 +18,49 @@ func (h *Handler) CreateCollection(w http.ResponseWriter, r *http.Request) {
 	if err := render.Bind(r, data); err != nil {
 		err = render.Render(w, r, ErrInvalidRequest(err))
 		if err != nil {
-			log.Error("error rendering response", err)
+			slog.ErrorContext(r.Context(), "error rendering response", "err", err)
+		}
+		return
+	}
+
+	userID, err := auth.GetUserID(r.Context())
+	if err != nil {
+		slog.ErrorContext(r.Context(), "error parsing user", "err", err)
+		err = render.Render(w, r, ErrUnauthorized(err))
+		if err != nil {
+			slog.ErrorContext(r.Context(), "error rendering response", "err", err)
+		}
+		return
+	}
+
+	organizationID, err := uuid.Parse(data.OrganizationID)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "error parsing organization", "err", err)
+		err = render.Render(w, r, ErrInvalidRequest(err))
+		if err != nil {
+			slog.ErrorContext(r.Context(), "error rendering response", "err", err)
+		}
+		return
+	}
+	audit.Describe(r.Context(), "collection.create", "organization", organizationID.String())
+	audit.SetIMXID(r.Context(), data.ContractAddress)
+
+	allowed, err := h.hasRole(r.Context(), organizationID, userID, models.RoleOwner)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "error getting organization member", "err", err)
+		err = render.Render(w, r, ErrServer(err))
+		if err != nil {
+			slog.ErrorContext(r.Context(), "error rendering response", "err", err)
+		}
+		return
+	}
+
+	if !allowed {
+		err = errors.New("invalid organization")
+		slog.ErrorContext(r.Context(), "invalid organization", "err", err)
+		err = render.Render(w, r, ErrForbidden(err))
+		if err != nil {
+			slog.ErrorContext(r.Context(), "error rendering response", "err", err)
 		}
 		return
 	}
@@ -28,12 +71,12 @@ func (h *Handler) CreateCollection(w http.ResponseWriter, r *http.Request) {
 		MetadataUrl:     data.MetadataUrl,
 	}
 
-	err := h.imx.CreateCollection(r.Context(), &info)
+	err = h.imx.CreateCollection(r.Context(), &info)
 	if err != nil {
-		log.Error("error creating collection", err)
-		err = render.Render(w, r, ErrInvalidRequest(err))
+		slog.ErrorContext(r.Context(), "error creating collection", "err", err)
+		err = render.Render(w, r, ErrIMX(err, ErrInvalidRequest))
 		if err != nil {
-			log.Error("error rendering response", err)
+			slog.ErrorContext(r.Context(), "error rendering response", "err", err)
 		}
 		return
 	}
@@ -49,20 +92,10 @@ func (h *Handler) CreateCollection(w http.ResponseWriter, r *http.Request) {
 
 	err = h.imx.CreateMetadata(r.Context(), &metadataInfo)
 	if err != nil {
-		log.Error("error creating metadata", err)
-		err = render.Render(w, r, ErrInvalidRequest(err))
-		if err != nil {
-			log.Error("error rendering response", err)
-		}
-		return
-	}
-
-	userID, err := uuid.Parse(r.Context().Value(oauth.CredentialContext).(string))
-	if err != nil {
-		log.Error("error parsing user", err)
-		err = render.Render(w, r, ErrInvalidRequest(err))
+		slog.ErrorContext(r.Context(), "error creating metadata", "err", err)
+		err = render.Render(w, r, ErrIMX(err, ErrInvalidRequest))
 		if err != nil {
-			log.Error("error rendering response", err)
+			slog.ErrorContext(r.Context(), "error rendering response", "err", err)
 		}
 		return
 	}
@@ -70,14 +103,17 @@ func (h *Handler) CreateCollection(w http.ResponseWriter, r *http.Request) {
 	collection := models.Collection{
 		ID:              uuid.New(),
 		UserID:          userID,
+		OrganizationID:  organizationID,
 		ContractAddress: data.ContractAddress,
 	}
-	err = h.db.CreateCollection(&collection)
+	audit.Describe(r.Context(), "collection.create", "collection", collection.ID.String())
+
+	err = h.db.WithContext(r.Context()).CreateCollection(&collection)
 	if err != nil {
-		log.Error("error saving collection", err)
+		slog.ErrorContext(r.Context(), "error saving collection", "err", err)
 		err = render.Render(w, r, ErrInvalidRequest(err))
 		if err != nil {
-			log.Error("error rendering response", err)
+			slog.ErrorContext(r.Context(), "error rendering response", "err", err)
 		}
 		return
 	}
@@ -85,11 +121,12 @@ func (h *Handler) CreateCollection(w http.ResponseWriter, r *http.Request) {
 	render.Status(r, http.StatusCreated)
 	err = render.Render(w, r, NewCollectionResponse(collection.ID.String(), data.CollectionName, data.ContractAddress))
 	if err != nil {
-		log.Error("error rendering response", err)
+		slog.ErrorContext(r.Context(), "error rendering response", "err", err)
 	}
 }
 
 type CollectionRequest struct {
+	OrganizationID  string                   `json:"organization_id"`
 	ContractAddress string                   `json:"contract_address"`
 	CollectionName  string                   `json:"collection_name"`
 	MetadataUrl     string                   `json:"metadata_url"`
@@ -102,6 +139,10 @@ type CollectionFieldRequest struct {
 }
 
 func (a *CollectionRequest) Bind(r *http.Request) error {
+	if len(a.OrganizationID) == 0 {
+		return errors.New("missing required fields")
+	}
+
 	if len(a.ContractAddress) == 0 {
 		return errors.New("missing required fields")
 	}
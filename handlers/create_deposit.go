@@ -1,14 +1,17 @@
 package handlers
 
 import (
+	"context"
 	"errors"
 	"net/http"
+	"nft/audit"
+	"nft/auth"
 	"nft/imx"
+	"nft/tasks"
 
-	"github.com/ethereum/go-ethereum/log"
-	"github.com/go-chi/oauth"
 	"github.com/go-chi/render"
 	"github.com/google/uuid"
+	"golang.org/x/exp/slog"
 )
 
 func (h *Handler) CreateDeposit(w http.ResponseWriter, r *http.Request) {
@@ -16,31 +19,52 @@ func (h *Handler) CreateDeposit(w http.ResponseWriter, r *http.Request) {
 	if err := render.Bind(r, data); err != nil {
 		err = render.Render(w, r, ErrInvalidRequest(err))
 		if err != nil {
-			log.Error("error rendering response", err)
+			slog.ErrorContext(r.Context(), "error rendering response", "err", err)
 		}
 		return
 	}
 
-	userID, err := uuid.Parse(r.Context().Value(oauth.CredentialContext).(string))
+	userID, err := auth.GetUserID(r.Context())
 	if err != nil {
-		log.Error("error parsing user", err)
-		err = render.Render(w, r, ErrInvalidRequest(err))
+		slog.ErrorContext(r.Context(), "error parsing user", "err", err)
+		err = render.Render(w, r, ErrUnauthorized(err))
 		if err != nil {
-			log.Error("error rendering response", err)
+			slog.ErrorContext(r.Context(), "error rendering response", "err", err)
 		}
 		return
 	}
 
-	user, err := h.db.GetUser(userID)
+	user, err := h.db.WithContext(r.Context()).GetUser(userID)
 	if err != nil {
-		log.Error("error getting user", err)
+		slog.ErrorContext(r.Context(), "error getting user", "err", err)
+		err = render.Render(w, r, ErrInvalidRequest(err))
+		if err != nil {
+			slog.ErrorContext(r.Context(), "error rendering response", "err", err)
+		}
+		return
+	}
+
+	if user == nil {
+		err = errors.New("user missing")
+		slog.ErrorContext(r.Context(), "error getting user", "err", err)
+		err = render.Render(w, r, ErrInvalidRequest(err))
+		if err != nil {
+			slog.ErrorContext(r.Context(), "error rendering response", "err", err)
+		}
+		return
+	}
+
+	if user.External {
+		err = errors.New("deposits must be sent from the external wallet")
 		err = render.Render(w, r, ErrInvalidRequest(err))
 		if err != nil {
-			log.Error("error rendering response", err)
+			slog.ErrorContext(r.Context(), "error rendering response", "err", err)
 		}
 		return
 	}
 
+	audit.Describe(r.Context(), "deposit.create", "user", user.ID.String())
+
 	info := imx.CreateDepositInformation{
 		AmountWei: data.AmountWei,
 		User:      user,
@@ -48,18 +72,37 @@ func (h *Handler) CreateDeposit(w http.ResponseWriter, r *http.Request) {
 
 	hash, err := h.imx.CreateEthDeposit(r.Context(), &info)
 	if err != nil {
-		log.Error("error creating deposit", err)
-		err = render.Render(w, r, ErrInvalidRequest(err))
+		slog.ErrorContext(r.Context(), "error creating deposit", "err", err)
+		err = render.Render(w, r, ErrIMX(err, ErrInvalidRequest))
 		if err != nil {
-			log.Error("error rendering response", err)
+			slog.ErrorContext(r.Context(), "error rendering response", "err", err)
 		}
 		return
 	}
 
+	audit.Describe(r.Context(), "deposit.create", "deposit", hash)
+	audit.SetIMXID(r.Context(), hash)
+
+	// the deposit was already sent, failing to track it only loses its confirmation event
+	h.confirmDeposit(r.Context(), hash, data.AmountWei, user.ID)
+
 	render.Status(r, http.StatusCreated)
 	err = render.Render(w, r, NewDepositResponse(hash))
 	if err != nil {
-		log.Error("error rendering response", err)
+		slog.ErrorContext(r.Context(), "error rendering response", "err", err)
+	}
+}
+
+func (h *Handler) confirmDeposit(ctx context.Context, hash string, amountWei string, userID uuid.UUID) {
+	confirmMessage, err := tasks.NewConfirmDepositMessage(ctx, hash, amountWei, userID)
+	if err != nil {
+		slog.ErrorContext(ctx, "error creating deposit confirmation", "err", err)
+		return
+	}
+
+	err = h.db.WithContext(ctx).CreateOutboxMessages(confirmMessage)
+	if err != nil {
+		slog.ErrorContext(ctx, "error saving deposit confirmation", "err", err)
 	}
 }
 
@@ -0,0 +1,130 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"nft/audit"
+	"nft/keys"
+	"nft/models"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/go-chi/render"
+	"github.com/google/uuid"
+	"golang.org/x/exp/slog"
+)
+
+// CreateExternalUser registers a user that brings their own wallet. The server never holds their
+// private keys, so the user must already be registered on IMX with the given address and stark key, and
+// proves they own the address by signing a challenge from CreateUserChallenge.
+func (h *Handler) CreateExternalUser(w http.ResponseWriter, r *http.Request) {
+	data := &ExternalUserRequest{}
+	if err := render.Bind(r, data); err != nil {
+		err = render.Render(w, r, ErrInvalidRequest(err))
+		if err != nil {
+			slog.ErrorContext(r.Context(), "error rendering response", "err", err)
+		}
+		return
+	}
+
+	// consumed whatever the outcome, a failed attempt needs a new challenge
+	challenge, err := h.db.WithContext(r.Context()).ConsumeUserChallenge(data.ChallengeID)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "error getting user challenge", "err", err)
+		err = render.Render(w, r, ErrServer(err))
+		if err != nil {
+			slog.ErrorContext(r.Context(), "error rendering response", "err", err)
+		}
+		return
+	}
+
+	if !validChallenge(challenge, data) {
+		err = render.Render(w, r, ErrUnauthorized(errors.New("invalid challenge signature")))
+		if err != nil {
+			slog.ErrorContext(r.Context(), "error rendering response", "err", err)
+		}
+		return
+	}
+
+	u, err := h.db.WithContext(r.Context()).GetUserByMail(data.Mail)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "error getting user", "err", err)
+		err = render.Render(w, r, ErrServer(err))
+		if err != nil {
+			slog.ErrorContext(r.Context(), "error rendering response", "err", err)
+		}
+		return
+	}
+
+	if u != nil {
+		err = render.Render(w, r, ErrInvalidRequest(errors.New("user already exists")))
+		if err != nil {
+			slog.ErrorContext(r.Context(), "error rendering response", "err", err)
+		}
+		return
+	}
+
+	user := models.User{}
+	user.ID = uuid.New()
+	user.ApiKey = uuid.NewString()
+	user.Mail = data.Mail
+	user.Address = common.HexToAddress(data.Address).Hex()
+	user.StarkPublicKey = data.StarkKey
+	user.External = true
+	audit.Describe(r.Context(), "user.create_external", "user", user.ID.String())
+	audit.SetActor(r.Context(), user.ID)
+
+	err = h.db.WithContext(r.Context()).CreateUser(&user)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "error saving user", "err", err)
+		err = render.Render(w, r, ErrServer(err))
+		if err != nil {
+			slog.ErrorContext(r.Context(), "error rendering response", "err", err)
+		}
+		return
+	}
+
+	render.Status(r, http.StatusCreated)
+	err = render.Render(w, r, NewUserResponse(&user))
+	if err != nil {
+		slog.ErrorContext(r.Context(), "error rendering response", "err", err)
+	}
+}
+
+// validChallenge checks the challenge was issued for the address, is not expired and is signed by it.
+func validChallenge(challenge *models.UserChallenge, data *ExternalUserRequest) bool {
+	if challenge == nil || time.Now().UnixMilli() > challenge.ExpiresAt {
+		return false
+	}
+
+	if common.HexToAddress(challenge.Address) != common.HexToAddress(data.Address) {
+		return false
+	}
+
+	return keys.VerifySignature(data.Address, challenge.Message, data.Signature)
+}
+
+type ExternalUserRequest struct {
+	Mail        string    `json:"mail"`
+	Address     string    `json:"address"`
+	StarkKey    string    `json:"stark_key"`
+	ChallengeID uuid.UUID `json:"challenge_id"`
+	// Signature is the personal_sign signature of the challenge message by the address.
+	Signature string `json:"signature"`
+}
+
+func (a *ExternalUserRequest) Bind(r *http.Request) error {
+	if len(a.Mail) == 0 {
+		return errors.New("missing required fields")
+	}
+
+	if len(a.StarkKey) == 0 || a.ChallengeID == uuid.Nil || len(a.Signature) == 0 {
+		return errors.New("missing required fields")
+	}
+
+	if !common.IsHexAddress(a.Address) {
+		return errors.New("invalid address")
+	}
+
+	return nil
+}
@@ -3,13 +3,16 @@ package handlers
 import (
 	"errors"
 	"net/http"
+	"nft/audit"
+	"nft/auth"
+	"nft/events"
 	"nft/imx"
+	"nft/models"
 	"strconv"
 
-	"github.com/ethereum/go-ethereum/log"
-	"github.com/go-chi/oauth"
 	"github.com/go-chi/render"
 	"github.com/google/uuid"
+	"golang.org/x/exp/slog"
 )
 
 func (h *Handler) CreateOrder(w http.ResponseWriter, r *http.Request) {
@@ -17,131 +20,176 @@ func (h *Handler) CreateOrder(w http.ResponseWriter, r *http.Request) {
 	if err := render.Bind(r, data); err != nil {
 		err = render.Render(w, r, ErrInvalidRequest(err))
 		if err != nil {
-			log.Error("error rendering response", err)
+			slog.ErrorContext(r.Context(), "error rendering response", "err", err)
 		}
 		return
 	}
 
 	amount, err := strconv.ParseUint(data.Amount, 10, 64)
 	if err != nil {
-		log.Error("error creating order", err)
+		slog.ErrorContext(r.Context(), "error creating order", "err", err)
 		err = render.Render(w, r, ErrInvalidRequest(err))
 		if err != nil {
-			log.Error("error rendering response", err)
+			slog.ErrorContext(r.Context(), "error rendering response", "err", err)
 		}
 		return
 	}
 
 	collectionID, err := uuid.Parse(data.CollectionID)
 	if err != nil {
-		log.Error("error parsing collection", err)
+		slog.ErrorContext(r.Context(), "error parsing collection", "err", err)
 		err = render.Render(w, r, ErrInvalidRequest(err))
 		if err != nil {
-			log.Error("error rendering response", err)
+			slog.ErrorContext(r.Context(), "error rendering response", "err", err)
 		}
 		return
 	}
 
-	collection, err := h.db.GetCollection(collectionID)
+	collection, err := h.db.WithContext(r.Context()).GetCollection(collectionID)
 	if err != nil {
-		log.Error("error getting collection", err)
+		slog.ErrorContext(r.Context(), "error getting collection", "err", err)
 		err = render.Render(w, r, ErrInvalidRequest(err))
 		if err != nil {
-			log.Error("error rendering response", err)
+			slog.ErrorContext(r.Context(), "error rendering response", "err", err)
 		}
 		return
 	}
 
-	if collection == nil {
+	if collection == nil || collection.Hidden {
 		err = errors.New("collection missing")
-		log.Error("error getting collection", err)
+		slog.ErrorContext(r.Context(), "error getting collection", "err", err)
 		err = render.Render(w, r, ErrInvalidRequest(err))
 		if err != nil {
-			log.Error("error rendering response", err)
+			slog.ErrorContext(r.Context(), "error rendering response", "err", err)
 		}
 		return
 	}
 
-	userID, err := uuid.Parse(r.Context().Value(oauth.CredentialContext).(string))
+	userID, err := auth.GetUserID(r.Context())
 	if err != nil {
-		log.Error("error parsing user", err)
-		err = render.Render(w, r, ErrInvalidRequest(err))
+		slog.ErrorContext(r.Context(), "error parsing user", "err", err)
+		err = render.Render(w, r, ErrUnauthorized(err))
+		if err != nil {
+			slog.ErrorContext(r.Context(), "error rendering response", "err", err)
+		}
+		return
+	}
+
+	allowed, err := h.hasRole(r.Context(), collection.OrganizationID, userID, models.RoleMinter)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "error getting organization member", "err", err)
+		err = render.Render(w, r, ErrServer(err))
 		if err != nil {
-			log.Error("error rendering response", err)
+			slog.ErrorContext(r.Context(), "error rendering response", "err", err)
 		}
 		return
 	}
 
-	if collection.UserID != userID {
+	if !allowed {
 		err = errors.New("invalid collection")
-		log.Error("invalid collection", err)
-		err = render.Render(w, r, ErrInvalidRequest(err))
+		slog.ErrorContext(r.Context(), "invalid collection", "err", err)
+		err = render.Render(w, r, ErrForbidden(err))
 		if err != nil {
-			log.Error("error rendering response", err)
+			slog.ErrorContext(r.Context(), "error rendering response", "err", err)
 		}
 		return
 	}
 
 	tokenID, err := uuid.Parse(data.TokenID)
 	if err != nil {
-		log.Error("error parsing token", err)
+		slog.ErrorContext(r.Context(), "error parsing token", "err", err)
 		err = render.Render(w, r, ErrInvalidRequest(err))
 		if err != nil {
-			log.Error("error rendering response", err)
+			slog.ErrorContext(r.Context(), "error rendering response", "err", err)
 		}
 		return
 	}
+	audit.Describe(r.Context(), "order.create", "token", tokenID.String())
 
-	token, err := h.db.GetToken(tokenID)
+	token, err := h.db.WithContext(r.Context()).GetToken(tokenID)
 	if err != nil {
-		log.Error("error getting token", err)
+		slog.ErrorContext(r.Context(), "error getting token", "err", err)
 		err = render.Render(w, r, ErrInvalidRequest(err))
 		if err != nil {
-			log.Error("error rendering response", err)
+			slog.ErrorContext(r.Context(), "error rendering response", "err", err)
 		}
 		return
 	}
 
 	if token == nil {
 		err = errors.New("token missing")
-		log.Error("error getting token", err)
+		slog.ErrorContext(r.Context(), "error getting token", "err", err)
 		err = render.Render(w, r, ErrInvalidRequest(err))
 		if err != nil {
-			log.Error("error rendering response", err)
+			slog.ErrorContext(r.Context(), "error rendering response", "err", err)
 		}
 		return
 	}
 
 	if token.CollectionID != collectionID {
 		err = errors.New("invalid token")
-		log.Error("invalid token", err)
+		slog.ErrorContext(r.Context(), "invalid token", "err", err)
 		err = render.Render(w, r, ErrInvalidRequest(err))
 		if err != nil {
-			log.Error("error rendering response", err)
+			slog.ErrorContext(r.Context(), "error rendering response", "err", err)
+		}
+		return
+	}
+
+	if token.Status != models.TokenMinted {
+		err = errors.New("token not minted")
+		slog.ErrorContext(r.Context(), "token not minted", "err", err, "status", token.Status)
+		err = render.Render(w, r, ErrConflict(err))
+		if err != nil {
+			slog.ErrorContext(r.Context(), "error rendering response", "err", err)
 		}
 		return
 	}
 
 	info := imx.OrderInformation{
 		ContractAddress: collection.ContractAddress,
-		TokenID:         data.TokenID,
+		TokenID:         token.TokenID,
 		Amount:          amount,
 	}
 
 	orderID, err := h.imx.CreateOrder(r.Context(), &info)
 	if err != nil {
-		log.Error("error creating order", err)
-		err = render.Render(w, r, ErrInvalidRequest(err))
+		slog.ErrorContext(r.Context(), "error creating order", "err", err)
+		err = render.Render(w, r, ErrIMX(err, ErrInvalidRequest))
 		if err != nil {
-			log.Error("error rendering response", err)
+			slog.ErrorContext(r.Context(), "error rendering response", "err", err)
 		}
 		return
 	}
+	audit.SetIMXID(r.Context(), strconv.FormatInt(int64(orderID), 10))
+
+	order := models.Order{
+		ID:           uuid.New(),
+		CollectionID: collectionID,
+		TokenID:      tokenID,
+		OrderID:      orderID,
+		Amount:       data.Amount,
+		Status:       models.OrderActive,
+		UserID:       userID,
+	}
+
+	messages := h.event(r.Context(), userID, events.OrderCreated, events.Order{
+		OrderID:      orderID,
+		CollectionID: data.CollectionID,
+		TokenID:      data.TokenID,
+		Amount:       data.Amount,
+	})
+
+	err = h.db.WithContext(r.Context()).CreateOrder(&order, messages...)
+	if err != nil {
+		// the order is listed on IMX, reconciliation reports it as missing locally
+		slog.ErrorContext(r.Context(), "error saving order", "err", err)
+	}
 
 	render.Status(r, http.StatusCreated)
 	err = render.Render(w, r, NewOrderResponse(orderID))
 	if err != nil {
-		log.Error("error rendering response", err)
+		slog.ErrorContext(r.Context(), "error rendering response", "err", err)
 	}
 }
 
@@ -168,7 +216,7 @@ type OrderResponse struct {
 }
 
 func NewOrderResponse(id int32) *OrderResponse {
-	resp := &OrderResponse{OrderID: string(id)}
+	resp := &OrderResponse{OrderID: strconv.FormatInt(int64(id), 10)}
 	return resp
 }
 
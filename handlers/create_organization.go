@@ -0,0 +1,81 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"nft/audit"
+	"nft/auth"
+	"nft/models"
+
+	"github.com/go-chi/render"
+	"github.com/google/uuid"
+	"golang.org/x/exp/slog"
+)
+
+func (h *Handler) CreateOrganization(w http.ResponseWriter, r *http.Request) {
+	data := &OrganizationRequest{}
+	if err := render.Bind(r, data); err != nil {
+		err = render.Render(w, r, ErrInvalidRequest(err))
+		if err != nil {
+			slog.ErrorContext(r.Context(), "error rendering response", "err", err)
+		}
+		return
+	}
+
+	userID, err := auth.GetUserID(r.Context())
+	if err != nil {
+		slog.ErrorContext(r.Context(), "error parsing user", "err", err)
+		err = render.Render(w, r, ErrUnauthorized(err))
+		if err != nil {
+			slog.ErrorContext(r.Context(), "error rendering response", "err", err)
+		}
+		return
+	}
+
+	organization := models.Organization{
+		ID:   uuid.New(),
+		Name: data.Name,
+	}
+	audit.Describe(r.Context(), "organization.create", "organization", organization.ID.String())
+
+	err = h.db.WithContext(r.Context()).CreateOrganization(&organization, userID)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "error saving organization", "err", err)
+		err = render.Render(w, r, ErrServer(err))
+		if err != nil {
+			slog.ErrorContext(r.Context(), "error rendering response", "err", err)
+		}
+		return
+	}
+
+	render.Status(r, http.StatusCreated)
+	err = render.Render(w, r, NewOrganizationResponse(&organization))
+	if err != nil {
+		slog.ErrorContext(r.Context(), "error rendering response", "err", err)
+	}
+}
+
+type OrganizationRequest struct {
+	Name string `json:"name"`
+}
+
+func (a *OrganizationRequest) Bind(r *http.Request) error {
+	if len(a.Name) == 0 {
+		return errors.New("missing required fields")
+	}
+
+	return nil
+}
+
+type OrganizationResponse struct {
+	*models.Organization
+}
+
+func NewOrganizationResponse(organization *models.Organization) *OrganizationResponse {
+	resp := &OrganizationResponse{Organization: organization}
+	return resp
+}
+
+func (rd *OrganizationResponse) Render(w http.ResponseWriter, r *http.Request) error {
+	return nil
+}
@@ -3,13 +3,14 @@ package handlers
 import (
 	"errors"
 	"net/http"
-	"nft/imx"
+	"nft/audit"
+	"nft/auth"
 	"nft/models"
+	"nft/tasks"
 
-	"github.com/ethereum/go-ethereum/log"
-	"github.com/go-chi/oauth"
 	"github.com/go-chi/render"
 	"github.com/google/uuid"
+	"golang.org/x/exp/slog"
 )
 
 func (h *Handler) CreateToken(w http.ResponseWriter, r *http.Request) {
@@ -17,73 +18,68 @@ func (h *Handler) CreateToken(w http.ResponseWriter, r *http.Request) {
 	if err := render.Bind(r, data); err != nil {
 		err = render.Render(w, r, ErrInvalidRequest(err))
 		if err != nil {
-			log.Error("error rendering response", err)
+			slog.ErrorContext(r.Context(), "error rendering response", "err", err)
 		}
 		return
 	}
 
 	collectionID, err := uuid.Parse(data.CollectionID)
 	if err != nil {
-		log.Error("error parsing collection", err)
+		slog.ErrorContext(r.Context(), "error parsing collection", "err", err)
 		err = render.Render(w, r, ErrInvalidRequest(err))
 		if err != nil {
-			log.Error("error rendering response", err)
+			slog.ErrorContext(r.Context(), "error rendering response", "err", err)
 		}
 		return
 	}
+	audit.Describe(r.Context(), "token.create", "collection", collectionID.String())
 
-	collection, err := h.db.GetCollection(collectionID)
+	collection, err := h.db.WithContext(r.Context()).GetCollection(collectionID)
 	if err != nil {
-		log.Error("error getting collection", err)
+		slog.ErrorContext(r.Context(), "error getting collection", "err", err)
 		err = render.Render(w, r, ErrInvalidRequest(err))
 		if err != nil {
-			log.Error("error rendering response", err)
+			slog.ErrorContext(r.Context(), "error rendering response", "err", err)
 		}
 		return
 	}
 
-	if collection == nil {
+	if collection == nil || collection.Hidden {
 		err = errors.New("collection missing")
-		log.Error("error getting collection", err)
+		slog.ErrorContext(r.Context(), "error getting collection", "err", err)
 		err = render.Render(w, r, ErrInvalidRequest(err))
 		if err != nil {
-			log.Error("error rendering response", err)
+			slog.ErrorContext(r.Context(), "error rendering response", "err", err)
 		}
 		return
 	}
 
-	userID, err := uuid.Parse(r.Context().Value(oauth.CredentialContext).(string))
+	userID, err := auth.GetUserID(r.Context())
 	if err != nil {
-		log.Error("error parsing user", err)
-		err = render.Render(w, r, ErrInvalidRequest(err))
+		slog.ErrorContext(r.Context(), "error parsing user", "err", err)
+		err = render.Render(w, r, ErrUnauthorized(err))
 		if err != nil {
-			log.Error("error rendering response", err)
+			slog.ErrorContext(r.Context(), "error rendering response", "err", err)
 		}
 		return
 	}
 
-	if collection.UserID != userID {
-		err = errors.New("invalid collection")
-		log.Error("invalid collection", err)
-		err = render.Render(w, r, ErrInvalidRequest(err))
+	allowed, err := h.hasRole(r.Context(), collection.OrganizationID, userID, models.RoleMinter)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "error getting organization member", "err", err)
+		err = render.Render(w, r, ErrServer(err))
 		if err != nil {
-			log.Error("error rendering response", err)
+			slog.ErrorContext(r.Context(), "error rendering response", "err", err)
 		}
 		return
 	}
 
-	info := imx.MintInformation{
-		ContractAddress: collection.ContractAddress,
-		TokenID:         data.TokenID,
-		Blueprint:       data.Blueprint,
-	}
-
-	err = h.imx.CreateToken(r.Context(), &info)
-	if err != nil {
-		log.Error("error creating token", err)
-		err = render.Render(w, r, ErrInvalidRequest(err))
+	if !allowed {
+		err = errors.New("invalid collection")
+		slog.ErrorContext(r.Context(), "invalid collection", "err", err)
+		err = render.Render(w, r, ErrForbidden(err))
 		if err != nil {
-			log.Error("error rendering response", err)
+			slog.ErrorContext(r.Context(), "error rendering response", "err", err)
 		}
 		return
 	}
@@ -92,22 +88,42 @@ func (h *Handler) CreateToken(w http.ResponseWriter, r *http.Request) {
 		ID:           uuid.New(),
 		CollectionID: collectionID,
 		TokenID:      data.TokenID,
+		Blueprint:    data.Blueprint,
+		Status:       models.TokenPending,
 	}
+	audit.Describe(r.Context(), "token.create", "token", token.ID.String())
 
-	err = h.db.CreateToken(&token)
+	mintMessage, err := tasks.NewMintTokenMessage(r.Context(), token.ID, userID)
 	if err != nil {
-		log.Error("error saving token", err)
-		err = render.Render(w, r, ErrInvalidRequest(err))
+		err = render.Render(w, r, ErrServer(err))
+		if err != nil {
+			slog.ErrorContext(r.Context(), "error rendering response", "err", err)
+		}
+		return
+	}
+
+	created, err := h.db.WithContext(r.Context()).CreateTokenUnlessExists(&token, mintMessage)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "error saving token", "err", err)
+		err = render.Render(w, r, ErrServer(err))
+		if err != nil {
+			slog.ErrorContext(r.Context(), "error rendering response", "err", err)
+		}
+		return
+	}
+
+	if !created {
+		err = render.Render(w, r, ErrConflict(errors.New("token already exists")))
 		if err != nil {
-			log.Error("error rendering response", err)
+			slog.ErrorContext(r.Context(), "error rendering response", "err", err)
 		}
 		return
 	}
 
-	render.Status(r, http.StatusCreated)
-	err = render.Render(w, r, NewTokenResponse(token.ID.String()))
+	render.Status(r, http.StatusAccepted)
+	err = render.Render(w, r, NewTokenResponse(token.ID.String(), mintMessage.TaskID))
 	if err != nil {
-		log.Error("error rendering response", err)
+		slog.ErrorContext(r.Context(), "error rendering response", "err", err)
 	}
 }
 
@@ -131,10 +147,11 @@ func (a *TokenRequest) Bind(r *http.Request) error {
 
 type TokenResponse struct {
 	TokenID string `json:"token_id"`
+	JobID   string `json:"job_id"`
 }
 
-func NewTokenResponse(id string) *TokenResponse {
-	resp := &TokenResponse{TokenID: id}
+func NewTokenResponse(id string, jobID string) *TokenResponse {
+	resp := &TokenResponse{TokenID: id, JobID: jobID}
 	return resp
 }
 
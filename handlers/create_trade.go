@@ -3,13 +3,17 @@ package handlers
 import (
 	"errors"
 	"net/http"
+	"nft/audit"
+	"nft/auth"
+	"nft/events"
 	"nft/imx"
+	"nft/models"
 	"strconv"
+	"time"
 
-	"github.com/ethereum/go-ethereum/log"
-	"github.com/go-chi/oauth"
 	"github.com/go-chi/render"
 	"github.com/google/uuid"
+	"golang.org/x/exp/slog"
 )
 
 func (h *Handler) CreateTrade(w http.ResponseWriter, r *http.Request) {
@@ -17,59 +21,122 @@ func (h *Handler) CreateTrade(w http.ResponseWriter, r *http.Request) {
 	if err := render.Bind(r, data); err != nil {
 		err = render.Render(w, r, ErrInvalidRequest(err))
 		if err != nil {
-			log.Error("error rendering response", err)
+			slog.ErrorContext(r.Context(), "error rendering response", "err", err)
 		}
 		return
 	}
 
-	userID, err := uuid.Parse(r.Context().Value(oauth.CredentialContext).(string))
+	userID, err := auth.GetUserID(r.Context())
 	if err != nil {
-		log.Error("error parsing user", err)
-		err = render.Render(w, r, ErrInvalidRequest(err))
+		slog.ErrorContext(r.Context(), "error parsing user", "err", err)
+		err = render.Render(w, r, ErrUnauthorized(err))
 		if err != nil {
-			log.Error("error rendering response", err)
+			slog.ErrorContext(r.Context(), "error rendering response", "err", err)
 		}
 		return
 	}
-	user, err := h.db.GetUser(userID)
+	user, err := h.db.WithContext(r.Context()).GetUser(userID)
 	if err != nil {
-		log.Error("error getting user", err)
+		slog.ErrorContext(r.Context(), "error getting user", "err", err)
+		err = render.Render(w, r, ErrInvalidRequest(err))
+		if err != nil {
+			slog.ErrorContext(r.Context(), "error rendering response", "err", err)
+		}
+		return
+	}
+
+	if user == nil {
+		err = errors.New("user missing")
+		slog.ErrorContext(r.Context(), "error getting user", "err", err)
 		err = render.Render(w, r, ErrInvalidRequest(err))
 		if err != nil {
-			log.Error("error rendering response", err)
+			slog.ErrorContext(r.Context(), "error rendering response", "err", err)
 		}
 		return
 	}
 
 	orderID, err := strconv.ParseInt(data.OrderID, 10, 32)
 	if err != nil {
-		log.Error("error creating order", err)
+		slog.ErrorContext(r.Context(), "error creating order", "err", err)
 		err = render.Render(w, r, ErrInvalidRequest(err))
 		if err != nil {
-			log.Error("error rendering response", err)
+			slog.ErrorContext(r.Context(), "error rendering response", "err", err)
 		}
 		return
 	}
 
+	audit.Describe(r.Context(), "trade.create", "order", data.OrderID)
+
 	info := imx.CreateTradeInformation{
 		OrderID: int32(orderID),
 		User:    user,
 	}
 
+	if user.External {
+		h.createSignableTrade(w, r, &info)
+		return
+	}
+
 	tradeID, err := h.imx.CreateTrade(r.Context(), &info)
 	if err != nil {
-		log.Error("error creating trade", err)
-		err = render.Render(w, r, ErrInvalidRequest(err))
+		slog.ErrorContext(r.Context(), "error creating trade", "err", err)
+		err = render.Render(w, r, ErrIMX(err, ErrInvalidRequest))
 		if err != nil {
-			log.Error("error rendering response", err)
+			slog.ErrorContext(r.Context(), "error rendering response", "err", err)
 		}
 		return
 	}
 
+	audit.SetIMXID(r.Context(), strconv.FormatInt(int64(tradeID), 10))
+
+	h.publish(r.Context(), h.tradeEvents(r.Context(), user.ID, events.Trade{TradeID: tradeID, OrderID: info.OrderID})...)
+
 	render.Status(r, http.StatusCreated)
 	err = render.Render(w, r, NewTradeResponse(tradeID))
 	if err != nil {
-		log.Error("error rendering response", err)
+		slog.ErrorContext(r.Context(), "error rendering response", "err", err)
+	}
+}
+
+func (h *Handler) createSignableTrade(w http.ResponseWriter, r *http.Request, info *imx.CreateTradeInformation) {
+	signable, err := h.imx.GetSignableTrade(r.Context(), info)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "error creating signable trade", "err", err)
+		err = render.Render(w, r, ErrIMX(err, ErrInvalidRequest))
+		if err != nil {
+			slog.ErrorContext(r.Context(), "error rendering response", "err", err)
+		}
+		return
+	}
+
+	request := models.SignatureRequest{
+		ID:              uuid.New(),
+		UserID:          info.User.ID,
+		Type:            models.SignatureRequestTrade,
+		Status:          models.SignatureRequestPending,
+		OrderID:         info.OrderID,
+		SignableMessage: signable.SignableMessage,
+		PayloadHash:     signable.PayloadHash,
+		Payload:         signable.Payload,
+		ExpiresAt:       time.Now().Add(signatureRequestTTL).UnixMilli(),
+	}
+	audit.Describe(r.Context(), "trade.request_signature", "order", strconv.FormatInt(int64(info.OrderID), 10))
+	audit.SetDetails(r.Context(), "signature request "+request.ID.String())
+
+	err = h.db.WithContext(r.Context()).CreateSignatureRequest(&request)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "error saving signature request", "err", err)
+		err = render.Render(w, r, ErrServer(err))
+		if err != nil {
+			slog.ErrorContext(r.Context(), "error rendering response", "err", err)
+		}
+		return
+	}
+
+	render.Status(r, http.StatusAccepted)
+	err = render.Render(w, r, NewSignableResponse(&request))
+	if err != nil {
+		slog.ErrorContext(r.Context(), "error rendering response", "err", err)
 	}
 }
 
@@ -90,7 +157,7 @@ type TradeResponse struct {
 }
 
 func NewTradeResponse(id int32) *TradeResponse {
-	resp := &TradeResponse{TradeID: string(id)}
+	resp := &TradeResponse{TradeID: strconv.FormatInt(int64(id), 10)}
 	return resp
 }
 
@@ -3,12 +3,13 @@ package handlers
 import (
 	"errors"
 	"net/http"
+	"nft/audit"
 	"nft/keys"
 	"nft/models"
 
-	"github.com/ethereum/go-ethereum/log"
 	"github.com/go-chi/render"
 	"github.com/google/uuid"
+	"golang.org/x/exp/slog"
 )
 
 func (h *Handler) CreateUser(w http.ResponseWriter, r *http.Request) {
@@ -16,80 +17,85 @@ func (h *Handler) CreateUser(w http.ResponseWriter, r *http.Request) {
 	if err := render.Bind(r, data); err != nil {
 		err = render.Render(w, r, ErrInvalidRequest(err))
 		if err != nil {
-			log.Error("error rendering response", err)
+			slog.ErrorContext(r.Context(), "error rendering response", "err", err)
 		}
 		return
 	}
 
 	mail := data.Mail
 
-	u, err := h.db.GetUserByMail(mail)
+	existing, err := h.db.WithContext(r.Context()).GetUserByMail(mail)
 	if err != nil {
-		log.Error("error getting user", err)
+		slog.ErrorContext(r.Context(), "error getting user", "err", err)
 		err = render.Render(w, r, ErrServer(err))
 		if err != nil {
-			log.Error("error rendering response", err)
+			slog.ErrorContext(r.Context(), "error rendering response", "err", err)
 		}
 		return
 	}
 
-	if u == nil {
-		pair, err := keys.CreateKeys()
+	// the response holds the credentials of the user, it is only ever sent to the request creating it
+	if existing != nil {
+		err = render.Render(w, r, ErrConflict(errors.New("user already exists")))
 		if err != nil {
-			err = render.Render(w, r, ErrInvalidRequest(errors.New("not allowed")))
-			if err != nil {
-				log.Error("error rendering response", err)
-			}
-			return
+			slog.ErrorContext(r.Context(), "error rendering response", "err", err)
 		}
+		return
+	}
 
-		user := models.User{}
-		user.ID = uuid.New()
-		user.ApiKey = uuid.NewString()
-		user.Mail = mail
-		user.Private = pair.Private //TODO do not store private keys in plain text. We must use a vault or similar.
-		user.Public = pair.Public
-		user.Address = pair.Address
+	pair, err := keys.CreateKeys()
+	if err != nil {
+		err = render.Render(w, r, ErrInvalidRequest(errors.New("not allowed")))
+		if err != nil {
+			slog.ErrorContext(r.Context(), "error rendering response", "err", err)
+		}
+		return
+	}
 
-		err = h.db.CreateUser(&user)
+	user := models.User{}
+	user.ID = uuid.New()
+	user.ApiKey = uuid.NewString()
+	user.Mail = mail
+	user.Private = pair.Private //TODO do not store private keys in plain text. We must use a vault or similar.
+	user.Public = pair.Public
+	user.Address = pair.Address
+	audit.Describe(r.Context(), "user.create", "user", user.ID.String())
+	audit.SetActor(r.Context(), user.ID)
+
+	// registered on IMX before being saved, a failed registration leaves no user whose credentials
+	// could not be returned
+	user.StarkKey, err = h.imx.CreateUser(r.Context(), &user)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "error creating user", "err", err)
+		err = render.Render(w, r, ErrIMX(err, ErrServer))
 		if err != nil {
-			log.Error("error saving user", err)
-			err = render.Render(w, r, ErrServer(err))
-			if err != nil {
-				log.Error("error rendering response", err)
-			}
-			return
+			slog.ErrorContext(r.Context(), "error rendering response", "err", err)
 		}
-		u = &user
+		return
 	}
 
-	if len(u.StarkKey) == 0 {
-		starkKey, err := h.imx.CreateUser(r.Context(), u)
+	created, err := h.db.WithContext(r.Context()).CreateUserUnlessExists(&user)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "error saving user", "err", err)
+		err = render.Render(w, r, ErrServer(err))
 		if err != nil {
-			log.Error("error creating user", err)
-			err = render.Render(w, r, ErrServer(err))
-			if err != nil {
-				log.Error("error rendering response", err)
-			}
-			return
+			slog.ErrorContext(r.Context(), "error rendering response", "err", err)
 		}
+		return
+	}
 
-		u.StarkKey = starkKey
-		err = h.db.UpdateUser(u)
+	if !created {
+		err = render.Render(w, r, ErrConflict(errors.New("user already exists")))
 		if err != nil {
-			log.Error("error saving user", err)
-			err = render.Render(w, r, ErrServer(err))
-			if err != nil {
-				log.Error("error rendering response", err)
-			}
-			return
+			slog.ErrorContext(r.Context(), "error rendering response", "err", err)
 		}
+		return
 	}
 
 	render.Status(r, http.StatusCreated)
-	err = render.Render(w, r, NewUserResponse(u))
+	err = render.Render(w, r, NewUserResponse(&user))
 	if err != nil {
-		log.Error("error rendering response", err)
+		slog.ErrorContext(r.Context(), "error rendering response", "err", err)
 	}
 }
 
@@ -0,0 +1,77 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"nft/models"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/go-chi/render"
+	"github.com/google/uuid"
+	"golang.org/x/exp/slog"
+)
+
+// userChallengeTTL is how long the wallet has to sign a challenge.
+const userChallengeTTL = 10 * time.Minute
+
+// CreateUserChallenge returns the message an external user signs to register their address.
+func (h *Handler) CreateUserChallenge(w http.ResponseWriter, r *http.Request) {
+	data := &UserChallengeRequest{}
+	if err := render.Bind(r, data); err != nil {
+		err = render.Render(w, r, ErrInvalidRequest(err))
+		if err != nil {
+			slog.ErrorContext(r.Context(), "error rendering response", "err", err)
+		}
+		return
+	}
+
+	now := time.Now()
+	challenge := models.UserChallenge{
+		ID:        uuid.New(),
+		Address:   common.HexToAddress(data.Address).Hex(),
+		ExpiresAt: now.Add(userChallengeTTL).UnixMilli(),
+	}
+	challenge.Message = fmt.Sprintf("Register %s with the NFT Marketplace.\n\nChallenge: %s", challenge.Address, challenge.ID)
+
+	err := h.db.WithContext(r.Context()).CreateUserChallenge(&challenge, now.UnixMilli())
+	if err != nil {
+		slog.ErrorContext(r.Context(), "error saving user challenge", "err", err)
+		err = render.Render(w, r, ErrServer(err))
+		if err != nil {
+			slog.ErrorContext(r.Context(), "error rendering response", "err", err)
+		}
+		return
+	}
+
+	render.Status(r, http.StatusCreated)
+	err = render.Render(w, r, NewUserChallengeResponse(&challenge))
+	if err != nil {
+		slog.ErrorContext(r.Context(), "error rendering response", "err", err)
+	}
+}
+
+type UserChallengeRequest struct {
+	Address string `json:"address"`
+}
+
+func (a *UserChallengeRequest) Bind(r *http.Request) error {
+	if !common.IsHexAddress(a.Address) {
+		return errors.New("invalid address")
+	}
+
+	return nil
+}
+
+type UserChallengeResponse struct {
+	*models.UserChallenge
+}
+
+func NewUserChallengeResponse(challenge *models.UserChallenge) *UserChallengeResponse {
+	return &UserChallengeResponse{challenge}
+}
+
+func (rd *UserChallengeResponse) Render(w http.ResponseWriter, r *http.Request) error {
+	return nil
+}
@@ -1,19 +1,20 @@
 package handlers
 
 import (
+	"context"
 	"errors"
 	"net/http"
+	"nft/audit"
+	"nft/auth"
 	"nft/imx"
+	"nft/models"
 	"nft/tasks"
 	"strconv"
 	"time"
 
-	"github.com/hibiken/asynq"
-
-	"github.com/ethereum/go-ethereum/log"
-	"github.com/go-chi/oauth"
 	"github.com/go-chi/render"
 	"github.com/google/uuid"
+	"golang.org/x/exp/slog"
 )
 
 func (h *Handler) CreateWithdrawal(w http.ResponseWriter, r *http.Request) {
@@ -21,70 +22,172 @@ func (h *Handler) CreateWithdrawal(w http.ResponseWriter, r *http.Request) {
 	if err := render.Bind(r, data); err != nil {
 		err = render.Render(w, r, ErrInvalidRequest(err))
 		if err != nil {
-			log.Error("error rendering response", err)
+			slog.ErrorContext(r.Context(), "error rendering response", "err", err)
 		}
 		return
 	}
 
-	userID, err := uuid.Parse(r.Context().Value(oauth.CredentialContext).(string))
+	userID, err := auth.GetUserID(r.Context())
 	if err != nil {
-		log.Error("error parsing user", err)
-		err = render.Render(w, r, ErrInvalidRequest(err))
+		slog.ErrorContext(r.Context(), "error parsing user", "err", err)
+		err = render.Render(w, r, ErrUnauthorized(err))
 		if err != nil {
-			log.Error("error rendering response", err)
+			slog.ErrorContext(r.Context(), "error rendering response", "err", err)
 		}
 		return
 	}
 
-	user, err := h.db.GetUser(userID)
+	user, err := h.db.WithContext(r.Context()).GetUser(userID)
 	if err != nil {
-		log.Error("error getting user", err)
+		slog.ErrorContext(r.Context(), "error getting user", "err", err)
+		err = render.Render(w, r, ErrInvalidRequest(err))
+		if err != nil {
+			slog.ErrorContext(r.Context(), "error rendering response", "err", err)
+		}
+		return
+	}
+
+	if user == nil {
+		err = errors.New("user missing")
+		slog.ErrorContext(r.Context(), "error getting user", "err", err)
 		err = render.Render(w, r, ErrInvalidRequest(err))
 		if err != nil {
-			log.Error("error rendering response", err)
+			slog.ErrorContext(r.Context(), "error rendering response", "err", err)
 		}
 		return
 	}
 
+	audit.Describe(r.Context(), "withdrawal.create", "user", user.ID.String())
+
 	info := imx.CreateWithdrawalInformation{
 		AmountWei: data.AmountWei,
 		User:      user,
 	}
 
-	withdrawalID, err := h.imx.CreateEthWithdrawal(r.Context(), &info)
+	if user.External {
+		h.createSignableWithdrawal(w, r, &info)
+		return
+	}
+
+	// saved before it is created on IMX, a withdrawal whose IMX ID is then not saved is not lost and keeps a
+	// retry from withdrawing again until it is reconciled
+	withdrawal := models.Withdrawal{
+		ID:        uuid.New(),
+		UserID:    userID,
+		AmountWei: data.AmountWei,
+		Status:    models.WithdrawalCreating,
+	}
+	audit.Describe(r.Context(), "withdrawal.create", "withdrawal", withdrawal.ID.String())
+
+	completeMessage, err := tasks.NewCompleteWithdrawalMessage(r.Context(), withdrawal.ID, userID, time.Now().Add(24*time.Hour))
 	if err != nil {
-		log.Error("error creating withdrawal", err)
-		err = render.Render(w, r, ErrInvalidRequest(err))
+		err = render.Render(w, r, ErrServer(err))
 		if err != nil {
-			log.Error("error rendering response", err)
+			slog.ErrorContext(r.Context(), "error rendering response", "err", err)
 		}
 		return
 	}
 
-	completeTask, err := tasks.NewCompleteWithdrawalTask(withdrawalID, userID)
+	started, err := h.db.WithContext(r.Context()).StartWithdrawal(&withdrawal, completeMessage)
 	if err != nil {
-		err = render.Render(w, r, ErrInvalidRequest(err))
+		slog.ErrorContext(r.Context(), "error saving withdrawal", "err", err)
+		err = render.Render(w, r, ErrServer(err))
+		if err != nil {
+			slog.ErrorContext(r.Context(), "error rendering response", "err", err)
+		}
+		return
+	}
+
+	if !started {
+		err = render.Render(w, r, ErrConflict(errors.New("a previous withdrawal is still being created")))
 		if err != nil {
-			log.Error("error rendering response", err)
+			slog.ErrorContext(r.Context(), "error rendering response", "err", err)
 		}
 		return
 	}
 
-	completeTaskInfo, err := h.asynqClient.Enqueue(completeTask, asynq.ProcessIn(24*time.Hour))
+	withdrawalID, err := h.imx.CreateEthWithdrawal(r.Context(), &info)
 	if err != nil {
-		err = render.Render(w, r, ErrInvalidRequest(err))
+		slog.ErrorContext(r.Context(), "error creating withdrawal", "err", err)
+		h.abortWithdrawal(r.Context(), &withdrawal, completeMessage, err)
+		err = render.Render(w, r, ErrIMX(err, ErrInvalidRequest))
 		if err != nil {
-			log.Error("error rendering response", err)
+			slog.ErrorContext(r.Context(), "error rendering response", "err", err)
 		}
 		return
 	}
 
-	log.Trace("Task scheduled", "taskID", completeTaskInfo.ID)
+	audit.SetIMXID(r.Context(), strconv.FormatInt(int64(withdrawalID), 10))
+
+	err = h.db.WithContext(r.Context()).SetWithdrawalCreated(withdrawal.ID, withdrawalID)
+	if err != nil {
+		// the withdrawal stays being created, its IMX ID is reconciled from this log
+		slog.ErrorContext(r.Context(), "error saving withdrawal", "err", err, "id", withdrawal.ID.String(), "withdrawal_id", withdrawalID)
+		err = render.Render(w, r, ErrServer(err))
+		if err != nil {
+			slog.ErrorContext(r.Context(), "error rendering response", "err", err)
+		}
+		return
+	}
 
 	render.Status(r, http.StatusCreated)
 	err = render.Render(w, r, NewWithdrawalResponse(withdrawalID))
 	if err != nil {
-		log.Error("error rendering response", err)
+		slog.ErrorContext(r.Context(), "error rendering response", "err", err)
+	}
+}
+
+// abortWithdrawal deletes the withdrawal IMX refused to create, so the user can withdraw again. A creation
+// that timed out may have been applied, so the withdrawal stays being created.
+func (h *Handler) abortWithdrawal(ctx context.Context, withdrawal *models.Withdrawal, message *models.OutboxMessage, createErr error) {
+	if errors.Is(createErr, imx.ErrUnknownOutcome) {
+		return
+	}
+
+	if err := h.db.WithContext(ctx).DeleteWithdrawal(withdrawal.ID, message); err != nil {
+		slog.ErrorContext(ctx, "error deleting withdrawal", "err", err)
+	}
+}
+
+func (h *Handler) createSignableWithdrawal(w http.ResponseWriter, r *http.Request, info *imx.CreateWithdrawalInformation) {
+	signable, err := h.imx.GetSignableWithdrawal(r.Context(), info)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "error creating signable withdrawal", "err", err)
+		err = render.Render(w, r, ErrIMX(err, ErrInvalidRequest))
+		if err != nil {
+			slog.ErrorContext(r.Context(), "error rendering response", "err", err)
+		}
+		return
+	}
+
+	request := models.SignatureRequest{
+		ID:              uuid.New(),
+		UserID:          info.User.ID,
+		Type:            models.SignatureRequestWithdrawal,
+		Status:          models.SignatureRequestPending,
+		SignableMessage: signable.SignableMessage,
+		PayloadHash:     signable.PayloadHash,
+		Payload:         signable.Payload,
+		ExpiresAt:       time.Now().Add(signatureRequestTTL).UnixMilli(),
+		AmountWei:       info.AmountWei,
+	}
+	audit.Describe(r.Context(), "withdrawal.request_signature", "user", info.User.ID.String())
+	audit.SetDetails(r.Context(), "signature request "+request.ID.String())
+
+	err = h.db.WithContext(r.Context()).CreateSignatureRequest(&request)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "error saving signature request", "err", err)
+		err = render.Render(w, r, ErrServer(err))
+		if err != nil {
+			slog.ErrorContext(r.Context(), "error rendering response", "err", err)
+		}
+		return
+	}
+
+	render.Status(r, http.StatusAccepted)
+	err = render.Render(w, r, NewSignableResponse(&request))
+	if err != nil {
+		slog.ErrorContext(r.Context(), "error rendering response", "err", err)
 	}
 }
 
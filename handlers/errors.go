@@ -1,7 +1,9 @@
 package handlers
 
 import (
+	"errors"
 	"net/http"
+	"nft/imx"
 
 	"github.com/go-chi/render"
 )
@@ -48,3 +50,87 @@ func ErrServer(err error) render.Renderer {
 		ErrorText:      err.Error(),
 	}
 }
+
+func ErrUnauthorized(err error) render.Renderer {
+	return &ErrResponse{
+		Err:            err,
+		HTTPStatusCode: 401,
+		StatusText:     "Unauthorized.",
+		ErrorText:      err.Error(),
+	}
+}
+
+func ErrForbidden(err error) render.Renderer {
+	return &ErrResponse{
+		Err:            err,
+		HTTPStatusCode: 403,
+		StatusText:     "Forbidden.",
+		ErrorText:      err.Error(),
+	}
+}
+
+func ErrConflict(err error) render.Renderer {
+	return &ErrResponse{
+		Err:            err,
+		HTTPStatusCode: 409,
+		StatusText:     "Conflict.",
+		ErrorText:      err.Error(),
+	}
+}
+
+// AppCodes of failed IMX requests. They are stable, clients can rely on them to handle each failure.
+const (
+	AppCodeIMXNotFound            int64 = 1001
+	AppCodeIMXConflict            int64 = 1002
+	AppCodeIMXInsufficientBalance int64 = 1003
+	AppCodeIMXRateLimited         int64 = 1004
+	AppCodeIMXUnavailable         int64 = 1005
+	AppCodeIMXUnknownOutcome      int64 = 1006
+	AppCodeIMXInvalidSignature    int64 = 1007
+)
+
+// ErrIMX renders a failed IMX request with the status of its kind, or with fallback when IMX did not say
+// why it failed. IMX rejecting the platform credentials is rendered as IMX being unavailable, without the
+// details of the rejection, which the caller has already logged. IMX rejecting the signature of an external
+// user is rendered as an invalid request, the user can sign again.
+func ErrIMX(err error, fallback func(error) render.Renderer) render.Renderer {
+	response := &ErrResponse{Err: err, ErrorText: err.Error()}
+	switch {
+	case errors.Is(err, imx.ErrNotFound):
+		response.HTTPStatusCode = http.StatusNotFound
+		response.StatusText = "Resource not found on IMX."
+		response.AppCode = AppCodeIMXNotFound
+	case errors.Is(err, imx.ErrConflict):
+		response.HTTPStatusCode = http.StatusConflict
+		response.StatusText = "Conflict with IMX state."
+		response.AppCode = AppCodeIMXConflict
+	case errors.Is(err, imx.ErrInsufficientBalance):
+		response.HTTPStatusCode = http.StatusPaymentRequired
+		response.StatusText = "Insufficient balance."
+		response.AppCode = AppCodeIMXInsufficientBalance
+	case errors.Is(err, imx.ErrRateLimited):
+		response.HTTPStatusCode = http.StatusTooManyRequests
+		response.StatusText = "IMX rate limit exceeded."
+		response.AppCode = AppCodeIMXRateLimited
+	case errors.Is(err, imx.ErrUnavailable):
+		response.HTTPStatusCode = http.StatusServiceUnavailable
+		response.StatusText = "IMX unavailable."
+		response.AppCode = AppCodeIMXUnavailable
+	case errors.Is(err, imx.ErrUnauthorized):
+		response.HTTPStatusCode = http.StatusServiceUnavailable
+		response.StatusText = "IMX unavailable."
+		response.AppCode = AppCodeIMXUnavailable
+		response.ErrorText = "imx unavailable"
+	case errors.Is(err, imx.ErrInvalidSignature):
+		response.HTTPStatusCode = http.StatusBadRequest
+		response.StatusText = "Invalid signature."
+		response.AppCode = AppCodeIMXInvalidSignature
+	case errors.Is(err, imx.ErrUnknownOutcome):
+		response.HTTPStatusCode = http.StatusGatewayTimeout
+		response.StatusText = "IMX did not answer in time, the operation may have been applied."
+		response.AppCode = AppCodeIMXUnknownOutcome
+	default:
+		return fallback(err)
+	}
+	return response
+}
@@ -0,0 +1,120 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"nft/auth"
+	"nft/models"
+	"nft/tasks"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/render"
+	"github.com/hibiken/asynq"
+	"golang.org/x/exp/slog"
+)
+
+// GetJob returns the state of a background job started by the user. Jobs not enqueued yet are pending,
+// finished jobs are kept for a limited time, after that they are reported as not found.
+func (h *Handler) GetJob(w http.ResponseWriter, r *http.Request) {
+	userID, err := auth.GetUserID(r.Context())
+	if err != nil {
+		slog.ErrorContext(r.Context(), "error parsing user", "err", err)
+		err = render.Render(w, r, ErrUnauthorized(err))
+		if err != nil {
+			slog.ErrorContext(r.Context(), "error rendering response", "err", err)
+		}
+		return
+	}
+
+	jobID := chi.URLParam(r, "jobID")
+
+	// the outbox is read first, a job is marked as sent only once asynq has it
+	message, err := h.db.WithContext(r.Context()).GetUnsentOutboxMessage(jobID)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "error getting job", "err", err)
+		err = render.Render(w, r, ErrServer(err))
+		if err != nil {
+			slog.ErrorContext(r.Context(), "error rendering response", "err", err)
+		}
+		return
+	}
+
+	if message != nil {
+		h.renderJob(w, r, userID.String(), message.Payload, NewPendingJobResponse(message))
+		return
+	}
+
+	info, err := h.inspector.GetTaskInfo(tasks.QueueDefault, jobID)
+	if err != nil {
+		switch {
+		case errors.Is(err, asynq.ErrTaskNotFound), errors.Is(err, asynq.ErrQueueNotFound):
+			err = render.Render(w, r, ErrNotFound)
+		default:
+			slog.ErrorContext(r.Context(), "error getting job", "err", err)
+			err = render.Render(w, r, ErrServer(err))
+		}
+		if err != nil {
+			slog.ErrorContext(r.Context(), "error rendering response", "err", err)
+		}
+		return
+	}
+
+	h.renderJob(w, r, userID.String(), info.Payload, NewJobResponse(info))
+}
+
+// renderJob renders the job if the user started it. Jobs of other users are reported as missing, the same
+// as jobs that do not exist.
+func (h *Handler) renderJob(w http.ResponseWriter, r *http.Request, userID string, payload []byte, job *JobResponse) {
+	var owner struct{ UserID string }
+	if err := json.Unmarshal(payload, &owner); err != nil || owner.UserID != userID {
+		err = render.Render(w, r, ErrNotFound)
+		if err != nil {
+			slog.ErrorContext(r.Context(), "error rendering response", "err", err)
+		}
+		return
+	}
+
+	err := render.Render(w, r, job)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "error rendering response", "err", err)
+	}
+}
+
+type JobResponse struct {
+	JobID       string     `json:"job_id"`
+	Type        string     `json:"type"`
+	State       string     `json:"state"`
+	Retried     int        `json:"retried"`
+	LastError   string     `json:"last_error,omitempty"`
+	CompletedAt *time.Time `json:"completed_at,omitempty"`
+}
+
+func NewJobResponse(info *asynq.TaskInfo) *JobResponse {
+	resp := &JobResponse{
+		JobID:     info.ID,
+		Type:      info.Type,
+		State:     info.State.String(),
+		Retried:   info.Retried,
+		LastError: info.LastErr,
+	}
+	if !info.CompletedAt.IsZero() {
+		resp.CompletedAt = &info.CompletedAt
+	}
+	return resp
+}
+
+// NewPendingJobResponse reports a job still waiting in the outbox as pending, the state asynq gives it once
+// enqueued.
+func NewPendingJobResponse(message *models.OutboxMessage) *JobResponse {
+	return &JobResponse{
+		JobID: message.TaskID,
+		Type:  message.TaskType,
+		State: asynq.TaskStatePending.String(),
+	}
+}
+
+func (rd *JobResponse) Render(w http.ResponseWriter, r *http.Request) error {
+	return nil
+}
@@ -1,18 +1,76 @@
 package handlers
 
 import (
+	"context"
 	"nft/db"
+	"nft/events"
 	"nft/imx"
+	"nft/models"
 
+	"github.com/google/uuid"
 	"github.com/hibiken/asynq"
+	"golang.org/x/exp/slog"
 )
 
 type Handler struct {
 	db          *db.DB
 	imx         imx.Client
 	asynqClient *asynq.Client
+	inspector   *asynq.Inspector
+	stream      *events.Stream
 }
 
-func NewHandler(db *db.DB, imx imx.Client, asynqClient *asynq.Client) *Handler {
-	return &Handler{db, imx, asynqClient}
+func NewHandler(db *db.DB, imx imx.Client, asynqClient *asynq.Client, inspector *asynq.Inspector, stream *events.Stream) *Handler {
+	return &Handler{db, imx, asynqClient, inspector, stream}
+}
+
+// event returns the outbox message publishing the event, to save with the rows changed by the request.
+// Data that cannot be encoded is a bug, it is logged and the event is not published.
+func (h *Handler) event(ctx context.Context, userID uuid.UUID, eventType string, data interface{}) []*models.OutboxMessage {
+	message, err := events.NewMessage(ctx, userID, eventType, data)
+	if err != nil {
+		slog.ErrorContext(ctx, "error encoding event", "type", eventType, "err", err)
+		return nil
+	}
+	return []*models.OutboxMessage{message}
+}
+
+// publish saves the events in the outbox for the requests changing nothing but IMX. The change cannot be
+// undone once IMX accepted it, so the request succeeds even if the events could not be saved.
+func (h *Handler) publish(ctx context.Context, messages ...*models.OutboxMessage) {
+	err := h.db.WithContext(ctx).CreateOutboxMessages(messages...)
+	if err != nil {
+		slog.ErrorContext(ctx, "error publishing events", "err", err)
+	}
+}
+
+// tradeEvents returns the messages publishing the completed trade to the buyer, and to the user who listed
+// the order when it was listed through the platform.
+func (h *Handler) tradeEvents(ctx context.Context, buyerID uuid.UUID, trade events.Trade) []*models.OutboxMessage {
+	messages := h.event(ctx, buyerID, events.TradeCompleted, trade)
+
+	order, err := h.db.WithContext(ctx).GetOrderByOrderID(trade.OrderID)
+	if err != nil {
+		slog.ErrorContext(ctx, "error getting order", "err", err)
+		return messages
+	}
+
+	if order != nil && order.UserID != uuid.Nil && order.UserID != buyerID {
+		messages = append(messages, h.event(ctx, order.UserID, events.TradeCompleted, trade)...)
+	}
+	return messages
+}
+
+// hasRole reports whether the user is a member of the organization with at least the given role.
+func (h *Handler) hasRole(ctx context.Context, organizationID uuid.UUID, userID uuid.UUID, role models.Role) (bool, error) {
+	member, err := h.db.WithContext(ctx).GetOrganizationMember(organizationID, userID)
+	if err != nil {
+		return false, err
+	}
+
+	if member == nil {
+		return false, nil
+	}
+
+	return member.Role.Includes(role), nil
 }
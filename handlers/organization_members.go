@@ -0,0 +1,216 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"nft/audit"
+	"nft/auth"
+	"nft/models"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/render"
+	"github.com/google/uuid"
+	"golang.org/x/exp/slog"
+)
+
+// SaveOrganizationMember adds a user to the organization in the URL or changes its role.
+// Only owners can manage members, and the last owner cannot be demoted.
+func (h *Handler) SaveOrganizationMember(w http.ResponseWriter, r *http.Request) {
+	data := &OrganizationMemberRequest{}
+	if err := render.Bind(r, data); err != nil {
+		err = render.Render(w, r, ErrInvalidRequest(err))
+		if err != nil {
+			slog.ErrorContext(r.Context(), "error rendering response", "err", err)
+		}
+		return
+	}
+
+	organizationID, errResponse := h.getOwnedOrganizationID(r)
+	if errResponse != nil {
+		err := render.Render(w, r, errResponse)
+		if err != nil {
+			slog.ErrorContext(r.Context(), "error rendering response", "err", err)
+		}
+		return
+	}
+
+	memberID, err := uuid.Parse(data.UserID)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "error parsing user", "err", err)
+		err = render.Render(w, r, ErrInvalidRequest(err))
+		if err != nil {
+			slog.ErrorContext(r.Context(), "error rendering response", "err", err)
+		}
+		return
+	}
+	audit.Describe(r.Context(), "organization.member_save", "organization", organizationID.String())
+	audit.SetDetails(r.Context(), "member "+memberID.String())
+
+	user, err := h.db.WithContext(r.Context()).GetUser(memberID)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "error getting user", "err", err)
+		err = render.Render(w, r, ErrServer(err))
+		if err != nil {
+			slog.ErrorContext(r.Context(), "error rendering response", "err", err)
+		}
+		return
+	}
+
+	if user == nil {
+		err = errors.New("user missing")
+		slog.ErrorContext(r.Context(), "error getting user", "err", err)
+		err = render.Render(w, r, ErrInvalidRequest(err))
+		if err != nil {
+			slog.ErrorContext(r.Context(), "error rendering response", "err", err)
+		}
+		return
+	}
+
+	member := models.OrganizationMember{
+		OrganizationID: organizationID,
+		UserID:         memberID,
+		Role:           data.Role,
+	}
+
+	saved, err := h.db.WithContext(r.Context()).SaveOrganizationMemberUnlessLastOwner(&member)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "error saving organization member", "err", err)
+		err = render.Render(w, r, ErrServer(err))
+		if err != nil {
+			slog.ErrorContext(r.Context(), "error rendering response", "err", err)
+		}
+		return
+	}
+
+	if !saved {
+		err = render.Render(w, r, ErrInvalidRequest(errors.New("organization needs at least one owner")))
+		if err != nil {
+			slog.ErrorContext(r.Context(), "error rendering response", "err", err)
+		}
+		return
+	}
+
+	render.Status(r, http.StatusOK)
+	err = render.Render(w, r, NewOrganizationMemberResponse(&member))
+	if err != nil {
+		slog.ErrorContext(r.Context(), "error rendering response", "err", err)
+	}
+}
+
+func (h *Handler) DeleteOrganizationMember(w http.ResponseWriter, r *http.Request) {
+	organizationID, errResponse := h.getOwnedOrganizationID(r)
+	if errResponse != nil {
+		err := render.Render(w, r, errResponse)
+		if err != nil {
+			slog.ErrorContext(r.Context(), "error rendering response", "err", err)
+		}
+		return
+	}
+
+	memberID, err := uuid.Parse(chi.URLParam(r, "userID"))
+	if err != nil {
+		slog.ErrorContext(r.Context(), "error parsing user", "err", err)
+		err = render.Render(w, r, ErrInvalidRequest(err))
+		if err != nil {
+			slog.ErrorContext(r.Context(), "error rendering response", "err", err)
+		}
+		return
+	}
+	audit.Describe(r.Context(), "organization.member_delete", "organization", organizationID.String())
+	audit.SetDetails(r.Context(), "member "+memberID.String())
+
+	member, err := h.db.WithContext(r.Context()).GetOrganizationMember(organizationID, memberID)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "error getting organization member", "err", err)
+		err = render.Render(w, r, ErrServer(err))
+		if err != nil {
+			slog.ErrorContext(r.Context(), "error rendering response", "err", err)
+		}
+		return
+	}
+
+	if member == nil {
+		err = render.Render(w, r, ErrNotFound)
+		if err != nil {
+			slog.ErrorContext(r.Context(), "error rendering response", "err", err)
+		}
+		return
+	}
+
+	deleted, err := h.db.WithContext(r.Context()).DeleteOrganizationMemberUnlessLastOwner(member)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "error deleting organization member", "err", err)
+		err = render.Render(w, r, ErrServer(err))
+		if err != nil {
+			slog.ErrorContext(r.Context(), "error rendering response", "err", err)
+		}
+		return
+	}
+
+	if !deleted {
+		err = render.Render(w, r, ErrInvalidRequest(errors.New("organization needs at least one owner")))
+		if err != nil {
+			slog.ErrorContext(r.Context(), "error rendering response", "err", err)
+		}
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// getOwnedOrganizationID returns the organization in the URL if the authenticated user owns it.
+func (h *Handler) getOwnedOrganizationID(r *http.Request) (uuid.UUID, render.Renderer) {
+	userID, err := auth.GetUserID(r.Context())
+	if err != nil {
+		slog.ErrorContext(r.Context(), "error parsing user", "err", err)
+		return uuid.Nil, ErrUnauthorized(err)
+	}
+
+	organizationID, err := uuid.Parse(chi.URLParam(r, "organizationID"))
+	if err != nil {
+		slog.ErrorContext(r.Context(), "error parsing organization", "err", err)
+		return uuid.Nil, ErrInvalidRequest(err)
+	}
+
+	allowed, err := h.hasRole(r.Context(), organizationID, userID, models.RoleOwner)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "error getting organization member", "err", err)
+		return uuid.Nil, ErrServer(err)
+	}
+
+	if !allowed {
+		return uuid.Nil, ErrForbidden(errors.New("invalid organization"))
+	}
+
+	return organizationID, nil
+}
+
+type OrganizationMemberRequest struct {
+	UserID string      `json:"user_id"`
+	Role   models.Role `json:"role"`
+}
+
+func (a *OrganizationMemberRequest) Bind(r *http.Request) error {
+	if len(a.UserID) == 0 {
+		return errors.New("missing required fields")
+	}
+
+	if !a.Role.IsValid() {
+		return errors.New("invalid role")
+	}
+
+	return nil
+}
+
+type OrganizationMemberResponse struct {
+	*models.OrganizationMember
+}
+
+func NewOrganizationMemberResponse(member *models.OrganizationMember) *OrganizationMemberResponse {
+	resp := &OrganizationMemberResponse{OrganizationMember: member}
+	return resp
+}
+
+func (rd *OrganizationMemberResponse) Render(w http.ResponseWriter, r *http.Request) error {
+	return nil
+}
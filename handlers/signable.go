@@ -0,0 +1,133 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"nft/auth"
+	"nft/imx"
+	"nft/models"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/render"
+	"github.com/google/uuid"
+	"golang.org/x/exp/slog"
+)
+
+// signatureRequestTTL is how long the user has to sign and submit a signature request.
+const signatureRequestTTL = 15 * time.Minute
+
+type SignatureRequest struct {
+	EthSignature   string `json:"eth_signature"`
+	StarkSignature string `json:"stark_signature"`
+}
+
+func (a *SignatureRequest) Bind(r *http.Request) error {
+	if len(a.EthSignature) == 0 {
+		return errors.New("missing required fields")
+	}
+
+	if len(a.StarkSignature) == 0 {
+		return errors.New("missing required fields")
+	}
+
+	return nil
+}
+
+// SignableResponse is returned to external wallet users instead of executing the operation.
+// The client signs SignableMessage with its L1 key and PayloadHash with its stark key.
+type SignableResponse struct {
+	RequestID       string `json:"request_id"`
+	SignableMessage string `json:"signable_message"`
+	PayloadHash     string `json:"payload_hash"`
+}
+
+func NewSignableResponse(request *models.SignatureRequest) *SignableResponse {
+	resp := &SignableResponse{
+		RequestID:       request.ID.String(),
+		SignableMessage: request.SignableMessage,
+		PayloadHash:     request.PayloadHash,
+	}
+	return resp
+}
+
+func (rd *SignableResponse) Render(w http.ResponseWriter, r *http.Request) error {
+	return nil
+}
+
+// getPendingSignatureRequest loads the signature request in the URL making sure it belongs to the
+// authenticated user, matches the expected type and was not submitted yet.
+func (h *Handler) getPendingSignatureRequest(r *http.Request, requestType string) (*models.SignatureRequest, *models.User, render.Renderer) {
+	requestID, err := uuid.Parse(chi.URLParam(r, "requestID"))
+	if err != nil {
+		slog.ErrorContext(r.Context(), "error parsing signature request", "err", err)
+		return nil, nil, ErrInvalidRequest(err)
+	}
+
+	userID, err := auth.GetUserID(r.Context())
+	if err != nil {
+		slog.ErrorContext(r.Context(), "error parsing user", "err", err)
+		return nil, nil, ErrUnauthorized(err)
+	}
+
+	request, err := h.db.WithContext(r.Context()).GetSignatureRequest(requestID)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "error getting signature request", "err", err)
+		return nil, nil, ErrInvalidRequest(err)
+	}
+
+	if request == nil || request.UserID != userID || request.Type != requestType {
+		return nil, nil, ErrNotFound
+	}
+
+	if request.Status != models.SignatureRequestPending {
+		return nil, nil, ErrInvalidRequest(errors.New("signature request already submitted"))
+	}
+
+	if time.Now().UnixMilli() >= request.ExpiresAt {
+		return nil, nil, ErrInvalidRequest(errors.New("signature request expired"))
+	}
+
+	user, err := h.db.WithContext(r.Context()).GetUser(userID)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "error getting user", "err", err)
+		return nil, nil, ErrInvalidRequest(err)
+	}
+
+	if user == nil {
+		return nil, nil, ErrInvalidRequest(errors.New("user missing"))
+	}
+
+	return request, user, nil
+}
+
+// claimSignatureRequest marks the request as being submitted, so concurrent submissions of the same request
+// do not all reach IMX.
+func (h *Handler) claimSignatureRequest(ctx context.Context, request *models.SignatureRequest) render.Renderer {
+	claimed, err := h.db.WithContext(ctx).ClaimSignatureRequest(request.ID, time.Now().UnixMilli())
+	if err != nil {
+		slog.ErrorContext(ctx, "error claiming signature request", "err", err)
+		return ErrServer(err)
+	}
+
+	if !claimed {
+		return ErrConflict(errors.New("signature request already submitted or expired"))
+	}
+
+	request.Status = models.SignatureRequestSubmitting
+	return nil
+}
+
+// releaseSignatureRequest lets the request be submitted again once IMX rejected it. A submission that timed
+// out may have been applied, so it stays claimed.
+func (h *Handler) releaseSignatureRequest(ctx context.Context, request *models.SignatureRequest, submitErr error) {
+	if errors.Is(submitErr, imx.ErrUnknownOutcome) {
+		return
+	}
+
+	request.Status = models.SignatureRequestPending
+	if err := h.db.WithContext(ctx).UpdateSignatureRequest(request); err != nil {
+		slog.ErrorContext(ctx, "error releasing signature request", "err", err)
+	}
+}
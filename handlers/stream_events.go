@@ -0,0 +1,85 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"nft/auth"
+	"time"
+
+	"github.com/go-chi/render"
+	"golang.org/x/exp/slog"
+)
+
+// heartbeatInterval keeps idle streams open through proxies closing silent connections.
+const heartbeatInterval = 15 * time.Second
+
+// StreamEvents sends the events of the user as server-sent events. Clients reconnecting with the
+// Last-Event-ID header get the recent events they missed first.
+func (h *Handler) StreamEvents(w http.ResponseWriter, r *http.Request) {
+	userID, err := auth.GetUserID(r.Context())
+	if err != nil {
+		slog.ErrorContext(r.Context(), "error parsing user", "err", err)
+		err = render.Render(w, r, ErrUnauthorized(err))
+		if err != nil {
+			slog.ErrorContext(r.Context(), "error rendering response", "err", err)
+		}
+		return
+	}
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		err = render.Render(w, r, ErrServer(errors.New("streaming not supported")))
+		if err != nil {
+			slog.ErrorContext(r.Context(), "error rendering response", "err", err)
+		}
+		return
+	}
+
+	messages, err := h.stream.Subscribe(r.Context(), userID, r.Header.Get("Last-Event-ID"))
+	if err != nil {
+		slog.ErrorContext(r.Context(), "error subscribing to events", "err", err)
+		err = render.Render(w, r, ErrServer(err))
+		if err != nil {
+			slog.ErrorContext(r.Context(), "error rendering response", "err", err)
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	heartbeat := time.NewTicker(heartbeatInterval)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-heartbeat.C:
+			_, err = fmt.Fprint(w, ": ping\n\n")
+		case message, ok := <-messages:
+			if !ok {
+				return
+			}
+
+			var data []byte
+			data, err = json.Marshal(message.Event)
+			if err != nil {
+				slog.ErrorContext(r.Context(), "error encoding event", "err", err)
+				continue
+			}
+			_, err = fmt.Fprintf(w, "id: %s\nevent: %s\ndata: %s\n\n", message.ID, message.Event.Type, data)
+		}
+
+		if err != nil {
+			slog.ErrorContext(r.Context(), "error writing event", "err", err)
+			return
+		}
+		flusher.Flush()
+	}
+}
@@ -0,0 +1,80 @@
+package handlers
+
+import (
+	"net/http"
+	"nft/audit"
+	"nft/events"
+	"nft/imx"
+	"nft/models"
+	"strconv"
+
+	"github.com/go-chi/render"
+	"golang.org/x/exp/slog"
+)
+
+func (h *Handler) SubmitTrade(w http.ResponseWriter, r *http.Request) {
+	data := &SignatureRequest{}
+	if err := render.Bind(r, data); err != nil {
+		err = render.Render(w, r, ErrInvalidRequest(err))
+		if err != nil {
+			slog.ErrorContext(r.Context(), "error rendering response", "err", err)
+		}
+		return
+	}
+
+	request, user, errResponse := h.getPendingSignatureRequest(r, models.SignatureRequestTrade)
+	if errResponse != nil {
+		err := render.Render(w, r, errResponse)
+		if err != nil {
+			slog.ErrorContext(r.Context(), "error rendering response", "err", err)
+		}
+		return
+	}
+
+	audit.Describe(r.Context(), "trade.submit", "order", strconv.FormatInt(int64(request.OrderID), 10))
+	audit.SetDetails(r.Context(), "signature request "+request.ID.String())
+
+	errResponse = h.claimSignatureRequest(r.Context(), request)
+	if errResponse != nil {
+		err := render.Render(w, r, errResponse)
+		if err != nil {
+			slog.ErrorContext(r.Context(), "error rendering response", "err", err)
+		}
+		return
+	}
+
+	info := imx.SubmitTradeInformation{
+		User:           user,
+		OrderID:        request.OrderID,
+		Payload:        request.Payload,
+		EthSignature:   data.EthSignature,
+		StarkSignature: data.StarkSignature,
+	}
+
+	tradeID, err := h.imx.SubmitTrade(r.Context(), &info)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "error submitting trade", "err", err)
+		h.releaseSignatureRequest(r.Context(), request, err)
+		err = render.Render(w, r, ErrIMX(err, ErrInvalidRequest))
+		if err != nil {
+			slog.ErrorContext(r.Context(), "error rendering response", "err", err)
+		}
+		return
+	}
+
+	audit.SetIMXID(r.Context(), strconv.FormatInt(int64(tradeID), 10))
+
+	request.Status = models.SignatureRequestSubmitted
+	messages := h.tradeEvents(r.Context(), user.ID, events.Trade{TradeID: tradeID, OrderID: request.OrderID})
+	err = h.db.WithContext(r.Context()).UpdateSignatureRequest(request, messages...)
+	if err != nil {
+		// the request stays claimed, it cannot be submitted again
+		slog.ErrorContext(r.Context(), "error saving signature request", "err", err)
+	}
+
+	render.Status(r, http.StatusCreated)
+	err = render.Render(w, r, NewTradeResponse(tradeID))
+	if err != nil {
+		slog.ErrorContext(r.Context(), "error rendering response", "err", err)
+	}
+}
@@ -0,0 +1,88 @@
+package handlers
+
+import (
+	"net/http"
+	"nft/audit"
+	"nft/imx"
+	"nft/models"
+	"strconv"
+
+	"github.com/go-chi/render"
+	"github.com/google/uuid"
+	"golang.org/x/exp/slog"
+)
+
+// SubmitWithdrawal sends a withdrawal signed by an external wallet. Completing it on L1 once it is
+// confirmed is left to the wallet owner, as the server cannot sign L1 transactions on their behalf.
+func (h *Handler) SubmitWithdrawal(w http.ResponseWriter, r *http.Request) {
+	data := &SignatureRequest{}
+	if err := render.Bind(r, data); err != nil {
+		err = render.Render(w, r, ErrInvalidRequest(err))
+		if err != nil {
+			slog.ErrorContext(r.Context(), "error rendering response", "err", err)
+		}
+		return
+	}
+
+	request, user, errResponse := h.getPendingSignatureRequest(r, models.SignatureRequestWithdrawal)
+	if errResponse != nil {
+		err := render.Render(w, r, errResponse)
+		if err != nil {
+			slog.ErrorContext(r.Context(), "error rendering response", "err", err)
+		}
+		return
+	}
+
+	audit.Describe(r.Context(), "withdrawal.submit", "user", user.ID.String())
+	audit.SetDetails(r.Context(), "signature request "+request.ID.String())
+
+	errResponse = h.claimSignatureRequest(r.Context(), request)
+	if errResponse != nil {
+		err := render.Render(w, r, errResponse)
+		if err != nil {
+			slog.ErrorContext(r.Context(), "error rendering response", "err", err)
+		}
+		return
+	}
+
+	info := imx.SubmitWithdrawalInformation{
+		User:           user,
+		Payload:        request.Payload,
+		EthSignature:   data.EthSignature,
+		StarkSignature: data.StarkSignature,
+	}
+
+	withdrawalID, err := h.imx.SubmitWithdrawal(r.Context(), &info)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "error submitting withdrawal", "err", err)
+		h.releaseSignatureRequest(r.Context(), request, err)
+		err = render.Render(w, r, ErrIMX(err, ErrInvalidRequest))
+		if err != nil {
+			slog.ErrorContext(r.Context(), "error rendering response", "err", err)
+		}
+		return
+	}
+
+	audit.Describe(r.Context(), "withdrawal.submit", "withdrawal", strconv.FormatInt(int64(withdrawalID), 10))
+	audit.SetIMXID(r.Context(), strconv.FormatInt(int64(withdrawalID), 10))
+
+	request.Status = models.SignatureRequestSubmitted
+	withdrawal := models.Withdrawal{
+		ID:           uuid.New(),
+		UserID:       user.ID,
+		WithdrawalID: &withdrawalID,
+		AmountWei:    request.AmountWei,
+		Status:       models.WithdrawalAwaitingUser,
+	}
+	err = h.db.WithContext(r.Context()).SaveSubmittedWithdrawal(request, &withdrawal)
+	if err != nil {
+		// the request stays claimed, it cannot be submitted again
+		slog.ErrorContext(r.Context(), "error saving signature request", "err", err)
+	}
+
+	render.Status(r, http.StatusCreated)
+	err = render.Render(w, r, NewWithdrawalResponse(withdrawalID))
+	if err != nil {
+		slog.ErrorContext(r.Context(), "error rendering response", "err", err)
+	}
+}
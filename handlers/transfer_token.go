@@ -3,12 +3,15 @@ package handlers
 import (
 	"errors"
 	"net/http"
+	"nft/audit"
+	"nft/auth"
+	"nft/events"
 	"nft/imx"
+	"nft/models"
 
-	"github.com/ethereum/go-ethereum/log"
-	"github.com/go-chi/oauth"
 	"github.com/go-chi/render"
 	"github.com/google/uuid"
+	"golang.org/x/exp/slog"
 )
 
 func (h *Handler) TransferToken(w http.ResponseWriter, r *http.Request) {
@@ -16,121 +19,167 @@ func (h *Handler) TransferToken(w http.ResponseWriter, r *http.Request) {
 	if err := render.Bind(r, data); err != nil {
 		err = render.Render(w, r, ErrInvalidRequest(err))
 		if err != nil {
-			log.Error("error rendering response", err)
+			slog.ErrorContext(r.Context(), "error rendering response", "err", err)
 		}
 		return
 	}
 
 	collectionID, err := uuid.Parse(data.CollectionID)
 	if err != nil {
-		log.Error("error parsing collection", err)
+		slog.ErrorContext(r.Context(), "error parsing collection", "err", err)
 		err = render.Render(w, r, ErrInvalidRequest(err))
 		if err != nil {
-			log.Error("error rendering response", err)
+			slog.ErrorContext(r.Context(), "error rendering response", "err", err)
 		}
 		return
 	}
 
-	collection, err := h.db.GetCollection(collectionID)
+	collection, err := h.db.WithContext(r.Context()).GetCollection(collectionID)
 	if err != nil {
-		log.Error("error getting collection", err)
+		slog.ErrorContext(r.Context(), "error getting collection", "err", err)
 		err = render.Render(w, r, ErrInvalidRequest(err))
 		if err != nil {
-			log.Error("error rendering response", err)
+			slog.ErrorContext(r.Context(), "error rendering response", "err", err)
 		}
 		return
 	}
 
-	if collection == nil {
+	if collection == nil || collection.Hidden {
 		err = errors.New("collection missing")
-		log.Error("error getting collection", err)
+		slog.ErrorContext(r.Context(), "error getting collection", "err", err)
 		err = render.Render(w, r, ErrInvalidRequest(err))
 		if err != nil {
-			log.Error("error rendering response", err)
+			slog.ErrorContext(r.Context(), "error rendering response", "err", err)
 		}
 		return
 	}
 
-	userID, err := uuid.Parse(r.Context().Value(oauth.CredentialContext).(string))
+	userID, err := auth.GetUserID(r.Context())
 	if err != nil {
-		log.Error("error parsing user", err)
-		err = render.Render(w, r, ErrInvalidRequest(err))
+		slog.ErrorContext(r.Context(), "error parsing user", "err", err)
+		err = render.Render(w, r, ErrUnauthorized(err))
+		if err != nil {
+			slog.ErrorContext(r.Context(), "error rendering response", "err", err)
+		}
+		return
+	}
+
+	allowed, err := h.hasRole(r.Context(), collection.OrganizationID, userID, models.RoleMinter)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "error getting organization member", "err", err)
+		err = render.Render(w, r, ErrServer(err))
 		if err != nil {
-			log.Error("error rendering response", err)
+			slog.ErrorContext(r.Context(), "error rendering response", "err", err)
 		}
 		return
 	}
 
-	if collection.UserID != userID {
+	if !allowed {
 		err = errors.New("invalid collection")
-		log.Error("invalid collection", err)
-		err = render.Render(w, r, ErrInvalidRequest(err))
+		slog.ErrorContext(r.Context(), "invalid collection", "err", err)
+		err = render.Render(w, r, ErrForbidden(err))
 		if err != nil {
-			log.Error("error rendering response", err)
+			slog.ErrorContext(r.Context(), "error rendering response", "err", err)
 		}
 		return
 	}
 
 	tokenID, err := uuid.Parse(data.TokenID)
 	if err != nil {
-		log.Error("error parsing token", err)
+		slog.ErrorContext(r.Context(), "error parsing token", "err", err)
 		err = render.Render(w, r, ErrInvalidRequest(err))
 		if err != nil {
-			log.Error("error rendering response", err)
+			slog.ErrorContext(r.Context(), "error rendering response", "err", err)
 		}
 		return
 	}
+	audit.Describe(r.Context(), "token.transfer", "token", tokenID.String())
+	audit.SetDetails(r.Context(), "receiver "+data.ReceiverAddress)
 
-	token, err := h.db.GetToken(tokenID)
+	token, err := h.db.WithContext(r.Context()).GetToken(tokenID)
 	if err != nil {
-		log.Error("error getting token", err)
+		slog.ErrorContext(r.Context(), "error getting token", "err", err)
 		err = render.Render(w, r, ErrInvalidRequest(err))
 		if err != nil {
-			log.Error("error rendering response", err)
+			slog.ErrorContext(r.Context(), "error rendering response", "err", err)
 		}
 		return
 	}
 
 	if token == nil {
 		err = errors.New("token missing")
-		log.Error("error getting token", err)
+		slog.ErrorContext(r.Context(), "error getting token", "err", err)
 		err = render.Render(w, r, ErrInvalidRequest(err))
 		if err != nil {
-			log.Error("error rendering response", err)
+			slog.ErrorContext(r.Context(), "error rendering response", "err", err)
 		}
 		return
 	}
 
 	if token.CollectionID != collectionID {
 		err = errors.New("invalid token")
-		log.Error("invalid token", err)
+		slog.ErrorContext(r.Context(), "invalid token", "err", err)
 		err = render.Render(w, r, ErrInvalidRequest(err))
 		if err != nil {
-			log.Error("error rendering response", err)
+			slog.ErrorContext(r.Context(), "error rendering response", "err", err)
+		}
+		return
+	}
+
+	if token.Status != models.TokenMinted {
+		err = errors.New("token not minted")
+		slog.ErrorContext(r.Context(), "token not minted", "err", err, "status", token.Status)
+		err = render.Render(w, r, ErrConflict(err))
+		if err != nil {
+			slog.ErrorContext(r.Context(), "error rendering response", "err", err)
 		}
 		return
 	}
 
 	info := imx.TransferInformation{
 		ContractAddress: collection.ContractAddress,
-		TokenID:         data.TokenID,
+		TokenID:         token.TokenID,
 		ReceiverAddress: data.ReceiverAddress,
 	}
 
 	err = h.imx.TransferToken(r.Context(), &info)
 	if err != nil {
-		log.Error("error transferring token", err)
-		err = render.Render(w, r, ErrInvalidRequest(err))
+		slog.ErrorContext(r.Context(), "error transferring token", "err", err)
+		err = render.Render(w, r, ErrIMX(err, ErrInvalidRequest))
 		if err != nil {
-			log.Error("error rendering response", err)
+			slog.ErrorContext(r.Context(), "error rendering response", "err", err)
 		}
 		return
 	}
 
+	// reconciliation corrects the owner if it could not be saved
+	err = h.db.WithContext(r.Context()).SetTokenOwner(token.ID, data.ReceiverAddress)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "error saving token owner", "err", err)
+	}
+
+	transfer := events.Transfer{
+		CollectionID:    data.CollectionID,
+		TokenID:         data.TokenID,
+		ReceiverAddress: data.ReceiverAddress,
+	}
+	messages := h.event(r.Context(), userID, events.TransferCompleted, transfer)
+
+	// the receiver is notified too when they are a user of the platform
+	receiver, err := h.db.WithContext(r.Context()).GetUserByAddress(data.ReceiverAddress)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "error getting receiver", "err", err)
+	}
+	if receiver != nil && receiver.ID != userID {
+		messages = append(messages, h.event(r.Context(), receiver.ID, events.TransferCompleted, transfer)...)
+	}
+
+	h.publish(r.Context(), messages...)
+
 	render.Status(r, http.StatusCreated)
 	err = render.Render(w, r, NewTransferTokenResponse(data.TokenID))
 	if err != nil {
-		log.Error("error rendering response", err)
+		slog.ErrorContext(r.Context(), "error rendering response", "err", err)
 	}
 }
 
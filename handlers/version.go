@@ -0,0 +1,33 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/carlmjohnson/versioninfo"
+	"github.com/go-chi/render"
+	"golang.org/x/exp/slog"
+)
+
+// Version reports the build and the IMX environment the server talks to.
+func Version(environment string, chainID int64) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		err := render.Render(w, r, &VersionResponse{
+			Version:        versioninfo.Short(),
+			IMXEnvironment: environment,
+			ChainID:        chainID,
+		})
+		if err != nil {
+			slog.ErrorContext(r.Context(), "error rendering response", "err", err)
+		}
+	}
+}
+
+type VersionResponse struct {
+	Version        string `json:"version"`
+	IMXEnvironment string `json:"imx_environment"`
+	ChainID        int64  `json:"chain_id"`
+}
+
+func (rd *VersionResponse) Render(w http.ResponseWriter, r *http.Request) error {
+	return nil
+}
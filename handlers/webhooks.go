@@ -0,0 +1,260 @@
+package handlers
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"nft/audit"
+	"nft/auth"
+	"nft/events"
+	"nft/models"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/render"
+	"github.com/google/uuid"
+	"golang.org/x/exp/slog"
+)
+
+func (h *Handler) CreateWebhook(w http.ResponseWriter, r *http.Request) {
+	data := &WebhookRequest{}
+	if err := render.Bind(r, data); err != nil {
+		err = render.Render(w, r, ErrInvalidRequest(err))
+		if err != nil {
+			slog.ErrorContext(r.Context(), "error rendering response", "err", err)
+		}
+		return
+	}
+
+	userID, err := auth.GetUserID(r.Context())
+	if err != nil {
+		slog.ErrorContext(r.Context(), "error parsing user", "err", err)
+		err = render.Render(w, r, ErrUnauthorized(err))
+		if err != nil {
+			slog.ErrorContext(r.Context(), "error rendering response", "err", err)
+		}
+		return
+	}
+
+	secret, err := newWebhookSecret()
+	if err != nil {
+		slog.ErrorContext(r.Context(), "error creating webhook secret", "err", err)
+		err = render.Render(w, r, ErrServer(err))
+		if err != nil {
+			slog.ErrorContext(r.Context(), "error rendering response", "err", err)
+		}
+		return
+	}
+
+	webhook := models.Webhook{
+		ID:     uuid.New(),
+		UserID: userID,
+		URL:    data.URL,
+		Secret: secret,
+		Events: strings.Join(data.Events, ","),
+	}
+	audit.Describe(r.Context(), "webhook.create", "webhook", webhook.ID.String())
+
+	err = h.db.WithContext(r.Context()).CreateWebhook(&webhook)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "error saving webhook", "err", err)
+		err = render.Render(w, r, ErrServer(err))
+		if err != nil {
+			slog.ErrorContext(r.Context(), "error rendering response", "err", err)
+		}
+		return
+	}
+
+	// the secret is only shown once, when the webhook is created
+	resp := NewWebhookResponse(&webhook)
+	resp.Secret = webhook.Secret
+
+	render.Status(r, http.StatusCreated)
+	err = render.Render(w, r, resp)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "error rendering response", "err", err)
+	}
+}
+
+func (h *Handler) ListWebhooks(w http.ResponseWriter, r *http.Request) {
+	userID, err := auth.GetUserID(r.Context())
+	if err != nil {
+		slog.ErrorContext(r.Context(), "error parsing user", "err", err)
+		err = render.Render(w, r, ErrUnauthorized(err))
+		if err != nil {
+			slog.ErrorContext(r.Context(), "error rendering response", "err", err)
+		}
+		return
+	}
+
+	webhooks, err := h.db.WithContext(r.Context()).ListWebhooks(userID)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "error listing webhooks", "err", err)
+		err = render.Render(w, r, ErrServer(err))
+		if err != nil {
+			slog.ErrorContext(r.Context(), "error rendering response", "err", err)
+		}
+		return
+	}
+
+	list := make([]render.Renderer, 0, len(webhooks))
+	for i := range webhooks {
+		list = append(list, NewWebhookResponse(&webhooks[i]))
+	}
+
+	err = render.RenderList(w, r, list)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "error rendering response", "err", err)
+	}
+}
+
+func (h *Handler) DeleteWebhook(w http.ResponseWriter, r *http.Request) {
+	webhook, errResponse := h.getOwnedWebhook(r)
+	if errResponse != nil {
+		err := render.Render(w, r, errResponse)
+		if err != nil {
+			slog.ErrorContext(r.Context(), "error rendering response", "err", err)
+		}
+		return
+	}
+
+	audit.Describe(r.Context(), "webhook.delete", "webhook", webhook.ID.String())
+
+	err := h.db.WithContext(r.Context()).DeleteWebhook(webhook.ID)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "error deleting webhook", "err", err)
+		err = render.Render(w, r, ErrServer(err))
+		if err != nil {
+			slog.ErrorContext(r.Context(), "error rendering response", "err", err)
+		}
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *Handler) ListWebhookDeliveries(w http.ResponseWriter, r *http.Request) {
+	webhook, errResponse := h.getOwnedWebhook(r)
+	if errResponse != nil {
+		err := render.Render(w, r, errResponse)
+		if err != nil {
+			slog.ErrorContext(r.Context(), "error rendering response", "err", err)
+		}
+		return
+	}
+
+	offset, limit, err := getPagination(r)
+	if err != nil {
+		err = render.Render(w, r, ErrInvalidRequest(err))
+		if err != nil {
+			slog.ErrorContext(r.Context(), "error rendering response", "err", err)
+		}
+		return
+	}
+
+	deliveries, err := h.db.WithContext(r.Context()).ListWebhookDeliveries(webhook.ID, offset, limit)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "error listing webhook deliveries", "err", err)
+		err = render.Render(w, r, ErrServer(err))
+		if err != nil {
+			slog.ErrorContext(r.Context(), "error rendering response", "err", err)
+		}
+		return
+	}
+
+	list := make([]render.Renderer, 0, len(deliveries))
+	for i := range deliveries {
+		list = append(list, NewWebhookDeliveryResponse(&deliveries[i]))
+	}
+
+	err = render.RenderList(w, r, list)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "error rendering response", "err", err)
+	}
+}
+
+// getOwnedWebhook returns the webhook in the URL. Webhooks of other users are reported as missing.
+func (h *Handler) getOwnedWebhook(r *http.Request) (*models.Webhook, render.Renderer) {
+	userID, err := auth.GetUserID(r.Context())
+	if err != nil {
+		slog.ErrorContext(r.Context(), "error parsing user", "err", err)
+		return nil, ErrUnauthorized(err)
+	}
+
+	webhookID, err := uuid.Parse(chi.URLParam(r, "webhookID"))
+	if err != nil {
+		return nil, ErrInvalidRequest(err)
+	}
+
+	webhook, err := h.db.WithContext(r.Context()).GetWebhook(webhookID)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "error getting webhook", "err", err)
+		return nil, ErrServer(err)
+	}
+
+	if webhook == nil || webhook.UserID != userID {
+		return nil, ErrNotFound
+	}
+
+	return webhook, nil
+}
+
+func newWebhookSecret() (string, error) {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(secret), nil
+}
+
+type WebhookRequest struct {
+	URL    string   `json:"url"`
+	Events []string `json:"events"`
+}
+
+func (a *WebhookRequest) Bind(r *http.Request) error {
+	if len(a.URL) == 0 || len(a.Events) == 0 {
+		return errors.New("missing required fields")
+	}
+
+	if err := events.ValidateWebhookURL(a.URL); err != nil {
+		return err
+	}
+
+	for _, event := range a.Events {
+		if !events.IsValidType(event) {
+			return errors.New("invalid event " + event)
+		}
+	}
+
+	return nil
+}
+
+type WebhookResponse struct {
+	*models.Webhook
+	Events []string `json:"events"`
+	Secret string   `json:"secret,omitempty"`
+}
+
+func NewWebhookResponse(webhook *models.Webhook) *WebhookResponse {
+	resp := &WebhookResponse{Webhook: webhook, Events: webhook.EventList()}
+	return resp
+}
+
+func (rd *WebhookResponse) Render(w http.ResponseWriter, r *http.Request) error {
+	return nil
+}
+
+type WebhookDeliveryResponse struct {
+	*models.WebhookDelivery
+}
+
+func NewWebhookDeliveryResponse(delivery *models.WebhookDelivery) *WebhookDeliveryResponse {
+	resp := &WebhookDeliveryResponse{WebhookDelivery: delivery}
+	return resp
+}
+
+func (rd *WebhookDeliveryResponse) Render(w http.ResponseWriter, r *http.Request) error {
+	return nil
+}
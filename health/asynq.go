@@ -0,0 +1,28 @@
+package health
+
+import (
+	"context"
+	"errors"
+
+	"github.com/hibiken/asynq"
+)
+
+var ErrNoWorkers = errors.New("no active asynq server")
+
+// Workers checks an asynq server is processing the queues. The servers report their state to Redis
+// every few seconds, a server just started may not be listed yet.
+func Workers(inspector *asynq.Inspector) Check {
+	return func(ctx context.Context) error {
+		servers, err := inspector.Servers()
+		if err != nil {
+			return err
+		}
+
+		for _, server := range servers {
+			if server.Status == "active" {
+				return nil
+			}
+		}
+		return ErrNoWorkers
+	}
+}
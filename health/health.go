@@ -0,0 +1,143 @@
+// Package health serves the liveness and readiness probes of the service, and the state of every dependency.
+package health
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/go-chi/render"
+	"golang.org/x/exp/slog"
+)
+
+const (
+	StatusOK          = "ok"
+	StatusUnavailable = "unavailable"
+
+	// DefaultTimeout bounds each check, a dependency slower than that is not ready.
+	DefaultTimeout = 5 * time.Second
+)
+
+// Check returns an error when the dependency cannot be used.
+type Check func(ctx context.Context) error
+
+// Result is the outcome of a check.
+type Result struct {
+	Status     string `json:"status"`
+	Error      string `json:"error,omitempty"`
+	DurationMS int64  `json:"duration_ms"`
+}
+
+// Report is the outcome of every check, it is ok only when all of them are.
+type Report struct {
+	Status string            `json:"status"`
+	Checks map[string]Result `json:"checks,omitempty"`
+}
+
+// Checker runs the checks of the dependencies needed to serve requests.
+type Checker struct {
+	timeout time.Duration
+	checks  map[string]Check
+}
+
+func NewChecker(timeout time.Duration) *Checker {
+	return &Checker{timeout: timeout, checks: make(map[string]Check)}
+}
+
+// Add registers the check of a dependency under its name.
+func (c *Checker) Add(name string, check Check) {
+	c.checks[name] = check
+}
+
+// Run runs the checks concurrently, each with the timeout of the checker.
+func (c *Checker) Run(ctx context.Context) Report {
+	report := Report{Status: StatusOK, Checks: make(map[string]Result, len(c.checks))}
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for name, check := range c.checks {
+		wg.Add(1)
+		go func(name string, check Check) {
+			defer wg.Done()
+			result := c.run(ctx, check)
+
+			mu.Lock()
+			defer mu.Unlock()
+			report.Checks[name] = result
+			if result.Status != StatusOK {
+				report.Status = StatusUnavailable
+			}
+		}(name, check)
+	}
+	wg.Wait()
+
+	return report
+}
+
+func (c *Checker) run(ctx context.Context, check Check) Result {
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+
+	start := time.Now()
+	err := check(ctx)
+	result := Result{Status: StatusOK, DurationMS: time.Since(start).Milliseconds()}
+	if err != nil {
+		result.Status = StatusUnavailable
+		result.Error = err.Error()
+	}
+	return result
+}
+
+// Cached returns check with its result kept for ttl, so an endpoint polled often does not call a remote
+// dependency on every request. A check interrupted by its caller is not kept.
+func Cached(check Check, ttl time.Duration) Check {
+	var mu sync.Mutex
+	var checkedAt time.Time
+	var last error
+	return func(ctx context.Context) error {
+		mu.Lock()
+		defer mu.Unlock()
+		if !checkedAt.IsZero() && time.Since(checkedAt) < ttl {
+			return last
+		}
+
+		err := check(ctx)
+		if ctx.Err() == nil {
+			last, checkedAt = err, time.Now()
+		}
+		return err
+	}
+}
+
+// Live answers the liveness probe: the process serves requests, whatever the state of its dependencies.
+func Live(w http.ResponseWriter, r *http.Request) {
+	render.JSON(w, r, Report{Status: StatusOK})
+}
+
+// Ready answers the readiness probe with the result of every check, and 503 Service Unavailable when one
+// of them failed.
+func (c *Checker) Ready(w http.ResponseWriter, r *http.Request) {
+	report := c.Run(r.Context())
+	if report.Status != StatusOK {
+		render.Status(r, http.StatusServiceUnavailable)
+	}
+	render.JSON(w, r, report)
+}
+
+// Details answers with the status of every check and 200 OK whatever they are. It reports the dependencies
+// the service degrades without, which must not take it out of rotation. It is public, so the errors of the
+// failed checks are logged rather than answered.
+func (c *Checker) Details(w http.ResponseWriter, r *http.Request) {
+	report := c.Run(r.Context())
+	for name, result := range report.Checks {
+		if len(result.Error) == 0 {
+			continue
+		}
+
+		slog.WarnContext(r.Context(), "dependency unavailable", "dependency", name, "err", result.Error)
+		result.Error = ""
+		report.Checks[name] = result
+	}
+	render.JSON(w, r, report)
+}
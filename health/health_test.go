@@ -0,0 +1,132 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type UnitTestSuite struct {
+	suite.Suite
+}
+
+func ok(ctx context.Context) error {
+	return nil
+}
+
+func (s *UnitTestSuite) ready(checker *Checker) (int, Report) {
+	response := httptest.NewRecorder()
+	checker.Ready(response, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+
+	var report Report
+	s.Require().NoError(json.Unmarshal(response.Body.Bytes(), &report))
+	return response.Code, report
+}
+
+func (s *UnitTestSuite) TestLive() {
+	response := httptest.NewRecorder()
+	Live(response, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+
+	s.Equal(http.StatusOK, response.Code)
+	s.JSONEq(`{"status":"ok"}`, response.Body.String())
+}
+
+func (s *UnitTestSuite) TestReady() {
+	checker := NewChecker(time.Second)
+	checker.Add("db", ok)
+	checker.Add("redis", ok)
+
+	code, report := s.ready(checker)
+	s.Equal(http.StatusOK, code)
+	s.Equal(StatusOK, report.Status)
+	s.Len(report.Checks, 2)
+	s.Equal(StatusOK, report.Checks["db"].Status)
+}
+
+func (s *UnitTestSuite) TestNotReadyWhenACheckFails() {
+	checker := NewChecker(time.Second)
+	checker.Add("db", ok)
+	checker.Add("redis", func(ctx context.Context) error {
+		return errors.New("connection refused")
+	})
+
+	code, report := s.ready(checker)
+	s.Equal(http.StatusServiceUnavailable, code)
+	s.Equal(StatusUnavailable, report.Status)
+	s.Equal(StatusOK, report.Checks["db"].Status)
+	s.Equal(Result{Status: StatusUnavailable, Error: "connection refused"}, report.Checks["redis"])
+}
+
+func (s *UnitTestSuite) TestSlowCheckTimesOut() {
+	checker := NewChecker(10 * time.Millisecond)
+	checker.Add("imx", func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	})
+
+	report := checker.Run(context.Background())
+	s.Equal(StatusUnavailable, report.Status)
+	s.Equal(context.DeadlineExceeded.Error(), report.Checks["imx"].Error)
+}
+
+func (s *UnitTestSuite) TestDetailsReportsFailedChecks() {
+	checker := NewChecker(time.Second)
+	checker.Add("db", ok)
+	checker.Add("imx", func(ctx context.Context) error {
+		return errors.New("connection refused")
+	})
+
+	response := httptest.NewRecorder()
+	checker.Details(response, httptest.NewRequest(http.MethodGet, "/health", nil))
+
+	var report Report
+	s.Require().NoError(json.Unmarshal(response.Body.Bytes(), &report))
+	s.Equal(http.StatusOK, response.Code)
+	s.Equal(StatusUnavailable, report.Status)
+	s.Equal(StatusUnavailable, report.Checks["imx"].Status)
+	s.Empty(report.Checks["imx"].Error)
+}
+
+func (s *UnitTestSuite) TestCachedCheck() {
+	calls := 0
+	check := Cached(func(ctx context.Context) error {
+		calls++
+		return errors.New("connection refused")
+	}, time.Hour)
+
+	s.EqualError(check(context.Background()), "connection refused")
+	s.EqualError(check(context.Background()), "connection refused")
+	s.Equal(1, calls)
+
+	expired := Cached(func(ctx context.Context) error {
+		calls++
+		return nil
+	}, 0)
+	s.NoError(expired(context.Background()))
+	s.NoError(expired(context.Background()))
+	s.Equal(3, calls)
+}
+
+func (s *UnitTestSuite) TestCachedCheckDoesNotKeepCanceledChecks() {
+	calls := 0
+	check := Cached(func(ctx context.Context) error {
+		calls++
+		return ctx.Err()
+	}, time.Hour)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	s.ErrorIs(check(ctx), context.Canceled)
+	s.NoError(check(context.Background()))
+	s.Equal(2, calls)
+}
+
+func TestUnitTestSuite(t *testing.T) {
+	suite.Run(t, new(UnitTestSuite))
+}
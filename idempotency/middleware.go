@@ -0,0 +1,191 @@
+package idempotency
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net/http"
+	"nft/auth"
+	"nft/db"
+	"nft/internal/operation"
+	"nft/models"
+	"time"
+
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/google/uuid"
+	"golang.org/x/exp/slog"
+)
+
+const (
+	// Header is the request header carrying the client chosen idempotency key.
+	Header = "Idempotency-Key"
+	// ReplayedHeader is set on responses replayed from a previous request.
+	ReplayedHeader = "Idempotent-Replayed"
+
+	maxKeyLength = 255
+	// maxBodySize bounds the bodies read to hash the request.
+	maxBodySize = 1 << 20
+
+	// KeyTTL is how long completed requests are replayed, their key can be used again after.
+	KeyTTL = 24 * time.Hour
+	// KeyLease is how long a request may stay in progress. Past it, the request is deemed abandoned, e.g. by
+	// a crashed instance, and a retry runs it again. It is longer than the IMX timeouts.
+	KeyLease = 5 * time.Minute
+)
+
+// Store persists the idempotency keys and the responses rendered for them.
+type Store interface {
+	CreateIdempotencyKey(key *models.IdempotencyKey, completedBefore int64, startedBefore int64) (bool, error)
+	GetIdempotencyKey(clientID uuid.UUID, key string) (*models.IdempotencyKey, error)
+	CompleteIdempotencyKey(key *models.IdempotencyKey) error
+	DeleteIdempotencyKey(key *models.IdempotencyKey) error
+}
+
+var _ Store = (*db.DB)(nil)
+
+// Middleware makes mutating requests carrying an Idempotency-Key header safe to retry. The first
+// request with a key runs normally and its response is stored; repeating it replays that response
+// without running the handler again. Reusing a key with a different request is rejected with
+// 409 Conflict, as is repeating a request that is still in progress. Server errors and rate limits are
+// not stored so the request can be retried, unless IMX may have applied it.
+type Middleware struct {
+	store Store
+}
+
+func NewMiddleware(store Store) *Middleware {
+	return &Middleware{store}
+}
+
+// Handle must run after authorization, keys are scoped to the authenticated client.
+func (m *Middleware) Handle(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		value := r.Header.Get(Header)
+		if len(value) == 0 || !operation.IsMutating(r.Method) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		if len(value) > maxKeyLength {
+			http.Error(w, "invalid idempotency key", http.StatusBadRequest)
+			return
+		}
+
+		clientID, err := auth.GetUserID(r.Context())
+		if err != nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxBodySize))
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			http.Error(w, "request body too large", http.StatusRequestEntityTooLarge)
+			return
+		}
+		if err != nil {
+			http.Error(w, "invalid request body", http.StatusBadRequest)
+			return
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+
+		key := &models.IdempotencyKey{
+			ClientID:    clientID,
+			Key:         value,
+			RequestHash: hashRequest(r, body),
+		}
+
+		now := time.Now()
+		created, err := m.store.CreateIdempotencyKey(key, now.Add(-KeyTTL).UnixMilli(), now.Add(-KeyLease).UnixMilli())
+		if err != nil {
+			slog.ErrorContext(r.Context(), "error saving idempotency key", "err", err)
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return
+		}
+
+		if !created {
+			m.replay(r.Context(), w, key)
+			return
+		}
+
+		var buffer bytes.Buffer
+		ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+		ww.Tee(&buffer)
+
+		defer func() {
+			if rec := recover(); rec != nil {
+				// let the request be retried, nothing was rendered for it
+				m.release(r.Context(), key)
+				panic(rec)
+			}
+		}()
+
+		next.ServeHTTP(ww, r)
+
+		status := ww.Status()
+		if status == 0 {
+			status = http.StatusOK
+		}
+
+		// a timed out IMX write may have been applied, its 504 is kept so it is not sent again. A rate limited
+		// request was not applied, it can be retried once the limit resets.
+		if (status >= http.StatusInternalServerError && status != http.StatusGatewayTimeout) || status == http.StatusTooManyRequests {
+			m.release(r.Context(), key)
+			return
+		}
+
+		key.StatusCode = status
+		key.ContentType = ww.Header().Get("Content-Type")
+		key.ResponseBody = buffer.Bytes()
+		err = m.store.CompleteIdempotencyKey(key)
+		if err != nil {
+			slog.ErrorContext(r.Context(), "error saving idempotent response", "err", err)
+		}
+	})
+}
+
+func (m *Middleware) replay(ctx context.Context, w http.ResponseWriter, key *models.IdempotencyKey) {
+	stored, err := m.store.GetIdempotencyKey(key.ClientID, key.Key)
+	if err != nil || stored == nil {
+		slog.ErrorContext(ctx, "error getting idempotency key", "err", err)
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	if stored.RequestHash != key.RequestHash {
+		http.Error(w, "idempotency key already used for a different request", http.StatusConflict)
+		return
+	}
+
+	if !stored.Completed {
+		http.Error(w, "request with the same idempotency key in progress", http.StatusConflict)
+		return
+	}
+
+	if len(stored.ContentType) > 0 {
+		w.Header().Set("Content-Type", stored.ContentType)
+	}
+	w.Header().Set(ReplayedHeader, "true")
+	w.WriteHeader(stored.StatusCode)
+	_, err = w.Write(stored.ResponseBody)
+	if err != nil {
+		slog.ErrorContext(ctx, "error writing response", "err", err)
+	}
+}
+
+func (m *Middleware) release(ctx context.Context, key *models.IdempotencyKey) {
+	err := m.store.DeleteIdempotencyKey(key)
+	if err != nil {
+		slog.ErrorContext(ctx, "error deleting idempotency key", "err", err)
+	}
+}
+
+// hashRequest identifies the request the key was used with, so the key is not reused for another one.
+func hashRequest(r *http.Request, body []byte) string {
+	hash := sha256.New()
+	hash.Write([]byte(r.Method + " " + r.URL.Path + "\n"))
+	hash.Write(body)
+	return hex.EncodeToString(hash.Sum(nil))
+}
@@ -0,0 +1,197 @@
+package idempotency
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"nft/models"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-chi/oauth"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/suite"
+)
+
+type memoryStore struct {
+	keys map[string]models.IdempotencyKey
+}
+
+func (m *memoryStore) CreateIdempotencyKey(key *models.IdempotencyKey, completedBefore int64, startedBefore int64) (bool, error) {
+	id := key.ClientID.String() + key.Key
+	if stored, ok := m.keys[id]; ok {
+		expired := (stored.Completed && stored.UpdatedAt < completedBefore) || (!stored.Completed && stored.UpdatedAt < startedBefore)
+		if !expired {
+			return false, nil
+		}
+	}
+	key.UpdatedAt = time.Now().UnixMilli()
+	m.keys[id] = *key
+	return true, nil
+}
+
+func (m *memoryStore) GetIdempotencyKey(clientID uuid.UUID, key string) (*models.IdempotencyKey, error) {
+	stored, ok := m.keys[clientID.String()+key]
+	if !ok {
+		return nil, nil
+	}
+	return &stored, nil
+}
+
+func (m *memoryStore) CompleteIdempotencyKey(key *models.IdempotencyKey) error {
+	key.Completed = true
+	key.UpdatedAt = time.Now().UnixMilli()
+	m.keys[key.ClientID.String()+key.Key] = *key
+	return nil
+}
+
+func (m *memoryStore) DeleteIdempotencyKey(key *models.IdempotencyKey) error {
+	delete(m.keys, key.ClientID.String()+key.Key)
+	return nil
+}
+
+type UnitTestSuite struct {
+	suite.Suite
+	store    *memoryStore
+	calls    int
+	status   int
+	clientID string
+	handler  http.Handler
+}
+
+func (s *UnitTestSuite) SetupTest() {
+	s.store = &memoryStore{keys: map[string]models.IdempotencyKey{}}
+	s.calls = 0
+	s.clientID = uuid.NewString()
+	s.status = http.StatusCreated
+	s.handler = NewMiddleware(s.store).Handle(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.calls++
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(s.status)
+		_, _ = w.Write([]byte(`{"call":` + strconv.Itoa(s.calls) + `}`))
+	}))
+}
+
+func (s *UnitTestSuite) serve(key string, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest("POST", "/tokens", strings.NewReader(body))
+	if len(key) > 0 {
+		req.Header.Set(Header, key)
+	}
+	req = req.WithContext(context.WithValue(req.Context(), oauth.CredentialContext, s.clientID))
+
+	rr := httptest.NewRecorder()
+	s.handler.ServeHTTP(rr, req)
+	return rr
+}
+
+func (s *UnitTestSuite) TestReplayResponse() {
+	first := s.serve("key", `{"token_id":"1"}`)
+	s.Assertions.Equal(http.StatusCreated, first.Code)
+
+	second := s.serve("key", `{"token_id":"1"}`)
+	s.Assertions.Equal(http.StatusCreated, second.Code)
+	s.Assertions.Equal(first.Body.String(), second.Body.String())
+	s.Assertions.Equal("application/json", second.Header().Get("Content-Type"))
+	s.Assertions.Equal("true", second.Header().Get(ReplayedHeader))
+	s.Assertions.Equal(1, s.calls)
+}
+
+func (s *UnitTestSuite) TestDifferentRequestShouldFail() {
+	s.serve("key", `{"token_id":"1"}`)
+
+	rr := s.serve("key", `{"token_id":"2"}`)
+	s.Assertions.Equal(http.StatusConflict, rr.Code)
+	s.Assertions.Equal(1, s.calls)
+}
+
+func (s *UnitTestSuite) TestRequestInProgressShouldFail() {
+	s.serve("key", `{"token_id":"1"}`)
+	stored := s.store.keys[s.clientID+"key"]
+	stored.Completed = false
+	s.store.keys[s.clientID+"key"] = stored
+
+	rr := s.serve("key", `{"token_id":"1"}`)
+	s.Assertions.Equal(http.StatusConflict, rr.Code)
+	s.Assertions.Equal(1, s.calls)
+}
+
+func (s *UnitTestSuite) TestWithoutKey() {
+	s.serve("", `{"token_id":"1"}`)
+	s.serve("", `{"token_id":"1"}`)
+	s.Assertions.Equal(2, s.calls)
+}
+
+func (s *UnitTestSuite) TestKeysScopedByClient() {
+	s.serve("key", `{"token_id":"1"}`)
+	s.clientID = uuid.NewString()
+	s.serve("key", `{"token_id":"1"}`)
+	s.Assertions.Equal(2, s.calls)
+}
+
+func (s *UnitTestSuite) TestServerErrorReleasesKey() {
+	s.status = http.StatusInternalServerError
+	first := s.serve("key", `{"token_id":"1"}`)
+	s.Assertions.Equal(http.StatusInternalServerError, first.Code)
+
+	s.status = http.StatusCreated
+	second := s.serve("key", `{"token_id":"1"}`)
+	s.Assertions.Equal(http.StatusCreated, second.Code)
+	s.Assertions.Equal(2, s.calls)
+}
+
+func (s *UnitTestSuite) TestRateLimitReleasesKey() {
+	s.status = http.StatusTooManyRequests
+	first := s.serve("key", `{"token_id":"1"}`)
+	s.Assertions.Equal(http.StatusTooManyRequests, first.Code)
+
+	s.status = http.StatusCreated
+	second := s.serve("key", `{"token_id":"1"}`)
+	s.Assertions.Equal(http.StatusCreated, second.Code)
+	s.Assertions.Equal(2, s.calls)
+}
+
+func (s *UnitTestSuite) TestUnknownOutcomeIsKept() {
+	s.status = http.StatusGatewayTimeout
+	s.serve("key", `{"token_id":"1"}`)
+
+	rr := s.serve("key", `{"token_id":"1"}`)
+	s.Assertions.Equal(http.StatusGatewayTimeout, rr.Code)
+	s.Assertions.Equal("true", rr.Header().Get(ReplayedHeader))
+	s.Assertions.Equal(1, s.calls)
+}
+
+func (s *UnitTestSuite) TestAbandonedRequestRunsAgain() {
+	s.serve("key", `{"token_id":"1"}`)
+	stored := s.store.keys[s.clientID+"key"]
+	stored.Completed = false
+	stored.UpdatedAt = time.Now().Add(-KeyLease - time.Second).UnixMilli()
+	s.store.keys[s.clientID+"key"] = stored
+
+	rr := s.serve("key", `{"token_id":"1"}`)
+	s.Assertions.Equal(http.StatusCreated, rr.Code)
+	s.Assertions.Empty(rr.Header().Get(ReplayedHeader))
+	s.Assertions.Equal(2, s.calls)
+}
+
+func (s *UnitTestSuite) TestExpiredKeyCanBeReused() {
+	s.serve("key", `{"token_id":"1"}`)
+	stored := s.store.keys[s.clientID+"key"]
+	stored.UpdatedAt = time.Now().Add(-KeyTTL - time.Second).UnixMilli()
+	s.store.keys[s.clientID+"key"] = stored
+
+	rr := s.serve("key", `{"token_id":"2"}`)
+	s.Assertions.Equal(http.StatusCreated, rr.Code)
+	s.Assertions.Equal(2, s.calls)
+}
+
+func (s *UnitTestSuite) TestBodyTooLargeShouldFail() {
+	rr := s.serve("key", `{"name":"`+strings.Repeat("a", maxBodySize)+`"}`)
+	s.Assertions.Equal(http.StatusRequestEntityTooLarge, rr.Code)
+	s.Assertions.Equal(0, s.calls)
+}
+
+func TestUnitTestSuite(t *testing.T) {
+	suite.Run(t, new(UnitTestSuite))
+}
@@ -0,0 +1,94 @@
+package imx
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+
+	"golang.org/x/exp/slog"
+)
+
+// Admin sets the platform up on IMX: its project, its signer and its collections. It is used from the command
+// line, once per environment and before the project ID is configured, so it is not part of Client.
+type Admin interface {
+	Close()
+	CreateProject(ctx context.Context, info *ProjectInformation) (int32, error)
+	RegisterSigner(ctx context.Context, email string) (*SignerInformation, error)
+	CreateCollection(ctx context.Context, info *CollectionInformation) error
+	CreateMetadata(ctx context.Context, info *MetadataInformation) error
+}
+
+var _ Admin = (*IMX)(nil)
+
+// SignerInformation identifies the platform signer on IMX.
+type SignerInformation struct {
+	Address  string
+	StarkKey string
+}
+
+// NewAdmin connects to IMX like NewIMX, but the project ID is optional as the project may not exist yet.
+// Collections are created in the project of CollectionInformation, or in the configured one.
+func NewAdmin(settings *Settings) (Admin, error) {
+	if err := settings.validateConnection(); err != nil {
+		return nil, err
+	}
+
+	i, err := newIMX(settings)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), verifyTimeout)
+	defer cancel()
+
+	if err = i.verifyChain(ctx); err != nil {
+		i.Close()
+		return nil, err
+	}
+
+	return i, nil
+}
+
+// RegisterSigner registers the platform signer offchain, which it must be to create projects and mint. An
+// already registered signer is left as is, unless it is registered with another stark key.
+func (i *IMX) RegisterSigner(ctx context.Context, email string) (*SignerInformation, error) {
+	info := &SignerInformation{
+		Address:  i.l1signer.GetAddress(),
+		StarkKey: i.l2signer.GetPublicKey(),
+	}
+
+	usersResponse, err := i.client.GetUsers(ctx, info.Address)
+	if err == nil {
+		for _, account := range usersResponse.GetAccounts() {
+			if sameStarkKey(account, info.StarkKey) {
+				slog.InfoContext(ctx, "signer already registered", "address", info.Address)
+				return info, nil
+			}
+		}
+		return nil, fmt.Errorf("%w: %s is registered with another stark key", ErrConflict, info.Address)
+	}
+
+	if err = classifyError(err); !errors.Is(err, ErrNotFound) {
+		return nil, err
+	}
+
+	response, err := i.client.RegisterOffchain(ctx, i.l1signer, i.l2signer, email)
+	if err != nil {
+		return nil, classifyError(err)
+	}
+
+	slog.InfoContext(ctx, "signer registered", "address", info.Address, "tx_hash", response.TxHash)
+	return info, nil
+}
+
+// sameStarkKey compares the keys as numbers, IMX does not always pad them the same way.
+func sameStarkKey(a string, b string) bool {
+	x, ok := new(big.Int).SetString(a, 0)
+	if !ok {
+		return false
+	}
+
+	y, ok := new(big.Int).SetString(b, 0)
+	return ok && x.Cmp(y) == 0
+}
@@ -0,0 +1,174 @@
+package imx
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/immutable/imx-core-sdk-golang/imx"
+)
+
+const (
+	EnvironmentSandbox = "sandbox"
+	EnvironmentMainnet = "mainnet"
+
+	verifyTimeout = 30 * time.Second
+)
+
+// Settings configures the connection to IMX. ChainID is optional; when set it must be the chain of
+// the environment, so a sandbox deployment can not be pointed at mainnet by mistake.
+//
+// APIURL and L1RPCURL replace the endpoints of the environment, to run against local stand-ins.
+// The Alchemy key is not used with a custom L1RPCURL, and ChainID then sets the chain of that RPC.
+type Settings struct {
+	Environment        string
+	ChainID            int64
+	APIURL             string
+	L1RPCURL           string
+	AlchemyAPIKey      string
+	L1SignerPrivateKey string
+	StarkPrivateKey    string
+	ProjectID          int32
+}
+
+func LookupEnvironment(name string) (imx.Environment, error) {
+	switch name {
+	case EnvironmentSandbox:
+		return imx.Sandbox, nil
+	case EnvironmentMainnet:
+		return imx.Mainnet, nil
+	default:
+		return imx.Environment{}, fmt.Errorf("unknown imx environment %q", name)
+	}
+}
+
+// ResolveEnvironment returns the environment with the configured endpoints applied.
+func (s *Settings) ResolveEnvironment() (imx.Environment, error) {
+	environment, err := LookupEnvironment(s.Environment)
+	if err != nil {
+		return environment, err
+	}
+
+	if len(s.APIURL) > 0 {
+		environment.BaseAPIPath = s.APIURL
+	}
+
+	if len(s.L1RPCURL) > 0 {
+		environment.EthereumRPC = s.L1RPCURL
+		if s.ChainID != 0 {
+			environment.ChainID = big.NewInt(s.ChainID)
+		}
+	}
+
+	return environment, nil
+}
+
+// Validate checks the settings without connecting to IMX.
+func (s *Settings) Validate() error {
+	if err := s.validateConnection(); err != nil {
+		return err
+	}
+
+	if s.ProjectID <= 0 {
+		return errors.New("missing imx project id")
+	}
+
+	return nil
+}
+
+// validateConnection checks the settings needed to connect to IMX, which do not include the project.
+func (s *Settings) validateConnection() error {
+	environment, err := LookupEnvironment(s.Environment)
+	if err != nil {
+		return err
+	}
+
+	if len(s.APIURL) > 0 {
+		if err = validateURL(s.APIURL); err != nil {
+			return fmt.Errorf("invalid imx api url: %w", err)
+		}
+	}
+
+	if len(s.L1RPCURL) > 0 {
+		if err = validateURL(s.L1RPCURL); err != nil {
+			return fmt.Errorf("invalid L1 rpc url: %w", err)
+		}
+	} else {
+		if s.ChainID != 0 && s.ChainID != environment.ChainID.Int64() {
+			return fmt.Errorf("chain id %d does not match imx environment %s (chain id %d)", s.ChainID, s.Environment, environment.ChainID.Int64())
+		}
+
+		if len(s.AlchemyAPIKey) == 0 {
+			return errors.New("missing alchemy api key")
+		}
+	}
+
+	if len(s.L1SignerPrivateKey) == 0 || len(s.StarkPrivateKey) == 0 {
+		return errors.New("missing signer private keys")
+	}
+
+	return nil
+}
+
+func validateURL(value string) error {
+	u, err := url.Parse(value)
+	if err != nil {
+		return err
+	}
+
+	if len(u.Scheme) == 0 || len(u.Host) == 0 {
+		return errors.New("url must be absolute")
+	}
+
+	return nil
+}
+
+// verify checks the L1 RPC is on the chain of the environment, which also fails on an invalid
+// Alchemy key, and that the project exists and is owned by the platform signer.
+func (i *IMX) verify(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, verifyTimeout)
+	defer cancel()
+
+	if err := i.verifyChain(ctx); err != nil {
+		return err
+	}
+
+	_, err := i.client.GetProject(ctx, i.l1signer, strconv.FormatInt(int64(i.projectID), 10))
+	if err != nil {
+		return fmt.Errorf("error getting imx project %d: %w", i.projectID, classifyError(err))
+	}
+
+	return nil
+}
+
+// verifyChain checks the L1 RPC is on the chain of the environment.
+func (i *IMX) verifyChain(ctx context.Context) error {
+	chainID, err := i.client.EthClient.ChainID(ctx)
+	if err != nil {
+		return fmt.Errorf("error connecting to L1 rpc: %w", classifyError(err))
+	}
+
+	if chainID.Cmp(i.chainId) != 0 {
+		return fmt.Errorf("L1 rpc is on chain %s, expected %s", chainID, i.chainId)
+	}
+
+	return nil
+}
+
+// Ping checks the L1 RPC and the IMX API answer, with the cheapest call to each, for the readiness probe.
+func (i *IMX) Ping(ctx context.Context) error {
+	if _, err := i.client.EthClient.BlockNumber(ctx); err != nil {
+		return fmt.Errorf("error connecting to L1 rpc: %w", classifyError(err))
+	}
+
+	_, httpResponse, err := i.client.CollectionsAPI.ListCollections(ctx).PageSize(1).Execute()
+	if err != nil {
+		return fmt.Errorf("error connecting to imx: %w", newRequestError(httpResponse, err))
+	}
+
+	return nil
+}
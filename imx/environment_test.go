@@ -0,0 +1,94 @@
+package imx
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type UnitTestSuite struct {
+	suite.Suite
+}
+
+func validSettings() *Settings {
+	return &Settings{
+		Environment:        EnvironmentSandbox,
+		AlchemyAPIKey:      "key",
+		L1SignerPrivateKey: "l1",
+		StarkPrivateKey:    "stark",
+		ProjectID:          1,
+	}
+}
+
+func (s *UnitTestSuite) TestLookupEnvironment() {
+	environment, err := LookupEnvironment(EnvironmentMainnet)
+	s.Assertions.Nil(err)
+	s.Assertions.Equal(int64(1), environment.ChainID.Int64())
+
+	_, err = LookupEnvironment("goerli")
+	s.Assertions.NotNil(err)
+}
+
+func (s *UnitTestSuite) TestValidate() {
+	s.Assertions.Nil(validSettings().Validate())
+
+	settings := validSettings()
+	settings.ChainID = 5
+	s.Assertions.Nil(settings.Validate())
+}
+
+func (s *UnitTestSuite) TestValidateWithInvalidSettingsShouldFail() {
+	settings := validSettings()
+	settings.Environment = "prod"
+	s.Assertions.NotNil(settings.Validate())
+
+	settings = validSettings()
+	settings.ChainID = 1
+	s.Assertions.NotNil(settings.Validate())
+
+	settings = validSettings()
+	settings.AlchemyAPIKey = ""
+	s.Assertions.NotNil(settings.Validate())
+
+	settings = validSettings()
+	settings.StarkPrivateKey = ""
+	s.Assertions.NotNil(settings.Validate())
+
+	settings = validSettings()
+	settings.ProjectID = 0
+	s.Assertions.NotNil(settings.Validate())
+}
+
+func (s *UnitTestSuite) TestResolveEnvironmentWithCustomEndpoints() {
+	settings := validSettings()
+	settings.APIURL = "http://localhost:8080"
+	settings.L1RPCURL = "http://localhost:8545"
+	settings.AlchemyAPIKey = ""
+	settings.ChainID = 31337
+	s.Assertions.Nil(settings.Validate())
+
+	environment, err := settings.ResolveEnvironment()
+	s.Assertions.Nil(err)
+	s.Assertions.Equal("http://localhost:8080", environment.BaseAPIPath)
+	s.Assertions.Equal("http://localhost:8545", environment.EthereumRPC)
+	s.Assertions.Equal(int64(31337), environment.ChainID.Int64())
+
+	// the sandbox environment is left untouched
+	sandbox, err := LookupEnvironment(EnvironmentSandbox)
+	s.Assertions.Nil(err)
+	s.Assertions.Equal(int64(5), sandbox.ChainID.Int64())
+}
+
+func (s *UnitTestSuite) TestValidateWithRelativeURLShouldFail() {
+	settings := validSettings()
+	settings.APIURL = "localhost"
+	s.Assertions.NotNil(settings.Validate())
+
+	settings = validSettings()
+	settings.L1RPCURL = "/rpc"
+	s.Assertions.NotNil(settings.Validate())
+}
+
+func TestUnitTestSuite(t *testing.T) {
+	suite.Run(t, new(UnitTestSuite))
+}
@@ -1,6 +1,35 @@
 package imx
 
-import "fmt"
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/immutable/imx-core-sdk-golang/imx"
+	"github.com/immutable/imx-core-sdk-golang/imx/api"
+)
+
+// Kinds of failed requests. Errors returned by Client match them with errors.Is.
+var (
+	ErrNotFound            = errors.New("not found on imx")
+	ErrConflict            = errors.New("conflicts with imx state")
+	ErrInsufficientBalance = errors.New("insufficient imx balance")
+	ErrRateLimited         = errors.New("imx rate limit exceeded")
+	ErrUnavailable         = errors.New("imx unavailable")
+	// ErrUnauthorized is IMX rejecting the credentials or signatures of the platform. It is a misconfiguration
+	// of the platform, never something the caller of the API can fix.
+	ErrUnauthorized = errors.New("imx rejected the platform credentials")
+	// ErrInvalidSignature is IMX rejecting the signatures of an external user, who can sign again.
+	ErrInvalidSignature = errors.New("imx rejected the user signature")
+	// ErrUnknownOutcome is a write that timed out, IMX may have applied it. Sending it again may apply it
+	// twice, so it is not transient.
+	ErrUnknownOutcome = errors.New("imx outcome unknown")
+)
 
 type WithdrawalNotReadyError struct {
 	CurrentStatus string
@@ -13,3 +42,131 @@ func (c WithdrawalNotReadyError) Error() string {
 func NewWithdrawalNotReadyError(currentStatus string) WithdrawalNotReadyError {
 	return WithdrawalNotReadyError{currentStatus}
 }
+
+type DepositNotConfirmedError struct {
+	TransactionHash string
+}
+
+func (c DepositNotConfirmedError) Error() string {
+	return fmt.Sprintf("Deposit transaction %s is not mined yet", c.TransactionHash)
+}
+
+func NewDepositNotConfirmedError(transactionHash string) DepositNotConfirmedError {
+	return DepositNotConfirmedError{transactionHash}
+}
+
+// RequestError is a failed request to IMX or the L1 rpc. Kind is the kind of failure when the status, code
+// or message tell it, nil otherwise.
+type RequestError struct {
+	Kind       error
+	StatusCode int
+	Code       string
+	Message    string
+	Err        error
+}
+
+func (e *RequestError) Error() string {
+	if e.StatusCode == 0 {
+		return fmt.Sprintf("imx request failed: %s", e.Message)
+	}
+	return fmt.Sprintf("imx request failed with status %d: %s", e.StatusCode, e.Message)
+}
+
+func (e *RequestError) Unwrap() error {
+	return e.Err
+}
+
+func (e *RequestError) Is(target error) bool {
+	return e.Kind != nil && e.Kind == target
+}
+
+// newRequestError is imx.NewIMXError for the api clients of the sdk, which also handles requests that
+// failed without a response.
+func newRequestError(httpResponse *http.Response, err error) error {
+	if httpResponse == nil {
+		return classifyError(err)
+	}
+	return classifyError(imx.NewIMXError(httpResponse, err))
+}
+
+// classifyError turns the errors of the sdk and of the L1 rpc into a RequestError. Other errors, like
+// signing failures, are returned as is.
+func classifyError(err error) error {
+	var requestError *RequestError
+	if err == nil || errors.As(err, &requestError) {
+		return err
+	}
+
+	var imxError *imx.IMXError
+	if errors.As(err, &imxError) {
+		code, message := decodeAPIError(imxError.APIError)
+		return &RequestError{
+			Kind:       kindOf(imxError.HTTPStatusCode, code, message),
+			StatusCode: imxError.HTTPStatusCode,
+			Code:       code,
+			Message:    message,
+			Err:        err,
+		}
+	}
+
+	var httpError rpc.HTTPError
+	if errors.As(err, &httpError) {
+		return &RequestError{
+			Kind:       kindOf(httpError.StatusCode, "", string(httpError.Body)),
+			StatusCode: httpError.StatusCode,
+			Message:    httpError.Error(),
+			Err:        err,
+		}
+	}
+
+	var netError net.Error
+	if errors.As(err, &netError) || errors.Is(err, context.DeadlineExceeded) {
+		return &RequestError{Kind: ErrUnavailable, Message: err.Error(), Err: err}
+	}
+
+	return err
+}
+
+// decodeAPIError returns the code and message of the error. The sdk only decodes the body of some statuses,
+// it keeps the others as the message.
+func decodeAPIError(apiError api.APIError) (string, string) {
+	if len(apiError.Code) == 0 {
+		var decoded api.APIError
+		if err := json.Unmarshal([]byte(apiError.Message), &decoded); err == nil && len(decoded.Message) > 0 {
+			apiError = decoded
+		}
+	}
+	return apiError.Code, strings.TrimSpace(apiError.Message)
+}
+
+// userSigned classifies IMX rejecting the credentials of a request signed by an external user as an invalid
+// signature of that user, rather than as the platform being unauthorized.
+func userSigned(err error) error {
+	var requestError *RequestError
+	if errors.As(err, &requestError) && requestError.Kind == ErrUnauthorized {
+		requestError.Kind = ErrInvalidSignature
+	}
+	return err
+}
+
+func kindOf(statusCode int, code string, message string) error {
+	text := strings.ToLower(code + " " + message)
+	switch {
+	case code == unknownOutcomeCode:
+		return ErrUnknownOutcome
+	case statusCode == http.StatusTooManyRequests:
+		return ErrRateLimited
+	case statusCode >= http.StatusInternalServerError:
+		return ErrUnavailable
+	case statusCode == http.StatusUnauthorized, statusCode == http.StatusForbidden:
+		return ErrUnauthorized
+	case strings.Contains(text, "insufficient"):
+		return ErrInsufficientBalance
+	case statusCode == http.StatusNotFound, strings.Contains(text, "not found"), strings.Contains(text, "not_found"):
+		return ErrNotFound
+	case statusCode == http.StatusConflict, strings.Contains(text, "already"), strings.Contains(text, "duplicate"):
+		return ErrConflict
+	default:
+		return nil
+	}
+}
@@ -0,0 +1,53 @@
+package imx
+
+import (
+	"errors"
+	"net"
+	"net/http"
+	"net/url"
+
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/immutable/imx-core-sdk-golang/imx"
+	"github.com/immutable/imx-core-sdk-golang/imx/api"
+)
+
+func imxError(statusCode int, code string, message string) error {
+	return &imx.IMXError{HTTPStatusCode: statusCode, APIError: *api.NewAPIError(code, message)}
+}
+
+func (s *UnitTestSuite) TestClassifyError() {
+	s.Assertions.ErrorIs(classifyError(imxError(http.StatusNotFound, "", `{"code":"resource_not_found","message":"order 1 not found"}`)), ErrNotFound)
+	s.Assertions.ErrorIs(classifyError(imxError(http.StatusConflict, "", "")), ErrConflict)
+	s.Assertions.ErrorIs(classifyError(imxError(http.StatusBadRequest, "asset_already_minted", "")), ErrConflict)
+	s.Assertions.ErrorIs(classifyError(imxError(http.StatusBadRequest, "insufficient_balance", "")), ErrInsufficientBalance)
+	s.Assertions.ErrorIs(classifyError(imxError(http.StatusTooManyRequests, "", "slow down")), ErrRateLimited)
+	s.Assertions.ErrorIs(classifyError(imxError(http.StatusServiceUnavailable, "", "")), ErrUnavailable)
+	s.Assertions.ErrorIs(classifyError(imxError(http.StatusUnauthorized, "", "invalid api key")), ErrUnauthorized)
+	s.Assertions.ErrorIs(classifyError(imxError(http.StatusForbidden, "", "asset not found")), ErrUnauthorized)
+	s.Assertions.ErrorIs(userSigned(classifyError(imxError(http.StatusUnauthorized, "", "invalid signature"))), ErrInvalidSignature)
+	s.Assertions.ErrorIs(userSigned(classifyError(imxError(http.StatusNotFound, "", "order not found"))), ErrNotFound)
+	s.Assertions.ErrorIs(classifyError(rpc.HTTPError{StatusCode: http.StatusBadGateway, Status: "502 Bad Gateway"}), ErrUnavailable)
+
+	dial := &url.Error{Op: "Post", URL: "https://api.x.immutable.com", Err: &net.OpError{Op: "dial", Err: errors.New("connection refused")}}
+	s.Assertions.ErrorIs(classifyError(dial), ErrUnavailable)
+}
+
+func (s *UnitTestSuite) TestClassifyErrorDecodesBody() {
+	var requestError *RequestError
+	s.Require().ErrorAs(classifyError(imxError(http.StatusNotFound, "", `{"code":"resource_not_found","message":"order 1 not found"}`)), &requestError)
+	s.Assertions.Equal(http.StatusNotFound, requestError.StatusCode)
+	s.Assertions.Equal("resource_not_found", requestError.Code)
+	s.Assertions.Equal("order 1 not found", requestError.Message)
+}
+
+func (s *UnitTestSuite) TestClassifyErrorKeepsOtherErrors() {
+	s.Assertions.Nil(classifyError(nil))
+
+	err := errors.New("error signing message")
+	s.Assertions.Equal(err, classifyError(err))
+
+	var requestError *RequestError
+	s.Require().ErrorAs(classifyError(imxError(http.StatusBadRequest, "invalid_request", "missing user")), &requestError)
+	s.Assertions.Nil(requestError.Kind)
+	s.Assertions.False(errors.Is(requestError, ErrNotFound))
+}
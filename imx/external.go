@@ -0,0 +1,147 @@
+package imx
+
+import (
+	"context"
+	"encoding/json"
+	"nft/models"
+	"strconv"
+
+	"github.com/immutable/imx-core-sdk-golang/imx"
+	"github.com/immutable/imx-core-sdk-golang/imx/api"
+	"golang.org/x/exp/slog"
+)
+
+// SignableInformation holds what an external wallet has to sign to approve an operation.
+// Payload is the raw signable response from IMX, kept to build the final request once signed.
+type SignableInformation struct {
+	SignableMessage string
+	PayloadHash     string
+	Payload         string
+}
+
+type SubmitTradeInformation struct {
+	User           *models.User
+	OrderID        int32
+	Payload        string
+	EthSignature   string
+	StarkSignature string
+}
+
+type SubmitWithdrawalInformation struct {
+	User           *models.User
+	Payload        string
+	EthSignature   string
+	StarkSignature string
+}
+
+func (i *IMX) GetSignableTrade(ctx context.Context, info *CreateTradeInformation) (*SignableInformation, error) {
+	tradeRequest := api.GetSignableTradeRequest{
+		Fees:    nil,
+		OrderId: info.OrderID,
+		User:    info.User.Address,
+	}
+	tradeRequest.SetExpirationTimestamp(0)
+
+	signableTrade, httpResponse, err := i.client.TradesAPI.GetSignableTrade(ctx).GetSignableTradeRequest(tradeRequest).Execute()
+	if err != nil {
+		return nil, newRequestError(httpResponse, err)
+	}
+
+	payload, err := json.Marshal(signableTrade)
+	if err != nil {
+		return nil, err
+	}
+
+	return &SignableInformation{
+		SignableMessage: signableTrade.SignableMessage,
+		PayloadHash:     signableTrade.PayloadHash,
+		Payload:         string(payload),
+	}, nil
+}
+
+func (i *IMX) SubmitTrade(ctx context.Context, info *SubmitTradeInformation) (int32, error) {
+	var signableTrade api.GetSignableTradeResponse
+	if err := json.Unmarshal([]byte(info.Payload), &signableTrade); err != nil {
+		return -1, err
+	}
+
+	includeFees := true
+	tradeResponse, httpResponse, err := i.client.TradesAPI.CreateTrade(ctx).
+		CreateTradeRequest(api.CreateTradeRequestV1{
+			AmountBuy:           signableTrade.AmountBuy,
+			AmountSell:          signableTrade.AmountSell,
+			AssetIdBuy:          signableTrade.AssetIdBuy,
+			AssetIdSell:         signableTrade.AssetIdSell,
+			ExpirationTimestamp: signableTrade.ExpirationTimestamp,
+			FeeInfo:             signableTrade.FeeInfo,
+			IncludeFees:         &includeFees,
+			Nonce:               signableTrade.Nonce,
+			OrderId:             info.OrderID,
+			StarkKey:            signableTrade.StarkKey,
+			StarkSignature:      info.StarkSignature,
+			VaultIdBuy:          signableTrade.VaultIdBuy,
+			VaultIdSell:         signableTrade.VaultIdSell,
+		}).
+		XImxEthAddress(info.User.Address).XImxEthSignature(info.EthSignature).Execute()
+	if err != nil {
+		return -1, userSigned(newRequestError(httpResponse, err))
+	}
+
+	slog.InfoContext(ctx, "external trade created", "trade_id", tradeResponse.TradeId)
+	return tradeResponse.TradeId, nil
+}
+
+func (i *IMX) GetSignableWithdrawal(ctx context.Context, info *CreateWithdrawalInformation) (*SignableInformation, error) {
+	ethAmountInWei, err := strconv.ParseUint(info.AmountWei, 10, 64)
+	if err != nil {
+		return nil, err
+	}
+
+	withdrawalRequest := api.GetSignableWithdrawalRequest{
+		Amount: strconv.FormatUint(ethAmountInWei, 10),
+		Token:  imx.SignableETHToken(),
+		User:   info.User.Address,
+	}
+
+	signableWithdrawal, httpResponse, err := i.client.WithdrawalsAPI.GetSignableWithdrawal(ctx).GetSignableWithdrawalRequest(withdrawalRequest).Execute()
+	if err != nil {
+		return nil, newRequestError(httpResponse, err)
+	}
+
+	payload, err := json.Marshal(signableWithdrawal)
+	if err != nil {
+		return nil, err
+	}
+
+	return &SignableInformation{
+		SignableMessage: signableWithdrawal.SignableMessage,
+		PayloadHash:     signableWithdrawal.PayloadHash,
+		Payload:         string(payload),
+	}, nil
+}
+
+func (i *IMX) SubmitWithdrawal(ctx context.Context, info *SubmitWithdrawalInformation) (int32, error) {
+	var signableWithdrawal api.GetSignableWithdrawalResponse
+	if err := json.Unmarshal([]byte(info.Payload), &signableWithdrawal); err != nil {
+		return -1, err
+	}
+
+	withdrawalRequest := api.CreateWithdrawalRequest{
+		Amount:         signableWithdrawal.Amount,
+		AssetId:        signableWithdrawal.AssetId,
+		Nonce:          signableWithdrawal.Nonce,
+		StarkKey:       signableWithdrawal.StarkKey,
+		StarkSignature: info.StarkSignature,
+		VaultId:        signableWithdrawal.VaultId,
+	}
+
+	response, httpResponse, err := i.client.WithdrawalsAPI.CreateWithdrawal(ctx).
+		XImxEthAddress(info.User.Address).XImxEthSignature(info.EthSignature).
+		CreateWithdrawalRequest(withdrawalRequest).Execute()
+	if err != nil {
+		return -1, userSigned(newRequestError(httpResponse, err))
+	}
+
+	slog.InfoContext(ctx, "external withdrawal created", "withdrawal_id", response.WithdrawalId)
+	return response.WithdrawalId, nil
+}
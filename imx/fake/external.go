@@ -0,0 +1,136 @@
+package fake
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"nft/imx"
+)
+
+type signableTrade struct {
+	OrderID int32  `json:"order_id"`
+	User    string `json:"user"`
+	Nonce   string `json:"nonce"`
+}
+
+type signableWithdrawal struct {
+	AmountWei string `json:"amount_wei"`
+	User      string `json:"user"`
+	Nonce     string `json:"nonce"`
+}
+
+func (f *IMX) GetSignableTrade(ctx context.Context, info *imx.CreateTradeInformation) (*imx.SignableInformation, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	buyer, err := f.account(info.User)
+	if err != nil {
+		return nil, err
+	}
+
+	o, ok := f.orders[info.OrderID]
+	if !ok {
+		return nil, fmt.Errorf("%w: %d", ErrOrderNotFound, info.OrderID)
+	}
+	if o.status != OrderStatusActive {
+		return nil, fmt.Errorf("%w: order %d is %s", ErrOrderNotActive, info.OrderID, o.status)
+	}
+
+	message := fmt.Sprintf("Buy order %d for %s wei", info.OrderID, o.amount)
+	return newSignable(message, signableTrade{OrderID: info.OrderID, User: buyer, Nonce: randomHex()})
+}
+
+func (f *IMX) SubmitTrade(ctx context.Context, info *imx.SubmitTradeInformation) (int32, error) {
+	var payload signableTrade
+	if err := json.Unmarshal([]byte(info.Payload), &payload); err != nil {
+		return -1, err
+	}
+
+	if err := checkSignatures(info.EthSignature, info.StarkSignature); err != nil {
+		return -1, err
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	buyer, err := f.account(info.User)
+	if err != nil {
+		return -1, err
+	}
+	if payload.User != buyer || payload.OrderID != info.OrderID {
+		return -1, fmt.Errorf("%w: payload signed for another trade", ErrInvalidSignature)
+	}
+
+	return f.trade(buyer, info.OrderID)
+}
+
+func (f *IMX) GetSignableWithdrawal(ctx context.Context, info *imx.CreateWithdrawalInformation) (*imx.SignableInformation, error) {
+	amount, err := parseAmount(info.AmountWei)
+	if err != nil {
+		return nil, err
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	address, err := f.account(info.User)
+	if err != nil {
+		return nil, err
+	}
+
+	message := fmt.Sprintf("Withdraw %s wei", amount)
+	return newSignable(message, signableWithdrawal{AmountWei: amount.String(), User: address, Nonce: randomHex()})
+}
+
+func (f *IMX) SubmitWithdrawal(ctx context.Context, info *imx.SubmitWithdrawalInformation) (int32, error) {
+	var payload signableWithdrawal
+	if err := json.Unmarshal([]byte(info.Payload), &payload); err != nil {
+		return -1, err
+	}
+
+	if err := checkSignatures(info.EthSignature, info.StarkSignature); err != nil {
+		return -1, err
+	}
+
+	amount, err := parseAmount(payload.AmountWei)
+	if err != nil {
+		return -1, err
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	address, err := f.account(info.User)
+	if err != nil {
+		return -1, err
+	}
+	if payload.User != address {
+		return -1, fmt.Errorf("%w: payload signed for another user", ErrInvalidSignature)
+	}
+
+	return f.withdraw(address, amount)
+}
+
+func newSignable(message string, payload interface{}) (*imx.SignableInformation, error) {
+	b, err := json.Marshal(payload)
+	if err != nil {
+		return nil, err
+	}
+
+	hash := sha256.Sum256(b)
+	return &imx.SignableInformation{
+		SignableMessage: message,
+		PayloadHash:     "0x" + hex.EncodeToString(hash[:]),
+		Payload:         string(b),
+	}, nil
+}
+
+// checkSignatures only requires both signatures, the fake cannot verify them against keys it does not hold.
+func checkSignatures(ethSignature string, starkSignature string) error {
+	if len(ethSignature) == 0 || len(starkSignature) == 0 {
+		return fmt.Errorf("%w: missing signature", ErrInvalidSignature)
+	}
+	return nil
+}
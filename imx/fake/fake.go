@@ -0,0 +1,599 @@
+// Package fake is an in-memory IMX. It keeps users, collections, assets, orders, balances, deposits and
+// withdrawals, and rejects what IMX rejects, so it can stand in for imx.IMX in tests and local development.
+// Server exposes it over the IMX REST API, to test imx.IMX itself without network access.
+package fake
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math/big"
+	"nft/imx"
+	"nft/models"
+	"strings"
+	"sync"
+	"time"
+)
+
+// PlatformAddress is the default address of the platform signer, the owner of minted assets and seller of orders.
+const PlatformAddress = "0x0000000000000000000000000000000000000001"
+
+const (
+	AssetStatusIMX = "imx"
+
+	OrderStatusActive = "active"
+	OrderStatusFilled = "filled"
+
+	DepositStatusPending  = "pending"
+	DepositStatusAccepted = "accepted"
+
+	RollupStatusIncluded  = "included"
+	RollupStatusConfirmed = "confirmed"
+)
+
+// Errors of the simulator. Those IMX classifies also match the kind of imx error, like imx.ErrNotFound.
+var (
+	ErrUserNotRegistered   = newError("user not registered", imx.ErrNotFound)
+	ErrCollectionExists    = newError("collection already exists", imx.ErrConflict)
+	ErrCollectionNotFound  = newError("collection not found", imx.ErrNotFound)
+	ErrMetadataInvalid     = errors.New("invalid metadata schema")
+	ErrAssetExists         = newError("asset already minted", imx.ErrConflict)
+	ErrAssetNotFound       = newError("asset not found", imx.ErrNotFound)
+	ErrNotOwner            = errors.New("asset not owned by sender")
+	ErrAssetListed         = newError("asset has an active order", imx.ErrConflict)
+	ErrOrderNotFound       = newError("order not found", imx.ErrNotFound)
+	ErrOrderNotActive      = newError("order not active", imx.ErrConflict)
+	ErrInvalidAmount       = errors.New("invalid amount")
+	ErrInsufficientBalance = newError("insufficient balance", imx.ErrInsufficientBalance)
+	ErrDepositNotFound     = newError("deposit not found", imx.ErrNotFound)
+	ErrWithdrawalNotFound  = newError("withdrawal not found", imx.ErrNotFound)
+	ErrWithdrawalCompleted = newError("withdrawal already completed", imx.ErrConflict)
+	ErrInvalidSignature    = newError("invalid signature", imx.ErrInvalidSignature)
+	ErrExternalUser        = errors.New("external users sign their own requests")
+)
+
+type kindError struct {
+	message string
+	kind    error
+}
+
+func newError(message string, kind error) error {
+	return &kindError{message, kind}
+}
+
+func (e *kindError) Error() string {
+	return e.message
+}
+
+func (e *kindError) Is(target error) bool {
+	return e.kind == target
+}
+
+var metadataTypes = map[string]bool{"enum": true, "text": true, "boolean": true, "discrete": true, "continuous": true}
+
+type assetKey struct {
+	contractAddress string
+	tokenID         string
+}
+
+type collection struct {
+	name     string
+	metadata map[string]string
+}
+
+type asset struct {
+	owner     string
+	status    string
+	blueprint string
+}
+
+type order struct {
+	id     int32
+	asset  assetKey
+	seller string
+	amount *big.Int
+	status string
+}
+
+type trade struct {
+	id      int32
+	orderID int32
+	buyer   string
+}
+
+type deposit struct {
+	user      string
+	amount    *big.Int
+	status    string
+	createdAt time.Time
+}
+
+type withdrawal struct {
+	user      string
+	amount    *big.Int
+	createdAt time.Time
+	completed bool
+}
+
+// IMX implements imx.Client in memory. Deposits and withdrawals are confirmed on L1 once the confirmation delay
+// has passed, zero confirms them right away.
+type IMX struct {
+	mu                sync.Mutex
+	platform          string
+	confirmationDelay time.Duration
+	now               func() time.Time
+
+	users       map[string]string
+	collections map[string]*collection
+	assets      map[assetKey]*asset
+	orders      map[int32]*order
+	trades      map[int32]*trade
+	balances    map[string]*big.Int
+	deposits    map[string]*deposit
+	withdrawals map[int32]*withdrawal
+
+	lastProjectID    int32
+	lastOrderID      int32
+	lastTradeID      int32
+	lastWithdrawalID int32
+}
+
+var (
+	_ imx.Client = (*IMX)(nil)
+	_ imx.Admin  = (*IMX)(nil)
+)
+
+// New returns an empty IMX where platformAddress mints, transfers and sells assets.
+func New(platformAddress string) *IMX {
+	f := &IMX{
+		platform:    normalize(platformAddress),
+		now:         time.Now,
+		users:       make(map[string]string),
+		collections: make(map[string]*collection),
+		assets:      make(map[assetKey]*asset),
+		orders:      make(map[int32]*order),
+		trades:      make(map[int32]*trade),
+		balances:    make(map[string]*big.Int),
+		deposits:    make(map[string]*deposit),
+		withdrawals: make(map[int32]*withdrawal),
+	}
+	return f
+}
+
+// SetConfirmationDelay sets how long deposits and withdrawals take to be confirmed.
+func (f *IMX) SetConfirmationDelay(delay time.Duration) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.confirmationDelay = delay
+}
+
+// RegisterUser registers an address as done by a wallet, outside the platform.
+func (f *IMX) RegisterUser(address string, starkKey string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.users[normalize(address)] = starkKey
+}
+
+// Fund credits the L2 balance of the address, as a deposit made outside the platform.
+func (f *IMX) Fund(address string, amountWei *big.Int) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	b := f.balance(normalize(address))
+	b.Add(b, amountWei)
+}
+
+// Balance returns the L2 ETH balance of the address in wei.
+func (f *IMX) Balance(address string) *big.Int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return new(big.Int).Set(f.balance(normalize(address)))
+}
+
+// Owner returns the owner of the asset and whether it was minted.
+func (f *IMX) Owner(contractAddress string, tokenID string) (string, bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	a, ok := f.assets[assetKey{normalize(contractAddress), tokenID}]
+	if !ok {
+		return "", false
+	}
+	return a.owner, true
+}
+
+// WithdrawalStatus returns the rollup status of the withdrawal.
+func (f *IMX) WithdrawalStatus(withdrawalID int32) (string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	w, ok := f.withdrawals[withdrawalID]
+	if !ok {
+		return "", fmt.Errorf("%w: %d", ErrWithdrawalNotFound, withdrawalID)
+	}
+	return f.rollupStatus(w), nil
+}
+
+func (f *IMX) Close() {}
+
+// Address returns the platform address.
+func (f *IMX) Address() string {
+	return f.platform
+}
+
+// Ping always succeeds, the fake has no dependencies.
+func (f *IMX) Ping(ctx context.Context) error {
+	return nil
+}
+
+func (f *IMX) CreateUser(ctx context.Context, user *models.User) (string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	address := normalize(user.Address)
+	if len(address) == 0 {
+		return "", errors.New("user without address")
+	}
+
+	// registering again returns the existing account, like the offchain registration
+	if starkKey, ok := f.users[address]; ok {
+		return starkKey, nil
+	}
+
+	starkKey := randomHex()
+	f.users[address] = starkKey
+	return starkKey, nil
+}
+
+// CreateProject returns the next project ID, projects only exist for IMX to limit collections and mints.
+func (f *IMX) CreateProject(ctx context.Context, info *imx.ProjectInformation) (int32, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.lastProjectID++
+	return f.lastProjectID, nil
+}
+
+// RegisterSigner registers the platform address with a random stark key, unless it already is.
+func (f *IMX) RegisterSigner(ctx context.Context, email string) (*imx.SignerInformation, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	starkKey, ok := f.users[f.platform]
+	if !ok {
+		starkKey = randomHex()
+		f.users[f.platform] = starkKey
+	}
+	return &imx.SignerInformation{Address: f.platform, StarkKey: starkKey}, nil
+}
+
+func (f *IMX) CreateCollection(ctx context.Context, info *imx.CollectionInformation) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.createCollection(info.ContractAddress, info.CollectionName)
+}
+
+func (f *IMX) CreateMetadata(ctx context.Context, info *imx.MetadataInformation) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	c, err := f.collection(info.ContractAddress)
+	if err != nil {
+		return err
+	}
+
+	for _, field := range info.Fields {
+		if !metadataTypes[field.Type] {
+			return fmt.Errorf("%w: field %s has type %s", ErrMetadataInvalid, field.Name, field.Type)
+		}
+		if _, ok := c.metadata[field.Name]; ok {
+			return fmt.Errorf("%w: field %s already exists", ErrMetadataInvalid, field.Name)
+		}
+	}
+
+	for _, field := range info.Fields {
+		c.metadata[field.Name] = field.Type
+	}
+	return nil
+}
+
+func (f *IMX) CreateToken(ctx context.Context, info *imx.MintInformation) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.mint(assetKey{normalize(info.ContractAddress), info.TokenID}, f.platform, info.Blueprint)
+}
+
+func (f *IMX) TransferToken(ctx context.Context, info *imx.TransferInformation) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.transfer(assetKey{normalize(info.ContractAddress), info.TokenID}, f.platform, normalize(info.ReceiverAddress))
+}
+
+func (f *IMX) CreateOrder(ctx context.Context, info *imx.OrderInformation) (int32, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	key := assetKey{normalize(info.ContractAddress), info.TokenID}
+	return f.createOrder(key, f.platform, new(big.Int).SetUint64(info.Amount))
+}
+
+func (f *IMX) CreateEthDeposit(ctx context.Context, info *imx.CreateDepositInformation) (string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	amount, err := parseAmount(info.AmountWei)
+	if err != nil {
+		return "", err
+	}
+
+	address, err := f.signer(info.User)
+	if err != nil {
+		return "", err
+	}
+
+	hash := randomHex()
+	f.deposits[hash] = &deposit{user: address, amount: amount, status: DepositStatusPending, createdAt: f.now()}
+	return hash, nil
+}
+
+// ConfirmEthDeposit credits the deposit to the L2 balance once it is confirmed. Confirming it again is a no-op.
+func (f *IMX) ConfirmEthDeposit(ctx context.Context, transactionHash string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	d, ok := f.deposits[transactionHash]
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrDepositNotFound, transactionHash)
+	}
+
+	if d.status == DepositStatusAccepted {
+		return nil
+	}
+
+	if !f.confirmed(d.createdAt) {
+		return imx.NewDepositNotConfirmedError(transactionHash)
+	}
+
+	d.status = DepositStatusAccepted
+	f.balance(d.user).Add(f.balance(d.user), d.amount)
+	return nil
+}
+
+func (f *IMX) CreateTrade(ctx context.Context, info *imx.CreateTradeInformation) (int32, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	buyer, err := f.signer(info.User)
+	if err != nil {
+		return -1, err
+	}
+
+	return f.trade(buyer, info.OrderID)
+}
+
+func (f *IMX) CreateEthWithdrawal(ctx context.Context, info *imx.CreateWithdrawalInformation) (int32, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	address, err := f.signer(info.User)
+	if err != nil {
+		return -1, err
+	}
+
+	amount, err := parseAmount(info.AmountWei)
+	if err != nil {
+		return -1, err
+	}
+
+	return f.withdraw(address, amount)
+}
+
+func (f *IMX) CompleteEthWithdrawal(ctx context.Context, info *imx.CompleteWithdrawalInformation) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	w, ok := f.withdrawals[info.WithdrawalID]
+	if !ok || w.user != normalize(info.User.Address) {
+		return fmt.Errorf("%w: %d", ErrWithdrawalNotFound, info.WithdrawalID)
+	}
+
+	if w.completed {
+		return fmt.Errorf("%w: %d", ErrWithdrawalCompleted, info.WithdrawalID)
+	}
+
+	if status := f.rollupStatus(w); status != RollupStatusConfirmed {
+		return imx.NewWithdrawalNotReadyError(status)
+	}
+
+	w.completed = true
+	return nil
+}
+
+// account returns the registered address of the user. External users registered their stark key with
+// their own wallet, so they are registered on first use.
+func (f *IMX) account(user *models.User) (string, error) {
+	address := normalize(user.Address)
+	if _, ok := f.users[address]; ok && len(address) > 0 {
+		return address, nil
+	}
+
+	if user.External && len(address) > 0 && len(user.StarkPublicKey) > 0 {
+		f.users[address] = user.StarkPublicKey
+		return address, nil
+	}
+
+	return "", fmt.Errorf("%w: %s", ErrUserNotRegistered, user.Address)
+}
+
+// signer returns the address of a user the platform signs for, external users sign through signature requests.
+func (f *IMX) signer(user *models.User) (string, error) {
+	if user.External {
+		return "", ErrExternalUser
+	}
+	return f.account(user)
+}
+
+func (f *IMX) collection(contractAddress string) (*collection, error) {
+	c, ok := f.collections[normalize(contractAddress)]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrCollectionNotFound, contractAddress)
+	}
+	return c, nil
+}
+
+func (f *IMX) createCollection(contractAddress string, name string) error {
+	if _, ok := f.collections[normalize(contractAddress)]; ok {
+		return fmt.Errorf("%w: %s", ErrCollectionExists, contractAddress)
+	}
+
+	f.collections[normalize(contractAddress)] = &collection{name: name, metadata: make(map[string]string)}
+	return nil
+}
+
+func (f *IMX) mint(key assetKey, owner string, blueprint string) error {
+	if _, ok := f.collections[key.contractAddress]; !ok {
+		return fmt.Errorf("%w: %s", ErrCollectionNotFound, key.contractAddress)
+	}
+
+	if len(key.tokenID) == 0 {
+		return errors.New("missing token id")
+	}
+
+	if _, ok := f.assets[key]; ok {
+		return fmt.Errorf("%w: %s/%s", ErrAssetExists, key.contractAddress, key.tokenID)
+	}
+
+	f.assets[key] = &asset{owner: owner, status: AssetStatusIMX, blueprint: blueprint}
+	return nil
+}
+
+func (f *IMX) transfer(key assetKey, sender string, receiver string) error {
+	if _, ok := f.users[receiver]; !ok {
+		return fmt.Errorf("%w: %s", ErrUserNotRegistered, receiver)
+	}
+
+	a, err := f.sellable(key, sender)
+	if err != nil {
+		return err
+	}
+
+	a.owner = receiver
+	return nil
+}
+
+func (f *IMX) createOrder(key assetKey, seller string, amount *big.Int) (int32, error) {
+	if amount.Sign() <= 0 {
+		return -1, fmt.Errorf("%w: order amount must be positive", ErrInvalidAmount)
+	}
+
+	if _, err := f.sellable(key, seller); err != nil {
+		return -1, err
+	}
+
+	f.lastOrderID++
+	f.orders[f.lastOrderID] = &order{
+		id:     f.lastOrderID,
+		asset:  key,
+		seller: seller,
+		amount: amount,
+		status: OrderStatusActive,
+	}
+	return f.lastOrderID, nil
+}
+
+// sellable returns the asset if owner can transfer or list it.
+func (f *IMX) sellable(key assetKey, owner string) (*asset, error) {
+	a, ok := f.assets[key]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s/%s", ErrAssetNotFound, key.contractAddress, key.tokenID)
+	}
+
+	if a.owner != owner {
+		return nil, fmt.Errorf("%w: %s/%s", ErrNotOwner, key.contractAddress, key.tokenID)
+	}
+
+	for _, o := range f.orders {
+		if o.asset == key && o.status == OrderStatusActive {
+			return nil, fmt.Errorf("%w: %s/%s", ErrAssetListed, key.contractAddress, key.tokenID)
+		}
+	}
+
+	return a, nil
+}
+
+func (f *IMX) trade(buyer string, orderID int32) (int32, error) {
+	o, ok := f.orders[orderID]
+	if !ok {
+		return -1, fmt.Errorf("%w: %d", ErrOrderNotFound, orderID)
+	}
+
+	if o.status != OrderStatusActive {
+		return -1, fmt.Errorf("%w: order %d is %s", ErrOrderNotActive, orderID, o.status)
+	}
+
+	if buyer == o.seller {
+		return -1, errors.New("cannot buy your own order")
+	}
+
+	if f.balance(buyer).Cmp(o.amount) < 0 {
+		return -1, fmt.Errorf("%w: order %d costs %s wei", ErrInsufficientBalance, orderID, o.amount)
+	}
+
+	f.balance(buyer).Sub(f.balance(buyer), o.amount)
+	f.balance(o.seller).Add(f.balance(o.seller), o.amount)
+	f.assets[o.asset].owner = buyer
+	o.status = OrderStatusFilled
+
+	f.lastTradeID++
+	f.trades[f.lastTradeID] = &trade{id: f.lastTradeID, orderID: orderID, buyer: buyer}
+	return f.lastTradeID, nil
+}
+
+func (f *IMX) withdraw(address string, amount *big.Int) (int32, error) {
+	if f.balance(address).Cmp(amount) < 0 {
+		return -1, fmt.Errorf("%w: withdrawing %s wei", ErrInsufficientBalance, amount)
+	}
+
+	f.balance(address).Sub(f.balance(address), amount)
+
+	f.lastWithdrawalID++
+	f.withdrawals[f.lastWithdrawalID] = &withdrawal{user: address, amount: amount, createdAt: f.now()}
+	return f.lastWithdrawalID, nil
+}
+
+func (f *IMX) balance(address string) *big.Int {
+	b, ok := f.balances[address]
+	if !ok {
+		b = new(big.Int)
+		f.balances[address] = b
+	}
+	return b
+}
+
+func (f *IMX) rollupStatus(w *withdrawal) string {
+	if f.confirmed(w.createdAt) {
+		return RollupStatusConfirmed
+	}
+	return RollupStatusIncluded
+}
+
+func (f *IMX) confirmed(createdAt time.Time) bool {
+	return !f.now().Before(createdAt.Add(f.confirmationDelay))
+}
+
+func parseAmount(amountWei string) (*big.Int, error) {
+	amount, ok := new(big.Int).SetString(amountWei, 10)
+	if !ok || amount.Sign() <= 0 {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidAmount, amountWei)
+	}
+	return amount, nil
+}
+
+func normalize(address string) string {
+	return strings.ToLower(address)
+}
+
+func randomHex() string {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+	return "0x" + hex.EncodeToString(b)
+}
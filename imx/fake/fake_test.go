@@ -0,0 +1,267 @@
+package fake
+
+import (
+	"context"
+	"math/big"
+	"nft/imx"
+	"nft/models"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+)
+
+const contractAddress = "0xC0FFEE0000000000000000000000000000000001"
+
+type UnitTestSuite struct {
+	suite.Suite
+	imx  *IMX
+	user *models.User
+}
+
+func (s *UnitTestSuite) SetupTest() {
+	s.imx = New(PlatformAddress)
+	s.user = &models.User{Address: "0x18b1ceDC9803096D970f52260D1835F07D7e448C"}
+
+	_, err := s.imx.CreateUser(context.Background(), s.user)
+	s.Assertions.Nil(err)
+	err = s.imx.CreateCollection(context.Background(), &imx.CollectionInformation{ContractAddress: contractAddress})
+	s.Assertions.Nil(err)
+}
+
+func (s *UnitTestSuite) mint(tokenID string) {
+	err := s.imx.CreateToken(context.Background(), &imx.MintInformation{ContractAddress: contractAddress, TokenID: tokenID})
+	s.Assertions.Nil(err)
+}
+
+func (s *UnitTestSuite) fund(amountWei string) {
+	hash, err := s.imx.CreateEthDeposit(context.Background(), &imx.CreateDepositInformation{User: s.user, AmountWei: amountWei})
+	s.Assertions.Nil(err)
+	err = s.imx.ConfirmEthDeposit(context.Background(), hash)
+	s.Assertions.Nil(err)
+}
+
+func (s *UnitTestSuite) TestCreateUserIsIdempotent() {
+	starkKey, err := s.imx.CreateUser(context.Background(), s.user)
+	s.Assertions.Nil(err)
+	again, err := s.imx.CreateUser(context.Background(), &models.User{Address: "0x18B1CEDC9803096D970F52260D1835F07D7E448C"})
+	s.Assertions.Nil(err)
+	s.Assertions.Equal(starkKey, again)
+}
+
+func (s *UnitTestSuite) TestRegisterSignerIsIdempotent() {
+	signer, err := s.imx.RegisterSigner(context.Background(), "platform@example.com")
+	s.Assertions.Nil(err)
+	s.Assertions.Equal(normalize(PlatformAddress), signer.Address)
+
+	again, err := s.imx.RegisterSigner(context.Background(), "platform@example.com")
+	s.Assertions.Nil(err)
+	s.Assertions.Equal(signer, again)
+}
+
+func (s *UnitTestSuite) TestCreateCollectionTwiceShouldFail() {
+	err := s.imx.CreateCollection(context.Background(), &imx.CollectionInformation{ContractAddress: contractAddress})
+	s.Assertions.ErrorIs(err, ErrCollectionExists)
+}
+
+func (s *UnitTestSuite) TestCreateMetadata() {
+	info := &imx.MetadataInformation{
+		ContractAddress: contractAddress,
+		Fields:          []imx.MetadataFieldInformation{{Name: "name", Type: "text"}},
+	}
+	s.Assertions.Nil(s.imx.CreateMetadata(context.Background(), info))
+	s.Assertions.ErrorIs(s.imx.CreateMetadata(context.Background(), info), ErrMetadataInvalid)
+
+	info.Fields = []imx.MetadataFieldInformation{{Name: "level", Type: "number"}}
+	s.Assertions.ErrorIs(s.imx.CreateMetadata(context.Background(), info), ErrMetadataInvalid)
+
+	info.ContractAddress = "0x02"
+	s.Assertions.ErrorIs(s.imx.CreateMetadata(context.Background(), info), ErrCollectionNotFound)
+	s.Assertions.ErrorIs(s.imx.CreateMetadata(context.Background(), info), imx.ErrNotFound)
+}
+
+func (s *UnitTestSuite) TestMint() {
+	s.mint("1")
+
+	owner, ok := s.imx.Owner(contractAddress, "1")
+	s.Assertions.True(ok)
+	s.Assertions.Equal(normalize(PlatformAddress), owner)
+
+	asset, err := s.imx.GetAsset(context.Background(), contractAddress, "1")
+	s.Assertions.Nil(err)
+	s.Assertions.Equal(s.imx.Address(), asset.User)
+
+	_, err = s.imx.GetAsset(context.Background(), contractAddress, "2")
+	s.Assertions.ErrorIs(err, imx.ErrNotFound)
+
+	err = s.imx.CreateToken(context.Background(), &imx.MintInformation{ContractAddress: contractAddress, TokenID: "1"})
+	s.Assertions.ErrorIs(err, ErrAssetExists)
+
+	err = s.imx.CreateToken(context.Background(), &imx.MintInformation{ContractAddress: "0x02", TokenID: "1"})
+	s.Assertions.ErrorIs(err, ErrCollectionNotFound)
+}
+
+func (s *UnitTestSuite) TestTransfer() {
+	s.mint("1")
+
+	info := &imx.TransferInformation{ContractAddress: contractAddress, TokenID: "1", ReceiverAddress: "0x03"}
+	s.Assertions.ErrorIs(s.imx.TransferToken(context.Background(), info), ErrUserNotRegistered)
+
+	info.ReceiverAddress = s.user.Address
+	s.Assertions.Nil(s.imx.TransferToken(context.Background(), info))
+	owner, _ := s.imx.Owner(contractAddress, "1")
+	s.Assertions.Equal(normalize(s.user.Address), owner)
+
+	// the platform can't transfer what it doesn't own anymore
+	s.Assertions.ErrorIs(s.imx.TransferToken(context.Background(), info), ErrNotOwner)
+}
+
+func (s *UnitTestSuite) TestCreateOrder() {
+	_, err := s.imx.CreateOrder(context.Background(), &imx.OrderInformation{ContractAddress: contractAddress, TokenID: "1", Amount: 10})
+	s.Assertions.ErrorIs(err, ErrAssetNotFound)
+
+	s.mint("1")
+	orderID, err := s.imx.CreateOrder(context.Background(), &imx.OrderInformation{ContractAddress: contractAddress, TokenID: "1", Amount: 10})
+	s.Assertions.Nil(err)
+	s.Assertions.Equal(int32(1), orderID)
+
+	_, err = s.imx.CreateOrder(context.Background(), &imx.OrderInformation{ContractAddress: contractAddress, TokenID: "1", Amount: 10})
+	s.Assertions.ErrorIs(err, ErrAssetListed)
+
+	err = s.imx.TransferToken(context.Background(), &imx.TransferInformation{ContractAddress: contractAddress, TokenID: "1", ReceiverAddress: s.user.Address})
+	s.Assertions.ErrorIs(err, ErrAssetListed)
+}
+
+func (s *UnitTestSuite) TestTrade() {
+	s.mint("1")
+	orderID, err := s.imx.CreateOrder(context.Background(), &imx.OrderInformation{ContractAddress: contractAddress, TokenID: "1", Amount: 100})
+	s.Assertions.Nil(err)
+
+	_, err = s.imx.CreateTrade(context.Background(), &imx.CreateTradeInformation{User: s.user, OrderID: orderID})
+	s.Assertions.ErrorIs(err, ErrInsufficientBalance)
+
+	s.fund("150")
+	tradeID, err := s.imx.CreateTrade(context.Background(), &imx.CreateTradeInformation{User: s.user, OrderID: orderID})
+	s.Assertions.Nil(err)
+	s.Assertions.Equal(int32(1), tradeID)
+
+	s.Assertions.Equal(big.NewInt(50), s.imx.Balance(s.user.Address))
+	s.Assertions.Equal(big.NewInt(100), s.imx.Balance(PlatformAddress))
+	owner, _ := s.imx.Owner(contractAddress, "1")
+	s.Assertions.Equal(normalize(s.user.Address), owner)
+
+	_, err = s.imx.CreateTrade(context.Background(), &imx.CreateTradeInformation{User: s.user, OrderID: orderID})
+	s.Assertions.ErrorIs(err, ErrOrderNotActive)
+
+	orders, err := s.imx.ListOrders(context.Background(), contractAddress)
+	s.Assertions.Nil(err)
+	s.Assertions.Len(orders, 1)
+	s.Assertions.Equal(OrderStatusFilled, orders[0].Status)
+}
+
+func (s *UnitTestSuite) TestTradeWithUnregisteredUserShouldFail() {
+	s.mint("1")
+	orderID, err := s.imx.CreateOrder(context.Background(), &imx.OrderInformation{ContractAddress: contractAddress, TokenID: "1", Amount: 100})
+	s.Assertions.Nil(err)
+
+	_, err = s.imx.CreateTrade(context.Background(), &imx.CreateTradeInformation{User: &models.User{Address: "0x04"}, OrderID: orderID})
+	s.Assertions.ErrorIs(err, ErrUserNotRegistered)
+}
+
+func (s *UnitTestSuite) TestPlatformSignedOperationsRejectExternalUsers() {
+	external := &models.User{Address: "0x05", StarkPublicKey: "0x0123", External: true}
+	s.imx.Fund(external.Address, big.NewInt(100))
+
+	_, err := s.imx.CreateEthDeposit(context.Background(), &imx.CreateDepositInformation{User: external, AmountWei: "100"})
+	s.Assertions.ErrorIs(err, ErrExternalUser)
+	_, err = s.imx.CreateEthWithdrawal(context.Background(), &imx.CreateWithdrawalInformation{User: external, AmountWei: "100"})
+	s.Assertions.ErrorIs(err, ErrExternalUser)
+	_, err = s.imx.CreateTrade(context.Background(), &imx.CreateTradeInformation{User: external, OrderID: 1})
+	s.Assertions.ErrorIs(err, ErrExternalUser)
+}
+
+func (s *UnitTestSuite) TestExternalTrade() {
+	s.mint("1")
+	orderID, err := s.imx.CreateOrder(context.Background(), &imx.OrderInformation{ContractAddress: contractAddress, TokenID: "1", Amount: 100})
+	s.Assertions.Nil(err)
+
+	external := &models.User{Address: "0x05", StarkPublicKey: "0x0123", External: true}
+	s.imx.Fund(external.Address, big.NewInt(100))
+
+	signable, err := s.imx.GetSignableTrade(context.Background(), &imx.CreateTradeInformation{User: external, OrderID: orderID})
+	s.Assertions.Nil(err)
+	s.Assertions.NotEmpty(signable.PayloadHash)
+
+	submit := &imx.SubmitTradeInformation{User: external, OrderID: orderID, Payload: signable.Payload, EthSignature: "0x01"}
+	_, err = s.imx.SubmitTrade(context.Background(), submit)
+	s.Assertions.ErrorIs(err, ErrInvalidSignature)
+	s.Assertions.ErrorIs(err, imx.ErrInvalidSignature)
+
+	submit.StarkSignature = "0x02"
+	_, err = s.imx.SubmitTrade(context.Background(), submit)
+	s.Assertions.Nil(err)
+	s.Assertions.Equal(0, s.imx.Balance(external.Address).Sign())
+}
+
+func (s *UnitTestSuite) TestDepositIsConfirmedAfterDelay() {
+	now := time.Now()
+	s.imx.now = func() time.Time { return now }
+	s.imx.SetConfirmationDelay(time.Minute)
+
+	hash, err := s.imx.CreateEthDeposit(context.Background(), &imx.CreateDepositInformation{User: s.user, AmountWei: "10"})
+	s.Assertions.Nil(err)
+
+	var notConfirmed imx.DepositNotConfirmedError
+	s.Assertions.ErrorAs(s.imx.ConfirmEthDeposit(context.Background(), hash), &notConfirmed)
+	s.Assertions.Equal(0, s.imx.Balance(s.user.Address).Sign())
+
+	now = now.Add(time.Minute)
+	s.Assertions.Nil(s.imx.ConfirmEthDeposit(context.Background(), hash))
+	s.Assertions.Nil(s.imx.ConfirmEthDeposit(context.Background(), hash))
+	s.Assertions.Equal(big.NewInt(10), s.imx.Balance(s.user.Address))
+}
+
+func (s *UnitTestSuite) TestWithdrawal() {
+	now := time.Now()
+	s.imx.now = func() time.Time { return now }
+	s.fund("10")
+	s.imx.SetConfirmationDelay(time.Hour)
+
+	_, err := s.imx.CreateEthWithdrawal(context.Background(), &imx.CreateWithdrawalInformation{User: s.user, AmountWei: "11"})
+	s.Assertions.ErrorIs(err, ErrInsufficientBalance)
+
+	withdrawalID, err := s.imx.CreateEthWithdrawal(context.Background(), &imx.CreateWithdrawalInformation{User: s.user, AmountWei: "10"})
+	s.Assertions.Nil(err)
+	s.Assertions.Equal(0, s.imx.Balance(s.user.Address).Sign())
+
+	complete := &imx.CompleteWithdrawalInformation{User: s.user, WithdrawalID: withdrawalID}
+	var notReady imx.WithdrawalNotReadyError
+	s.Assertions.ErrorAs(s.imx.CompleteEthWithdrawal(context.Background(), complete), &notReady)
+	s.Assertions.Equal(RollupStatusIncluded, notReady.CurrentStatus)
+
+	now = now.Add(time.Hour)
+	s.Assertions.Nil(s.imx.CompleteEthWithdrawal(context.Background(), complete))
+	s.Assertions.ErrorIs(s.imx.CompleteEthWithdrawal(context.Background(), complete), ErrWithdrawalCompleted)
+
+	other := &imx.CompleteWithdrawalInformation{User: &models.User{Address: "0x06"}, WithdrawalID: withdrawalID}
+	s.Assertions.ErrorIs(s.imx.CompleteEthWithdrawal(context.Background(), other), ErrWithdrawalNotFound)
+}
+
+func (s *UnitTestSuite) TestListAssets() {
+	s.mint("2")
+	s.mint("1")
+
+	assets, err := s.imx.ListAssets(context.Background(), contractAddress)
+	s.Assertions.Nil(err)
+	s.Assertions.Len(assets, 2)
+	s.Assertions.Equal("1", assets[0].TokenID)
+	s.Assertions.Equal(AssetStatusIMX, assets[0].Status)
+
+	assets, err = s.imx.ListAssets(context.Background(), "0x02")
+	s.Assertions.Nil(err)
+	s.Assertions.Empty(assets)
+}
+
+func TestUnitTestSuite(t *testing.T) {
+	suite.Run(t, new(UnitTestSuite))
+}
@@ -0,0 +1,57 @@
+package fake
+
+import (
+	"context"
+	"fmt"
+	"nft/imx"
+	"sort"
+)
+
+func (f *IMX) GetAsset(ctx context.Context, contractAddress string, tokenID string) (*imx.AssetInformation, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	key := assetKey{normalize(contractAddress), tokenID}
+	a, ok := f.assets[key]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s/%s", ErrAssetNotFound, key.contractAddress, key.tokenID)
+	}
+	return &imx.AssetInformation{TokenID: tokenID, Status: a.status, User: a.owner}, nil
+}
+
+func (f *IMX) ListAssets(ctx context.Context, contractAddress string) ([]imx.AssetInformation, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	contractAddress = normalize(contractAddress)
+	assets := make([]imx.AssetInformation, 0)
+	for key, a := range f.assets {
+		if key.contractAddress == contractAddress {
+			assets = append(assets, imx.AssetInformation{TokenID: key.tokenID, Status: a.status, User: a.owner})
+		}
+	}
+
+	sort.Slice(assets, func(i, j int) bool { return assets[i].TokenID < assets[j].TokenID })
+	return assets, nil
+}
+
+func (f *IMX) ListOrders(ctx context.Context, contractAddress string) ([]imx.OrderSummary, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	contractAddress = normalize(contractAddress)
+	orders := make([]imx.OrderSummary, 0)
+	for _, o := range f.orders {
+		if o.asset.contractAddress == contractAddress {
+			orders = append(orders, imx.OrderSummary{
+				OrderID: o.id,
+				TokenID: o.asset.tokenID,
+				Status:  o.status,
+				Amount:  o.amount.String(),
+			})
+		}
+	}
+
+	sort.Slice(orders, func(i, j int) bool { return orders[i].OrderID < orders[j].OrderID })
+	return orders, nil
+}
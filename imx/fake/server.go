@@ -0,0 +1,1117 @@
+package fake
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"nft/imx"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/go-chi/chi/v5"
+	sdk "github.com/immutable/imx-core-sdk-golang/imx"
+	"github.com/immutable/imx-core-sdk-golang/imx/api"
+	"github.com/immutable/imx-core-sdk-golang/imx/signers/stark"
+)
+
+const (
+	// authorisationMaxAge is how old the IMX-Timestamp of a project owner request can be.
+	authorisationMaxAge = 5 * time.Minute
+
+	defaultPageSize = 100
+
+	registrationMessage = "Only sign this key linking request from Immutable X"
+)
+
+// ethAssetID is the StarkEx asset ID of ETH in orders, trades and withdrawals.
+var ethAssetID = assetID(assetKey{contractAddress: sdk.ETHTokenType})
+
+var (
+	errUnauthorised      = errors.New("unauthorised request")
+	errAlreadyRegistered = errors.New("user already registered with another stark key")
+)
+
+// Server is an httptest server speaking the subset of the IMX REST API and of the L1 JSON-RPC used by imx.IMX,
+// backed by an IMX simulator. Eth signatures must recover the caller address and stark signatures must verify
+// against the caller stark key, so request shapes, signing and error decoding of the client are exercised end
+// to end. Deposits and withdrawal completions send L1 transactions, which the server does not mine.
+type Server struct {
+	*httptest.Server
+	imx      *IMX
+	chainID  *big.Int
+	verifier *stark.Signer
+
+	mu                sync.Mutex
+	projects          map[int32]*project
+	collections       map[string]*api.Collection
+	registrations     map[string]*signable
+	signables         map[int32]*signable
+	lastProjectID     int32
+	lastNonce         int32
+	lastTransactionID int32
+}
+
+type project struct {
+	api.Project
+	owner string
+}
+
+// signable is an operation waiting for the signatures of user with starkKey. apply runs it on the simulator,
+// with its lock held, once they verify.
+type signable struct {
+	user        string
+	starkKey    string
+	message     string
+	payloadHash string
+	apply       func() (int32, error)
+}
+
+// NewServer starts a server backed by f, with an L1 on chainID. Close it when done.
+func NewServer(f *IMX, chainID *big.Int) (*Server, error) {
+	key, err := stark.GenerateKey()
+	if err != nil {
+		return nil, err
+	}
+
+	// any signer verifies signatures of other keys, creating one loads the curve
+	verifier, err := stark.NewSigner(key)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &Server{
+		imx:           f,
+		chainID:       chainID,
+		verifier:      verifier,
+		projects:      make(map[int32]*project),
+		collections:   make(map[string]*api.Collection),
+		registrations: make(map[string]*signable),
+		signables:     make(map[int32]*signable),
+	}
+
+	r := chi.NewRouter()
+	r.Post("/rpc", s.rpc)
+	r.Route("/v1", func(r chi.Router) {
+		r.Post("/signable-registration-offchain", s.signableRegistration)
+		r.Post("/users", s.registerUser)
+		r.Get("/users/{user}", s.getUser)
+		r.Post("/projects", s.createProject)
+		r.Get("/projects/{id}", s.getProject)
+		r.Post("/collections", s.createCollection)
+		r.Get("/collections", s.listCollections)
+		r.Get("/collections/{address}", s.getCollection)
+		r.Post("/collections/{address}/metadata-schema", s.addMetadataSchema)
+		r.Post("/orders", s.createOrder)
+		r.Get("/orders", s.listOrders)
+		r.Post("/trades", s.createTrade)
+		r.Post("/signable-withdrawal-details", s.signableWithdrawal)
+		r.Post("/withdrawals", s.createWithdrawal)
+		r.Get("/withdrawals/{id}", s.getWithdrawal)
+		r.Get("/assets", s.listAssets)
+		r.Get("/assets/{address}/{id}", s.getAsset)
+	})
+	r.Route("/v2", func(r chi.Router) {
+		r.Post("/mints", s.mint)
+		r.Post("/signable-transfer-details", s.signableTransfer)
+		r.Post("/transfers", s.createTransfer)
+	})
+	r.Route("/v3", func(r chi.Router) {
+		r.Post("/signable-order-details", s.signableOrder)
+		r.Post("/signable-trade-details", s.signableTrade)
+	})
+
+	s.Server = httptest.NewServer(r)
+	return s, nil
+}
+
+// RPCURL returns the url of the L1 JSON-RPC endpoint.
+func (s *Server) RPCURL() string {
+	return s.URL + "/rpc"
+}
+
+// AddProject creates a project owned by the address, as if it was created on the IMX dashboard, and returns its ID.
+func (s *Server) AddProject(owner string, name string) int32 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.addProject(owner, api.CreateProjectRequest{Name: name})
+}
+
+func (s *Server) addProject(owner string, request api.CreateProjectRequest) int32 {
+	s.lastProjectID++
+	s.projects[s.lastProjectID] = &project{
+		Project: api.Project{
+			Id:                     s.lastProjectID,
+			Name:                   request.Name,
+			CompanyName:            request.CompanyName,
+			ContactEmail:           request.ContactEmail,
+			CollectionMonthlyLimit: 5,
+			CollectionRemaining:    5,
+			MintMonthlyLimit:       50000,
+			MintRemaining:          50000,
+		},
+		owner: normalize(owner),
+	}
+	return s.lastProjectID
+}
+
+type rpcRequest struct {
+	ID     json.RawMessage `json:"id"`
+	Method string          `json:"method"`
+}
+
+type rpcError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+type rpcResponse struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id"`
+	Result  interface{}     `json:"result"`
+	Error   *rpcError       `json:"error,omitempty"`
+}
+
+// rpc answers the L1 calls that do not send transactions: the chain ID, the contract code checked when the
+// client is created, and transaction receipts, which are never found.
+func (s *Server) rpc(w http.ResponseWriter, r *http.Request) {
+	var request rpcRequest
+	if !decode(w, r, &request) {
+		return
+	}
+
+	response := rpcResponse{JSONRPC: "2.0", ID: request.ID}
+	switch request.Method {
+	case "eth_chainId":
+		response.Result = hexutil.EncodeBig(s.chainID)
+	case "eth_blockNumber":
+		response.Result = "0x1"
+	case "eth_getCode":
+		response.Result = "0x01"
+	case "eth_getTransactionReceipt":
+		response.Result = nil
+	default:
+		response.Error = &rpcError{Code: -32601, Message: fmt.Sprintf("method %s is not supported", request.Method)}
+	}
+
+	writeJSON(w, http.StatusOK, response)
+}
+
+func (s *Server) signableRegistration(w http.ResponseWriter, r *http.Request) {
+	var request api.GetSignableRegistrationRequest
+	if !decode(w, r, &request) {
+		return
+	}
+
+	etherKey := normalize(request.EtherKey)
+	starkKey := request.StarkKey
+	pending := &signable{
+		user:        etherKey,
+		starkKey:    starkKey,
+		message:     registrationMessage,
+		payloadHash: randomPayloadHash(),
+		apply: func() (int32, error) {
+			if registered, ok := s.imx.users[etherKey]; ok && !sameKey(registered, starkKey) {
+				return 0, fmt.Errorf("%w: %s", errAlreadyRegistered, etherKey)
+			}
+			s.imx.users[etherKey] = starkKey
+			return 0, nil
+		},
+	}
+
+	s.mu.Lock()
+	s.registrations[etherKey+starkKey] = pending
+	s.mu.Unlock()
+
+	writeJSON(w, http.StatusOK, api.GetSignableRegistrationOffchainResponse{
+		PayloadHash:     pending.payloadHash,
+		SignableMessage: pending.message,
+	})
+}
+
+func (s *Server) registerUser(w http.ResponseWriter, r *http.Request) {
+	var request api.RegisterUserRequest
+	if !decode(w, r, &request) {
+		return
+	}
+
+	key := normalize(request.EtherKey) + request.StarkKey
+	s.mu.Lock()
+	pending, ok := s.registrations[key]
+	delete(s.registrations, key)
+	s.mu.Unlock()
+	if !ok {
+		writeFailure(w, fmt.Errorf("%w: no signable registration for %s", ErrInvalidSignature, request.EtherKey))
+		return
+	}
+
+	if _, ok := s.run(w, pending, request.EtherKey, request.EthSignature, request.StarkKey, request.StarkSignature); !ok {
+		return
+	}
+
+	writeJSON(w, http.StatusOK, api.RegisterUserResponse{})
+}
+
+func (s *Server) getUser(w http.ResponseWriter, r *http.Request) {
+	starkKey, err := s.starkKey(chi.URLParam(r, "user"))
+	if err != nil {
+		writeFailure(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, api.GetUsersApiResponse{Accounts: []string{starkKey}})
+}
+
+func (s *Server) createProject(w http.ResponseWriter, r *http.Request) {
+	owner, err := authorise(r)
+	if err != nil {
+		writeFailure(w, err)
+		return
+	}
+
+	var request api.CreateProjectRequest
+	if !decode(w, r, &request) {
+		return
+	}
+
+	s.mu.Lock()
+	id := s.addProject(owner, request)
+	s.mu.Unlock()
+
+	writeJSON(w, http.StatusCreated, api.CreateProjectResponse{Id: id})
+}
+
+func (s *Server) getProject(w http.ResponseWriter, r *http.Request) {
+	owner, err := authorise(r)
+	if err != nil {
+		writeFailure(w, err)
+		return
+	}
+
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 32)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid_request", err.Error())
+		return
+	}
+
+	p, err := s.project(int32(id), owner)
+	if err != nil {
+		writeFailure(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, p.Project)
+}
+
+func (s *Server) createCollection(w http.ResponseWriter, r *http.Request) {
+	owner, err := authorise(r)
+	if err != nil {
+		writeFailure(w, err)
+		return
+	}
+
+	var request api.CreateCollectionRequest
+	if !decode(w, r, &request) {
+		return
+	}
+
+	p, err := s.project(request.ProjectId, owner)
+	if err != nil {
+		writeFailure(w, err)
+		return
+	}
+
+	s.imx.mu.Lock()
+	err = s.imx.createCollection(request.ContractAddress, request.Name)
+	s.imx.mu.Unlock()
+	if err != nil {
+		writeFailure(w, err)
+		return
+	}
+
+	collection := &api.Collection{
+		Address:             request.ContractAddress,
+		Name:                request.Name,
+		ProjectId:           p.Id,
+		ProjectOwnerAddress: p.owner,
+	}
+	collection.MetadataApiUrl.Set(request.MetadataApiUrl)
+
+	s.mu.Lock()
+	s.collections[normalize(request.ContractAddress)] = collection
+	s.mu.Unlock()
+
+	writeJSON(w, http.StatusCreated, collection)
+}
+
+func (s *Server) getCollection(w http.ResponseWriter, r *http.Request) {
+	collection, err := s.collection(chi.URLParam(r, "address"))
+	if err != nil {
+		writeFailure(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, collection)
+}
+
+func (s *Server) listCollections(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	collections := make([]api.Collection, 0, len(s.collections))
+	for _, collection := range s.collections {
+		collections = append(collections, *collection)
+	}
+	s.mu.Unlock()
+	sort.Slice(collections, func(i, j int) bool { return collections[i].Address < collections[j].Address })
+
+	start, end, cursor, remaining, err := page(r, len(collections))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid_request", err.Error())
+		return
+	}
+
+	writeJSON(w, http.StatusOK, api.ListCollectionsResponse{Cursor: cursor, Remaining: remaining, Result: collections[start:end]})
+}
+
+func (s *Server) addMetadataSchema(w http.ResponseWriter, r *http.Request) {
+	owner, err := authorise(r)
+	if err != nil {
+		writeFailure(w, err)
+		return
+	}
+
+	var request api.AddMetadataSchemaToCollectionRequest
+	if !decode(w, r, &request) {
+		return
+	}
+
+	contractAddress := chi.URLParam(r, "address")
+	if err := s.checkCollectionOwner(contractAddress, owner); err != nil {
+		writeFailure(w, err)
+		return
+	}
+
+	info := &imx.MetadataInformation{ContractAddress: contractAddress}
+	for _, field := range request.Metadata {
+		info.Fields = append(info.Fields, imx.MetadataFieldInformation{Name: field.Name, Type: field.GetType()})
+	}
+
+	if err := s.imx.CreateMetadata(r.Context(), info); err != nil {
+		writeFailure(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, api.SuccessResponse{Result: "success"})
+}
+
+func (s *Server) mint(w http.ResponseWriter, r *http.Request) {
+	var requests []api.MintRequest
+	if !decode(w, r, &requests) {
+		return
+	}
+
+	response := api.MintTokensResponse{Results: make([]api.MintResultDetails, 0)}
+	for _, request := range requests {
+		hash, err := mintHash(request)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "invalid_request", err.Error())
+			return
+		}
+
+		minter, err := recoverAddress(hash, request.AuthSignature)
+		if err != nil {
+			writeFailure(w, err)
+			return
+		}
+
+		if err := s.checkCollectionOwner(request.ContractAddress, minter); err != nil {
+			writeFailure(w, err)
+			return
+		}
+
+		for _, user := range request.Users {
+			for _, token := range user.Tokens {
+				s.imx.mu.Lock()
+				err = s.imx.mint(assetKey{normalize(request.ContractAddress), token.Id}, normalize(user.User), token.Blueprint)
+				s.imx.mu.Unlock()
+				if err != nil {
+					writeFailure(w, err)
+					return
+				}
+
+				response.Results = append(response.Results, api.MintResultDetails{
+					ContractAddress: request.ContractAddress,
+					TokenId:         token.Id,
+					TxId:            s.nextTransactionID(),
+				})
+			}
+		}
+	}
+
+	writeJSON(w, http.StatusOK, response)
+}
+
+func (s *Server) signableTransfer(w http.ResponseWriter, r *http.Request) {
+	var request api.GetSignableTransferRequest
+	if !decode(w, r, &request) {
+		return
+	}
+
+	sender := normalize(request.SenderEtherKey)
+	starkKey, err := s.starkKey(sender)
+	if err != nil {
+		writeFailure(w, err)
+		return
+	}
+
+	// the eth signature covers the whole batch, each transfer has its own stark signature
+	message := fmt.Sprintf("You are transferring %d assets", len(request.SignableRequests))
+	response := api.GetSignableTransferResponse{
+		SenderStarkKey:    starkKey,
+		SignableMessage:   message,
+		SignableResponses: make([]api.SignableTransferResponseDetails, 0, len(request.SignableRequests)),
+	}
+	for _, details := range request.SignableRequests {
+		key, err := erc721Key(details.Token)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "invalid_request", err.Error())
+			return
+		}
+
+		receiver := normalize(details.Receiver)
+		receiverStarkKey, err := s.starkKey(receiver)
+		if err != nil {
+			writeFailure(w, err)
+			return
+		}
+
+		nonce, pending := s.newSignable(sender, starkKey, message, func() (int32, error) {
+			return 0, s.imx.transfer(key, sender, receiver)
+		})
+		response.SignableResponses = append(response.SignableResponses, api.SignableTransferResponseDetails{
+			Amount:           details.Amount,
+			AssetId:          assetID(key),
+			Nonce:            nonce,
+			PayloadHash:      pending.payloadHash,
+			ReceiverStarkKey: receiverStarkKey,
+			Token:            details.Token,
+		})
+	}
+
+	writeJSON(w, http.StatusOK, response)
+}
+
+func (s *Server) createTransfer(w http.ResponseWriter, r *http.Request) {
+	var request api.CreateTransferRequest
+	if !decode(w, r, &request) {
+		return
+	}
+
+	response := api.CreateTransferResponse{TransferIds: make([]int32, 0, len(request.Requests))}
+	for _, transfer := range request.Requests {
+		pending, err := s.takeSignable(transfer.Nonce)
+		if err != nil {
+			writeFailure(w, err)
+			return
+		}
+
+		if _, ok := s.run(w, pending, ethAddress(r), ethSignature(r), request.SenderStarkKey, transfer.StarkSignature); !ok {
+			return
+		}
+		response.TransferIds = append(response.TransferIds, s.nextTransactionID())
+	}
+
+	writeJSON(w, http.StatusOK, response)
+}
+
+func (s *Server) signableOrder(w http.ResponseWriter, r *http.Request) {
+	var request api.GetSignableOrderRequest
+	if !decode(w, r, &request) {
+		return
+	}
+
+	seller := normalize(request.User)
+	starkKey, err := s.starkKey(seller)
+	if err != nil {
+		writeFailure(w, err)
+		return
+	}
+
+	key, err := erc721Key(request.TokenSell)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid_request", err.Error())
+		return
+	}
+
+	amount, err := parseAmount(request.AmountBuy)
+	if err != nil {
+		writeFailure(w, err)
+		return
+	}
+
+	message := fmt.Sprintf("You are selling asset %s for %s wei", assetID(key), amount)
+	nonce, pending := s.newSignable(seller, starkKey, message, func() (int32, error) {
+		return s.imx.createOrder(key, seller, amount)
+	})
+
+	writeJSON(w, http.StatusOK, api.GetSignableOrderResponse{
+		AmountBuy:       request.AmountBuy,
+		AmountSell:      request.AmountSell,
+		AssetIdBuy:      ethAssetID,
+		AssetIdSell:     assetID(key),
+		Nonce:           nonce,
+		PayloadHash:     pending.payloadHash,
+		SignableMessage: pending.message,
+		StarkKey:        starkKey,
+	})
+}
+
+func (s *Server) createOrder(w http.ResponseWriter, r *http.Request) {
+	var request api.CreateOrderRequest
+	if !decode(w, r, &request) {
+		return
+	}
+
+	pending, err := s.takeSignable(request.Nonce)
+	if err != nil {
+		writeFailure(w, err)
+		return
+	}
+
+	orderID, ok := s.run(w, pending, ethAddress(r), ethSignature(r), request.StarkKey, request.StarkSignature)
+	if !ok {
+		return
+	}
+
+	writeJSON(w, http.StatusOK, api.CreateOrderResponse{OrderId: orderID, Status: "success", Time: int32(time.Now().Unix())})
+}
+
+func (s *Server) listOrders(w http.ResponseWriter, r *http.Request) {
+	contractAddress := r.URL.Query().Get("sell_token_address")
+	orders, err := s.imx.ListOrders(r.Context(), contractAddress)
+	if err != nil {
+		writeFailure(w, err)
+		return
+	}
+
+	start, end, cursor, remaining, err := page(r, len(orders))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid_request", err.Error())
+		return
+	}
+
+	result := make([]api.Order, 0, end-start)
+	for _, o := range orders[start:end] {
+		tokenID := o.TokenID
+		result = append(result, api.Order{
+			OrderId: o.OrderID,
+			Status:  o.Status,
+			Sell:    api.Token{Type: sdk.ERC721TokenType, Data: api.TokenData{TokenId: &tokenID, TokenAddress: &contractAddress, Quantity: "1"}},
+			Buy:     api.Token{Type: sdk.ETHTokenType, Data: api.TokenData{Quantity: o.Amount}},
+		})
+	}
+
+	writeJSON(w, http.StatusOK, api.ListOrdersResponse{Cursor: cursor, Remaining: remaining, Result: result})
+}
+
+func (s *Server) signableTrade(w http.ResponseWriter, r *http.Request) {
+	var request api.GetSignableTradeRequest
+	if !decode(w, r, &request) {
+		return
+	}
+
+	buyer := normalize(request.User)
+	starkKey, err := s.starkKey(buyer)
+	if err != nil {
+		writeFailure(w, err)
+		return
+	}
+
+	s.imx.mu.Lock()
+	o, ok := s.imx.orders[request.OrderId]
+	s.imx.mu.Unlock()
+	if !ok {
+		writeFailure(w, fmt.Errorf("%w: %d", ErrOrderNotFound, request.OrderId))
+		return
+	}
+
+	orderID := request.OrderId
+	message := fmt.Sprintf("You are buying asset %s for %s wei", assetID(o.asset), o.amount)
+	nonce, pending := s.newSignable(buyer, starkKey, message, func() (int32, error) {
+		return s.imx.trade(buyer, orderID)
+	})
+
+	writeJSON(w, http.StatusOK, api.GetSignableTradeResponse{
+		AmountBuy:       "1",
+		AmountSell:      o.amount.String(),
+		AssetIdBuy:      assetID(o.asset),
+		AssetIdSell:     ethAssetID,
+		Nonce:           nonce,
+		PayloadHash:     pending.payloadHash,
+		SignableMessage: pending.message,
+		StarkKey:        starkKey,
+	})
+}
+
+func (s *Server) createTrade(w http.ResponseWriter, r *http.Request) {
+	var request api.CreateTradeRequestV1
+	if !decode(w, r, &request) {
+		return
+	}
+
+	pending, err := s.takeSignable(request.Nonce)
+	if err != nil {
+		writeFailure(w, err)
+		return
+	}
+
+	tradeID, ok := s.run(w, pending, ethAddress(r), ethSignature(r), request.StarkKey, request.StarkSignature)
+	if !ok {
+		return
+	}
+
+	writeJSON(w, http.StatusOK, api.CreateTradeResponse{TradeId: tradeID, Status: "success"})
+}
+
+func (s *Server) signableWithdrawal(w http.ResponseWriter, r *http.Request) {
+	var request api.GetSignableWithdrawalRequest
+	if !decode(w, r, &request) {
+		return
+	}
+
+	user := normalize(request.User)
+	starkKey, err := s.starkKey(user)
+	if err != nil {
+		writeFailure(w, err)
+		return
+	}
+
+	if request.Token.GetType() != sdk.ETHTokenType {
+		writeError(w, http.StatusBadRequest, "invalid_request", "only ETH withdrawals are supported")
+		return
+	}
+
+	amount, err := parseAmount(request.Amount)
+	if err != nil {
+		writeFailure(w, err)
+		return
+	}
+
+	message := fmt.Sprintf("You are withdrawing %s wei", amount)
+	nonce, pending := s.newSignable(user, starkKey, message, func() (int32, error) {
+		return s.imx.withdraw(user, amount)
+	})
+
+	writeJSON(w, http.StatusOK, api.GetSignableWithdrawalResponse{
+		Amount:          request.Amount,
+		AssetId:         ethAssetID,
+		Nonce:           nonce,
+		PayloadHash:     pending.payloadHash,
+		SignableMessage: pending.message,
+		StarkKey:        starkKey,
+	})
+}
+
+func (s *Server) createWithdrawal(w http.ResponseWriter, r *http.Request) {
+	var request api.CreateWithdrawalRequest
+	if !decode(w, r, &request) {
+		return
+	}
+
+	pending, err := s.takeSignable(request.Nonce)
+	if err != nil {
+		writeFailure(w, err)
+		return
+	}
+
+	withdrawalID, ok := s.run(w, pending, ethAddress(r), ethSignature(r), request.StarkKey, request.StarkSignature)
+	if !ok {
+		return
+	}
+
+	writeJSON(w, http.StatusOK, api.CreateWithdrawalResponse{WithdrawalId: withdrawalID, Status: "success", Time: int32(time.Now().Unix())})
+}
+
+func (s *Server) getWithdrawal(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 32)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid_request", err.Error())
+		return
+	}
+
+	s.imx.mu.Lock()
+	defer s.imx.mu.Unlock()
+
+	withdrawal, ok := s.imx.withdrawals[int32(id)]
+	if !ok {
+		writeFailure(w, fmt.Errorf("%w: %d", ErrWithdrawalNotFound, id))
+		return
+	}
+
+	writeJSON(w, http.StatusOK, api.Withdrawal{
+		RollupStatus:      s.imx.rollupStatus(withdrawal),
+		Sender:            withdrawal.user,
+		Status:            "success",
+		Timestamp:         withdrawal.createdAt.UTC().Format(time.RFC3339),
+		Token:             api.Token{Type: sdk.ETHTokenType, Data: api.TokenData{Quantity: withdrawal.amount.String()}},
+		TransactionId:     int32(id),
+		WithdrawnToWallet: withdrawal.completed,
+	})
+}
+
+func (s *Server) getAsset(w http.ResponseWriter, r *http.Request) {
+	contractAddress := chi.URLParam(r, "address")
+	a, err := s.imx.GetAsset(r.Context(), contractAddress, chi.URLParam(r, "id"))
+	if err != nil {
+		writeFailure(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, api.Asset{
+		Status:       a.Status,
+		TokenAddress: contractAddress,
+		TokenId:      a.TokenID,
+		User:         a.User,
+	})
+}
+
+func (s *Server) listAssets(w http.ResponseWriter, r *http.Request) {
+	contractAddress := r.URL.Query().Get("collection")
+	assets, err := s.imx.ListAssets(r.Context(), contractAddress)
+	if err != nil {
+		writeFailure(w, err)
+		return
+	}
+
+	start, end, cursor, remaining, err := page(r, len(assets))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid_request", err.Error())
+		return
+	}
+
+	result := make([]api.AssetWithOrders, 0, end-start)
+	for _, a := range assets[start:end] {
+		result = append(result, api.AssetWithOrders{
+			Status:       a.Status,
+			TokenAddress: contractAddress,
+			TokenId:      a.TokenID,
+			User:         a.User,
+		})
+	}
+
+	writeJSON(w, http.StatusOK, api.ListAssetsResponse{Cursor: cursor, Remaining: remaining, Result: result})
+}
+
+// run verifies the signatures of a signable operation and applies it, writing the failure if any.
+func (s *Server) run(w http.ResponseWriter, pending *signable, ethAddress string, ethSignature string, starkKey string, starkSignature string) (int32, bool) {
+	if err := s.verify(pending, ethAddress, ethSignature, starkKey, starkSignature); err != nil {
+		writeFailure(w, err)
+		return 0, false
+	}
+
+	s.imx.mu.Lock()
+	id, err := pending.apply()
+	s.imx.mu.Unlock()
+	if err != nil {
+		writeFailure(w, err)
+		return 0, false
+	}
+
+	return id, true
+}
+
+// verify checks the eth signature of the signable message recovers the address of the user, and the stark
+// signature of the payload hash verifies against the stark key of the user.
+func (s *Server) verify(pending *signable, ethAddress string, ethSignature string, starkKey string, starkSignature string) error {
+	signer, err := recoverAddress(pending.message, ethSignature)
+	if err != nil {
+		return err
+	}
+
+	if signer != pending.user || normalize(ethAddress) != pending.user {
+		return fmt.Errorf("%w: eth signature is not from %s", ErrInvalidSignature, pending.user)
+	}
+
+	if !sameKey(starkKey, pending.starkKey) {
+		return fmt.Errorf("%w: stark key %s is not the key of %s", ErrInvalidSignature, starkKey, pending.user)
+	}
+
+	hash, ok := new(big.Int).SetString(pending.payloadHash, 0)
+	if !ok {
+		return fmt.Errorf("invalid payload hash %s", pending.payloadHash)
+	}
+
+	if err := s.verifier.VerifySignature(hash, starkSignature, pending.starkKey); err != nil {
+		return fmt.Errorf("%w: %s", ErrInvalidSignature, err)
+	}
+
+	return nil
+}
+
+func (s *Server) newSignable(user string, starkKey string, message string, apply func() (int32, error)) (int32, *signable) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.lastNonce++
+	pending := &signable{user: user, starkKey: starkKey, message: message, payloadHash: randomPayloadHash(), apply: apply}
+	s.signables[s.lastNonce] = pending
+	return s.lastNonce, pending
+}
+
+// takeSignable removes the signable operation, so it can only be submitted once.
+func (s *Server) takeSignable(nonce int32) (*signable, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	pending, ok := s.signables[nonce]
+	if !ok {
+		return nil, fmt.Errorf("%w: unknown nonce %d", ErrInvalidSignature, nonce)
+	}
+
+	delete(s.signables, nonce)
+	return pending, nil
+}
+
+func (s *Server) project(id int32, owner string) (*project, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	p, ok := s.projects[id]
+	if !ok || p.owner != owner {
+		return nil, fmt.Errorf("%w: project %d is not owned by %s", errUnauthorised, id, owner)
+	}
+
+	return p, nil
+}
+
+func (s *Server) collection(contractAddress string) (*api.Collection, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	collection, ok := s.collections[normalize(contractAddress)]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrCollectionNotFound, contractAddress)
+	}
+
+	return collection, nil
+}
+
+func (s *Server) checkCollectionOwner(contractAddress string, owner string) error {
+	collection, err := s.collection(contractAddress)
+	if err != nil {
+		return err
+	}
+
+	if collection.ProjectOwnerAddress != owner {
+		return fmt.Errorf("%w: collection %s is not owned by %s", errUnauthorised, contractAddress, owner)
+	}
+
+	return nil
+}
+
+func (s *Server) starkKey(address string) (string, error) {
+	s.imx.mu.Lock()
+	defer s.imx.mu.Unlock()
+
+	starkKey, ok := s.imx.users[normalize(address)]
+	if !ok {
+		return "", fmt.Errorf("%w: %s", ErrUserNotRegistered, address)
+	}
+
+	return starkKey, nil
+}
+
+func (s *Server) nextTransactionID() int32 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.lastTransactionID++
+	return s.lastTransactionID
+}
+
+// authorise returns the address that signed the IMX-Timestamp header of a project owner request.
+func authorise(r *http.Request) (string, error) {
+	timestamp := r.Header.Get("IMX-Timestamp")
+	seconds, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return "", fmt.Errorf("%w: invalid IMX-Timestamp", errUnauthorised)
+	}
+
+	if time.Since(time.Unix(seconds, 0)).Abs() > authorisationMaxAge {
+		return "", fmt.Errorf("%w: expired IMX-Timestamp", errUnauthorised)
+	}
+
+	signer, err := recoverAddress(timestamp, r.Header.Get("IMX-Signature"))
+	if err != nil {
+		return "", fmt.Errorf("%w: %s", errUnauthorised, err)
+	}
+
+	return signer, nil
+}
+
+func ethAddress(r *http.Request) string {
+	return r.Header.Get("x-imx-eth-address")
+}
+
+func ethSignature(r *http.Request) string {
+	return r.Header.Get("x-imx-eth-signature")
+}
+
+// recoverAddress returns the address that signed the message as an eth personal message.
+func recoverAddress(message string, signature string) (string, error) {
+	sig, err := hexutil.Decode(signature)
+	if err != nil || len(sig) != crypto.SignatureLength {
+		return "", fmt.Errorf("%w: malformed eth signature", ErrInvalidSignature)
+	}
+
+	// wallets sign with a recovery ID of 27 or 28, go-ethereum with 0 or 1
+	if sig[crypto.RecoveryIDOffset] >= 27 {
+		sig[crypto.RecoveryIDOffset] -= 27
+	}
+
+	publicKey, err := crypto.SigToPub(accounts.TextHash([]byte(message)), sig)
+	if err != nil {
+		return "", fmt.Errorf("%w: %s", ErrInvalidSignature, err)
+	}
+
+	return normalize(crypto.PubkeyToAddress(*publicKey).Hex()), nil
+}
+
+// mintHash rebuilds the message the minter signed, the hash of the unsigned mint request as the sdk encodes it.
+func mintHash(request api.MintRequest) (string, error) {
+	unsigned := sdk.UnsignedMintRequest{ContractAddress: request.ContractAddress, Royalties: mintFees(request.Royalties)}
+	for _, user := range request.Users {
+		tokens := make([]sdk.MintableTokenData, 0, len(user.Tokens))
+		for _, token := range user.Tokens {
+			tokens = append(tokens, sdk.MintableTokenData{ID: token.Id, Blueprint: token.Blueprint, Royalties: mintFees(token.Royalties)})
+		}
+		unsigned.Users = append(unsigned.Users, sdk.User{User: user.User, Tokens: tokens})
+	}
+
+	b, err := json.Marshal(unsigned)
+	if err != nil {
+		return "", err
+	}
+
+	return crypto.Keccak256Hash(b).String(), nil
+}
+
+func mintFees(fees []api.MintFee) []sdk.MintFee {
+	if len(fees) == 0 {
+		return nil
+	}
+
+	mapped := make([]sdk.MintFee, 0, len(fees))
+	for _, fee := range fees {
+		mapped = append(mapped, sdk.MintFee{Recipient: fee.Recipient, Percentage: fee.Percentage})
+	}
+	return mapped
+}
+
+func erc721Key(token api.SignableToken) (assetKey, error) {
+	if token.GetType() != sdk.ERC721TokenType {
+		return assetKey{}, fmt.Errorf("token type %s is not supported", token.GetType())
+	}
+
+	contractAddress, _ := token.Data["token_address"].(string)
+	tokenID, _ := token.Data["token_id"].(string)
+	if len(contractAddress) == 0 || len(tokenID) == 0 {
+		return assetKey{}, errors.New("missing token address or token id")
+	}
+
+	return assetKey{normalize(contractAddress), tokenID}, nil
+}
+
+func assetID(key assetKey) string {
+	return crypto.Keccak256Hash([]byte(key.contractAddress + "/" + key.tokenID)).String()
+}
+
+func sameKey(a string, b string) bool {
+	x, ok := new(big.Int).SetString(a, 0)
+	if !ok {
+		return false
+	}
+
+	y, ok := new(big.Int).SetString(b, 0)
+	return ok && x.Cmp(y) == 0
+}
+
+// randomPayloadHash returns a hash below the stark field prime, which stark signers require.
+func randomPayloadHash() string {
+	return randomHex()[:64]
+}
+
+// page returns the bounds of the requested page of n results, with the cursor and count of what remains after it.
+func page(r *http.Request, n int) (int, int, string, int32, error) {
+	size := defaultPageSize
+	if value := r.URL.Query().Get("page_size"); len(value) > 0 {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed <= 0 {
+			return 0, 0, "", 0, fmt.Errorf("invalid page size %s", value)
+		}
+		size = parsed
+	}
+
+	start := 0
+	if value := r.URL.Query().Get("cursor"); len(value) > 0 {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed < 0 {
+			return 0, 0, "", 0, fmt.Errorf("invalid cursor %s", value)
+		}
+		start = parsed
+	}
+
+	if start > n {
+		start = n
+	}
+	end := start + size
+	if end > n {
+		end = n
+	}
+
+	cursor := ""
+	if end < n {
+		cursor = strconv.Itoa(end)
+	}
+	return start, end, cursor, int32(n - end), nil
+}
+
+func decode(w http.ResponseWriter, r *http.Request, v interface{}) bool {
+	if err := json.NewDecoder(r.Body).Decode(v); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid_request", err.Error())
+		return false
+	}
+	return true
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, code string, message string) {
+	writeJSON(w, status, api.APIError{Code: code, Message: message})
+}
+
+// writeFailure writes the error with the status and code IMX answers for its kind.
+func writeFailure(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, imx.ErrNotFound):
+		writeError(w, http.StatusNotFound, "resource_not_found", err.Error())
+	case errors.Is(err, imx.ErrConflict), errors.Is(err, errAlreadyRegistered):
+		writeError(w, http.StatusConflict, "conflict", err.Error())
+	case errors.Is(err, imx.ErrInsufficientBalance):
+		writeError(w, http.StatusBadRequest, "insufficient_balance", err.Error())
+	case errors.Is(err, ErrInvalidSignature), errors.Is(err, errUnauthorised):
+		writeError(w, http.StatusUnauthorized, "unauthorised_request", err.Error())
+	default:
+		writeError(w, http.StatusBadRequest, "invalid_request", err.Error())
+	}
+}
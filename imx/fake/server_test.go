@@ -0,0 +1,376 @@
+package fake
+
+import (
+	"context"
+	"encoding/hex"
+	"math/big"
+	"net/http"
+	"nft/imx"
+	"nft/models"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/immutable/imx-core-sdk-golang/imx/signers/stark"
+	"github.com/stretchr/testify/suite"
+)
+
+const collectionAddress = "0xC0FFEE0000000000000000000000000000000002"
+
+type ServerTestSuite struct {
+	suite.Suite
+	imx      *IMX
+	server   *Server
+	client   imx.Client
+	settings *imx.Settings
+	platform string
+}
+
+func (s *ServerTestSuite) SetupTest() {
+	key, err := crypto.GenerateKey()
+	s.Assertions.Nil(err)
+	s.platform = crypto.PubkeyToAddress(key.PublicKey).Hex()
+
+	starkPrivateKey, err := stark.GenerateKey()
+	s.Assertions.Nil(err)
+	starkSigner, err := stark.NewSigner(starkPrivateKey)
+	s.Assertions.Nil(err)
+
+	s.imx = New(s.platform)
+	s.imx.RegisterUser(s.platform, starkSigner.GetPublicKey())
+
+	s.server, err = NewServer(s.imx, big.NewInt(5))
+	s.Assertions.Nil(err)
+
+	s.settings = &imx.Settings{
+		Environment:        imx.EnvironmentSandbox,
+		ChainID:            5,
+		APIURL:             s.server.URL,
+		L1RPCURL:           s.server.RPCURL(),
+		L1SignerPrivateKey: hex.EncodeToString(crypto.FromECDSA(key)),
+		StarkPrivateKey:    starkPrivateKey,
+		ProjectID:          s.server.AddProject(s.platform, "nft"),
+	}
+
+	s.client, err = imx.NewIMX(s.settings)
+	s.Assertions.Nil(err)
+
+	err = s.client.CreateCollection(context.Background(), &imx.CollectionInformation{ContractAddress: collectionAddress, CollectionName: "collection"})
+	s.Assertions.Nil(err)
+}
+
+func (s *ServerTestSuite) TearDownTest() {
+	s.client.Close()
+	s.server.Close()
+}
+
+func (s *ServerTestSuite) newUser() *models.User {
+	key, err := crypto.GenerateKey()
+	s.Assertions.Nil(err)
+
+	user := &models.User{
+		Mail:    "user@example.com",
+		Private: hex.EncodeToString(crypto.FromECDSA(key)),
+		Address: crypto.PubkeyToAddress(key.PublicKey).Hex(),
+	}
+
+	user.StarkKey, err = s.client.CreateUser(context.Background(), user)
+	s.Assertions.Nil(err)
+	return user
+}
+
+func (s *ServerTestSuite) mint(tokenID string) {
+	err := s.client.CreateToken(context.Background(), &imx.MintInformation{ContractAddress: collectionAddress, TokenID: tokenID})
+	s.Assertions.Nil(err)
+}
+
+func (s *ServerTestSuite) requireStatus(err error, status int) {
+	var requestError *imx.RequestError
+	s.Require().ErrorAs(err, &requestError)
+	s.Assertions.Equal(status, requestError.StatusCode)
+}
+
+func (s *ServerTestSuite) TestNewIMXWithProjectOfAnotherOwnerShouldFail() {
+	settings := *s.settings
+	settings.ProjectID = s.server.AddProject("0x0000000000000000000000000000000000000002", "other")
+
+	_, err := imx.NewIMX(&settings)
+	s.Assertions.ErrorContains(err, "error getting imx project")
+}
+
+func (s *ServerTestSuite) TestAdminBootstrap() {
+	key, err := crypto.GenerateKey()
+	s.Assertions.Nil(err)
+	starkPrivateKey, err := stark.GenerateKey()
+	s.Assertions.Nil(err)
+
+	// a new platform, neither registered nor owning a project
+	settings := *s.settings
+	settings.L1SignerPrivateKey = hex.EncodeToString(crypto.FromECDSA(key))
+	settings.StarkPrivateKey = starkPrivateKey
+	settings.ProjectID = 0
+
+	_, err = imx.NewIMX(&settings)
+	s.Assertions.ErrorContains(err, "missing imx project id")
+
+	admin, err := imx.NewAdmin(&settings)
+	s.Require().Nil(err)
+	defer admin.Close()
+
+	signer, err := admin.RegisterSigner(context.Background(), "platform@example.com")
+	s.Assertions.Nil(err)
+	s.Assertions.Equal(normalize(crypto.PubkeyToAddress(key.PublicKey).Hex()), normalize(signer.Address))
+
+	again, err := admin.RegisterSigner(context.Background(), "platform@example.com")
+	s.Assertions.Nil(err)
+	s.Assertions.Equal(signer, again)
+
+	projectID, err := admin.CreateProject(context.Background(), &imx.ProjectInformation{ProjectName: "nft", CompanyName: "company", ContactEmail: "platform@example.com"})
+	s.Assertions.Nil(err)
+
+	info := &imx.CollectionInformation{ProjectID: projectID, ContractAddress: "0xC0FFEE0000000000000000000000000000000003", CollectionName: "bootstrap"}
+	s.Assertions.Nil(admin.CreateCollection(context.Background(), info))
+	s.Assertions.Nil(admin.CreateMetadata(context.Background(), &imx.MetadataInformation{
+		ContractAddress: info.ContractAddress,
+		Fields:          []imx.MetadataFieldInformation{{Name: "name", Type: "text"}},
+	}))
+
+	settings.ProjectID = projectID
+	client, err := imx.NewIMX(&settings)
+	s.Require().Nil(err)
+	client.Close()
+}
+
+func (s *ServerTestSuite) TestRegisterSignerWithAnotherStarkKeyShouldFail() {
+	starkPrivateKey, err := stark.GenerateKey()
+	s.Assertions.Nil(err)
+
+	settings := *s.settings
+	settings.StarkPrivateKey = starkPrivateKey
+	admin, err := imx.NewAdmin(&settings)
+	s.Require().Nil(err)
+	defer admin.Close()
+
+	_, err = admin.RegisterSigner(context.Background(), "platform@example.com")
+	s.Assertions.ErrorIs(err, imx.ErrConflict)
+}
+
+func (s *ServerTestSuite) TestCreateUser() {
+	user := s.newUser()
+
+	signer, err := stark.NewSigner(user.StarkKey)
+	s.Assertions.Nil(err)
+	s.imx.mu.Lock()
+	registered := s.imx.users[normalize(user.Address)]
+	s.imx.mu.Unlock()
+	s.Assertions.True(sameKey(signer.GetPublicKey(), registered))
+}
+
+func (s *ServerTestSuite) TestCreateCollectionTwiceShouldFail() {
+	err := s.client.CreateCollection(context.Background(), &imx.CollectionInformation{ContractAddress: collectionAddress, CollectionName: "collection"})
+	s.requireStatus(err, http.StatusConflict)
+	s.Assertions.ErrorIs(err, imx.ErrConflict)
+}
+
+func (s *ServerTestSuite) TestCreateMetadata() {
+	info := &imx.MetadataInformation{
+		ContractAddress: collectionAddress,
+		Fields:          []imx.MetadataFieldInformation{{Name: "name", Type: "text"}},
+	}
+	s.Assertions.Nil(s.client.CreateMetadata(context.Background(), info))
+
+	info.Fields = []imx.MetadataFieldInformation{{Name: "level", Type: "number"}}
+	s.requireStatus(s.client.CreateMetadata(context.Background(), info), http.StatusBadRequest)
+}
+
+func (s *ServerTestSuite) TestMintAndTransfer() {
+	s.mint("1")
+	owner, _ := s.imx.Owner(collectionAddress, "1")
+	s.Assertions.Equal(normalize(s.platform), owner)
+
+	asset, err := s.client.GetAsset(context.Background(), collectionAddress, "1")
+	s.Assertions.Nil(err)
+	s.Assertions.Equal(normalize(s.platform), asset.User)
+
+	_, err = s.client.GetAsset(context.Background(), collectionAddress, "2")
+	s.Assertions.ErrorIs(err, imx.ErrNotFound)
+
+	err = s.client.CreateToken(context.Background(), &imx.MintInformation{ContractAddress: collectionAddress, TokenID: "1"})
+	s.requireStatus(err, http.StatusConflict)
+
+	user := s.newUser()
+	err = s.client.TransferToken(context.Background(), &imx.TransferInformation{ContractAddress: collectionAddress, TokenID: "1", ReceiverAddress: user.Address})
+	s.Assertions.Nil(err)
+	owner, _ = s.imx.Owner(collectionAddress, "1")
+	s.Assertions.Equal(normalize(user.Address), owner)
+
+	err = s.client.TransferToken(context.Background(), &imx.TransferInformation{ContractAddress: collectionAddress, TokenID: "1", ReceiverAddress: user.Address})
+	s.requireStatus(err, http.StatusBadRequest)
+}
+
+func (s *ServerTestSuite) TestOrderAndTrade() {
+	s.mint("1")
+	orderID, err := s.client.CreateOrder(context.Background(), &imx.OrderInformation{ContractAddress: collectionAddress, TokenID: "1", Amount: 100})
+	s.Assertions.Nil(err)
+
+	orders, err := s.client.ListOrders(context.Background(), collectionAddress)
+	s.Assertions.Nil(err)
+	s.Assertions.Equal([]imx.OrderSummary{{OrderID: orderID, TokenID: "1", Status: OrderStatusActive, Amount: "100"}}, orders)
+
+	user := s.newUser()
+	_, err = s.client.CreateTrade(context.Background(), &imx.CreateTradeInformation{User: user, OrderID: orderID})
+	s.requireStatus(err, http.StatusBadRequest)
+	s.Assertions.ErrorIs(err, imx.ErrInsufficientBalance)
+
+	s.imx.Fund(user.Address, big.NewInt(100))
+	tradeID, err := s.client.CreateTrade(context.Background(), &imx.CreateTradeInformation{User: user, OrderID: orderID})
+	s.Assertions.Nil(err)
+	s.Assertions.Equal(int32(1), tradeID)
+
+	owner, _ := s.imx.Owner(collectionAddress, "1")
+	s.Assertions.Equal(normalize(user.Address), owner)
+	s.Assertions.Equal(big.NewInt(100), s.imx.Balance(s.platform))
+
+	_, err = s.client.CreateTrade(context.Background(), &imx.CreateTradeInformation{User: user, OrderID: orderID + 1})
+	s.requireStatus(err, http.StatusNotFound)
+	s.Assertions.ErrorIs(err, imx.ErrNotFound)
+}
+
+func (s *ServerTestSuite) TestExternalTrade() {
+	s.mint("1")
+	orderID, err := s.client.CreateOrder(context.Background(), &imx.OrderInformation{ContractAddress: collectionAddress, TokenID: "1", Amount: 100})
+	s.Assertions.Nil(err)
+
+	user := s.newUser()
+	s.imx.Fund(user.Address, big.NewInt(100))
+	info := &imx.CreateTradeInformation{User: &models.User{Address: user.Address, External: true}, OrderID: orderID}
+
+	// the wallet signs with its own keys, the platform never sees them
+	signable, err := s.client.GetSignableTrade(context.Background(), info)
+	s.Assertions.Nil(err)
+
+	key, err := crypto.HexToECDSA(user.Private)
+	s.Assertions.Nil(err)
+	ethSignature, err := crypto.Sign(accounts.TextHash([]byte(signable.SignableMessage)), key)
+	s.Assertions.Nil(err)
+	starkSigner, err := stark.NewSigner(user.StarkKey)
+	s.Assertions.Nil(err)
+	starkSignature, err := starkSigner.SignMessage(signable.PayloadHash)
+	s.Assertions.Nil(err)
+
+	submit := &imx.SubmitTradeInformation{
+		User:           info.User,
+		OrderID:        orderID,
+		Payload:        signable.Payload,
+		EthSignature:   hexutil.Encode(ethSignature),
+		StarkSignature: starkSignature,
+	}
+	tradeID, err := s.client.SubmitTrade(context.Background(), submit)
+	s.Assertions.Nil(err)
+	s.Assertions.Equal(int32(1), tradeID)
+	s.Assertions.Equal(0, s.imx.Balance(user.Address).Sign())
+
+	// a signable operation can only be submitted once
+	_, err = s.client.SubmitTrade(context.Background(), submit)
+	s.requireStatus(err, http.StatusUnauthorized)
+}
+
+func (s *ServerTestSuite) TestExternalWithdrawalWithInvalidSignatureShouldFail() {
+	user := s.newUser()
+	s.imx.Fund(user.Address, big.NewInt(10))
+	info := &imx.CreateWithdrawalInformation{User: &models.User{Address: user.Address, External: true}, AmountWei: "10"}
+
+	signable, err := s.client.GetSignableWithdrawal(context.Background(), info)
+	s.Assertions.Nil(err)
+
+	// signed by the platform instead of the user
+	starkSigner, err := stark.NewSigner(s.settings.StarkPrivateKey)
+	s.Assertions.Nil(err)
+	starkSignature, err := starkSigner.SignMessage(signable.PayloadHash)
+	s.Assertions.Nil(err)
+	key, err := crypto.HexToECDSA(user.Private)
+	s.Assertions.Nil(err)
+	ethSignature, err := crypto.Sign(accounts.TextHash([]byte(signable.SignableMessage)), key)
+	s.Assertions.Nil(err)
+
+	_, err = s.client.SubmitWithdrawal(context.Background(), &imx.SubmitWithdrawalInformation{
+		User:           info.User,
+		Payload:        signable.Payload,
+		EthSignature:   hexutil.Encode(ethSignature),
+		StarkSignature: starkSignature,
+	})
+	s.requireStatus(err, http.StatusUnauthorized)
+	s.Assertions.ErrorIs(err, imx.ErrInvalidSignature)
+	s.Assertions.Equal(big.NewInt(10), s.imx.Balance(user.Address))
+}
+
+func (s *ServerTestSuite) TestWithdrawal() {
+	s.imx.SetConfirmationDelay(time.Hour)
+	user := s.newUser()
+	s.imx.Fund(user.Address, big.NewInt(10))
+
+	withdrawalID, err := s.client.CreateEthWithdrawal(context.Background(), &imx.CreateWithdrawalInformation{User: user, AmountWei: "10"})
+	s.Assertions.Nil(err)
+	s.Assertions.Equal(0, s.imx.Balance(user.Address).Sign())
+
+	var notReady imx.WithdrawalNotReadyError
+	err = s.client.CompleteEthWithdrawal(context.Background(), &imx.CompleteWithdrawalInformation{User: user, WithdrawalID: withdrawalID})
+	s.Assertions.ErrorAs(err, &notReady)
+	s.Assertions.Equal(RollupStatusIncluded, notReady.CurrentStatus)
+
+	err = s.client.CompleteEthWithdrawal(context.Background(), &imx.CompleteWithdrawalInformation{User: user, WithdrawalID: withdrawalID + 1})
+	s.requireStatus(err, http.StatusNotFound)
+}
+
+func (s *ServerTestSuite) TestCompletedWithdrawalIsNotCompletedAgain() {
+	user := s.newUser()
+	s.imx.Fund(user.Address, big.NewInt(10))
+
+	withdrawalID, err := s.client.CreateEthWithdrawal(context.Background(), &imx.CreateWithdrawalInformation{User: user, AmountWei: "10"})
+	s.Assertions.Nil(err)
+	err = s.imx.CompleteEthWithdrawal(context.Background(), &imx.CompleteWithdrawalInformation{User: user, WithdrawalID: withdrawalID})
+	s.Assertions.Nil(err)
+
+	err = s.client.CompleteEthWithdrawal(context.Background(), &imx.CompleteWithdrawalInformation{User: user, WithdrawalID: withdrawalID})
+	s.Assertions.ErrorIs(err, imx.ErrConflict)
+}
+
+func (s *ServerTestSuite) TestDepositIsNotConfirmed() {
+	var notConfirmed imx.DepositNotConfirmedError
+	err := s.client.ConfirmEthDeposit(context.Background(), randomHex())
+	s.Assertions.ErrorAs(err, &notConfirmed)
+}
+
+func (s *ServerTestSuite) TestListAssetsFollowsCursor() {
+	for i := 0; i < 250; i++ {
+		err := s.imx.CreateToken(context.Background(), &imx.MintInformation{ContractAddress: collectionAddress, TokenID: strconv.Itoa(i)})
+		s.Assertions.Nil(err)
+	}
+
+	assets, err := s.client.ListAssets(context.Background(), collectionAddress)
+	s.Assertions.Nil(err)
+	s.Assertions.Len(assets, 250)
+	s.Assertions.Equal(AssetStatusIMX, assets[0].Status)
+	s.Assertions.Equal(normalize(s.platform), assets[0].User)
+}
+
+func (s *ServerTestSuite) TestUnreachableServerIsUnavailable() {
+	s.server.Close()
+
+	err := s.client.CreateToken(context.Background(), &imx.MintInformation{ContractAddress: collectionAddress, TokenID: "1"})
+	s.Assertions.ErrorIs(err, imx.ErrUnavailable)
+}
+
+func (s *ServerTestSuite) TestPing() {
+	s.Assertions.Nil(s.client.Ping(context.Background()))
+
+	s.server.Close()
+	s.Assertions.ErrorIs(s.client.Ping(context.Background()), imx.ErrUnavailable)
+}
+
+func TestServerTestSuite(t *testing.T) {
+	suite.Run(t, new(ServerTestSuite))
+}
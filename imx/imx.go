@@ -2,20 +2,26 @@ package imx
 
 import (
 	"context"
-	"encoding/json"
-	"log"
+	"errors"
+	"fmt"
 	"math/big"
+	"net/http"
 	"nft/models"
 	"strconv"
 
+	goethereum "github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/immutable/imx-core-sdk-golang/imx"
 	"github.com/immutable/imx-core-sdk-golang/imx/api"
 	"github.com/immutable/imx-core-sdk-golang/imx/signers/ethereum"
 	"github.com/immutable/imx-core-sdk-golang/imx/signers/stark"
+	"golang.org/x/exp/slog"
 )
 
 type Client interface {
 	Close()
+	Address() string
 	CreateUser(ctx context.Context, user *models.User) (string, error)
 	CreateCollection(ctx context.Context, info *CollectionInformation) error
 	CreateMetadata(ctx context.Context, info *MetadataInformation) error
@@ -23,9 +29,18 @@ type Client interface {
 	TransferToken(ctx context.Context, info *TransferInformation) error
 	CreateOrder(ctx context.Context, info *OrderInformation) (int32, error)
 	CreateEthDeposit(ctx context.Context, info *CreateDepositInformation) (string, error)
+	ConfirmEthDeposit(ctx context.Context, transactionHash string) error
 	CreateTrade(ctx context.Context, info *CreateTradeInformation) (int32, error)
 	CreateEthWithdrawal(ctx context.Context, info *CreateWithdrawalInformation) (int32, error)
 	CompleteEthWithdrawal(ctx context.Context, info *CompleteWithdrawalInformation) error
+	GetSignableTrade(ctx context.Context, info *CreateTradeInformation) (*SignableInformation, error)
+	SubmitTrade(ctx context.Context, info *SubmitTradeInformation) (int32, error)
+	GetSignableWithdrawal(ctx context.Context, info *CreateWithdrawalInformation) (*SignableInformation, error)
+	SubmitWithdrawal(ctx context.Context, info *SubmitWithdrawalInformation) (int32, error)
+	GetAsset(ctx context.Context, contractAddress string, tokenID string) (*AssetInformation, error)
+	ListAssets(ctx context.Context, contractAddress string) ([]AssetInformation, error)
+	ListOrders(ctx context.Context, contractAddress string) ([]OrderSummary, error)
+	Ping(ctx context.Context) error
 }
 
 type IMX struct {
@@ -98,29 +113,60 @@ type TransferInformation struct {
 	ReceiverAddress string
 }
 
-func NewIMX(alchemyAPIKey string, l1SignerPrivateKey string, starkPrivateKey string, projectID int32) (Client, error) {
+func NewIMX(settings *Settings) (Client, error) {
+	if err := settings.Validate(); err != nil {
+		return nil, err
+	}
+
+	i, err := newIMX(settings)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = i.verify(context.Background()); err != nil {
+		i.Close()
+		return nil, err
+	}
+
+	return i, nil
+}
+
+// newIMX creates the client from validated settings, without checking it can reach IMX.
+func newIMX(settings *Settings) (*IMX, error) {
+	environment, err := settings.ResolveEnvironment()
+	if err != nil {
+		return nil, err
+	}
+
+	// the sdk appends the key to the rpc url, custom rpc urls already include any credentials
+	alchemyAPIKey := settings.AlchemyAPIKey
+	if len(settings.L1RPCURL) > 0 {
+		alchemyAPIKey = ""
+	}
+
 	apiConfiguration := api.NewConfiguration()
+	apiConfiguration.HTTPClient = &http.Client{Transport: unavailableTransport{http.DefaultTransport}}
 	cfg := imx.Config{
 		APIConfig:     apiConfiguration,
 		AlchemyAPIKey: alchemyAPIKey,
-		Environment:   imx.Sandbox,
+		Environment:   environment,
 	}
 	client, err := imx.NewClient(&cfg)
 	if err != nil {
 		return nil, err
 	}
 
-	l1signer, err := ethereum.NewSigner(l1SignerPrivateKey, cfg.ChainID)
+	l1signer, err := ethereum.NewSigner(settings.L1SignerPrivateKey, cfg.ChainID)
 	if err != nil {
 		return nil, err
 	}
 
-	l2signer, _, err := newStarkSigner(starkPrivateKey)
+	l2signer, _, err := newStarkSigner(settings.StarkPrivateKey)
 	if err != nil {
 		return nil, err
 	}
 
-	return &IMX{client, l1signer, l2signer, cfg.ChainID, projectID}, nil
+	return &IMX{client, l1signer, l2signer, cfg.ChainID, settings.ProjectID}, nil
 }
 
 func (i *IMX) CreateUser(ctx context.Context, user *models.User) (string, error) {
@@ -136,21 +182,17 @@ func (i *IMX) CreateUser(ctx context.Context, user *models.User) (string, error)
 
 	response, err := i.client.RegisterOffchain(ctx, l1signer, l2signer, user.Mail)
 	if err != nil {
-		return "", err
+		return "", classifyError(err)
 	}
 
-	val, err := prettyStruct(response)
-	if err != nil {
-		return "", err
-	}
-	log.Println("RegisterOffchain response: ", val)
+	slog.InfoContext(ctx, "user registered", "address", l1signer.GetAddress(), "tx_hash", response.TxHash)
 
 	// Get the accounts registered on offchain.
 	usersResponse, err := i.client.GetUsers(ctx, l1signer.GetAddress())
 	if err != nil {
-		return "", err
+		return "", classifyError(err)
 	}
-	log.Println("Registered accounts: ", usersResponse.GetAccounts())
+	slog.DebugContext(ctx, "registered accounts", "accounts", usersResponse.GetAccounts())
 	return starkKey, nil
 }
 
@@ -158,6 +200,34 @@ func (i *IMX) Close() {
 	i.client.EthClient.Close()
 }
 
+// Address returns the address of the platform signer, which mints, transfers and sells assets.
+func (i *IMX) Address() string {
+	return i.l1signer.GetAddress()
+}
+
+// errExternalUser fails the operations signed by the platform for users holding their own keys.
+var errExternalUser = errors.New("external users sign their requests with their own wallet")
+
+// userSigners returns the signers of a user the platform holds the keys of. The stark key of external users
+// is their public key, they sign through the signature requests.
+func (i *IMX) userSigners(user *models.User) (imx.L1Signer, imx.L2Signer, error) {
+	if user.External {
+		return nil, nil, errExternalUser
+	}
+
+	l1signer, err := ethereum.NewSigner(user.Private, i.chainId)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	l2signer, _, err := newStarkSigner(user.StarkKey)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return l1signer, l2signer, nil
+}
+
 func newStarkSigner(privateStarkKeyStr string) (imx.L2Signer, string, error) {
 	var err error
 	if privateStarkKeyStr == "" {
@@ -174,43 +244,28 @@ func newStarkSigner(privateStarkKeyStr string) (imx.L2Signer, string, error) {
 	return l2signer, privateStarkKeyStr, nil
 }
 
-func prettyStruct(data interface{}) (string, error) {
-	val, err := json.MarshalIndent(data, "", "    ")
-	if err != nil {
-		return "", err
-	}
-	return string(val), nil
-}
-
 func (i *IMX) CreateProject(ctx context.Context, info *ProjectInformation) (int32, error) {
 	response, err := i.client.CreateProject(ctx, i.l1signer, info.ProjectName, info.CompanyName, info.ContactEmail)
 	if err != nil {
-		return -1, err
+		return -1, classifyError(err)
 	}
 
-	val, err := prettyStruct(response)
-	if err != nil {
-		return -1, err
-	}
-
-	log.Println("Created new project, response: ", val)
+	slog.InfoContext(ctx, "project created", "project_id", response.Id)
 
 	// Get the project details we just created.
 	projectResponse, err := i.client.GetProject(ctx, i.l1signer, strconv.FormatInt(int64(response.Id), 10))
 	if err != nil {
-		return -1, err
+		return -1, classifyError(err)
 	}
-	val, err = prettyStruct(projectResponse)
-	if err != nil {
-		return -1, err
-	}
-
-	log.Println("Project details: ", val)
+	slog.DebugContext(ctx, "project details", "project_id", projectResponse.Id, "name", projectResponse.Name,
+		"collection_remaining", projectResponse.CollectionRemaining, "mint_remaining", projectResponse.MintRemaining)
 	return response.Id, nil
 }
 
 func (i *IMX) CreateCollection(ctx context.Context, info *CollectionInformation) error {
-	info.ProjectID = i.projectID
+	if info.ProjectID == 0 {
+		info.ProjectID = i.projectID
+	}
 	info.PublicKey = i.l1signer.GetPublicKey()
 
 	createCollectionRequest := api.NewCreateCollectionRequest(info.ContractAddress,
@@ -222,21 +277,17 @@ func (i *IMX) CreateCollection(ctx context.Context, info *CollectionInformation)
 
 	response, err := i.client.CreateCollection(ctx, i.l1signer, createCollectionRequest)
 	if err != nil {
-		return err
+		return classifyError(err)
 	}
 
-	val, err := prettyStruct(response)
-	if err != nil {
-		return err
-	}
-	log.Println("Created new collection, response: ", val)
+	slog.InfoContext(ctx, "collection created", "contract_address", response.Address, "name", response.Name)
 
 	// Get the collection details we just created.
 	collectionReponse, err := i.client.GetCollection(ctx, info.ContractAddress)
 	if err != nil {
-		return err
+		return classifyError(err)
 	}
-	log.Println("Created Collection Name: ", collectionReponse.Name)
+	slog.DebugContext(ctx, "collection details", "contract_address", collectionReponse.Address, "name", collectionReponse.Name)
 
 	return nil
 }
@@ -252,14 +303,10 @@ func (i *IMX) CreateMetadata(ctx context.Context, info *MetadataInformation) err
 	request := api.NewAddMetadataSchemaToCollectionRequest(metadata)
 	response, err := i.client.AddMetadataSchemaToCollection(ctx, i.l1signer, info.ContractAddress, *request)
 	if err != nil {
-		return err
+		return classifyError(err)
 	}
 
-	val, err := prettyStruct(response)
-	if err != nil {
-		return err
-	}
-	log.Println("Created new metadata, response: ", val)
+	slog.InfoContext(ctx, "metadata schema added", "contract_address", info.ContractAddress, "result", response.Result)
 	return nil
 }
 
@@ -303,10 +350,11 @@ func (i *IMX) CreateToken(ctx context.Context, info *MintInformation) error {
 
 	mintTokensResponse, err := i.client.Mint(ctx, i.l1signer, request)
 	if err != nil {
-		return err
+		return classifyError(err)
 	}
 
-	log.Printf("Mint Tokens response:\n%v\n", mintTokensResponse.Results[0].TxId)
+	slog.InfoContext(ctx, "token minted", "contract_address", info.ContractAddress, "token_id", info.TokenID,
+		"tx_id", mintTokensResponse.Results[0].TxId)
 	return nil
 }
 
@@ -328,14 +376,11 @@ func (i *IMX) TransferToken(ctx context.Context, info *TransferInformation) erro
 
 	response, err := i.client.BatchNftTransfer(ctx, i.l1signer, i.l2signer, batchTransferRequest)
 	if err != nil {
-		return err
+		return classifyError(err)
 	}
 
-	val, err := prettyStruct(response)
-	if err != nil {
-		return err
-	}
-	log.Println("Created new metadata, response: ", val)
+	slog.InfoContext(ctx, "token transferred", "contract_address", info.ContractAddress, "token_id", info.TokenID,
+		"transfer_ids", response.TransferIds)
 	return nil
 }
 
@@ -356,14 +401,10 @@ func (i *IMX) CreateOrder(ctx context.Context, info *OrderInformation) (int32, e
 	// Create order will list the given asset for sale.
 	createOrderResponse, err := i.client.CreateOrder(ctx, i.l1signer, i.l2signer, createOrderRequest)
 	if err != nil {
-		return -1, err
+		return -1, classifyError(err)
 	}
 
-	createOrderResponseStr, err := prettyStruct(createOrderResponse)
-	if err != nil {
-		return -1, err
-	}
-	log.Printf("CreateOrder response:\n%v\n", createOrderResponseStr)
+	slog.InfoContext(ctx, "order created", "order_id", createOrderResponse.OrderId, "status", createOrderResponse.Status)
 	return createOrderResponse.OrderId, nil
 }
 
@@ -374,6 +415,10 @@ func (i *IMX) CreateEthDeposit(ctx context.Context, info *CreateDepositInformati
 		return "", err
 	}
 
+	if info.User.External {
+		return "", errExternalUser
+	}
+
 	l1signer, err := ethereum.NewSigner(info.User.Private, i.chainId)
 	if err != nil {
 		return "", err
@@ -381,19 +426,14 @@ func (i *IMX) CreateEthDeposit(ctx context.Context, info *CreateDepositInformati
 
 	transaction, err := imx.NewETHDeposit(ethAmountInWei).Deposit(ctx, i.client, l1signer, nil)
 	if err != nil {
-		return "", err
+		return "", classifyError(err)
 	}
-	log.Println("Eth Deposit transaction hash:", transaction.Hash())
+	slog.InfoContext(ctx, "deposit sent", "tx_hash", transaction.Hash().String())
 	return transaction.Hash().String(), nil
 }
 
 func (i *IMX) CreateTrade(ctx context.Context, info *CreateTradeInformation) (int32, error) {
-	l1signer, err := ethereum.NewSigner(info.User.Private, i.chainId)
-	if err != nil {
-		return -1, err
-	}
-
-	l2signer, _, err := newStarkSigner(info.User.StarkKey)
+	l1signer, l2signer, err := i.userSigners(info.User)
 	if err != nil {
 		return -1, err
 	}
@@ -407,15 +447,10 @@ func (i *IMX) CreateTrade(ctx context.Context, info *CreateTradeInformation) (in
 	tradeResponse, err := i.client.CreateTrade(ctx, l1signer, l2signer, tradeRequest)
 
 	if err != nil {
-		return -1, err
-	}
-
-	val, err := prettyStruct(tradeResponse)
-	if err != nil {
-		return -1, err
+		return -1, classifyError(err)
 	}
 
-	log.Printf("trade response:\n%s\n", val)
+	slog.InfoContext(ctx, "trade created", "trade_id", tradeResponse.TradeId, "status", tradeResponse.Status)
 	return tradeResponse.TradeId, nil
 }
 
@@ -425,12 +460,7 @@ func (i *IMX) CreateEthWithdrawal(ctx context.Context, info *CreateWithdrawalInf
 		return -1, err
 	}
 
-	l1signer, err := ethereum.NewSigner(info.User.Private, i.chainId)
-	if err != nil {
-		return -1, err
-	}
-
-	l2signer, _, err := newStarkSigner(info.User.StarkKey)
+	l1signer, l2signer, err := i.userSigners(info.User)
 	if err != nil {
 		return -1, err
 	}
@@ -442,31 +472,46 @@ func (i *IMX) CreateEthWithdrawal(ctx context.Context, info *CreateWithdrawalInf
 
 	response, err := i.client.PrepareWithdrawal(ctx, l1signer, l2signer, withdrawalRequest)
 	if err != nil {
-		return -1, err
+		return -1, classifyError(err)
 	}
-	val, _ := json.MarshalIndent(response, "", "  ")
-	log.Printf("response:\n%s\n", val)
+	slog.InfoContext(ctx, "withdrawal created", "withdrawal_id", response.WithdrawalId, "status", response.Status)
 	return response.WithdrawalId, nil
 }
 
+// ConfirmEthDeposit checks the deposit transaction was mined on L1. It returns a DepositNotConfirmedError
+// while the transaction is still pending.
+func (i *IMX) ConfirmEthDeposit(ctx context.Context, transactionHash string) error {
+	receipt, err := i.client.EthClient.TransactionReceipt(ctx, common.HexToHash(transactionHash))
+	if err != nil {
+		if errors.Is(err, goethereum.NotFound) {
+			return NewDepositNotConfirmedError(transactionHash)
+		}
+		return classifyError(err)
+	}
+
+	if receipt.Status != types.ReceiptStatusSuccessful {
+		return fmt.Errorf("deposit transaction %s failed", transactionHash)
+	}
+
+	return nil
+}
+
 func (i *IMX) CompleteEthWithdrawal(ctx context.Context, info *CompleteWithdrawalInformation) error {
 	getWithdrawalResponse, err := i.client.GetWithdrawal(ctx, strconv.FormatInt(int64(info.WithdrawalID), 10))
 	if err != nil {
-		return err
+		return classifyError(err)
 	}
-	val, _ := json.MarshalIndent(getWithdrawalResponse, "", "  ")
-	log.Printf("response:\n%s\n", val)
+	slog.DebugContext(ctx, "withdrawal status", "withdrawal_id", info.WithdrawalID, "rollup_status", getWithdrawalResponse.RollupStatus)
 
-	if getWithdrawalResponse.RollupStatus != "confirmed" {
-		return NewWithdrawalNotReadyError(getWithdrawalResponse.RollupStatus)
+	if getWithdrawalResponse.WithdrawnToWallet {
+		return fmt.Errorf("%w: withdrawal %d already completed", ErrConflict, info.WithdrawalID)
 	}
 
-	l1signer, err := ethereum.NewSigner(info.User.Private, i.chainId)
-	if err != nil {
-		return err
+	if getWithdrawalResponse.RollupStatus != "confirmed" {
+		return NewWithdrawalNotReadyError(getWithdrawalResponse.RollupStatus)
 	}
 
-	l2signer, _, err := newStarkSigner(info.User.StarkKey)
+	l1signer, l2signer, err := i.userSigners(info.User)
 	if err != nil {
 		return err
 	}
@@ -474,9 +519,9 @@ func (i *IMX) CompleteEthWithdrawal(ctx context.Context, info *CompleteWithdrawa
 	ethWithdrawal := imx.NewEthWithdrawal()
 	transaction, err := ethWithdrawal.CompleteWithdrawal(ctx, i.client, l1signer, l2signer.GetPublicKey(), nil)
 	if err != nil {
-		return err
+		return classifyError(err)
 	}
-	log.Println("transaction hash:", transaction.Hash())
+	slog.InfoContext(ctx, "withdrawal completed", "withdrawal_id", info.WithdrawalID, "tx_hash", transaction.Hash().String())
 	return nil
 }
 
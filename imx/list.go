@@ -0,0 +1,95 @@
+package imx
+
+import "context"
+
+const listPageSize = 200
+
+// AssetInformation is an ERC721 asset of a collection as seen by IMX.
+type AssetInformation struct {
+	TokenID string
+	Status  string
+	User    string
+}
+
+// OrderSummary is a sell order of a collection asset as seen by IMX.
+type OrderSummary struct {
+	OrderID int32
+	TokenID string
+	Status  string
+	Amount  string
+}
+
+// GetAsset returns an asset of the collection, or ErrNotFound if it is not minted.
+func (i *IMX) GetAsset(ctx context.Context, contractAddress string, tokenID string) (*AssetInformation, error) {
+	asset, httpResponse, err := i.client.AssetsAPI.GetAsset(ctx, contractAddress, tokenID).Execute()
+	if err != nil {
+		return nil, newRequestError(httpResponse, err)
+	}
+
+	return &AssetInformation{TokenID: asset.TokenId, Status: asset.Status, User: asset.User}, nil
+}
+
+// ListAssets returns every asset of the collection, following the IMX cursor through all pages.
+func (i *IMX) ListAssets(ctx context.Context, contractAddress string) ([]AssetInformation, error) {
+	assets := make([]AssetInformation, 0)
+	cursor := ""
+	for {
+		request := i.client.AssetsAPI.ListAssets(ctx).Collection(contractAddress).PageSize(listPageSize)
+		if len(cursor) > 0 {
+			request = request.Cursor(cursor)
+		}
+
+		response, httpResponse, err := request.Execute()
+		if err != nil {
+			return nil, newRequestError(httpResponse, err)
+		}
+
+		for _, asset := range response.Result {
+			assets = append(assets, AssetInformation{
+				TokenID: asset.TokenId,
+				Status:  asset.Status,
+				User:    asset.User,
+			})
+		}
+
+		if response.Remaining == 0 || len(response.Cursor) == 0 {
+			return assets, nil
+		}
+		cursor = response.Cursor
+	}
+}
+
+// ListOrders returns every order selling an asset of the collection, following the IMX cursor
+// through all pages.
+func (i *IMX) ListOrders(ctx context.Context, contractAddress string) ([]OrderSummary, error) {
+	orders := make([]OrderSummary, 0)
+	cursor := ""
+	for {
+		request := i.client.OrdersAPI.ListOrders(ctx).SellTokenAddress(contractAddress).PageSize(listPageSize)
+		if len(cursor) > 0 {
+			request = request.Cursor(cursor)
+		}
+
+		response, httpResponse, err := request.Execute()
+		if err != nil {
+			return nil, newRequestError(httpResponse, err)
+		}
+
+		for _, order := range response.Result {
+			summary := OrderSummary{
+				OrderID: order.OrderId,
+				Status:  order.Status,
+				Amount:  order.Buy.Data.Quantity,
+			}
+			if order.Sell.Data.TokenId != nil {
+				summary.TokenID = *order.Sell.Data.TokenId
+			}
+			orders = append(orders, summary)
+		}
+
+		if response.Remaining == 0 || len(response.Cursor) == 0 {
+			return orders, nil
+		}
+		cursor = response.Cursor
+	}
+}
@@ -0,0 +1,433 @@
+package imx
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net"
+	"net/http"
+	"nft/internal/operation"
+	"nft/models"
+	"sync"
+	"time"
+
+	"github.com/immutable/imx-core-sdk-golang/imx/api"
+)
+
+// ErrCircuitOpen fails the calls made while the circuit breaker is open. It is an ErrUnavailable.
+var ErrCircuitOpen = fmt.Errorf("%w: circuit breaker open", ErrUnavailable)
+
+// Policy configures Resilient. Timeout bounds each attempt of an operation, unless Timeouts has one for the
+// operation name. Reads are retried up to Retries times when IMX is unavailable or rate limits, waiting a
+// random time up to Backoff doubled on every attempt and capped to MaxBackoff. BreakerThreshold consecutive
+// unavailable failures open the breaker for BreakerCooldown, zero disables it.
+type Policy struct {
+	Timeout          time.Duration
+	Timeouts         map[string]time.Duration
+	Retries          int
+	Backoff          time.Duration
+	MaxBackoff       time.Duration
+	BreakerThreshold int
+	BreakerCooldown  time.Duration
+}
+
+// DefaultPolicy gives the operations sending L1 transactions longer, as they wait on the L1 rpc.
+func DefaultPolicy() Policy {
+	return Policy{
+		Timeout: 30 * time.Second,
+		Timeouts: map[string]time.Duration{
+			"CreateEthDeposit":      2 * time.Minute,
+			"CompleteEthWithdrawal": 2 * time.Minute,
+		},
+		Retries:          2,
+		Backoff:          200 * time.Millisecond,
+		MaxBackoff:       5 * time.Second,
+		BreakerThreshold: 5,
+		BreakerCooldown:  30 * time.Second,
+	}
+}
+
+// operationKind tells how a failed operation may be tried again.
+type operationKind int
+
+const (
+	// opWrite changes IMX, it is not retried and may have been applied when it times out.
+	opWrite operationKind = iota
+	// opRead is retried on transient failures.
+	opRead
+	// opProbe only checks IMX, it is not retried.
+	opProbe
+)
+
+// Resilient is a Client that applies a Policy to the calls of another.
+type Resilient struct {
+	client Client
+	policy Policy
+	now    func() time.Time
+	sleep  func(ctx context.Context, d time.Duration) error
+
+	mu        sync.Mutex
+	failures  int
+	openUntil time.Time
+	probing   bool
+}
+
+var _ Client = (*Resilient)(nil)
+
+func NewResilient(client Client, policy Policy) *Resilient {
+	return &Resilient{client: client, policy: policy, now: time.Now, sleep: sleep}
+}
+
+func (r *Resilient) Close() {
+	r.client.Close()
+}
+
+func (r *Resilient) Address() string {
+	return r.client.Address()
+}
+
+func (r *Resilient) CreateUser(ctx context.Context, user *models.User) (string, error) {
+	var starkKey string
+	err := r.call(ctx, "CreateUser", opWrite, func(ctx context.Context) (err error) {
+		starkKey, err = r.client.CreateUser(ctx, user)
+		return err
+	})
+	return starkKey, err
+}
+
+func (r *Resilient) CreateCollection(ctx context.Context, info *CollectionInformation) error {
+	return r.call(ctx, "CreateCollection", opWrite, func(ctx context.Context) error {
+		return r.client.CreateCollection(ctx, info)
+	})
+}
+
+func (r *Resilient) CreateMetadata(ctx context.Context, info *MetadataInformation) error {
+	return r.call(ctx, "CreateMetadata", opWrite, func(ctx context.Context) error {
+		return r.client.CreateMetadata(ctx, info)
+	})
+}
+
+func (r *Resilient) CreateToken(ctx context.Context, info *MintInformation) error {
+	return r.call(ctx, "CreateToken", opWrite, func(ctx context.Context) error {
+		return r.client.CreateToken(ctx, info)
+	})
+}
+
+func (r *Resilient) TransferToken(ctx context.Context, info *TransferInformation) error {
+	return r.call(ctx, "TransferToken", opWrite, func(ctx context.Context) error {
+		return r.client.TransferToken(ctx, info)
+	})
+}
+
+func (r *Resilient) CreateOrder(ctx context.Context, info *OrderInformation) (int32, error) {
+	var orderID int32
+	err := r.call(ctx, "CreateOrder", opWrite, func(ctx context.Context) (err error) {
+		orderID, err = r.client.CreateOrder(ctx, info)
+		return err
+	})
+	return orderID, err
+}
+
+func (r *Resilient) CreateEthDeposit(ctx context.Context, info *CreateDepositInformation) (string, error) {
+	var hash string
+	err := r.call(ctx, "CreateEthDeposit", opWrite, func(ctx context.Context) (err error) {
+		hash, err = r.client.CreateEthDeposit(ctx, info)
+		return err
+	})
+	return hash, err
+}
+
+func (r *Resilient) ConfirmEthDeposit(ctx context.Context, transactionHash string) error {
+	return r.call(ctx, "ConfirmEthDeposit", opRead, func(ctx context.Context) error {
+		return r.client.ConfirmEthDeposit(ctx, transactionHash)
+	})
+}
+
+func (r *Resilient) CreateTrade(ctx context.Context, info *CreateTradeInformation) (int32, error) {
+	var tradeID int32
+	err := r.call(ctx, "CreateTrade", opWrite, func(ctx context.Context) (err error) {
+		tradeID, err = r.client.CreateTrade(ctx, info)
+		return err
+	})
+	return tradeID, err
+}
+
+func (r *Resilient) CreateEthWithdrawal(ctx context.Context, info *CreateWithdrawalInformation) (int32, error) {
+	var withdrawalID int32
+	err := r.call(ctx, "CreateEthWithdrawal", opWrite, func(ctx context.Context) (err error) {
+		withdrawalID, err = r.client.CreateEthWithdrawal(ctx, info)
+		return err
+	})
+	return withdrawalID, err
+}
+
+func (r *Resilient) CompleteEthWithdrawal(ctx context.Context, info *CompleteWithdrawalInformation) error {
+	return r.call(ctx, "CompleteEthWithdrawal", opWrite, func(ctx context.Context) error {
+		return r.client.CompleteEthWithdrawal(ctx, info)
+	})
+}
+
+// GetSignableTrade only prepares the trade, getting it again is harmless.
+func (r *Resilient) GetSignableTrade(ctx context.Context, info *CreateTradeInformation) (*SignableInformation, error) {
+	var signable *SignableInformation
+	err := r.call(ctx, "GetSignableTrade", opRead, func(ctx context.Context) (err error) {
+		signable, err = r.client.GetSignableTrade(ctx, info)
+		return err
+	})
+	return signable, err
+}
+
+func (r *Resilient) SubmitTrade(ctx context.Context, info *SubmitTradeInformation) (int32, error) {
+	var tradeID int32
+	err := r.call(ctx, "SubmitTrade", opWrite, func(ctx context.Context) (err error) {
+		tradeID, err = r.client.SubmitTrade(ctx, info)
+		return err
+	})
+	return tradeID, err
+}
+
+// GetSignableWithdrawal only prepares the withdrawal, getting it again is harmless.
+func (r *Resilient) GetSignableWithdrawal(ctx context.Context, info *CreateWithdrawalInformation) (*SignableInformation, error) {
+	var signable *SignableInformation
+	err := r.call(ctx, "GetSignableWithdrawal", opRead, func(ctx context.Context) (err error) {
+		signable, err = r.client.GetSignableWithdrawal(ctx, info)
+		return err
+	})
+	return signable, err
+}
+
+func (r *Resilient) SubmitWithdrawal(ctx context.Context, info *SubmitWithdrawalInformation) (int32, error) {
+	var withdrawalID int32
+	err := r.call(ctx, "SubmitWithdrawal", opWrite, func(ctx context.Context) (err error) {
+		withdrawalID, err = r.client.SubmitWithdrawal(ctx, info)
+		return err
+	})
+	return withdrawalID, err
+}
+
+func (r *Resilient) GetAsset(ctx context.Context, contractAddress string, tokenID string) (*AssetInformation, error) {
+	var asset *AssetInformation
+	err := r.call(ctx, "GetAsset", opRead, func(ctx context.Context) (err error) {
+		asset, err = r.client.GetAsset(ctx, contractAddress, tokenID)
+		return err
+	})
+	return asset, err
+}
+
+func (r *Resilient) ListAssets(ctx context.Context, contractAddress string) ([]AssetInformation, error) {
+	var assets []AssetInformation
+	err := r.call(ctx, "ListAssets", opRead, func(ctx context.Context) (err error) {
+		assets, err = r.client.ListAssets(ctx, contractAddress)
+		return err
+	})
+	return assets, err
+}
+
+func (r *Resilient) ListOrders(ctx context.Context, contractAddress string) ([]OrderSummary, error) {
+	var orders []OrderSummary
+	err := r.call(ctx, "ListOrders", opRead, func(ctx context.Context) (err error) {
+		orders, err = r.client.ListOrders(ctx, contractAddress)
+		return err
+	})
+	return orders, err
+}
+
+// Ping is not retried, the readiness probe reports the state of IMX as it is.
+func (r *Resilient) Ping(ctx context.Context) error {
+	return r.call(ctx, "Ping", opProbe, r.client.Ping)
+}
+
+// call runs the operation through the breaker with its timeout, retrying reads on transient failures.
+func (r *Resilient) call(ctx context.Context, operation string, kind operationKind, fn func(ctx context.Context) error) error {
+	retries := 0
+	if kind == opRead {
+		retries = r.policy.Retries
+	}
+
+	for attempt := 0; ; attempt++ {
+		probe, err := r.allow()
+		if err != nil {
+			return err
+		}
+
+		err = r.attempt(ctx, operation, kind, fn)
+		if ctx.Err() != nil {
+			// the caller gave up or timed out, which tells nothing about IMX
+			r.release(probe)
+			return err
+		}
+
+		r.record(probe, err)
+		if err == nil || attempt >= retries || !transient(err) {
+			return err
+		}
+
+		if err := r.sleep(ctx, r.backoff(attempt)); err != nil {
+			return err
+		}
+	}
+}
+
+// attempt runs the operation with its timeout. A write timing out may still be applied by IMX, it fails with
+// ErrUnknownOutcome rather than ErrUnavailable so it is not sent again as if it had failed.
+func (r *Resilient) attempt(ctx context.Context, operation string, kind operationKind, fn func(ctx context.Context) error) error {
+	timeout, ok := r.policy.Timeouts[operation]
+	if !ok {
+		timeout = r.policy.Timeout
+	}
+
+	attemptCtx := ctx
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		attemptCtx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	err := fn(attemptCtx)
+	if err == nil || ctx.Err() != nil || !errors.Is(attemptCtx.Err(), context.DeadlineExceeded) {
+		return err
+	}
+
+	message := fmt.Sprintf("%s timed out after %s", operation, timeout)
+	if kind == opWrite {
+		return &RequestError{Kind: ErrUnknownOutcome, Message: message, Err: err}
+	}
+	if !errors.Is(err, ErrUnavailable) {
+		return &RequestError{Kind: ErrUnavailable, Message: message, Err: err}
+	}
+	return err
+}
+
+// backoff returns a random wait up to Backoff doubled for each attempt, the full jitter spreads the retries
+// of concurrent callers.
+func (r *Resilient) backoff(attempt int) time.Duration {
+	ceiling := r.policy.Backoff << attempt
+	if ceiling <= 0 || (r.policy.MaxBackoff > 0 && ceiling > r.policy.MaxBackoff) {
+		ceiling = r.policy.MaxBackoff
+	}
+	if ceiling <= 0 {
+		return 0
+	}
+	return time.Duration(rand.Int63n(int64(ceiling)))
+}
+
+// allow fails fast while the breaker is open. Once the cooldown is over, a single call probes IMX and the
+// others keep failing until it succeeds. It returns whether the call is that probe.
+func (r *Resilient) allow() (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if !r.open() {
+		return false, nil
+	}
+
+	if r.probing || r.now().Before(r.openUntil) {
+		return false, ErrCircuitOpen
+	}
+
+	r.probing = true
+	return true, nil
+}
+
+// record counts the failures telling IMX is unavailable, a write timing out being one. Once the breaker is
+// open only the probe closes or opens it again, the calls still in flight from before it opened do not.
+func (r *Resilient) record(probe bool, err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.open() && !probe {
+		return
+	}
+
+	r.probing = false
+	if !errors.Is(err, ErrUnavailable) && !errors.Is(err, ErrUnknownOutcome) {
+		r.failures = 0
+		return
+	}
+
+	r.failures++
+	if r.open() {
+		r.openUntil = r.now().Add(r.policy.BreakerCooldown)
+	}
+}
+
+// release ends the probe of a call whose result is not recorded, so the next call probes IMX again.
+func (r *Resilient) release(probe bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if probe {
+		r.probing = false
+	}
+}
+
+// open tells whether enough consecutive failures opened the breaker, whether its cooldown is over or not.
+func (r *Resilient) open() bool {
+	return r.policy.BreakerThreshold > 0 && r.failures >= r.policy.BreakerThreshold
+}
+
+func transient(err error) bool {
+	return errors.Is(err, ErrUnavailable) || errors.Is(err, ErrRateLimited)
+}
+
+func sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// unknownOutcomeCode tags the responses made up for writes that failed once they may have reached IMX.
+const unknownOutcomeCode = "imx_outcome_unknown"
+
+// unavailableTransport answers the requests that got no response, as the sdk expects a response with every
+// failure and panics without one. Requests that could not connect, and reads, get a 503. Writes that failed
+// after connecting may have been applied, they get a response tagged with unknownOutcomeCode.
+type unavailableTransport struct {
+	http.RoundTripper
+}
+
+func (t unavailableTransport) RoundTrip(request *http.Request) (*http.Response, error) {
+	response, err := t.RoundTripper.RoundTrip(request)
+	if err == nil {
+		return response, nil
+	}
+
+	if operation.IsMutating(request.Method) && !isDialError(err) {
+		body, marshalErr := json.Marshal(api.APIError{Code: unknownOutcomeCode, Message: err.Error()})
+		if marshalErr != nil {
+			return nil, marshalErr
+		}
+		return failureResponse(request, http.StatusBadGateway, "application/json", body), nil
+	}
+
+	return failureResponse(request, http.StatusServiceUnavailable, "text/plain", []byte(err.Error())), nil
+}
+
+// isDialError tells the request failed while connecting, before anything was sent.
+func isDialError(err error) bool {
+	var opError *net.OpError
+	return errors.As(err, &opError) && opError.Op == "dial"
+}
+
+func failureResponse(request *http.Request, statusCode int, contentType string, body []byte) *http.Response {
+	return &http.Response{
+		Status:     fmt.Sprintf("%d %s", statusCode, http.StatusText(statusCode)),
+		StatusCode: statusCode,
+		Proto:      "HTTP/1.1",
+		ProtoMajor: 1,
+		ProtoMinor: 1,
+		Header:     http.Header{"Content-Type": []string{contentType}},
+		Body:       io.NopCloser(bytes.NewReader(body)),
+		Request:    request,
+	}
+}
@@ -0,0 +1,277 @@
+package imx
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/immutable/imx-core-sdk-golang/imx/api"
+)
+
+// stubClient fails every call with err, the methods it does not override panic.
+type stubClient struct {
+	Client
+	calls int
+	err   func(call int) error
+}
+
+func (c *stubClient) ListAssets(ctx context.Context, contractAddress string) ([]AssetInformation, error) {
+	c.calls++
+	if err := c.err(c.calls); err != nil {
+		return nil, err
+	}
+	return []AssetInformation{{TokenID: "1"}}, nil
+}
+
+func (c *stubClient) CreateToken(ctx context.Context, info *MintInformation) error {
+	c.calls++
+	return c.err(c.calls)
+}
+
+func (c *stubClient) ConfirmEthDeposit(ctx context.Context, transactionHash string) error {
+	c.calls++
+	<-ctx.Done()
+	return ctx.Err()
+}
+
+func (c *stubClient) CreateEthDeposit(ctx context.Context, info *CreateDepositInformation) (string, error) {
+	c.calls++
+	<-ctx.Done()
+	return "", ctx.Err()
+}
+
+func unavailable(int) error {
+	return &RequestError{Kind: ErrUnavailable, StatusCode: http.StatusServiceUnavailable}
+}
+
+func newTestResilient(client Client, policy Policy) *Resilient {
+	r := NewResilient(client, policy)
+	r.sleep = func(context.Context, time.Duration) error { return nil }
+	return r
+}
+
+func (s *UnitTestSuite) TestResilientRetriesReads() {
+	client := &stubClient{err: func(call int) error {
+		if call < 3 {
+			return unavailable(call)
+		}
+		return nil
+	}}
+	r := newTestResilient(client, Policy{Retries: 2})
+
+	assets, err := r.ListAssets(context.Background(), "0x01")
+	s.Require().NoError(err)
+	s.Assertions.Len(assets, 1)
+	s.Assertions.Equal(3, client.calls)
+}
+
+func (s *UnitTestSuite) TestResilientDoesNotRetryWrites() {
+	client := &stubClient{err: unavailable}
+	r := newTestResilient(client, Policy{Retries: 2})
+
+	s.Assertions.ErrorIs(r.CreateToken(context.Background(), &MintInformation{}), ErrUnavailable)
+	s.Assertions.Equal(1, client.calls)
+}
+
+func (s *UnitTestSuite) TestResilientDoesNotRetryPermanentFailures() {
+	client := &stubClient{err: func(int) error { return &RequestError{Kind: ErrNotFound} }}
+	r := newTestResilient(client, Policy{Retries: 2})
+
+	_, err := r.ListAssets(context.Background(), "0x01")
+	s.Assertions.ErrorIs(err, ErrNotFound)
+	s.Assertions.Equal(1, client.calls)
+}
+
+func (s *UnitTestSuite) TestResilientTimesOut() {
+	client := &stubClient{}
+	r := newTestResilient(client, Policy{Timeout: time.Second, Timeouts: map[string]time.Duration{"ConfirmEthDeposit": time.Millisecond}})
+
+	err := r.ConfirmEthDeposit(context.Background(), "0x01")
+	s.Assertions.ErrorIs(err, ErrUnavailable)
+	s.Assertions.ErrorIs(err, context.DeadlineExceeded)
+}
+
+func (s *UnitTestSuite) TestResilientWriteTimeoutIsUnknownOutcome() {
+	client := &stubClient{}
+	r := newTestResilient(client, Policy{Timeout: time.Millisecond, Retries: 2})
+
+	_, err := r.CreateEthDeposit(context.Background(), &CreateDepositInformation{})
+	s.Assertions.ErrorIs(err, ErrUnknownOutcome)
+	s.Assertions.False(errors.Is(err, ErrUnavailable))
+	s.Assertions.Equal(1, client.calls)
+}
+
+func (s *UnitTestSuite) TestResilientDoesNotRecordCanceledCalls() {
+	client := &stubClient{}
+	r := newTestResilient(client, Policy{Timeout: time.Minute, BreakerThreshold: 1, BreakerCooldown: time.Minute})
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond)
+	defer cancel()
+	err := r.ConfirmEthDeposit(ctx, "0x01")
+	s.Assertions.ErrorIs(err, context.DeadlineExceeded)
+	s.Assertions.False(errors.Is(err, ErrUnavailable))
+
+	// the breaker stays closed
+	_, err = r.allow()
+	s.Assertions.Nil(err)
+}
+
+func (s *UnitTestSuite) TestResilientOpensBreaker() {
+	now := time.Now()
+	failing := true
+	client := &stubClient{err: func(call int) error {
+		if failing {
+			return unavailable(call)
+		}
+		return nil
+	}}
+	r := newTestResilient(client, Policy{BreakerThreshold: 2, BreakerCooldown: time.Minute})
+	r.now = func() time.Time { return now }
+
+	for i := 0; i < 2; i++ {
+		s.Assertions.ErrorIs(r.CreateToken(context.Background(), &MintInformation{}), ErrUnavailable)
+	}
+
+	// the breaker fails fast without calling IMX
+	err := r.CreateToken(context.Background(), &MintInformation{})
+	s.Assertions.ErrorIs(err, ErrCircuitOpen)
+	s.Assertions.ErrorIs(err, ErrUnavailable)
+	s.Assertions.Equal(2, client.calls)
+
+	// after the cooldown a failing probe opens it again
+	now = now.Add(time.Minute)
+	s.Assertions.ErrorIs(r.CreateToken(context.Background(), &MintInformation{}), ErrUnavailable)
+	s.Assertions.ErrorIs(r.CreateToken(context.Background(), &MintInformation{}), ErrCircuitOpen)
+	s.Assertions.Equal(3, client.calls)
+
+	// a successful probe closes it
+	now = now.Add(time.Minute)
+	failing = false
+	s.Assertions.NoError(r.CreateToken(context.Background(), &MintInformation{}))
+	s.Assertions.NoError(r.CreateToken(context.Background(), &MintInformation{}))
+	s.Assertions.Equal(5, client.calls)
+}
+
+func (s *UnitTestSuite) TestResilientOnlyProbeClosesBreaker() {
+	now := time.Now()
+	r := newTestResilient(&stubClient{}, Policy{BreakerThreshold: 1, BreakerCooldown: time.Minute})
+	r.now = func() time.Time { return now }
+
+	// two calls in flight, one failing opens the breaker
+	first, err := r.allow()
+	s.Require().NoError(err)
+	second, err := r.allow()
+	s.Require().NoError(err)
+	r.record(first, unavailable(1))
+	_, err = r.allow()
+	s.Assertions.ErrorIs(err, ErrCircuitOpen)
+
+	now = now.Add(time.Minute)
+	probe, err := r.allow()
+	s.Require().NoError(err)
+	s.Assertions.True(probe)
+
+	// the call admitted before the breaker opened ends during the probe, it neither closes the breaker nor
+	// lets another call probe
+	r.record(second, nil)
+	_, err = r.allow()
+	s.Assertions.ErrorIs(err, ErrCircuitOpen)
+	r.release(second)
+	_, err = r.allow()
+	s.Assertions.ErrorIs(err, ErrCircuitOpen)
+
+	// the probe closes it
+	r.record(probe, nil)
+	probe, err = r.allow()
+	s.Assertions.NoError(err)
+	s.Assertions.False(probe)
+}
+
+func (s *UnitTestSuite) TestResilientIgnoresPermanentFailuresInBreaker() {
+	client := &stubClient{err: func(int) error { return errors.New("invalid request") }}
+	r := newTestResilient(client, Policy{BreakerThreshold: 1, BreakerCooldown: time.Minute})
+
+	for i := 0; i < 3; i++ {
+		s.Assertions.NotErrorIs(r.CreateToken(context.Background(), &MintInformation{}), ErrCircuitOpen)
+	}
+	s.Assertions.Equal(3, client.calls)
+}
+
+type failingTransport struct{}
+
+func (failingTransport) RoundTrip(*http.Request) (*http.Response, error) {
+	return nil, errors.New("connection refused")
+}
+
+func (s *UnitTestSuite) TestUnavailableTransport() {
+	request, err := http.NewRequest(http.MethodGet, "https://api.sandbox.x.immutable.com/v1/assets", nil)
+	s.Require().NoError(err)
+
+	response, err := unavailableTransport{failingTransport{}}.RoundTrip(request)
+	s.Require().NoError(err)
+	defer response.Body.Close()
+
+	body, err := io.ReadAll(response.Body)
+	s.Require().NoError(err)
+	s.Assertions.Equal(http.StatusServiceUnavailable, response.StatusCode)
+	s.Assertions.Equal("connection refused", string(body))
+}
+
+// resettingListener accepts connections and resets them once the request was read.
+func (s *UnitTestSuite) resettingListener() net.Listener {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	s.Require().NoError(err)
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			_, _ = conn.Read(make([]byte, 4096))
+			_ = conn.(*net.TCPConn).SetLinger(0)
+			_ = conn.Close()
+		}
+	}()
+	return listener
+}
+
+func newTestAPIClient(url string) *api.APIClient {
+	configuration := api.NewConfiguration()
+	configuration.Servers = api.ServerConfigurations{{URL: url}}
+	configuration.OperationServers = nil
+	configuration.HTTPClient = &http.Client{Transport: unavailableTransport{http.DefaultTransport}}
+	return api.NewAPIClient(configuration)
+}
+
+func (s *UnitTestSuite) TestUnavailableTransportKeepsResetWritesUnknown() {
+	listener := s.resettingListener()
+	defer listener.Close()
+	client := newTestAPIClient("http://" + listener.Addr().String())
+
+	// the trade may have been applied before the connection was reset
+	_, response, err := client.TradesApi.CreateTrade(context.Background()).
+		CreateTradeRequest(api.CreateTradeRequestV1{}).XImxEthAddress("0x01").XImxEthSignature("0x02").Execute()
+	err = newRequestError(response, err)
+	s.Assertions.ErrorIs(err, ErrUnknownOutcome)
+	s.Assertions.NotErrorIs(err, ErrUnavailable)
+
+	// reads can be sent again
+	_, response, err = client.AssetsApi.ListAssets(context.Background()).Execute()
+	s.Assertions.ErrorIs(newRequestError(response, err), ErrUnavailable)
+}
+
+func (s *UnitTestSuite) TestUnavailableTransportReportsUnsentWritesUnavailable() {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	s.Require().NoError(err)
+	address := listener.Addr().String()
+	s.Require().NoError(listener.Close())
+	client := newTestAPIClient("http://" + address)
+
+	_, response, err := client.TradesApi.CreateTrade(context.Background()).
+		CreateTradeRequest(api.CreateTradeRequestV1{}).XImxEthAddress("0x01").XImxEthSignature("0x02").Execute()
+	s.Assertions.ErrorIs(newRequestError(response, err), ErrUnavailable)
+}
@@ -0,0 +1,32 @@
+// Package operation tells the middlewares and the tasks about the operation they run.
+package operation
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/hibiken/asynq"
+)
+
+// IsMutating reports whether a request with the method changes state.
+func IsMutating(method string) bool {
+	switch method {
+	case http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete:
+		return true
+	default:
+		return false
+	}
+}
+
+// IsLastAttempt reports whether the task will be archived instead of retried if it fails now.
+func IsLastAttempt(ctx context.Context) bool {
+	retried, ok := asynq.GetRetryCount(ctx)
+	if !ok {
+		return false
+	}
+	maxRetry, ok := asynq.GetMaxRetry(ctx)
+	if !ok {
+		return false
+	}
+	return retried >= maxRetry
+}
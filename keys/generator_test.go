@@ -3,6 +3,10 @@ package keys
 import (
 	"testing"
 
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
+
 	"github.com/stretchr/testify/suite"
 )
 
@@ -18,6 +22,21 @@ func (s *UnitTestSuite) TestGenerator() {
 	s.Assertions.NotEmpty(pair.Address)
 }
 
+func (s *UnitTestSuite) TestVerifySignature() {
+	privateKey, err := crypto.GenerateKey()
+	s.Require().NoError(err)
+	address := crypto.PubkeyToAddress(privateKey.PublicKey).Hex()
+
+	signature, err := crypto.Sign(accounts.TextHash([]byte("challenge")), privateKey)
+	s.Require().NoError(err)
+	signature[crypto.RecoveryIDOffset] += 27
+
+	s.Assertions.True(VerifySignature(address, "challenge", hexutil.Encode(signature)))
+	s.Assertions.False(VerifySignature(address, "another challenge", hexutil.Encode(signature)))
+	s.Assertions.False(VerifySignature("0x18b1ceDC9803096D970f52260D1835F07D7e448C", "challenge", hexutil.Encode(signature)))
+	s.Assertions.False(VerifySignature(address, "challenge", "0x01"))
+}
+
 func TestUnitTestSuite(t *testing.T) {
 	suite.Run(t, new(UnitTestSuite))
 }
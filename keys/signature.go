@@ -0,0 +1,29 @@
+package keys
+
+import (
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// VerifySignature reports whether signature is the signature of message by address, as signed by wallets with
+// personal_sign (EIP-191).
+func VerifySignature(address string, message string, signature string) bool {
+	sig, err := hexutil.Decode(signature)
+	if err != nil || len(sig) != crypto.SignatureLength {
+		return false
+	}
+
+	// wallets give the recovery id as 27 or 28
+	if sig[crypto.RecoveryIDOffset] >= 27 {
+		sig[crypto.RecoveryIDOffset] -= 27
+	}
+
+	publicKey, err := crypto.SigToPub(accounts.TextHash([]byte(message)), sig)
+	if err != nil {
+		return false
+	}
+
+	return common.IsHexAddress(address) && crypto.PubkeyToAddress(*publicKey) == common.HexToAddress(address)
+}
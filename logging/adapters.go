@@ -0,0 +1,132 @@
+package logging
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	gethlog "github.com/ethereum/go-ethereum/log"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/hibiken/asynq"
+	"golang.org/x/exp/slog"
+	"gorm.io/gorm"
+	gormlogger "gorm.io/gorm/logger"
+)
+
+// slowQuery is the duration from which queries are logged as warnings.
+const slowQuery = 200 * time.Millisecond
+
+// gethHandler writes the records of the go-ethereum logger to logger.
+func gethHandler(logger *slog.Logger) gethlog.Handler {
+	return gethlog.FuncHandler(func(r *gethlog.Record) error {
+		level := slog.LevelDebug
+		switch r.Lvl {
+		case gethlog.LvlCrit, gethlog.LvlError:
+			level = slog.LevelError
+		case gethlog.LvlWarn:
+			level = slog.LevelWarn
+		case gethlog.LvlInfo:
+			level = slog.LevelInfo
+		}
+
+		logger.Log(context.Background(), level, r.Msg, r.Ctx...)
+		return nil
+	})
+}
+
+// GormLogger writes the gorm logs with the context of the query: errors, and slow queries as warnings.
+// The other queries are only logged at the debug level.
+type GormLogger struct{}
+
+var (
+	_ gormlogger.Interface = GormLogger{}
+	_ gorm.ParamsFilter    = GormLogger{}
+)
+
+func (l GormLogger) LogMode(gormlogger.LogLevel) gormlogger.Interface {
+	return l
+}
+
+// Info, Warn and Error take printf style arguments.
+func (GormLogger) Info(ctx context.Context, msg string, args ...interface{}) {
+	slog.InfoContext(ctx, fmt.Sprintf(msg, args...))
+}
+
+func (GormLogger) Warn(ctx context.Context, msg string, args ...interface{}) {
+	slog.WarnContext(ctx, fmt.Sprintf(msg, args...))
+}
+
+func (GormLogger) Error(ctx context.Context, msg string, args ...interface{}) {
+	slog.ErrorContext(ctx, fmt.Sprintf(msg, args...))
+}
+
+// ParamsFilter drops the values bound to the statement, so Trace logs it with its placeholders: the values
+// may be secrets, such as api keys.
+func (GormLogger) ParamsFilter(ctx context.Context, sql string, params ...interface{}) (string, []interface{}) {
+	return sql, nil
+}
+
+// Trace logs the statement with its placeholders, see ParamsFilter.
+func (GormLogger) Trace(ctx context.Context, begin time.Time, fc func() (sql string, rowsAffected int64), err error) {
+	elapsed := time.Since(begin)
+	switch {
+	case err != nil && !errors.Is(err, gorm.ErrRecordNotFound):
+		sql, rows := fc()
+		slog.ErrorContext(ctx, "query failed", "sql", sql, "rows", rows, "duration", elapsed, "err", err)
+	case elapsed >= slowQuery:
+		sql, rows := fc()
+		slog.WarnContext(ctx, "slow query", "sql", sql, "rows", rows, "duration", elapsed)
+	case slog.Default().Enabled(ctx, slog.LevelDebug):
+		sql, rows := fc()
+		slog.DebugContext(ctx, "query", "sql", sql, "rows", rows, "duration", elapsed)
+	}
+}
+
+// Middleware logs each request once served, replacing chi's middleware.Logger.
+func Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+		start := time.Now()
+
+		next.ServeHTTP(ww, r)
+
+		status := ww.Status()
+		if status == 0 {
+			status = http.StatusOK
+		}
+
+		level := slog.LevelInfo
+		if status >= http.StatusInternalServerError {
+			level = slog.LevelError
+		}
+		slog.Log(r.Context(), level, "request served",
+			"method", r.Method,
+			"path", r.URL.Path,
+			"status", status,
+			"bytes", ww.BytesWritten(),
+			"duration", time.Since(start),
+		)
+	})
+}
+
+// TaskMiddleware logs each task once processed, with the task type as operation of the logs of its
+// processing.
+func TaskMiddleware(next asynq.Handler) asynq.Handler {
+	return asynq.HandlerFunc(func(ctx context.Context, task *asynq.Task) error {
+		ctx = WithOperation(ctx, task.Type())
+		if taskID, ok := asynq.GetTaskID(ctx); ok {
+			ctx = With(ctx, "task_id", taskID)
+		}
+		start := time.Now()
+
+		err := next.ProcessTask(ctx, task)
+		if err != nil {
+			slog.WarnContext(ctx, "task failed", "duration", time.Since(start), "err", err)
+		} else {
+			slog.InfoContext(ctx, "task processed", "duration", time.Since(start))
+		}
+		return err
+	})
+}
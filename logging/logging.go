@@ -0,0 +1,127 @@
+// Package logging configures the structured logger of the application. Records carry the request ID, the
+// user and the operation found in their context, and secrets are redacted before they are written.
+package logging
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+
+	gethlog "github.com/ethereum/go-ethereum/log"
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+	"go.opentelemetry.io/otel/trace"
+	"golang.org/x/exp/slog"
+)
+
+// Settings sets the minimum level of the records ("debug", "info", "warn" or "error") and whether they
+// are written as JSON rather than as key=value text.
+type Settings struct {
+	Level string
+	JSON  bool
+}
+
+// Setup makes the logger the default one. The go-ethereum logger, used by the IMX sdk, and the standard
+// logger write to it too.
+func Setup(settings Settings) error {
+	logger, err := New(os.Stderr, settings)
+	if err != nil {
+		return err
+	}
+
+	slog.SetDefault(logger)
+	gethlog.Root().SetHandler(gethHandler(logger))
+	return nil
+}
+
+// New returns a logger writing to w.
+func New(w io.Writer, settings Settings) (*slog.Logger, error) {
+	var level slog.Level
+	if len(settings.Level) > 0 {
+		if err := level.UnmarshalText([]byte(settings.Level)); err != nil {
+			return nil, fmt.Errorf("invalid log level %q: %w", settings.Level, err)
+		}
+	}
+
+	opts := &slog.HandlerOptions{Level: level, ReplaceAttr: redact}
+	var handler slog.Handler
+	if settings.JSON {
+		handler = slog.NewJSONHandler(w, opts)
+	} else {
+		handler = slog.NewTextHandler(w, opts)
+	}
+
+	return slog.New(contextHandler{handler}), nil
+}
+
+type contextKey struct{}
+
+// With returns ctx with attributes added to the records logged with it.
+func With(ctx context.Context, args ...any) context.Context {
+	attrs := append(attributes(ctx), slog.Group("", args...).Value.Group()...)
+	return context.WithValue(ctx, contextKey{}, attrs)
+}
+
+// WithUserID returns ctx with the user added to the records logged with it.
+func WithUserID(ctx context.Context, userID fmt.Stringer) context.Context {
+	return With(ctx, "user_id", userID.String())
+}
+
+// WithOperation returns ctx with the operation added to the records logged with it. Records logged while
+// serving a request default to its route.
+func WithOperation(ctx context.Context, operation string) context.Context {
+	return With(ctx, "operation", operation)
+}
+
+func attributes(ctx context.Context) []slog.Attr {
+	attrs, _ := ctx.Value(contextKey{}).([]slog.Attr)
+	// copied, so contexts derived from the same parent do not share the array
+	return append([]slog.Attr(nil), attrs...)
+}
+
+// contextHandler adds the attributes of the context to the records.
+type contextHandler struct {
+	slog.Handler
+}
+
+func (h contextHandler) Handle(ctx context.Context, record slog.Record) error {
+	if ctx == nil {
+		return h.Handler.Handle(ctx, record)
+	}
+
+	if requestID := middleware.GetReqID(ctx); len(requestID) > 0 {
+		record.AddAttrs(slog.String("request_id", requestID))
+	}
+
+	attrs := attributes(ctx)
+	record.AddAttrs(attrs...)
+	if !hasOperation(attrs) {
+		if rctx := chi.RouteContext(ctx); rctx != nil && len(rctx.RoutePattern()) > 0 {
+			record.AddAttrs(slog.String("operation", rctx.RouteMethod+" "+rctx.RoutePattern()))
+		}
+	}
+
+	if span := trace.SpanContextFromContext(ctx); span.IsValid() {
+		record.AddAttrs(slog.String("trace_id", span.TraceID().String()))
+	}
+
+	return h.Handler.Handle(ctx, record)
+}
+
+func (h contextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return contextHandler{h.Handler.WithAttrs(attrs)}
+}
+
+func (h contextHandler) WithGroup(name string) slog.Handler {
+	return contextHandler{h.Handler.WithGroup(name)}
+}
+
+func hasOperation(attrs []slog.Attr) bool {
+	for _, attr := range attrs {
+		if attr.Key == "operation" {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,133 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/suite"
+	"golang.org/x/exp/slog"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+)
+
+type UnitTestSuite struct {
+	suite.Suite
+	output *bytes.Buffer
+	logger *slog.Logger
+}
+
+func (s *UnitTestSuite) SetupTest() {
+	s.output = &bytes.Buffer{}
+	logger, err := New(s.output, Settings{Level: "info", JSON: true})
+	s.Require().NoError(err)
+	s.logger = logger
+}
+
+func (s *UnitTestSuite) record() map[string]interface{} {
+	var record map[string]interface{}
+	s.Require().NoError(json.Unmarshal(s.output.Bytes(), &record))
+	return record
+}
+
+func (s *UnitTestSuite) TestNewRejectsUnknownLevel() {
+	_, err := New(s.output, Settings{Level: "verbose"})
+	s.Error(err)
+}
+
+func (s *UnitTestSuite) TestLevel() {
+	s.logger.Debug("query")
+	s.Empty(s.output.String())
+
+	s.logger.Info("served")
+	s.NotEmpty(s.output.String())
+}
+
+func (s *UnitTestSuite) TestRedactsSecrets() {
+	s.logger.Info("signed", "signature", "0xabc", "AuthSecret", "secret", "private_key", "key")
+
+	record := s.record()
+	s.Equal(redacted, record["signature"])
+	s.Equal(redacted, record["AuthSecret"])
+	s.Equal(redacted, record["private_key"])
+}
+
+func (s *UnitTestSuite) TestShortensAddresses() {
+	address := "0x5fdcca53617f4d2b9134b29090c87d01058e27e9"
+	s.logger.Info("registered", "address", address, "err", errors.New("unknown user "+address))
+
+	record := s.record()
+	s.Equal("0x5fdc…27e9", record["address"])
+	s.Equal("unknown user 0x5fdc…27e9", record["err"])
+}
+
+func (s *UnitTestSuite) TestContextAttributes() {
+	userID := uuid.New()
+	ctx := WithOperation(WithUserID(context.Background(), userID), "mint_token")
+	ctx = With(ctx, "token_id", 7)
+
+	s.logger.InfoContext(ctx, "minting token")
+
+	record := s.record()
+	s.Equal(userID.String(), record["user_id"])
+	s.Equal("mint_token", record["operation"])
+	s.Equal(float64(7), record["token_id"])
+}
+
+func (s *UnitTestSuite) TestContextAttributesAreNotShared() {
+	parent := With(context.Background(), "a", 1)
+	With(parent, "b", 2)
+
+	s.logger.InfoContext(parent, "logged")
+
+	s.NotContains(s.record(), "b")
+}
+
+func (s *UnitTestSuite) TestRequestIDAndRoute() {
+	router := chi.NewRouter()
+	router.Use(middleware.RequestID)
+	router.Get("/jobs/{jobID}", func(w http.ResponseWriter, r *http.Request) {
+		s.logger.InfoContext(r.Context(), "getting job")
+	})
+
+	router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/jobs/1", nil))
+
+	record := s.record()
+	s.NotEmpty(record["request_id"])
+	s.Equal("GET /jobs/{jobID}", record["operation"])
+}
+
+func (s *UnitTestSuite) TestGormLoggerOmitsBoundValues() {
+	logger, err := New(s.output, Settings{Level: "debug", JSON: true})
+	s.Require().NoError(err)
+	defaultLogger := slog.Default()
+	slog.SetDefault(logger)
+	defer slog.SetDefault(defaultLogger)
+
+	sqlDB, err := sql.Open("pgx", "host=localhost")
+	s.Require().NoError(err)
+	defer sqlDB.Close()
+	db, err := gorm.Open(postgres.New(postgres.Config{Conn: sqlDB}), &gorm.Config{Logger: GormLogger{}, DisableAutomaticPing: true, DryRun: true})
+	s.Require().NoError(err)
+
+	var user struct {
+		ID     string
+		ApiKey string
+	}
+	db.Table("users").Where("api_key = ?", "7e418a84-4e15-4412-8165-fe31901e623a").First(&user)
+
+	s.Contains(s.output.String(), "api_key = $1")
+	s.NotContains(s.output.String(), "7e418a84")
+}
+
+func TestUnitTestSuite(t *testing.T) {
+	suite.Run(t, new(UnitTestSuite))
+}
@@ -0,0 +1,43 @@
+package logging
+
+import (
+	"regexp"
+	"strings"
+
+	"golang.org/x/exp/slog"
+)
+
+const redacted = "[REDACTED]"
+
+// secretKeys are the parts of attribute names whose values are never logged.
+var secretKeys = []string{"private", "secret", "password", "signature", "authorization", "api_key", "apikey", "mnemonic"}
+
+// hexValue matches addresses, keys and hashes: 20 bytes or more in hex.
+var hexValue = regexp.MustCompile(`0x[0-9a-fA-F]{40,}`)
+
+// redact removes the values of secret attributes and shortens the addresses and keys in the others to
+// their first and last characters, which are enough to tell them apart.
+func redact(groups []string, attr slog.Attr) slog.Attr {
+	key := strings.ToLower(attr.Key)
+	for _, secret := range secretKeys {
+		if strings.Contains(key, secret) {
+			return slog.String(attr.Key, redacted)
+		}
+	}
+
+	switch attr.Value.Kind() {
+	case slog.KindString:
+		return slog.String(attr.Key, shorten(attr.Value.String()))
+	case slog.KindAny:
+		if err, ok := attr.Value.Any().(error); ok {
+			return slog.String(attr.Key, shorten(err.Error()))
+		}
+	}
+	return attr
+}
+
+func shorten(value string) string {
+	return hexValue.ReplaceAllStringFunc(value, func(hex string) string {
+		return hex[:6] + "…" + hex[len(hex)-4:]
+	})
+}
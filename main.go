@@ -3,108 +3,160 @@ package main
 import (
 	"context"
 	"errors"
-	"log"
-	"net/http"
+	"flag"
+	"fmt"
 	"nft/config"
-	"nft/db"
-	"nft/imx"
-	"nft/server"
-	"nft/tasks"
+	"nft/logging"
 	"os"
 	"os/signal"
+	"strings"
 	"syscall"
 	"time"
 
 	"github.com/carlmjohnson/versioninfo"
 
-	"github.com/hibiken/asynq"
+	"golang.org/x/exp/slog"
 )
 
-func main() {
-	log.Println("Starting NFT Marketplace")
+const usage = `Usage: nft-imx [command] [flags]
 
-	settings := config.GetConfig()
+Commands:
+  all      serve the API and run the workers in the same process (default)
+  serve    serve the API only
+  worker   run the task workers, the scheduler and the outbox relay only
+  migrate  apply, roll back or create migrations, see migrate -h
+  admin    set the platform up on IMX, see admin -h
 
-	log.Printf("Version: %s", versioninfo.Short())
-	log.Printf("Port: %s", settings.Port)
-	log.Printf("DebugMode: %t", settings.DebugMode)
+Flags:
+`
 
-	migrations := db.NewMigrations(settings.DSN)
-	err := migrations.Up(context.TODO())
-	if err != nil {
-		log.Fatal("Error applying migrations")
-	}
+// service is a long running part of the process, started once and stopped on shutdown.
+type service interface {
+	// Start runs the service in the background, errors stopping it early are sent to errs.
+	Start(errs chan<- error) error
+	Shutdown(ctx context.Context) error
+}
 
-	newDB, err := db.NewDB(settings.DSN)
-	if err != nil {
-		log.Fatal("error configuring DB", err)
+func main() {
+	command := "all"
+	args := os.Args[1:]
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		command, args = args[0], args[1:]
 	}
 
-	imxClient, err := imx.NewIMX(settings.AlchemyAPIKey, settings.L1SignerPrivateKey, settings.StarkPrivateKey, settings.ProjectID)
-	if err != nil {
-		log.Fatal("error configuring imx", err)
+	switch command {
+	case "all", "serve", "worker", "migrate", "admin":
+	default:
+		fmt.Fprintf(os.Stderr, "unknown command %q\n\n%s", command, usage)
+		os.Exit(2)
 	}
 
-	defer imxClient.Close()
+	settings := config.GetConfig()
 
-	asyncClient := asynq.NewClient(asynq.RedisClientOpt{Addr: settings.RedisUrl})
+	if err := logging.Setup(settings.LoggingSettings()); err != nil {
+		fatal("error configuring logging", err)
+	}
 
-	newServer := server.NewServer(settings, newDB, imxClient, asyncClient)
-	newServer.Configure()
+	if err := settings.Validate(); err != nil {
+		fatal("invalid config", err)
+	}
 
-	httpServer := &http.Server{Addr: ":" + settings.Port, Handler: newServer.Router}
+	if command == "migrate" {
+		if err := runMigrate(settings, args); err != nil {
+			fatal("error running migrations", err)
+		}
+		return
+	}
 
-	serverCtx, serverStopCtx := context.WithCancel(context.Background())
+	if command == "admin" {
+		if err := runAdmin(settings, args); err != nil {
+			fatal("error running admin action", err)
+		}
+		return
+	}
 
-	asynqServer := asynq.NewServer(
-		asynq.RedisClientOpt{Addr: settings.RedisUrl},
-		asynq.Config{
-			IsFailure: func(err error) bool {
-				// this type of error will not count as an error in asynq and will not affect retry count
-				// the task will be scheduled again for execution.
-				var notReady imx.WithdrawalNotReadyError
-				return !errors.As(err, &notReady)
-			},
-		},
+	flags := flag.NewFlagSet(command, flag.ExitOnError)
+	flags.Usage = func() {
+		fmt.Fprint(flags.Output(), usage)
+		flags.PrintDefaults()
+	}
+	// a single process keeps migrating unless disabled, the split ones leave it to the migrate command
+	migrate := flags.Bool("migrate", command == "all" && settings.AutoMigrate, "apply the pending migrations before starting")
+	_ = flags.Parse(args)
+
+	slog.Info("starting NFT Marketplace",
+		"version", versioninfo.Short(),
+		"command", command,
+		"port", settings.Port,
+		"worker_port", settings.WorkerPort,
+		"debug_auth", settings.DebugAuth,
+		"imx_environment", settings.IMXEnvironment,
+		"imx_api_url", settings.IMXAPIURL,
+		// rpc urls often embed credentials, only say it is overridden
+		"custom_l1_rpc", len(settings.L1RPCURL) > 0,
 	)
 
-	mux := asynq.NewServeMux()
-	mux.Handle(tasks.TypeCompleteWithdrawal, tasks.NewCompleteWithdrawalProcessor(imxClient, newDB))
+	a, err := newApp(settings, *migrate)
+	if err != nil {
+		fatal("error starting", err)
+	}
 
-	if err := asynqServer.Start(mux); err != nil {
-		log.Fatalf("could not run asynq server: %v", err)
+	var services []service
+	if command != "worker" {
+		services = append(services, newAPI(a))
+	}
+	if command != "serve" {
+		services = append(services, newWorker(a))
 	}
 
-	sig := make(chan os.Signal, 1)
-	signal.Notify(sig, syscall.SIGHUP, syscall.SIGINT, syscall.SIGTERM, syscall.SIGQUIT)
-	go func() {
-		<-sig
+	err = run(services, time.Second*time.Duration(settings.ShutdownTimeoutSeconds))
+	a.close()
+	if err != nil {
+		fatal("error stopping", err)
+	}
+}
 
-		shutdownCtx, cancelShutdown := context.WithTimeout(serverCtx, 30*time.Second)
+// run starts the services and shuts them down in reverse order on a termination signal, or as soon as one
+// of them fails.
+func run(services []service, shutdownTimeout time.Duration) error {
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGHUP, syscall.SIGINT, syscall.SIGTERM, syscall.SIGQUIT)
+	defer stop()
+
+	errs := make(chan error, len(services))
+	started := 0
+	var err error
+	for _, s := range services {
+		if err = s.Start(errs); err != nil {
+			break
+		}
+		started++
+	}
 
-		go func() {
-			<-shutdownCtx.Done()
-			if shutdownCtx.Err() == context.DeadlineExceeded {
-				log.Fatal("graceful shutdown timed out.. forcing exit.")
-			}
-		}()
+	if err == nil {
+		select {
+		case <-ctx.Done():
+			slog.Info("shutting down")
+		case err = <-errs:
+		}
+	}
 
-		asynqServer.Stop()
-		asynqServer.Shutdown()
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
 
-		err := httpServer.Shutdown(shutdownCtx)
-		if err != nil {
-			log.Fatal(err)
+	for i := started - 1; i >= 0; i-- {
+		if shutdownErr := services[i].Shutdown(shutdownCtx); shutdownErr != nil && err == nil {
+			err = shutdownErr
 		}
+	}
 
-		serverStopCtx()
-		cancelShutdown()
-	}()
-
-	err = httpServer.ListenAndServe()
-	if err != nil && err != http.ErrServerClosed {
-		log.Fatal("error stopping web server", err)
+	if errors.Is(shutdownCtx.Err(), context.DeadlineExceeded) && err == nil {
+		err = errors.New("graceful shutdown timed out")
 	}
+	return err
+}
 
-	<-serverCtx.Done()
+// fatal logs the error and exits.
+func fatal(msg string, err error) {
+	slog.Error(msg, "err", err)
+	os.Exit(1)
 }
@@ -0,0 +1,207 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"nft/imx"
+	"nft/models"
+	"time"
+)
+
+// IMXClient is an imx.Client reporting the latency and the errors of the calls of another.
+type IMXClient struct {
+	client  imx.Client
+	metrics *Metrics
+}
+
+var _ imx.Client = (*IMXClient)(nil)
+
+// InstrumentIMX wraps the client. Wrapping the client under imx.Resilient observes every attempt, as IMX
+// sees them.
+func (m *Metrics) InstrumentIMX(client imx.Client) *IMXClient {
+	return &IMXClient{client, m}
+}
+
+func (c *IMXClient) observe(method string, start time.Time, err error) {
+	c.metrics.imxRequests.WithLabelValues(method).Observe(time.Since(start).Seconds())
+	if err != nil {
+		c.metrics.imxErrors.WithLabelValues(method, errorKind(err)).Inc()
+	}
+}
+
+// errorKind labels the errors with their imx kind, the bounded set keeps the series few.
+func errorKind(err error) string {
+	switch {
+	case errors.Is(err, imx.ErrNotFound):
+		return "not_found"
+	case errors.Is(err, imx.ErrConflict):
+		return "conflict"
+	case errors.Is(err, imx.ErrInsufficientBalance):
+		return "insufficient_balance"
+	case errors.Is(err, imx.ErrRateLimited):
+		return "rate_limited"
+	case errors.Is(err, imx.ErrUnavailable):
+		return "unavailable"
+	case errors.Is(err, imx.ErrUnknownOutcome):
+		return "unknown_outcome"
+	case errors.Is(err, imx.ErrUnauthorized):
+		return "unauthorized"
+	case errors.Is(err, imx.ErrInvalidSignature):
+		return "invalid_signature"
+	default:
+		return "other"
+	}
+}
+
+func (c *IMXClient) Close() {
+	c.client.Close()
+}
+
+func (c *IMXClient) Address() string {
+	return c.client.Address()
+}
+
+func (c *IMXClient) CreateUser(ctx context.Context, user *models.User) (string, error) {
+	start := time.Now()
+	starkKey, err := c.client.CreateUser(ctx, user)
+	c.observe("CreateUser", start, err)
+	return starkKey, err
+}
+
+func (c *IMXClient) CreateCollection(ctx context.Context, info *imx.CollectionInformation) error {
+	start := time.Now()
+	err := c.client.CreateCollection(ctx, info)
+	c.observe("CreateCollection", start, err)
+	return err
+}
+
+func (c *IMXClient) CreateMetadata(ctx context.Context, info *imx.MetadataInformation) error {
+	start := time.Now()
+	err := c.client.CreateMetadata(ctx, info)
+	c.observe("CreateMetadata", start, err)
+	return err
+}
+
+func (c *IMXClient) CreateToken(ctx context.Context, info *imx.MintInformation) error {
+	start := time.Now()
+	err := c.client.CreateToken(ctx, info)
+	c.observe("CreateToken", start, err)
+	return err
+}
+
+func (c *IMXClient) TransferToken(ctx context.Context, info *imx.TransferInformation) error {
+	start := time.Now()
+	err := c.client.TransferToken(ctx, info)
+	c.observe("TransferToken", start, err)
+	return err
+}
+
+func (c *IMXClient) CreateOrder(ctx context.Context, info *imx.OrderInformation) (int32, error) {
+	start := time.Now()
+	orderID, err := c.client.CreateOrder(ctx, info)
+	c.observe("CreateOrder", start, err)
+	return orderID, err
+}
+
+func (c *IMXClient) CreateEthDeposit(ctx context.Context, info *imx.CreateDepositInformation) (string, error) {
+	start := time.Now()
+	hash, err := c.client.CreateEthDeposit(ctx, info)
+	c.observe("CreateEthDeposit", start, err)
+	return hash, err
+}
+
+// ConfirmEthDeposit does not count the deposits still waiting for confirmation as errors, they are expected.
+func (c *IMXClient) ConfirmEthDeposit(ctx context.Context, transactionHash string) error {
+	start := time.Now()
+	err := c.client.ConfirmEthDeposit(ctx, transactionHash)
+	var notConfirmed imx.DepositNotConfirmedError
+	if errors.As(err, &notConfirmed) {
+		c.observe("ConfirmEthDeposit", start, nil)
+	} else {
+		c.observe("ConfirmEthDeposit", start, err)
+	}
+	return err
+}
+
+func (c *IMXClient) CreateTrade(ctx context.Context, info *imx.CreateTradeInformation) (int32, error) {
+	start := time.Now()
+	tradeID, err := c.client.CreateTrade(ctx, info)
+	c.observe("CreateTrade", start, err)
+	return tradeID, err
+}
+
+func (c *IMXClient) CreateEthWithdrawal(ctx context.Context, info *imx.CreateWithdrawalInformation) (int32, error) {
+	start := time.Now()
+	withdrawalID, err := c.client.CreateEthWithdrawal(ctx, info)
+	c.observe("CreateEthWithdrawal", start, err)
+	return withdrawalID, err
+}
+
+// CompleteEthWithdrawal does not count the withdrawals not ready yet as errors, they are expected.
+func (c *IMXClient) CompleteEthWithdrawal(ctx context.Context, info *imx.CompleteWithdrawalInformation) error {
+	start := time.Now()
+	err := c.client.CompleteEthWithdrawal(ctx, info)
+	var notReady imx.WithdrawalNotReadyError
+	if errors.As(err, &notReady) {
+		c.observe("CompleteEthWithdrawal", start, nil)
+	} else {
+		c.observe("CompleteEthWithdrawal", start, err)
+	}
+	return err
+}
+
+func (c *IMXClient) GetSignableTrade(ctx context.Context, info *imx.CreateTradeInformation) (*imx.SignableInformation, error) {
+	start := time.Now()
+	signable, err := c.client.GetSignableTrade(ctx, info)
+	c.observe("GetSignableTrade", start, err)
+	return signable, err
+}
+
+func (c *IMXClient) SubmitTrade(ctx context.Context, info *imx.SubmitTradeInformation) (int32, error) {
+	start := time.Now()
+	tradeID, err := c.client.SubmitTrade(ctx, info)
+	c.observe("SubmitTrade", start, err)
+	return tradeID, err
+}
+
+func (c *IMXClient) GetSignableWithdrawal(ctx context.Context, info *imx.CreateWithdrawalInformation) (*imx.SignableInformation, error) {
+	start := time.Now()
+	signable, err := c.client.GetSignableWithdrawal(ctx, info)
+	c.observe("GetSignableWithdrawal", start, err)
+	return signable, err
+}
+
+func (c *IMXClient) SubmitWithdrawal(ctx context.Context, info *imx.SubmitWithdrawalInformation) (int32, error) {
+	start := time.Now()
+	withdrawalID, err := c.client.SubmitWithdrawal(ctx, info)
+	c.observe("SubmitWithdrawal", start, err)
+	return withdrawalID, err
+}
+
+func (c *IMXClient) GetAsset(ctx context.Context, contractAddress string, tokenID string) (*imx.AssetInformation, error) {
+	start := time.Now()
+	asset, err := c.client.GetAsset(ctx, contractAddress, tokenID)
+	c.observe("GetAsset", start, err)
+	return asset, err
+}
+
+func (c *IMXClient) ListAssets(ctx context.Context, contractAddress string) ([]imx.AssetInformation, error) {
+	start := time.Now()
+	assets, err := c.client.ListAssets(ctx, contractAddress)
+	c.observe("ListAssets", start, err)
+	return assets, err
+}
+
+func (c *IMXClient) ListOrders(ctx context.Context, contractAddress string) ([]imx.OrderSummary, error) {
+	start := time.Now()
+	orders, err := c.client.ListOrders(ctx, contractAddress)
+	c.observe("ListOrders", start, err)
+	return orders, err
+}
+
+func (c *IMXClient) Ping(ctx context.Context) error {
+	start := time.Now()
+	err := c.client.Ping(ctx)
+	c.observe("Ping", start, err)
+	return err
+}
@@ -0,0 +1,183 @@
+// Package metrics exposes Prometheus metrics of the HTTP requests, the IMX calls, the asynq tasks and queues
+// and the database pool.
+package metrics
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/hibiken/asynq"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"golang.org/x/exp/slog"
+)
+
+const namespace = "nft"
+
+// Metrics holds the collectors, registered in its own registry along with the Go runtime and process ones.
+type Metrics struct {
+	registry     *prometheus.Registry
+	httpRequests *prometheus.HistogramVec
+	imxRequests  *prometheus.HistogramVec
+	imxErrors    *prometheus.CounterVec
+	tasks        *prometheus.HistogramVec
+}
+
+func New() *Metrics {
+	m := &Metrics{
+		registry: prometheus.NewRegistry(),
+		httpRequests: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "http_request_duration_seconds",
+			Help:      "Duration of the HTTP requests by route pattern.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"method", "route", "status"}),
+		imxRequests: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "imx_request_duration_seconds",
+			Help:      "Duration of the IMX calls by client method.",
+			Buckets:   []float64{.05, .1, .25, .5, 1, 2.5, 5, 10, 30, 60, 120},
+		}, []string{"method"}),
+		imxErrors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "imx_request_errors_total",
+			Help:      "Failed IMX calls by client method and kind of error.",
+		}, []string{"method", "kind"}),
+		tasks: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "task_duration_seconds",
+			Help:      "Duration of the asynq tasks by type and outcome.",
+			Buckets:   []float64{.05, .1, .25, .5, 1, 2.5, 5, 10, 30, 60, 120},
+		}, []string{"type", "outcome"}),
+	}
+
+	m.registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		m.httpRequests,
+		m.imxRequests,
+		m.imxErrors,
+		m.tasks,
+	)
+
+	return m
+}
+
+// Handler serves the metrics in the Prometheus exposition format.
+func (m *Metrics) Handler() http.Handler {
+	return promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{})
+}
+
+// RegisterDB reports the stats of the database connection pool.
+func (m *Metrics) RegisterDB(db *sql.DB, name string) error {
+	return m.registry.Register(collectors.NewDBStatsCollector(db, name))
+}
+
+// RegisterQueues reports the depth of the asynq queues.
+func (m *Metrics) RegisterQueues(inspector *asynq.Inspector) error {
+	return m.registry.Register(newQueueCollector(inspector))
+}
+
+// Middleware observes the duration of the requests labelled with the chi route pattern rather than the path,
+// which would make a series for every id. Requests matching no route are labelled "unmatched".
+func (m *Metrics) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+		start := time.Now()
+
+		next.ServeHTTP(ww, r)
+
+		route := "unmatched"
+		if rctx := chi.RouteContext(r.Context()); rctx != nil && len(rctx.RoutePattern()) > 0 {
+			route = rctx.RoutePattern()
+		}
+
+		status := ww.Status()
+		if status == 0 {
+			status = http.StatusOK
+		}
+
+		m.httpRequests.WithLabelValues(r.Method, route, strconv.Itoa(status)).Observe(time.Since(start).Seconds())
+	})
+}
+
+// TaskMiddleware observes the duration and outcome of the asynq tasks.
+func (m *Metrics) TaskMiddleware(next asynq.Handler) asynq.Handler {
+	return asynq.HandlerFunc(func(ctx context.Context, task *asynq.Task) error {
+		start := time.Now()
+		err := next.ProcessTask(ctx, task)
+
+		outcome := "success"
+		switch {
+		case errors.Is(err, asynq.SkipRetry):
+			outcome = "skipped"
+		case err != nil:
+			outcome = "failure"
+		}
+
+		m.tasks.WithLabelValues(task.Type(), outcome).Observe(time.Since(start).Seconds())
+		return err
+	})
+}
+
+var queueSize = prometheus.NewDesc(
+	prometheus.BuildFQName(namespace, "queue", "tasks"),
+	"Tasks in the asynq queues by state.",
+	[]string{"queue", "state"}, nil,
+)
+
+var queueLatency = prometheus.NewDesc(
+	prometheus.BuildFQName(namespace, "queue", "latency_seconds"),
+	"Age of the oldest pending task of the asynq queues.",
+	[]string{"queue"}, nil,
+)
+
+// queueCollector reads the queues from Redis on every scrape.
+type queueCollector struct {
+	inspector *asynq.Inspector
+}
+
+func newQueueCollector(inspector *asynq.Inspector) *queueCollector {
+	return &queueCollector{inspector}
+}
+
+func (c *queueCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- queueSize
+	ch <- queueLatency
+}
+
+func (c *queueCollector) Collect(ch chan<- prometheus.Metric) {
+	queues, err := c.inspector.Queues()
+	if err != nil {
+		slog.Warn("could not list the queues", "err", err)
+		return
+	}
+
+	for _, queue := range queues {
+		info, err := c.inspector.GetQueueInfo(queue)
+		if err != nil {
+			slog.Warn("could not get the queue", "queue", queue, "err", err)
+			continue
+		}
+
+		states := map[string]int{
+			"pending":   info.Pending,
+			"active":    info.Active,
+			"scheduled": info.Scheduled,
+			"retry":     info.Retry,
+			"archived":  info.Archived,
+			"completed": info.Completed,
+		}
+		for state, size := range states {
+			ch <- prometheus.MustNewConstMetric(queueSize, prometheus.GaugeValue, float64(size), queue, state)
+		}
+		ch <- prometheus.MustNewConstMetric(queueLatency, prometheus.GaugeValue, info.Latency.Seconds(), queue)
+	}
+}
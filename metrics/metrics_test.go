@@ -0,0 +1,111 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"nft/imx"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/hibiken/asynq"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/stretchr/testify/suite"
+)
+
+type UnitTestSuite struct {
+	suite.Suite
+}
+
+func (s *UnitTestSuite) TestMiddlewareLabelsRoutePattern() {
+	m := New()
+	router := chi.NewRouter()
+	router.Use(m.Middleware)
+	router.Get("/webhooks/{webhookID}/deliveries", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusAccepted)
+	})
+
+	for _, path := range []string{"/webhooks/1/deliveries", "/webhooks/2/deliveries", "/unknown"} {
+		router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, path, nil))
+	}
+
+	s.Assertions.Equal(2, testutil.CollectAndCount(m.httpRequests))
+	s.Assertions.Equal(uint64(2), histogramCount(m, "GET", "/webhooks/{webhookID}/deliveries", "202"))
+	s.Assertions.Equal(uint64(1), histogramCount(m, "GET", "unmatched", "404"))
+}
+
+func histogramCount(m *Metrics, labels ...string) uint64 {
+	families, err := m.registry.Gather()
+	if err != nil {
+		panic(err)
+	}
+	for _, family := range families {
+		if family.GetName() != "nft_http_request_duration_seconds" {
+			continue
+		}
+		for _, metric := range family.GetMetric() {
+			if fmt.Sprint(labelValues(metric.GetLabel())) == fmt.Sprint(labels) {
+				return metric.GetHistogram().GetSampleCount()
+			}
+		}
+	}
+	return 0
+}
+
+func labelValues(pairs []*dto.LabelPair) []string {
+	values := make([]string, len(pairs))
+	for i, pair := range pairs {
+		values[i] = pair.GetValue()
+	}
+	return values
+}
+
+// stubClient fails every call with err, the methods it does not override panic.
+type stubClient struct {
+	imx.Client
+	err error
+}
+
+func (c *stubClient) CreateToken(ctx context.Context, info *imx.MintInformation) error {
+	return c.err
+}
+
+func (s *UnitTestSuite) TestInstrumentIMXCountsErrorsByKind() {
+	m := New()
+	client := &stubClient{err: &imx.RequestError{Kind: imx.ErrConflict}}
+	instrumented := m.InstrumentIMX(client)
+
+	s.Assertions.ErrorIs(instrumented.CreateToken(context.Background(), &imx.MintInformation{}), imx.ErrConflict)
+	client.err = errors.New("invalid signature")
+	s.Assertions.Error(instrumented.CreateToken(context.Background(), &imx.MintInformation{}))
+	client.err = nil
+	s.Assertions.NoError(instrumented.CreateToken(context.Background(), &imx.MintInformation{}))
+
+	s.Assertions.Equal(float64(1), testutil.ToFloat64(m.imxErrors.WithLabelValues("CreateToken", "conflict")))
+	s.Assertions.Equal(float64(1), testutil.ToFloat64(m.imxErrors.WithLabelValues("CreateToken", "other")))
+	s.Assertions.Equal(1, testutil.CollectAndCount(m.imxRequests))
+}
+
+func (s *UnitTestSuite) TestTaskMiddlewareRecordsOutcome() {
+	m := New()
+	var err error
+	handler := m.TaskMiddleware(asynq.HandlerFunc(func(ctx context.Context, task *asynq.Task) error {
+		return err
+	}))
+
+	task := asynq.NewTask("token:mint", nil)
+	s.Assertions.NoError(handler.ProcessTask(context.Background(), task))
+	err = fmt.Errorf("invalid payload: %w", asynq.SkipRetry)
+	s.Assertions.Error(handler.ProcessTask(context.Background(), task))
+	err = errors.New("imx unavailable")
+	s.Assertions.Error(handler.ProcessTask(context.Background(), task))
+
+	s.Assertions.Equal(3, testutil.CollectAndCount(m.tasks))
+}
+
+func TestUnitTestSuite(t *testing.T) {
+	suite.Run(t, new(UnitTestSuite))
+}
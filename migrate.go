@@ -0,0 +1,88 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"nft/config"
+	"nft/db"
+	"os"
+	"strconv"
+)
+
+const migrateUsage = `Usage: nft-imx migrate <action> [flags] [args]
+
+Actions:
+  up                 apply all the pending migrations
+  down               roll back the last migration
+  down-to <version>  roll back the migrations applied after version
+  reset              roll back all the migrations
+  status             print whether each migration is applied
+  version            print the version of the last migration applied
+  create <name>      write a new blank migration to the migrations directory
+
+Flags:
+`
+
+// runMigrate runs the migrate command, args are what follows it.
+func runMigrate(settings *config.Settings, args []string) error {
+	flags := flag.NewFlagSet("migrate", flag.ExitOnError)
+	flags.Usage = func() {
+		fmt.Fprint(flags.Output(), migrateUsage)
+		flags.PrintDefaults()
+	}
+	dir := flags.String("dir", "db/migrations", "the migrations directory of the source tree, for create")
+
+	if len(args) == 0 {
+		flags.Usage()
+		os.Exit(2)
+	}
+	action := args[0]
+	_ = flags.Parse(args[1:])
+
+	ctx := context.Background()
+	migrations := db.NewMigrations(settings.DSN)
+
+	switch action {
+	case "up":
+		return migrations.Up(ctx)
+	case "down":
+		return migrations.Down(ctx)
+	case "down-to":
+		if flags.NArg() != 1 {
+			return errors.New("down-to expects the version to roll back to")
+		}
+		version, err := strconv.ParseInt(flags.Arg(0), 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid version %q: %w", flags.Arg(0), err)
+		}
+		return migrations.DownTo(ctx, version)
+	case "reset":
+		return migrations.Reset(ctx)
+	case "status":
+		return migrations.Status(ctx)
+	case "version":
+		version, err := migrations.Version(ctx)
+		if err != nil {
+			return err
+		}
+		fmt.Println(version)
+		return nil
+	case "create":
+		if flags.NArg() != 1 {
+			return errors.New("create expects the name of the migration")
+		}
+		path, err := db.CreateMigration(*dir, flags.Arg(0))
+		if err != nil {
+			return err
+		}
+		fmt.Println(path)
+		return nil
+	default:
+		fmt.Fprintf(os.Stderr, "unknown migrate action %q\n\n", action)
+		flags.Usage()
+		os.Exit(2)
+		return nil
+	}
+}
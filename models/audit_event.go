@@ -0,0 +1,24 @@
+package models
+
+import "github.com/google/uuid"
+
+const (
+	AuditSuccess = "success"
+	AuditFailure = "failure"
+)
+
+// AuditEvent records who performed an operation changing the state of the platform and how it went.
+// ActorID is empty for operations started by the platform itself, RequestID for tasks. IMXID is the
+// ID IMX gave to the operation, when it has one.
+type AuditEvent struct {
+	ID         uuid.UUID  `json:"id" gorm:"type:uuid;primary_key;"`
+	ActorID    *uuid.UUID `json:"actor_id,omitempty" gorm:"type:uuid;null;"`
+	Action     string     `json:"action" gorm:"not null;"`
+	TargetType string     `json:"target_type,omitempty" gorm:"null;"`
+	TargetID   string     `json:"target_id,omitempty" gorm:"null;"`
+	RequestID  string     `json:"request_id,omitempty" gorm:"null;"`
+	Outcome    string     `json:"outcome" gorm:"not null;"`
+	IMXID      string     `json:"imx_id,omitempty" gorm:"column:imx_id;null;"`
+	Details    string     `json:"details,omitempty" gorm:"null;"`
+	CreatedAt  int64      `json:"created_at" gorm:"autoCreateTime:milli;"`
+}
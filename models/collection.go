@@ -5,7 +5,9 @@ import "github.com/google/uuid"
 type Collection struct {
 	ID              uuid.UUID `json:"id" gorm:"type:uuid;primary_key;"`
 	UserID          uuid.UUID `json:"user_id" gorm:"type:uuid;not null;"`
+	OrganizationID  uuid.UUID `json:"organization_id" gorm:"type:uuid;not null;"`
 	ContractAddress string    `json:"contract_address" gorm:"not null;unique;"`
+	Hidden          bool      `json:"hidden" gorm:"not null;default:false;"`
 	CreatedAt       int64     `json:"-" gorm:"autoCreateTime:milli;"`
 	UpdatedAt       int64     `json:"-" gorm:"autoUpdateTime:milli;"`
 }
@@ -0,0 +1,25 @@
+package models
+
+import "github.com/google/uuid"
+
+const (
+	DiscrepancyTokenMissingOnIMX   = "token_missing_on_imx"
+	DiscrepancyTokenMissingLocally = "token_missing_locally"
+	DiscrepancyTokenStatus         = "token_status"
+	DiscrepancyTokenOwner          = "token_owner"
+	DiscrepancyOrderMissingOnIMX   = "order_missing_on_imx"
+	DiscrepancyOrderMissingLocally = "order_missing_locally"
+	DiscrepancyOrderStatus         = "order_status"
+)
+
+// Discrepancy is a difference found between our tables and IMX when reconciling a collection.
+// Resolved discrepancies were fixed automatically by updating our tables to match IMX.
+type Discrepancy struct {
+	ID           uuid.UUID `json:"id" gorm:"type:uuid;primary_key;"`
+	CollectionID uuid.UUID `json:"collection_id" gorm:"type:uuid;not null;"`
+	Kind         string    `json:"kind" gorm:"not null;"`
+	Reference    string    `json:"reference" gorm:"not null;"`
+	Details      string    `json:"details" gorm:"null;"`
+	Resolved     bool      `json:"resolved" gorm:"not null;default:false;"`
+	CreatedAt    int64     `json:"created_at" gorm:"autoCreateTime:milli;"`
+}
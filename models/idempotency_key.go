@@ -0,0 +1,15 @@
+package models
+
+import "github.com/google/uuid"
+
+type IdempotencyKey struct {
+	ClientID     uuid.UUID `json:"client_id" gorm:"type:uuid;primary_key;"`
+	Key          string    `json:"key" gorm:"primary_key;"`
+	RequestHash  string    `json:"-" gorm:"not null;"`
+	Completed    bool      `json:"completed" gorm:"not null;default:false;"`
+	StatusCode   int       `json:"-" gorm:"null;"`
+	ContentType  string    `json:"-" gorm:"null;"`
+	ResponseBody []byte    `json:"-" gorm:"null;"`
+	CreatedAt    int64     `json:"-" gorm:"autoCreateTime:milli;"`
+	UpdatedAt    int64     `json:"-" gorm:"autoUpdateTime:milli;"`
+}
@@ -0,0 +1,19 @@
+package models
+
+import "github.com/google/uuid"
+
+// OrderActive is the status of an order when created. Later statuses are the ones reported by IMX.
+const OrderActive = "active"
+
+type Order struct {
+	ID           uuid.UUID `json:"id" gorm:"type:uuid;primary_key;"`
+	CollectionID uuid.UUID `json:"collection_id" gorm:"type:uuid;not null;"`
+	TokenID      uuid.UUID `json:"token_id" gorm:"type:uuid;not null;"`
+	OrderID      int32     `json:"order_id" gorm:"not null;"`
+	Amount       string    `json:"amount" gorm:"not null;"`
+	Status       string    `json:"status" gorm:"not null;"`
+	CreatedAt    int64     `json:"-" gorm:"autoCreateTime:milli;"`
+	UpdatedAt    int64     `json:"-" gorm:"autoUpdateTime:milli;"`
+	// UserID is the user who listed the token, notified when the order is filled.
+	UserID uuid.UUID `json:"-" gorm:"type:uuid;null;"`
+}
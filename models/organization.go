@@ -0,0 +1,44 @@
+package models
+
+import "github.com/google/uuid"
+
+type Role string
+
+const (
+	RoleViewer Role = "viewer"
+	RoleMinter Role = "minter"
+	RoleOwner  Role = "owner"
+)
+
+var roleRanks = map[Role]int{
+	RoleViewer: 1,
+	RoleMinter: 2,
+	RoleOwner:  3,
+}
+
+// IsValid reports whether the role is one of the known organization roles.
+func (r Role) IsValid() bool {
+	_, ok := roleRanks[r]
+	return ok
+}
+
+// Includes reports whether the role grants at least the permissions of the given role.
+// Owners can do everything minters can, and minters everything viewers can.
+func (r Role) Includes(other Role) bool {
+	return r.IsValid() && roleRanks[r] >= roleRanks[other]
+}
+
+type Organization struct {
+	ID        uuid.UUID `json:"id" gorm:"type:uuid;primary_key;"`
+	Name      string    `json:"name" gorm:"not null;"`
+	CreatedAt int64     `json:"-" gorm:"autoCreateTime:milli;"`
+	UpdatedAt int64     `json:"-" gorm:"autoUpdateTime:milli;"`
+}
+
+type OrganizationMember struct {
+	OrganizationID uuid.UUID `json:"organization_id" gorm:"type:uuid;primary_key;"`
+	UserID         uuid.UUID `json:"user_id" gorm:"type:uuid;primary_key;"`
+	Role           Role      `json:"role" gorm:"not null;"`
+	CreatedAt      int64     `json:"-" gorm:"autoCreateTime:milli;"`
+	UpdatedAt      int64     `json:"-" gorm:"autoUpdateTime:milli;"`
+}
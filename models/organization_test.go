@@ -0,0 +1,24 @@
+package models
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type UnitTestSuite struct {
+	suite.Suite
+}
+
+func (s *UnitTestSuite) TestRoleIncludes() {
+	s.Assertions.True(RoleOwner.Includes(RoleMinter))
+	s.Assertions.True(RoleOwner.Includes(RoleViewer))
+	s.Assertions.True(RoleMinter.Includes(RoleMinter))
+	s.Assertions.False(RoleMinter.Includes(RoleOwner))
+	s.Assertions.False(RoleViewer.Includes(RoleMinter))
+	s.Assertions.False(Role("admin").Includes(RoleViewer))
+}
+
+func TestUnitTestSuite(t *testing.T) {
+	suite.Run(t, new(UnitTestSuite))
+}
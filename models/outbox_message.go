@@ -0,0 +1,23 @@
+package models
+
+import "github.com/google/uuid"
+
+// OutboxMessage is a task waiting to be enqueued. It is saved in the same transaction as the
+// rows the task works on, so the task is enqueued if and only if those rows were saved.
+type OutboxMessage struct {
+	ID               uuid.UUID `json:"id" gorm:"type:uuid;primary_key;"`
+	TaskType         string    `json:"task_type" gorm:"not null;"`
+	Payload          []byte    `json:"-" gorm:"not null;"`
+	TaskID           string    `json:"task_id" gorm:"null;"`
+	ProcessAt        int64     `json:"process_at" gorm:"not null;default:0;"`
+	RetentionSeconds int64     `json:"-" gorm:"not null;default:0;"`
+	MaxRetry         int       `json:"-" gorm:"not null;default:0;"`
+	SentAt           *int64    `json:"sent_at" gorm:"null;"`
+	CreatedAt        int64     `json:"-" gorm:"autoCreateTime:milli;"`
+	UpdatedAt        int64     `json:"-" gorm:"autoUpdateTime:milli;"`
+	// Attempts counts the failed enqueues, NextAttemptAt is when the relay tries again, or until when a
+	// relay holds the message.
+	Attempts      int    `json:"attempts" gorm:"not null;default:0;"`
+	NextAttemptAt int64  `json:"next_attempt_at" gorm:"not null;default:0;"`
+	LastError     string `json:"last_error" gorm:"null;"`
+}
@@ -0,0 +1,29 @@
+package models
+
+import "github.com/google/uuid"
+
+const (
+	SignatureRequestTrade      = "trade"
+	SignatureRequestWithdrawal = "withdrawal"
+
+	SignatureRequestPending    = "pending"
+	SignatureRequestSubmitting = "submitting"
+	SignatureRequestSubmitted  = "submitted"
+)
+
+type SignatureRequest struct {
+	ID              uuid.UUID `json:"id" gorm:"type:uuid;primary_key;"`
+	UserID          uuid.UUID `json:"user_id" gorm:"type:uuid;not null;"`
+	Type            string    `json:"type" gorm:"not null;"`
+	Status          string    `json:"status" gorm:"not null;"`
+	OrderID         int32     `json:"-" gorm:"null;"`
+	SignableMessage string    `json:"signable_message" gorm:"not null;"`
+	PayloadHash     string    `json:"payload_hash" gorm:"not null;"`
+	Payload         string    `json:"-" gorm:"not null;"`
+	CreatedAt       int64     `json:"-" gorm:"autoCreateTime:milli;"`
+	UpdatedAt       int64     `json:"-" gorm:"autoUpdateTime:milli;"`
+	// ExpiresAt is when the request can no longer be submitted, in unix milliseconds.
+	ExpiresAt int64 `json:"expires_at" gorm:"not null;default:0;"`
+	// AmountWei is the amount of a withdrawal, saved with the withdrawal once submitted.
+	AmountWei string `json:"-" gorm:"null;"`
+}
@@ -2,10 +2,20 @@ package models
 
 import "github.com/google/uuid"
 
+const (
+	TokenPending = "pending"
+	TokenMinted  = "minted"
+	TokenFailed  = "failed"
+)
+
 type Token struct {
 	ID           uuid.UUID `json:"id" gorm:"type:uuid;primary_key;"`
-	CollectionID uuid.UUID `json:"collection_id" gorm:"type:uuid;not null;"`
-	TokenID      string    `json:"token_id" gorm:"not null;"`
+	CollectionID uuid.UUID `json:"collection_id" gorm:"type:uuid;not null;uniqueIndex:tokens_collection_id_token_id_key;"`
+	TokenID      string    `json:"token_id" gorm:"not null;uniqueIndex:tokens_collection_id_token_id_key;"`
+	Blueprint    string    `json:"-" gorm:"null;"`
+	Status       string    `json:"status" gorm:"not null;default:minted;"`
 	CreatedAt    int64     `json:"-" gorm:"autoCreateTime:milli;"`
 	UpdatedAt    int64     `json:"-" gorm:"autoUpdateTime:milli;"`
+	// Owner is the address owning the token on IMX, as of its last transfer or reconciliation.
+	Owner string `json:"owner,omitempty" gorm:"null;"`
 }
@@ -10,6 +10,15 @@ type User struct {
 	Public    string    `json:"public" gorm:"not null;"`
 	Address   string    `json:"address" gorm:"not null;"`
 	StarkKey  string    `json:"-" gorm:"null;"`
+	External  bool      `json:"external" gorm:"not null;default:false;"`
+	Admin     bool      `json:"-" gorm:"not null;default:false;"`
+	Disabled  bool      `json:"-" gorm:"not null;default:false;"`
 	CreatedAt int64     `json:"-" gorm:"autoCreateTime:milli;"`
 	UpdatedAt int64     `json:"-" gorm:"autoUpdateTime:milli;"`
+	// AdminKeyHash is the SHA-256 of the key admins authenticate with to get the admin scope, their api key
+	// does not grant it.
+	AdminKeyHash string `json:"-" gorm:"null;"`
+	// StarkPublicKey is the stark key external users registered on IMX with. StarkKey is the private key of the
+	// other users, and is empty for external ones.
+	StarkPublicKey string `json:"stark_public_key,omitempty" gorm:"null;"`
 }
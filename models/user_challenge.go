@@ -0,0 +1,13 @@
+package models
+
+import "github.com/google/uuid"
+
+// UserChallenge is the message an external user signs with their wallet to prove they own the address they
+// register. It is used once.
+type UserChallenge struct {
+	ID        uuid.UUID `json:"challenge_id" gorm:"type:uuid;primary_key;"`
+	Address   string    `json:"address" gorm:"not null;"`
+	Message   string    `json:"message" gorm:"not null;"`
+	ExpiresAt int64     `json:"expires_at" gorm:"not null;"`
+	CreatedAt int64     `json:"-" gorm:"autoCreateTime:milli;"`
+}
@@ -0,0 +1,43 @@
+package models
+
+import (
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+type Webhook struct {
+	ID        uuid.UUID `json:"id" gorm:"type:uuid;primary_key;"`
+	UserID    uuid.UUID `json:"user_id" gorm:"type:uuid;not null;"`
+	URL       string    `json:"url" gorm:"not null;"`
+	Secret    string    `json:"-" gorm:"not null;"`
+	Events    string    `json:"-" gorm:"not null;"`
+	CreatedAt int64     `json:"-" gorm:"autoCreateTime:milli;"`
+	UpdatedAt int64     `json:"-" gorm:"autoUpdateTime:milli;"`
+}
+
+// EventList returns the subscribed event types, stored comma separated.
+func (w *Webhook) EventList() []string {
+	return strings.Split(w.Events, ",")
+}
+
+func (w *Webhook) Subscribes(eventType string) bool {
+	for _, event := range w.EventList() {
+		if event == eventType {
+			return true
+		}
+	}
+	return false
+}
+
+type WebhookDelivery struct {
+	ID         uuid.UUID `json:"id" gorm:"type:uuid;primary_key;"`
+	WebhookID  uuid.UUID `json:"webhook_id" gorm:"type:uuid;not null;"`
+	EventID    uuid.UUID `json:"event_id" gorm:"type:uuid;not null;"`
+	Event      string    `json:"event" gorm:"not null;"`
+	Attempt    int       `json:"attempt" gorm:"not null;"`
+	StatusCode int       `json:"status_code" gorm:"null;"`
+	Error      string    `json:"error,omitempty" gorm:"null;"`
+	Success    bool      `json:"success" gorm:"not null;default:false;"`
+	CreatedAt  int64     `json:"created_at" gorm:"autoCreateTime:milli;"`
+}
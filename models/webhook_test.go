@@ -0,0 +1,9 @@
+package models
+
+func (s *UnitTestSuite) TestWebhookSubscribes() {
+	webhook := Webhook{Events: "token.minted,order.created"}
+	s.Assertions.True(webhook.Subscribes("token.minted"))
+	s.Assertions.True(webhook.Subscribes("order.created"))
+	s.Assertions.False(webhook.Subscribes("trade.completed"))
+	s.Assertions.False(webhook.Subscribes("token"))
+}
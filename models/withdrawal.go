@@ -0,0 +1,23 @@
+package models
+
+import "github.com/google/uuid"
+
+const (
+	// WithdrawalCreating is a withdrawal saved before it is created on IMX, until its IMX ID is saved.
+	WithdrawalCreating  = "creating"
+	WithdrawalPending   = "pending"
+	WithdrawalCompleted = "completed"
+	// WithdrawalAwaitingUser is a withdrawal of an external user, who completes it on L1 with their own wallet.
+	WithdrawalAwaitingUser = "awaiting_user"
+)
+
+type Withdrawal struct {
+	ID     uuid.UUID `json:"id" gorm:"type:uuid;primary_key;"`
+	UserID uuid.UUID `json:"user_id" gorm:"type:uuid;not null;"`
+	// WithdrawalID is the IMX ID of the withdrawal, nil while it is being created.
+	WithdrawalID *int32 `json:"withdrawal_id" gorm:"null;"`
+	AmountWei    string `json:"amount_wei" gorm:"not null;"`
+	Status       string `json:"status" gorm:"not null;"`
+	CreatedAt    int64  `json:"-" gorm:"autoCreateTime:milli;"`
+	UpdatedAt    int64  `json:"-" gorm:"autoUpdateTime:milli;"`
+}
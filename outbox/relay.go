@@ -0,0 +1,156 @@
+package outbox
+
+import (
+	"context"
+	"errors"
+	"nft/db"
+	"nft/models"
+	"nft/tracing"
+	"time"
+
+	"github.com/hibiken/asynq"
+	"golang.org/x/exp/slog"
+)
+
+const (
+	batchSize = 100
+
+	// claimDuration is how long a relay holds the messages it claimed, before another one may claim them.
+	claimDuration = time.Minute
+	// maxBackoff bounds the delay before enqueuing a failed message again.
+	maxBackoff = 5 * time.Minute
+
+	// sentRetention is how long sent messages are kept, to look into what was enqueued.
+	sentRetention = 7 * 24 * time.Hour
+	pruneInterval = time.Hour
+)
+
+// Relay enqueues the tasks saved in the outbox and marks them as sent.
+//
+// A message is sent at least once: if the relay stops between enqueuing a task and marking it,
+// it is enqueued again once its claim expires. Messages are enqueued with their ID as task ID (unless
+// they set their own), so asynq rejects the duplicate while the first task is still retained.
+//
+// Several relays may run at once, each claims its own messages. A message failing to enqueue is retried
+// with backoff without holding the others back, so messages are not always enqueued in order.
+type Relay struct {
+	db        *db.DB
+	client    *asynq.Client
+	interval  time.Duration
+	lastPrune time.Time
+}
+
+func NewRelay(db *db.DB, client *asynq.Client, interval time.Duration) *Relay {
+	return &Relay{db: db, client: client, interval: interval}
+}
+
+// Run relays the outbox every interval until the context is done.
+func (r *Relay) Run(ctx context.Context) {
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+
+	for {
+		if err := r.RelayPending(ctx); err != nil {
+			slog.ErrorContext(ctx, "error relaying outbox", "err", err)
+		}
+
+		if time.Since(r.lastPrune) >= pruneInterval {
+			r.prune(ctx)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// RelayPending enqueues the messages due. Only failing to read or update the outbox is an error, messages
+// failing to enqueue are recorded and tried again later.
+func (r *Relay) RelayPending(ctx context.Context) error {
+	for ctx.Err() == nil {
+		now := time.Now()
+		messages, err := r.db.ClaimOutboxMessages(batchSize, now.UnixMilli(), now.Add(claimDuration).UnixMilli())
+		if err != nil {
+			return err
+		}
+
+		for i := range messages {
+			if err = r.send(ctx, &messages[i]); err != nil {
+				return err
+			}
+		}
+
+		if len(messages) < batchSize {
+			return nil
+		}
+	}
+
+	return nil
+}
+
+func (r *Relay) send(ctx context.Context, message *models.OutboxMessage) error {
+	task, opts := NewTask(message)
+	info, err := tracing.Enqueue(ctx, r.client, task, opts...)
+	if err != nil && !errors.Is(err, asynq.ErrTaskIDConflict) {
+		slog.ErrorContext(ctx, "error enqueuing outbox message", "messageID", message.ID, "attempts", message.Attempts+1, "err", err)
+		nextAttemptAt := time.Now().Add(backoff(message.Attempts + 1)).UnixMilli()
+		return r.db.SetOutboxMessageFailed(message.ID, nextAttemptAt, err.Error())
+	}
+
+	if info != nil {
+		slog.DebugContext(ctx, "Task enqueued", "taskID", info.ID, "messageID", message.ID)
+	}
+
+	return r.db.SetOutboxMessageSent(message.ID, time.Now().UnixMilli())
+}
+
+// prune deletes the messages sent more than sentRetention ago.
+func (r *Relay) prune(ctx context.Context) {
+	r.lastPrune = time.Now()
+
+	deleted, err := r.db.DeleteSentOutboxMessages(r.lastPrune.Add(-sentRetention).UnixMilli())
+	if err != nil {
+		slog.ErrorContext(ctx, "error pruning outbox", "err", err)
+		return
+	}
+
+	if deleted > 0 {
+		slog.InfoContext(ctx, "outbox pruned", "deleted", deleted)
+	}
+}
+
+// backoff returns the delay before enqueuing again a message that failed attempts times.
+func backoff(attempts int) time.Duration {
+	if attempts > 9 {
+		return maxBackoff
+	}
+
+	delay := time.Second << attempts
+	if delay > maxBackoff {
+		return maxBackoff
+	}
+	return delay
+}
+
+// NewTask builds the asynq task stored in the message.
+func NewTask(message *models.OutboxMessage) (*asynq.Task, []asynq.Option) {
+	taskID := message.TaskID
+	if len(taskID) == 0 {
+		taskID = message.ID.String()
+	}
+
+	opts := []asynq.Option{asynq.TaskID(taskID)}
+	if message.ProcessAt > 0 {
+		opts = append(opts, asynq.ProcessAt(time.UnixMilli(message.ProcessAt)))
+	}
+	if message.MaxRetry > 0 {
+		opts = append(opts, asynq.MaxRetry(message.MaxRetry))
+	}
+	if message.RetentionSeconds > 0 {
+		opts = append(opts, asynq.Retention(time.Second*time.Duration(message.RetentionSeconds)))
+	}
+
+	return asynq.NewTask(message.TaskType, message.Payload), opts
+}
@@ -0,0 +1,65 @@
+package outbox
+
+import (
+	"nft/models"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/hibiken/asynq"
+	"github.com/stretchr/testify/suite"
+)
+
+type UnitTestSuite struct {
+	suite.Suite
+}
+
+func optionValues(opts []asynq.Option) map[asynq.OptionType]interface{} {
+	values := map[asynq.OptionType]interface{}{}
+	for _, opt := range opts {
+		values[opt.Type()] = opt.Value()
+	}
+	return values
+}
+
+func (s *UnitTestSuite) TestNewTaskUsesMessageID() {
+	message := &models.OutboxMessage{ID: uuid.New(), TaskType: "withdrawal:complete", Payload: []byte("{}")}
+
+	task, opts := NewTask(message)
+	s.Assertions.Equal("withdrawal:complete", task.Type())
+	s.Assertions.Equal([]byte("{}"), task.Payload())
+
+	values := optionValues(opts)
+	s.Assertions.Equal(message.ID.String(), values[asynq.TaskIDOpt])
+	s.Assertions.NotContains(values, asynq.ProcessAtOpt)
+	s.Assertions.NotContains(values, asynq.RetentionOpt)
+}
+
+func (s *UnitTestSuite) TestNewTaskWithOptions() {
+	processAt := time.Now().Add(time.Hour).Truncate(time.Millisecond)
+	message := &models.OutboxMessage{
+		ID:               uuid.New(),
+		TaskType:         "token:mint",
+		Payload:          []byte("{}"),
+		TaskID:           "token",
+		ProcessAt:        processAt.UnixMilli(),
+		RetentionSeconds: 60,
+	}
+
+	_, opts := NewTask(message)
+	values := optionValues(opts)
+	s.Assertions.Equal("token", values[asynq.TaskIDOpt])
+	s.Assertions.True(processAt.Equal(values[asynq.ProcessAtOpt].(time.Time)))
+	s.Assertions.Equal(time.Minute, values[asynq.RetentionOpt])
+}
+
+func (s *UnitTestSuite) TestBackoff() {
+	s.Assertions.Equal(2*time.Second, backoff(1))
+	s.Assertions.Equal(8*time.Second, backoff(3))
+	s.Assertions.Equal(maxBackoff, backoff(9))
+	s.Assertions.Equal(maxBackoff, backoff(100))
+}
+
+func TestUnitTestSuite(t *testing.T) {
+	suite.Run(t, new(UnitTestSuite))
+}
@@ -0,0 +1,159 @@
+package ratelimit
+
+import (
+	"context"
+	"math"
+	"net/http"
+	"nft/auth"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"golang.org/x/exp/slog"
+)
+
+// Limit allows Requests every Period for each client. Route restricts it to requests matching
+// "METHOD /path" (the path also matches its sub paths) and Scope to tokens granted that scope.
+// Empty Route or Scope match every request.
+type Limit struct {
+	Route    string
+	Scope    string
+	Requests int
+	Period   time.Duration
+}
+
+// tokenBucket refills the bucket in KEYS[1] according to the elapsed time and takes a token from it.
+// It returns whether the request is allowed and, when it is not, the milliseconds until a token is available.
+var tokenBucket = redis.NewScript(`
+local capacity = tonumber(ARGV[1])
+local rate = tonumber(ARGV[2])
+local now = tonumber(ARGV[3])
+
+local bucket = redis.call("HMGET", KEYS[1], "tokens", "timestamp")
+local tokens = tonumber(bucket[1]) or capacity
+local timestamp = tonumber(bucket[2]) or now
+
+tokens = math.min(capacity, tokens + math.max(0, now - timestamp) * rate)
+
+local allowed = 0
+local retry = 0
+if tokens >= 1 then
+	tokens = tokens - 1
+	allowed = 1
+else
+	retry = math.ceil((1 - tokens) / rate)
+end
+
+redis.call("HSET", KEYS[1], "tokens", tostring(tokens), "timestamp", now)
+redis.call("PEXPIRE", KEYS[1], math.ceil(capacity / rate))
+return {allowed, retry}
+`)
+
+// Limiter is a Redis backed token bucket rate limiter keyed by the authenticated OAuth client.
+type Limiter struct {
+	client *redis.Client
+	limits []Limit
+}
+
+func NewLimiter(client *redis.Client, limits []Limit) *Limiter {
+	return &Limiter{client, limits}
+}
+
+// Limit is a middleware rejecting requests over the matching limit with 429 Too Many Requests.
+// It must run after authorization. Redis failures let the request through, so an outage of
+// the limiter does not take the API down with it.
+func (l *Limiter) Limit(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		clientID, err := auth.GetUserID(r.Context())
+		if err != nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		limit, ok := l.match(r)
+		if !ok {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		key := "ratelimit:" + clientID.String() + ":" + limit.Route + ":" + limit.Scope
+		allowed, retryAfter, err := l.take(r.Context(), key, limit)
+		if err != nil {
+			slog.ErrorContext(r.Context(), "error applying rate limit", "err", err)
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		if !allowed {
+			seconds := int(math.Ceil(retryAfter.Seconds()))
+			w.Header().Set("Retry-After", strconv.Itoa(seconds))
+			http.Error(w, http.StatusText(http.StatusTooManyRequests), http.StatusTooManyRequests)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (l *Limiter) take(ctx context.Context, key string, limit *Limit) (bool, time.Duration, error) {
+	// tokens refilled per millisecond
+	rate := float64(limit.Requests) / float64(limit.Period.Milliseconds())
+	now := time.Now().UnixMilli()
+
+	result, err := tokenBucket.Run(ctx, l.client, []string{key}, limit.Requests, rate, now).Int64Slice()
+	if err != nil {
+		return false, 0, err
+	}
+
+	return result[0] == 1, time.Duration(result[1]) * time.Millisecond, nil
+}
+
+// match returns the most specific limit for the request: one matching both route and scope wins
+// over one matching only the route, which wins over one matching only the scope.
+func (l *Limiter) match(r *http.Request) (*Limit, bool) {
+	var best *Limit
+	bestScore := -1
+	for i := range l.limits {
+		limit := &l.limits[i]
+		if limit.Requests <= 0 || limit.Period <= 0 {
+			continue
+		}
+
+		score := 0
+		if len(limit.Route) > 0 {
+			if !matchRoute(limit.Route, r) {
+				continue
+			}
+			score += 2
+		}
+
+		if len(limit.Scope) > 0 {
+			if !auth.HasScope(r.Context(), limit.Scope) {
+				continue
+			}
+			score++
+		}
+
+		if score > bestScore {
+			best, bestScore = limit, score
+		}
+	}
+
+	return best, best != nil
+}
+
+func matchRoute(route string, r *http.Request) bool {
+	method, path, found := strings.Cut(route, " ")
+	if !found {
+		method, path = "", route
+	}
+
+	if len(method) > 0 && !strings.EqualFold(method, r.Method) {
+		return false
+	}
+
+	path = strings.TrimSuffix(path, "/")
+	requestPath := strings.TrimSuffix(r.URL.Path, "/")
+	return requestPath == path || strings.HasPrefix(requestPath, path+"/")
+}
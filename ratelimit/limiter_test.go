@@ -0,0 +1,78 @@
+package ratelimit
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/go-chi/oauth"
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/suite"
+)
+
+type UnitTestSuite struct {
+	suite.Suite
+}
+
+func newRequest(method string, path string, scope string) *http.Request {
+	req := httptest.NewRequest(method, path, nil)
+	ctx := context.WithValue(req.Context(), oauth.CredentialContext, uuid.NewString())
+	ctx = context.WithValue(ctx, oauth.ScopeContext, scope)
+	return req.WithContext(ctx)
+}
+
+func (s *UnitTestSuite) TestMatchMostSpecificLimit() {
+	limiter := NewLimiter(nil, []Limit{
+		{Requests: 60, Period: time.Minute},
+		{Route: "POST /tokens", Requests: 10, Period: time.Minute},
+		{Scope: "admin", Requests: 600, Period: time.Minute},
+		{Route: "POST /tokens", Scope: "admin", Requests: 100, Period: time.Minute},
+	})
+
+	limit, ok := limiter.match(newRequest("POST", "/tokens/", ""))
+	s.Assertions.True(ok)
+	s.Assertions.Equal(10, limit.Requests)
+
+	limit, ok = limiter.match(newRequest("POST", "/tokens", "admin"))
+	s.Assertions.True(ok)
+	s.Assertions.Equal(100, limit.Requests)
+
+	limit, ok = limiter.match(newRequest("GET", "/tokens", "admin"))
+	s.Assertions.True(ok)
+	s.Assertions.Equal(600, limit.Requests)
+
+	limit, ok = limiter.match(newRequest("POST", "/orders", ""))
+	s.Assertions.True(ok)
+	s.Assertions.Equal(60, limit.Requests)
+}
+
+func (s *UnitTestSuite) TestMatchRouteSubPaths() {
+	limiter := NewLimiter(nil, []Limit{
+		{Route: "POST /trades", Requests: 10, Period: time.Minute},
+	})
+
+	_, ok := limiter.match(newRequest("POST", "/trades/1234/signatures", ""))
+	s.Assertions.True(ok)
+
+	_, ok = limiter.match(newRequest("POST", "/tradesx", ""))
+	s.Assertions.False(ok)
+}
+
+func (s *UnitTestSuite) TestLimitFailsOpenWithoutRedis() {
+	client := redis.NewClient(&redis.Options{Addr: "127.0.0.1:1", MaxRetries: -1})
+	limiter := NewLimiter(client, []Limit{{Requests: 1, Period: time.Minute}})
+	handler := limiter.Limit(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusCreated)
+	}))
+
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, newRequest("POST", "/tokens", ""))
+	s.Assertions.Equal(http.StatusCreated, rr.Code)
+}
+
+func TestUnitTestSuite(t *testing.T) {
+	suite.Run(t, new(UnitTestSuite))
+}
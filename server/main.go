@@ -1,11 +1,20 @@
 package server
 
 import (
+	"context"
+	"nft/audit"
 	"nft/auth"
 	"nft/config"
 	"nft/db"
+	"nft/events"
 	"nft/handlers"
+	"nft/health"
+	"nft/idempotency"
 	"nft/imx"
+	"nft/logging"
+	"nft/metrics"
+	"nft/ratelimit"
+	"nft/tracing"
 	"time"
 
 	"github.com/hibiken/asynq"
@@ -14,28 +23,48 @@ import (
 	"github.com/go-chi/chi/v5/middleware"
 	"github.com/go-chi/oauth"
 	"github.com/go-chi/render"
+	"github.com/redis/go-redis/v9"
 )
 
+// imxCheckTTL is how long the result of the IMX check is reused, as it calls both the L1 RPC and IMX.
+const imxCheckTTL = 10 * time.Second
+
 type Server struct {
 	Router      *chi.Mux
 	config      *config.Settings
 	db          *db.DB
 	imx         imx.Client
 	asynqClient *asynq.Client
+	metrics     *metrics.Metrics
+	stream      *events.Stream
+}
+
+func NewServer(config *config.Settings, db *db.DB, imx imx.Client, asynqClient *asynq.Client, metrics *metrics.Metrics) *Server {
+	return &Server{Router: chi.NewRouter(), config: config, db: db, imx: imx, asynqClient: asynqClient, metrics: metrics}
 }
 
-func NewServer(config *config.Settings, db *db.DB, imx imx.Client, asynqClient *asynq.Client) *Server {
-	return &Server{chi.NewRouter(), config, db, imx, asynqClient}
+// CloseStreams ends the open event streams. http.Server.Shutdown waits for the active requests without
+// canceling them, streams would hold it until its deadline.
+func (s *Server) CloseStreams() {
+	if s.stream != nil {
+		s.stream.Close()
+	}
 }
 
 func (s *Server) Configure() {
 	s.Router.Use(middleware.RequestID)
-	s.Router.Use(middleware.Logger)
+	s.Router.Use(tracing.Middleware)
+	s.Router.Use(s.metrics.Middleware)
+	s.Router.Use(logging.Middleware)
 	s.Router.Use(middleware.Recoverer)
 	s.Router.Use(middleware.URLFormat)
 	s.Router.Use(render.SetContentType(render.ContentTypeJSON))
 
-	newHandler := handlers.NewHandler(s.db, s.imx, s.asynqClient)
+	redisClient := redis.NewClient(&redis.Options{Addr: s.config.RedisUrl})
+	inspector := asynq.NewInspector(asynq.RedisClientOpt{Addr: s.config.RedisUrl})
+	s.stream = events.NewStream(redisClient)
+	newHandler := handlers.NewHandler(s.db, s.imx, s.asynqClient, inspector, s.stream)
+	adminHandler := handlers.NewAdminHandler(s.db, inspector, s.asynqClient)
 
 	bearerServer := oauth.NewBearerServer(
 		s.config.AuthSecret,
@@ -43,16 +72,48 @@ func (s *Server) Configure() {
 		auth.NewUserVerifier(s.db),
 		nil)
 	s.Router.Post("/auth", bearerServer.ClientCredentials)
+	s.routeProbes(redisClient, inspector)
+
+	// the chain is only unknown with an invalid environment, which fails the startup before serving
+	environment, _ := s.config.IMXSettings().ResolveEnvironment()
+	var chainID int64
+	if environment.ChainID != nil {
+		chainID = environment.ChainID.Int64()
+	}
+	s.Router.Get("/version", handlers.Version(s.config.IMXEnvironment, chainID))
+
+	auditRecorder := audit.NewRecorder(s.db)
 
 	s.Router.Route("/users", func(r chi.Router) {
+		r.Use(auditRecorder.Middleware)
 		r.Post("/", newHandler.CreateUser)
+		r.Post("/external", newHandler.CreateExternalUser)
+		r.Post("/external/challenges", newHandler.CreateUserChallenge)
 	})
 
 	s.Router.Group(func(r chi.Router) {
-		if !s.config.DebugMode {
-			r.Use(oauth.Authorize(s.config.AuthSecret, nil))
+		authorize := oauth.Authorize(s.config.AuthSecret, nil)
+		if s.config.DebugAuth {
+			authorize = auth.NewDebugAuthenticator(s.db).Authorize(authorize)
+		}
+		r.Use(authorize)
+		r.Use(auth.RequireActiveUser(s.db))
+		r.Use(auth.LogUser)
+
+		if s.config.RateLimitEnabled {
+			r.Use(s.newRateLimiter(redisClient).Limit)
 		}
 
+		r.Use(idempotency.NewMiddleware(s.db).Handle)
+		// after idempotency, replayed requests did nothing
+		r.Use(auditRecorder.Middleware)
+
+		r.Route("/organizations", func(r chi.Router) {
+			r.Post("/", newHandler.CreateOrganization)
+			r.Put("/{organizationID}/members", newHandler.SaveOrganizationMember)
+			r.Delete("/{organizationID}/members/{userID}", newHandler.DeleteOrganizationMember)
+		})
+
 		r.Route("/collections", func(r chi.Router) {
 			r.Post("/", newHandler.CreateCollection)
 		})
@@ -61,6 +122,21 @@ func (s *Server) Configure() {
 			r.Post("/", newHandler.CreateToken)
 		})
 
+		r.Route("/webhooks", func(r chi.Router) {
+			r.Post("/", newHandler.CreateWebhook)
+			r.Get("/", newHandler.ListWebhooks)
+			r.Delete("/{webhookID}", newHandler.DeleteWebhook)
+			r.Get("/{webhookID}/deliveries", newHandler.ListWebhookDeliveries)
+		})
+
+		r.Route("/events", func(r chi.Router) {
+			r.Get("/stream", newHandler.StreamEvents)
+		})
+
+		r.Route("/jobs", func(r chi.Router) {
+			r.Get("/{jobID}", newHandler.GetJob)
+		})
+
 		r.Route("/transfers", func(r chi.Router) {
 			r.Post("/", newHandler.TransferToken)
 		})
@@ -75,10 +151,78 @@ func (s *Server) Configure() {
 
 		r.Route("/trades", func(r chi.Router) {
 			r.Post("/", newHandler.CreateTrade)
+			r.Post("/{requestID}/signatures", newHandler.SubmitTrade)
 		})
 
 		r.Route("/withdrawals", func(r chi.Router) {
 			r.Post("/", newHandler.CreateWithdrawal)
+			r.Post("/{requestID}/signatures", newHandler.SubmitWithdrawal)
+		})
+
+		r.Route("/admin", func(r chi.Router) {
+			r.Use(auth.RequireScope(auth.AdminScope))
+			r.Get("/users", adminHandler.ListUsers)
+			r.Post("/users/{userID}/disable", adminHandler.DisableUser)
+			r.Post("/collections/{collectionID}/hide", adminHandler.HideCollection)
+			r.Post("/collections/{collectionID}/reconcile", adminHandler.ReconcileCollection)
+			r.Get("/discrepancies", adminHandler.ListDiscrepancies)
+			r.Get("/audit", adminHandler.ListAuditEvents)
+			r.Get("/withdrawals/pending", adminHandler.ListPendingWithdrawals)
+			r.Get("/tasks/failed", adminHandler.ListFailedTasks)
+			r.Post("/tasks/{taskID}/requeue", adminHandler.RequeueTask)
 		})
 	})
 }
+
+// ConfigureWorker only serves the metrics and the probes, for the processes running the workers without
+// the API.
+func (s *Server) ConfigureWorker() {
+	s.Router.Use(middleware.RequestID)
+	s.Router.Use(s.metrics.Middleware)
+	s.Router.Use(middleware.Recoverer)
+	s.Router.Use(render.SetContentType(render.ContentTypeJSON))
+
+	redisClient := redis.NewClient(&redis.Options{Addr: s.config.RedisUrl})
+	inspector := asynq.NewInspector(asynq.RedisClientOpt{Addr: s.config.RedisUrl})
+	s.routeProbes(redisClient, inspector)
+}
+
+// routeProbes serves the metrics, the liveness probe, the readiness probe checking the dependencies every
+// request needs, and the status of every dependency. IMX and the workers are left out of the readiness
+// probe: the service degrades without them, and an IMX outage must not take every instance out of rotation.
+// /health reports them, without the errors of their checks as it is public.
+func (s *Server) routeProbes(redisClient *redis.Client, inspector *asynq.Inspector) {
+	s.Router.Handle("/metrics", s.metrics.Handler())
+
+	pingRedis := func(ctx context.Context) error {
+		return redisClient.Ping(ctx).Err()
+	}
+
+	checker := health.NewChecker(health.DefaultTimeout)
+	checker.Add("db", s.db.Ping)
+	checker.Add("redis", pingRedis)
+
+	details := health.NewChecker(health.DefaultTimeout)
+	details.Add("db", s.db.Ping)
+	details.Add("redis", pingRedis)
+	details.Add("asynq", health.Workers(inspector))
+	details.Add("imx", health.Cached(s.imx.Ping, imxCheckTTL))
+
+	s.Router.Get("/healthz", health.Live)
+	s.Router.Get("/readyz", checker.Ready)
+	s.Router.Get("/health", details.Details)
+}
+
+func (s *Server) newRateLimiter(redisClient *redis.Client) *ratelimit.Limiter {
+	limits := make([]ratelimit.Limit, 0, len(s.config.RateLimits))
+	for _, l := range s.config.RateLimits {
+		limits = append(limits, ratelimit.Limit{
+			Route:    l.Route,
+			Scope:    l.Scope,
+			Requests: l.Requests,
+			Period:   time.Second * time.Duration(l.PeriodSeconds),
+		})
+	}
+
+	return ratelimit.NewLimiter(redisClient, limits)
+}
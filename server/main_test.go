@@ -3,18 +3,38 @@ package server
 import (
 	"bytes"
 	"context"
+	"crypto/ecdsa"
 	"encoding/json"
+	"math/big"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
+	"nft/auth"
 	"nft/config"
 	"nft/db"
+	"nft/events"
+	"nft/handlers"
+	"nft/idempotency"
+	"nft/imx"
+	"nft/imx/fake"
+	"nft/metrics"
+	"nft/models"
+	"nft/outbox"
+	"nft/tasks"
 	"nft/test"
+	"strconv"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/hibiken/asynq"
 
-	"github.com/go-chi/oauth"
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
 	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
 	"github.com/stretchr/testify/suite"
 )
 
@@ -37,6 +57,7 @@ type UnitTestSuite struct {
 	db         *db.DB
 	migrations *db.Migrations
 	server     *Server
+	imx        *fake.IMX
 }
 
 func (s *UnitTestSuite) SetupTest() {
@@ -48,17 +69,79 @@ func (s *UnitTestSuite) SetupTest() {
 	s.db = newDB
 	s.migrations = migrations
 	settings := config.GetConfig()
-	settings.DebugMode = true
+	settings.DebugAuth = true
 	asyncClient := asynq.NewClient(asynq.RedisClientOpt{Addr: settings.RedisUrl})
-	s.server = NewServer(settings, newDB, ImxDummy{}, asyncClient)
+	s.imx = fake.New(fake.PlatformAddress)
+	s.server = NewServer(settings, newDB, s.imx, asyncClient, metrics.New())
 	s.server.Configure()
 }
 
+// createIMXUser saves a dummy user that is registered on IMX.
+func (s *UnitTestSuite) createIMXUser(mail string) *models.User {
+	user := test.CreateDummyUser(uuid.New(), mail)
+	id := uuid.New()
+	user.Address = common.BytesToAddress(id[:]).Hex()
+	err := s.db.CreateUser(user)
+	s.Assertions.Nil(err)
+	_, err = s.imx.CreateUser(context.Background(), user)
+	s.Assertions.Nil(err)
+	return user
+}
+
+// mintOnIMX mints the token of the collection on IMX, owned by the platform.
+func (s *UnitTestSuite) mintOnIMX(collection *models.Collection, token *models.Token) {
+	err := s.imx.CreateCollection(context.Background(), &imx.CollectionInformation{ContractAddress: collection.ContractAddress})
+	s.Assertions.Nil(err)
+	err = s.imx.CreateToken(context.Background(), &imx.MintInformation{ContractAddress: collection.ContractAddress, TokenID: token.TokenID})
+	s.Assertions.Nil(err)
+}
+
+// listOnIMX mints a token on IMX and lists it for amount wei, returning the order ID.
+func (s *UnitTestSuite) listOnIMX(amount uint64) string {
+	collection := test.CreateDummyCollection(uuid.New(), uuid.New(), uuid.New(), "address")
+	token := test.CreateDummyToken(uuid.New(), collection.ID, "1")
+	s.mintOnIMX(collection, token)
+	orderID, err := s.imx.CreateOrder(context.Background(), &imx.OrderInformation{ContractAddress: collection.ContractAddress, TokenID: token.TokenID, Amount: amount})
+	s.Assertions.Nil(err)
+	return strconv.FormatInt(int64(orderID), 10)
+}
+
 func (s *UnitTestSuite) AfterTest(suiteName, testName string) {
 	err := s.migrations.Reset(context.Background())
 	s.Assertions.Nil(err)
 }
 
+func (s *UnitTestSuite) TestRateLimitExceeded() {
+	settings := config.GetConfig()
+	settings.DebugAuth = true
+	settings.RateLimitEnabled = true
+	settings.RateLimits = []config.RateLimitSettings{{Route: "GET /webhooks", Requests: 1, PeriodSeconds: 60}}
+	server := NewServer(settings, s.db, s.imx, asynq.NewClient(asynq.RedisClientOpt{Addr: settings.RedisUrl}), metrics.New())
+	server.Configure()
+
+	user := test.CreateDummyUser(uuid.New(), "test")
+	err := s.db.CreateUser(user)
+	s.Assertions.Nil(err)
+
+	req, _ := http.NewRequest("GET", "/webhooks", nil)
+	req.Header.Set(auth.DebugUserHeader, user.ID.String())
+	response := httptest.NewRecorder()
+	server.Router.ServeHTTP(response, req)
+	s.checkResponseCode(http.StatusOK, response.Code)
+
+	req, _ = http.NewRequest("GET", "/webhooks", nil)
+	req.Header.Set(auth.DebugUserHeader, user.ID.String())
+	response = httptest.NewRecorder()
+	server.Router.ServeHTTP(response, req)
+	s.checkResponseCode(http.StatusTooManyRequests, response.Code)
+
+	// one request a minute, the next one is allowed within the minute
+	retryAfter, err := strconv.Atoi(response.Header().Get("Retry-After"))
+	s.Assertions.Nil(err)
+	s.Assertions.Greater(retryAfter, 0)
+	s.Assertions.LessOrEqual(retryAfter, 60)
+}
+
 func (s *UnitTestSuite) TestCreateUser() {
 	var jsonStr = []byte(`{"mail":"test1@test.com"}`)
 	req, _ := http.NewRequest("POST", "/users", bytes.NewBuffer(jsonStr))
@@ -76,6 +159,124 @@ func (s *UnitTestSuite) TestCreateUser() {
 	s.Assertions.NotEmpty(objMap["address"])
 }
 
+func (s *UnitTestSuite) TestCreateUserTwiceShouldFail() {
+	var jsonStr = []byte(`{"mail":"test1@test.com"}`)
+	req, _ := http.NewRequest("POST", "/users", bytes.NewBuffer(jsonStr))
+	response := s.executeRequest(req)
+	s.checkResponseCode(http.StatusCreated, response.Code)
+
+	// the credentials of an existing user are never returned again
+	req, _ = http.NewRequest("POST", "/users", bytes.NewBuffer(jsonStr))
+	response = s.executeRequest(req)
+	s.checkResponseCode(http.StatusConflict, response.Code)
+	s.Assertions.NotContains(response.Body.String(), "api_key")
+}
+
+// requestToken requests a bearer token with the client credentials and scope, returning the response.
+func (s *UnitTestSuite) requestToken(clientID string, clientSecret string, scope string) *httptest.ResponseRecorder {
+	form := url.Values{
+		"grant_type":    {"client_credentials"},
+		"client_id":     {clientID},
+		"client_secret": {clientSecret},
+		"scope":         {scope},
+	}
+	req, _ := http.NewRequest("POST", "/auth", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	return s.executeRequest(req)
+}
+
+func (s *UnitTestSuite) bearerToken(response *httptest.ResponseRecorder) string {
+	objMap := map[string]interface{}{}
+	err := json.Unmarshal(response.Body.Bytes(), &objMap)
+	s.Assertions.Nil(err)
+	token, _ := objMap["access_token"].(string)
+	s.Assertions.NotEmpty(token)
+	return "Bearer " + token
+}
+
+func (s *UnitTestSuite) TestAdminScopeRequiresAdminKey() {
+	admin := test.CreateDummyUser(uuid.New(), "admin")
+	err := s.db.CreateUser(admin)
+	s.Assertions.Nil(err)
+	adminKey, adminKeyHash, err := auth.NewAdminKey()
+	s.Assertions.Nil(err)
+	err = s.db.SetUserAdmin(admin.ID, adminKeyHash)
+	s.Assertions.Nil(err)
+
+	// the api key only grants the default scope
+	response := s.requestToken(admin.ID.String(), admin.ApiKey, auth.AdminScope)
+	s.checkResponseCode(http.StatusUnauthorized, response.Code)
+
+	response = s.requestToken(admin.ID.String(), adminKey, auth.AdminScope)
+	s.checkResponseCode(http.StatusOK, response.Code)
+	token := s.bearerToken(response)
+
+	req, _ := http.NewRequest("GET", "/admin/users", nil)
+	req.Header.Set("Authorization", token)
+	response = s.executeRequest(req)
+	s.checkResponseCode(http.StatusOK, response.Code)
+
+	// demoted admins lose the scope of the tokens they hold
+	err = s.db.SetUserAdmin(admin.ID, "")
+	s.Assertions.Nil(err)
+	req, _ = http.NewRequest("GET", "/admin/users", nil)
+	req.Header.Set("Authorization", token)
+	response = s.executeRequest(req)
+	s.checkResponseCode(http.StatusForbidden, response.Code)
+}
+
+func (s *UnitTestSuite) TestDisabledUserTokenShouldFail() {
+	user := test.CreateDummyUser(uuid.New(), "test")
+	err := s.db.CreateUser(user)
+	s.Assertions.Nil(err)
+
+	response := s.requestToken(user.ID.String(), user.ApiKey, "")
+	s.checkResponseCode(http.StatusOK, response.Code)
+	token := s.bearerToken(response)
+
+	req, _ := http.NewRequest("GET", "/webhooks", nil)
+	req.Header.Set("Authorization", token)
+	response = s.executeRequest(req)
+	s.checkResponseCode(http.StatusOK, response.Code)
+
+	// tokens issued before the user was disabled stop working
+	err = s.db.SetUserDisabled(user.ID, true)
+	s.Assertions.Nil(err)
+	req, _ = http.NewRequest("GET", "/webhooks", nil)
+	req.Header.Set("Authorization", token)
+	response = s.executeRequest(req)
+	s.checkResponseCode(http.StatusUnauthorized, response.Code)
+}
+
+func (s *UnitTestSuite) TestVersion() {
+	req, _ := http.NewRequest("GET", "/version", nil)
+	response := s.executeRequest(req)
+	s.checkResponseCode(http.StatusOK, response.Code)
+
+	objMap := map[string]interface{}{}
+	err := json.Unmarshal(response.Body.Bytes(), &objMap)
+	s.Assertions.Nil(err)
+	s.Assertions.Equal(s.server.config.IMXEnvironment, objMap["imx_environment"])
+	s.Assertions.NotEmpty(objMap["version"])
+}
+
+func (s *UnitTestSuite) TestMetrics() {
+	req, _ := http.NewRequest("GET", "/version", nil)
+	s.executeRequest(req)
+
+	req, _ = http.NewRequest("GET", "/metrics", nil)
+	response := s.executeRequest(req)
+	s.checkResponseCode(http.StatusOK, response.Code)
+	s.Assertions.Contains(response.Body.String(), `nft_http_request_duration_seconds_count{method="GET",route="/version",status="200"} 1`)
+}
+
+func (s *UnitTestSuite) TestHealthz() {
+	req, _ := http.NewRequest("GET", "/healthz", nil)
+	response := s.executeRequest(req)
+	s.checkResponseCode(http.StatusOK, response.Code)
+	s.Assertions.JSONEq(`{"status":"ok"}`, response.Body.String())
+}
+
 func (s *UnitTestSuite) TestCreateUserWithoutEmailShouldFail() {
 	req, _ := http.NewRequest("POST", "/users", nil)
 	response := s.executeRequest(req)
@@ -83,15 +284,22 @@ func (s *UnitTestSuite) TestCreateUserWithoutEmailShouldFail() {
 }
 
 func (s *UnitTestSuite) TestCreateCollection() {
-	var jsonStr = []byte(`{"contract_address":"0x4958d0B91412eE2b8D715bF9279DCDB68e33d195", "collection_name":"prueba", "metadata_url":"https://gateway.pinata.cloud/ipfs/QmNj8NJwPbNGGv7HtjBii3TH1qa6yTmoJomvGth2rsXXyR", "fields": [ {"name":"name", "type": "text"} ]}`)
+	user := test.CreateDummyUser(uuid.New(), "test")
+	err := s.db.CreateUser(user)
+	s.Assertions.Nil(err)
+
+	organization := test.CreateDummyOrganization(uuid.New())
+	err = s.db.CreateOrganization(organization, user.ID)
+	s.Assertions.Nil(err)
+
+	var jsonStr = []byte(`{"organization_id":"` + organization.ID.String() + `", "contract_address":"0x4958d0B91412eE2b8D715bF9279DCDB68e33d195", "collection_name":"prueba", "metadata_url":"https://gateway.pinata.cloud/ipfs/QmNj8NJwPbNGGv7HtjBii3TH1qa6yTmoJomvGth2rsXXyR", "fields": [ {"name":"name", "type": "text"} ]}`)
 	req, _ := http.NewRequest("POST", "/collections", bytes.NewBuffer(jsonStr))
-	ctx := req.Context()
-	ctx = context.WithValue(ctx, oauth.CredentialContext, uuid.NewString())
-	response := s.executeRequest(req.WithContext(ctx))
+	req.Header.Set(auth.DebugUserHeader, user.ID.String())
+	response := s.executeRequest(req)
 	s.checkResponseCode(http.StatusCreated, response.Code)
 
 	objMap := map[string]string{}
-	err := json.Unmarshal(response.Body.Bytes(), &objMap)
+	err = json.Unmarshal(response.Body.Bytes(), &objMap)
 	s.Assertions.Nil(err)
 	s.Assertions.Equal("0x4958d0B91412eE2b8D715bF9279DCDB68e33d195", objMap["contract_address"])
 	s.Assertions.Equal("prueba", objMap["collection_name"])
@@ -102,42 +310,164 @@ func (s *UnitTestSuite) TestCreateToken() {
 	user := test.CreateDummyUser(uuid.New(), "test")
 	err := s.db.CreateUser(user)
 	s.Assertions.Nil(err)
-	collection := test.CreateDummyCollection(uuid.New(), user.ID, "address")
+	organization := test.CreateDummyOrganization(uuid.New())
+	err = s.db.CreateOrganization(organization, user.ID)
+	s.Assertions.Nil(err)
+	collection := test.CreateDummyCollection(uuid.New(), user.ID, organization.ID, "address")
 	err = s.db.CreateCollection(collection)
 	s.Assertions.Nil(err)
 
 	var jsonStr = []byte(`{"collection_id":"` + collection.ID.String() + `", "token_id": "1", "blueprint": "123456" }`)
 	req, _ := http.NewRequest("POST", "/tokens", bytes.NewBuffer(jsonStr))
 
-	ctx := req.Context()
-	ctx = context.WithValue(ctx, oauth.CredentialContext, user.ID.String())
-	response := s.executeRequest(req.WithContext(ctx))
+	req.Header.Set(auth.DebugUserHeader, user.ID.String())
+	response := s.executeRequest(req)
 
-	s.checkResponseCode(http.StatusCreated, response.Code)
+	s.checkResponseCode(http.StatusAccepted, response.Code)
 
 	objMap := map[string]string{}
 	err = json.Unmarshal(response.Body.Bytes(), &objMap)
 	s.Assertions.Nil(err)
 	s.Assertions.NotEmpty(objMap["token_id"])
+	s.Assertions.NotEmpty(objMap["job_id"])
+
+	token, err := s.db.GetToken(uuid.MustParse(objMap["token_id"]))
+	s.Assertions.Nil(err)
+	s.Assertions.Equal(models.TokenPending, token.Status)
+
+	err = outbox.NewRelay(s.db, s.server.asynqClient, time.Second).RelayPending(context.Background())
+	s.Assertions.Nil(err)
+
+	req, _ = http.NewRequest("GET", "/jobs/"+objMap["job_id"], nil)
+	req.Header.Set(auth.DebugUserHeader, user.ID.String())
+	response = s.executeRequest(req)
+	s.checkResponseCode(http.StatusOK, response.Code)
+
+	other := test.CreateDummyUser(uuid.New(), "other")
+	err = s.db.CreateUser(other)
+	s.Assertions.Nil(err)
+	req.Header.Set(auth.DebugUserHeader, other.ID.String())
+	response = s.executeRequest(req)
+	s.checkResponseCode(http.StatusNotFound, response.Code)
+}
+
+func (s *UnitTestSuite) TestGetJobBeforeItIsEnqueued() {
+	user := test.CreateDummyUser(uuid.New(), "test")
+	err := s.db.CreateUser(user)
+	s.Assertions.Nil(err)
+	organization := test.CreateDummyOrganization(uuid.New())
+	err = s.db.CreateOrganization(organization, user.ID)
+	s.Assertions.Nil(err)
+	collection := test.CreateDummyCollection(uuid.New(), user.ID, organization.ID, "address")
+	err = s.db.CreateCollection(collection)
+	s.Assertions.Nil(err)
+
+	var jsonStr = []byte(`{"collection_id":"` + collection.ID.String() + `", "token_id": "1", "blueprint": "123456" }`)
+	req, _ := http.NewRequest("POST", "/tokens", bytes.NewBuffer(jsonStr))
+	req.Header.Set(auth.DebugUserHeader, user.ID.String())
+	response := s.executeRequest(req)
+	s.checkResponseCode(http.StatusAccepted, response.Code)
+
+	objMap := map[string]string{}
+	err = json.Unmarshal(response.Body.Bytes(), &objMap)
+	s.Assertions.Nil(err)
+
+	// polled before the relay enqueued the task
+	req, _ = http.NewRequest("GET", "/jobs/"+objMap["job_id"], nil)
+	req.Header.Set(auth.DebugUserHeader, user.ID.String())
+	response = s.executeRequest(req)
+	s.checkResponseCode(http.StatusOK, response.Code)
+
+	job := map[string]interface{}{}
+	err = json.Unmarshal(response.Body.Bytes(), &job)
+	s.Assertions.Nil(err)
+	s.Assertions.Equal(objMap["job_id"], job["job_id"])
+	s.Assertions.Equal(tasks.TypeMintToken, job["type"])
+	s.Assertions.Equal("pending", job["state"])
+
+	other := test.CreateDummyUser(uuid.New(), "other")
+	err = s.db.CreateUser(other)
+	s.Assertions.Nil(err)
+	req.Header.Set(auth.DebugUserHeader, other.ID.String())
+	response = s.executeRequest(req)
+	s.checkResponseCode(http.StatusNotFound, response.Code)
+}
+
+func (s *UnitTestSuite) TestCreateTokenTwiceShouldFail() {
+	user := test.CreateDummyUser(uuid.New(), "test")
+	err := s.db.CreateUser(user)
+	s.Assertions.Nil(err)
+	organization := test.CreateDummyOrganization(uuid.New())
+	err = s.db.CreateOrganization(organization, user.ID)
+	s.Assertions.Nil(err)
+	collection := test.CreateDummyCollection(uuid.New(), user.ID, organization.ID, "address")
+	err = s.db.CreateCollection(collection)
+	s.Assertions.Nil(err)
+
+	var jsonStr = []byte(`{"collection_id":"` + collection.ID.String() + `", "token_id": "1", "blueprint": "123456" }`)
+	req, _ := http.NewRequest("POST", "/tokens", bytes.NewBuffer(jsonStr))
+	req.Header.Set(auth.DebugUserHeader, user.ID.String())
+	response := s.executeRequest(req)
+	s.checkResponseCode(http.StatusAccepted, response.Code)
+
+	req, _ = http.NewRequest("POST", "/tokens", bytes.NewBuffer(jsonStr))
+	req.Header.Set(auth.DebugUserHeader, user.ID.String())
+	response = s.executeRequest(req)
+	s.checkResponseCode(http.StatusConflict, response.Code)
+}
+
+func (s *UnitTestSuite) TestCreateTokenWithIdempotencyKey() {
+	user := test.CreateDummyUser(uuid.New(), "test")
+	err := s.db.CreateUser(user)
+	s.Assertions.Nil(err)
+	organization := test.CreateDummyOrganization(uuid.New())
+	err = s.db.CreateOrganization(organization, user.ID)
+	s.Assertions.Nil(err)
+	collection := test.CreateDummyCollection(uuid.New(), user.ID, organization.ID, "address")
+	err = s.db.CreateCollection(collection)
+	s.Assertions.Nil(err)
+
+	newRequest := func(tokenID string) *http.Request {
+		var jsonStr = []byte(`{"collection_id":"` + collection.ID.String() + `", "token_id": "` + tokenID + `", "blueprint": "123456" }`)
+		req, _ := http.NewRequest("POST", "/tokens", bytes.NewBuffer(jsonStr))
+		req.Header.Set(auth.DebugUserHeader, user.ID.String())
+		req.Header.Set(idempotency.Header, "mint-1")
+		return req
+	}
+
+	first := s.executeRequest(newRequest("1"))
+	s.checkResponseCode(http.StatusAccepted, first.Code)
+
+	second := s.executeRequest(newRequest("1"))
+	s.checkResponseCode(http.StatusAccepted, second.Code)
+	s.Assertions.Equal(first.Body.String(), second.Body.String())
+	s.Assertions.Equal("true", second.Header().Get(idempotency.ReplayedHeader))
+
+	conflict := s.executeRequest(newRequest("2"))
+	s.checkResponseCode(http.StatusConflict, conflict.Code)
 }
 
 func (s *UnitTestSuite) TestTransferToken() {
 	user := test.CreateDummyUser(uuid.New(), "test")
 	err := s.db.CreateUser(user)
 	s.Assertions.Nil(err)
-	collection := test.CreateDummyCollection(uuid.New(), user.ID, "address")
+	organization := test.CreateDummyOrganization(uuid.New())
+	err = s.db.CreateOrganization(organization, user.ID)
+	s.Assertions.Nil(err)
+	collection := test.CreateDummyCollection(uuid.New(), user.ID, organization.ID, "address")
 	err = s.db.CreateCollection(collection)
 	s.Assertions.Nil(err)
 	token := test.CreateDummyToken(uuid.New(), collection.ID, "1")
 	err = s.db.CreateToken(token)
 	s.Assertions.Nil(err)
+	s.mintOnIMX(collection, token)
+	s.imx.RegisterUser("0x18b1ceDC9803096D970f52260D1835F07D7e448C", "0x0123")
 
 	var jsonStr = []byte(`{"collection_id":"` + collection.ID.String() + `", "token_id":"` + token.ID.String() + `", "receiver_address": "0x18b1ceDC9803096D970f52260D1835F07D7e448C"}`)
 	req, _ := http.NewRequest("POST", "/transfers", bytes.NewBuffer(jsonStr))
 
-	ctx := req.Context()
-	ctx = context.WithValue(ctx, oauth.CredentialContext, user.ID.String())
-	response := s.executeRequest(req.WithContext(ctx))
+	req.Header.Set(auth.DebugUserHeader, user.ID.String())
+	response := s.executeRequest(req)
 
 	s.checkResponseCode(http.StatusCreated, response.Code)
 
@@ -145,132 +475,1224 @@ func (s *UnitTestSuite) TestTransferToken() {
 	err = json.Unmarshal(response.Body.Bytes(), &objMap)
 	s.Assertions.Nil(err)
 	s.Assertions.Equal(token.ID.String(), objMap["token_id"])
+
+	owner, _ := s.imx.Owner(collection.ContractAddress, token.TokenID)
+	s.Assertions.Equal("0x18b1cedc9803096d970f52260d1835f07d7e448c", owner)
 }
 
-func (s *UnitTestSuite) TestCreateOrder() {
+func (s *UnitTestSuite) TestTransferPendingTokenShouldFail() {
 	user := test.CreateDummyUser(uuid.New(), "test")
 	err := s.db.CreateUser(user)
 	s.Assertions.Nil(err)
-	collection := test.CreateDummyCollection(uuid.New(), user.ID, "address")
+	organization := test.CreateDummyOrganization(uuid.New())
+	err = s.db.CreateOrganization(organization, user.ID)
+	s.Assertions.Nil(err)
+	collection := test.CreateDummyCollection(uuid.New(), user.ID, organization.ID, "address")
 	err = s.db.CreateCollection(collection)
 	s.Assertions.Nil(err)
 	token := test.CreateDummyToken(uuid.New(), collection.ID, "1")
+	token.Status = models.TokenPending
 	err = s.db.CreateToken(token)
 	s.Assertions.Nil(err)
+	s.imx.RegisterUser("0x18b1ceDC9803096D970f52260D1835F07D7e448C", "0x0123")
 
-	var jsonStr = []byte(`{"collection_id":"` + collection.ID.String() + `", "token_id":"` + token.ID.String() + `", "amount": "1000000"}`)
-	req, _ := http.NewRequest("POST", "/orders", bytes.NewBuffer(jsonStr))
+	var jsonStr = []byte(`{"collection_id":"` + collection.ID.String() + `", "token_id":"` + token.ID.String() + `", "receiver_address": "0x18b1ceDC9803096D970f52260D1835F07D7e448C"}`)
+	req, _ := http.NewRequest("POST", "/transfers", bytes.NewBuffer(jsonStr))
+	req.Header.Set(auth.DebugUserHeader, user.ID.String())
+	response := s.executeRequest(req)
+	s.checkResponseCode(http.StatusConflict, response.Code)
 
-	ctx := req.Context()
-	ctx = context.WithValue(ctx, oauth.CredentialContext, user.ID.String())
-	response := s.executeRequest(req.WithContext(ctx))
+	jsonStr = []byte(`{"collection_id":"` + collection.ID.String() + `", "token_id":"` + token.ID.String() + `", "amount": "100"}`)
+	req, _ = http.NewRequest("POST", "/orders", bytes.NewBuffer(jsonStr))
+	req.Header.Set(auth.DebugUserHeader, user.ID.String())
+	response = s.executeRequest(req)
+	s.checkResponseCode(http.StatusConflict, response.Code)
+}
 
-	s.checkResponseCode(http.StatusCreated, response.Code)
+// rateLimitedIMX rate limits the first transfer, as IMX does when the platform sends too many requests.
+type rateLimitedIMX struct {
+	*fake.IMX
+	limited bool
+}
 
-	objMap := map[string]string{}
-	err = json.Unmarshal(response.Body.Bytes(), &objMap)
-	s.Assertions.Nil(err)
-	s.Assertions.NotEmpty(objMap["order_id"])
+func (i *rateLimitedIMX) TransferToken(ctx context.Context, info *imx.TransferInformation) error {
+	if !i.limited {
+		i.limited = true
+		return &imx.RequestError{Kind: imx.ErrRateLimited, StatusCode: http.StatusTooManyRequests, Message: "too many requests"}
+	}
+	return i.IMX.TransferToken(ctx, info)
 }
 
-func (s *UnitTestSuite) TestCreateDeposit() {
+func (s *UnitTestSuite) TestTransferTokenRetriedAfterRateLimit() {
+	settings := config.GetConfig()
+	settings.DebugAuth = true
+	s.server = NewServer(settings, s.db, &rateLimitedIMX{IMX: s.imx}, asynq.NewClient(asynq.RedisClientOpt{Addr: settings.RedisUrl}), metrics.New())
+	s.server.Configure()
+
 	user := test.CreateDummyUser(uuid.New(), "test")
 	err := s.db.CreateUser(user)
 	s.Assertions.Nil(err)
+	organization := test.CreateDummyOrganization(uuid.New())
+	err = s.db.CreateOrganization(organization, user.ID)
+	s.Assertions.Nil(err)
+	collection := test.CreateDummyCollection(uuid.New(), user.ID, organization.ID, "address")
+	err = s.db.CreateCollection(collection)
+	s.Assertions.Nil(err)
+	token := test.CreateDummyToken(uuid.New(), collection.ID, "1")
+	err = s.db.CreateToken(token)
+	s.Assertions.Nil(err)
+	s.mintOnIMX(collection, token)
+	s.imx.RegisterUser("0x18b1ceDC9803096D970f52260D1835F07D7e448C", "0x0123")
 
-	var jsonStr = []byte(`{"amount_wei":"1000000000"}`)
-	req, _ := http.NewRequest("POST", "/deposits", bytes.NewBuffer(jsonStr))
+	newRequest := func() *http.Request {
+		var jsonStr = []byte(`{"collection_id":"` + collection.ID.String() + `", "token_id":"` + token.ID.String() + `", "receiver_address": "0x18b1ceDC9803096D970f52260D1835F07D7e448C"}`)
+		req, _ := http.NewRequest("POST", "/transfers", bytes.NewBuffer(jsonStr))
+		req.Header.Set(auth.DebugUserHeader, user.ID.String())
+		req.Header.Set(idempotency.Header, "transfer-1")
+		return req
+	}
 
-	ctx := req.Context()
-	ctx = context.WithValue(ctx, oauth.CredentialContext, user.ID.String())
-	response := s.executeRequest(req.WithContext(ctx))
+	response := s.executeRequest(newRequest())
+	s.checkResponseCode(http.StatusTooManyRequests, response.Code)
 
+	// the rate limited transfer was not applied, the same key runs it again
+	response = s.executeRequest(newRequest())
 	s.checkResponseCode(http.StatusCreated, response.Code)
+	s.Assertions.Empty(response.Header().Get(idempotency.ReplayedHeader))
 
-	objMap := map[string]string{}
-	err = json.Unmarshal(response.Body.Bytes(), &objMap)
+	owner, _ := s.imx.Owner(collection.ContractAddress, token.TokenID)
+	s.Assertions.Equal("0x18b1cedc9803096d970f52260d1835f07d7e448c", owner)
+}
+
+// publishedTo returns the users the unsent outbox messages publish an event of the type to.
+func (s *UnitTestSuite) publishedTo(eventType string) []uuid.UUID {
+	messages, err := s.db.ListUnsentOutboxMessages(10)
 	s.Assertions.Nil(err)
-	s.Assertions.NotEmpty(objMap["tx_hash"])
+
+	users := make([]uuid.UUID, 0, len(messages))
+	for _, message := range messages {
+		var payload events.PublishPayload
+		s.Assertions.Nil(json.Unmarshal(message.Payload, &payload))
+		if payload.Event.Type == eventType {
+			users = append(users, payload.UserID)
+		}
+	}
+	return users
 }
 
-func (s *UnitTestSuite) TestCreateWithdrawal() {
+func (s *UnitTestSuite) TestTransferTokenNotifiesReceiver() {
 	user := test.CreateDummyUser(uuid.New(), "test")
 	err := s.db.CreateUser(user)
 	s.Assertions.Nil(err)
+	receiver := s.createIMXUser("receiver")
+	organization := test.CreateDummyOrganization(uuid.New())
+	err = s.db.CreateOrganization(organization, user.ID)
+	s.Assertions.Nil(err)
+	collection := test.CreateDummyCollection(uuid.New(), user.ID, organization.ID, "address")
+	err = s.db.CreateCollection(collection)
+	s.Assertions.Nil(err)
+	token := test.CreateDummyToken(uuid.New(), collection.ID, "1")
+	err = s.db.CreateToken(token)
+	s.Assertions.Nil(err)
+	s.mintOnIMX(collection, token)
 
-	var jsonStr = []byte(`{"amount_wei":"1000000000"}`)
-	req, _ := http.NewRequest("POST", "/withdrawals", bytes.NewBuffer(jsonStr))
-
-	ctx := req.Context()
-	ctx = context.WithValue(ctx, oauth.CredentialContext, user.ID.String())
-	response := s.executeRequest(req.WithContext(ctx))
-
+	// the receiver address is given in another case than the one saved
+	var jsonStr = []byte(`{"collection_id":"` + collection.ID.String() + `", "token_id":"` + token.ID.String() + `", "receiver_address": "` + strings.ToLower(receiver.Address) + `"}`)
+	req, _ := http.NewRequest("POST", "/transfers", bytes.NewBuffer(jsonStr))
+	req.Header.Set(auth.DebugUserHeader, user.ID.String())
+	response := s.executeRequest(req)
 	s.checkResponseCode(http.StatusCreated, response.Code)
 
-	objMap := map[string]string{}
-	err = json.Unmarshal(response.Body.Bytes(), &objMap)
-	s.Assertions.Nil(err)
-	s.Assertions.NotEmpty(objMap["withdrawal_id"])
+	s.Assertions.ElementsMatch([]uuid.UUID{user.ID, receiver.ID}, s.publishedTo(events.TransferCompleted))
 }
 
-func (s *UnitTestSuite) TestCreateTrade() {
+func (s *UnitTestSuite) TestCreateOrder() {
 	user := test.CreateDummyUser(uuid.New(), "test")
 	err := s.db.CreateUser(user)
 	s.Assertions.Nil(err)
+	organization := test.CreateDummyOrganization(uuid.New())
+	err = s.db.CreateOrganization(organization, user.ID)
+	s.Assertions.Nil(err)
+	collection := test.CreateDummyCollection(uuid.New(), user.ID, organization.ID, "address")
+	err = s.db.CreateCollection(collection)
+	s.Assertions.Nil(err)
+	token := test.CreateDummyToken(uuid.New(), collection.ID, "1")
+	err = s.db.CreateToken(token)
+	s.Assertions.Nil(err)
+	s.mintOnIMX(collection, token)
 
-	var jsonStr = []byte(`{"order_id":"1"}`)
-	req, _ := http.NewRequest("POST", "/trades", bytes.NewBuffer(jsonStr))
+	var jsonStr = []byte(`{"collection_id":"` + collection.ID.String() + `", "token_id":"` + token.ID.String() + `", "amount": "1000000"}`)
+	req, _ := http.NewRequest("POST", "/orders", bytes.NewBuffer(jsonStr))
 
-	ctx := req.Context()
-	ctx = context.WithValue(ctx, oauth.CredentialContext, user.ID.String())
-	response := s.executeRequest(req.WithContext(ctx))
+	req.Header.Set(auth.DebugUserHeader, user.ID.String())
+	response := s.executeRequest(req)
 
 	s.checkResponseCode(http.StatusCreated, response.Code)
 
 	objMap := map[string]string{}
 	err = json.Unmarshal(response.Body.Bytes(), &objMap)
 	s.Assertions.Nil(err)
-	s.Assertions.NotEmpty(objMap["trade_id"])
-}
+	s.Assertions.Equal("1", objMap["order_id"])
 
-func (s *UnitTestSuite) TestCreateCollectionWithoutParamsShouldFail() {
-	req, _ := http.NewRequest("POST", "/collections", nil)
-	response := s.executeRequest(req)
-	s.checkResponseCode(http.StatusInternalServerError, response.Code)
+	orders, err := s.imx.ListOrders(context.Background(), collection.ContractAddress)
+	s.Assertions.Nil(err)
+	s.Assertions.Len(orders, 1)
+	s.Assertions.Equal(token.TokenID, orders[0].TokenID)
 }
 
-func (s *UnitTestSuite) TestCreateTokenWithoutParamsShouldFail() {
-	req, _ := http.NewRequest("POST", "/tokens", nil)
-	response := s.executeRequest(req)
-	s.checkResponseCode(http.StatusInternalServerError, response.Code)
-}
+func (s *UnitTestSuite) TestOrderAndTransferUseTheIMXTokenID() {
+	user := test.CreateDummyUser(uuid.New(), "test")
+	err := s.db.CreateUser(user)
+	s.Assertions.Nil(err)
+	organization := test.CreateDummyOrganization(uuid.New())
+	err = s.db.CreateOrganization(organization, user.ID)
+	s.Assertions.Nil(err)
+	collection := test.CreateDummyCollection(uuid.New(), user.ID, organization.ID, "address")
+	err = s.db.CreateCollection(collection)
+	s.Assertions.Nil(err)
+	listed := test.CreateDummyToken(uuid.New(), collection.ID, "42")
+	err = s.db.CreateToken(listed)
+	s.Assertions.Nil(err)
+	s.mintOnIMX(collection, listed)
+	transferred := test.CreateDummyToken(uuid.New(), collection.ID, "43")
+	err = s.db.CreateToken(transferred)
+	s.Assertions.Nil(err)
+	err = s.imx.CreateToken(context.Background(), &imx.MintInformation{ContractAddress: collection.ContractAddress, TokenID: transferred.TokenID})
+	s.Assertions.Nil(err)
+	s.imx.RegisterUser("0x18b1ceDC9803096D970f52260D1835F07D7e448C", "0x0123")
 
-func (s *UnitTestSuite) TestTransferTokenWithoutParamsShouldFail() {
-	req, _ := http.NewRequest("POST", "/transfers", nil)
+	// the request names the token by its database ID, IMX knows it by its token ID
+	var jsonStr = []byte(`{"collection_id":"` + collection.ID.String() + `", "token_id":"` + listed.ID.String() + `", "amount": "1000000"}`)
+	req, _ := http.NewRequest("POST", "/orders", bytes.NewBuffer(jsonStr))
+	req.Header.Set(auth.DebugUserHeader, user.ID.String())
 	response := s.executeRequest(req)
-	s.checkResponseCode(http.StatusInternalServerError, response.Code)
-}
+	s.checkResponseCode(http.StatusCreated, response.Code)
 
-func (s *UnitTestSuite) TestCreateOrderWithoutParamsShouldFail() {
-	req, _ := http.NewRequest("POST", "/orders", nil)
-	response := s.executeRequest(req)
-	s.checkResponseCode(http.StatusInternalServerError, response.Code)
+	orders, err := s.imx.ListOrders(context.Background(), collection.ContractAddress)
+	s.Assertions.Nil(err)
+	s.Assertions.Len(orders, 1)
+	s.Assertions.Equal("42", orders[0].TokenID)
+
+	jsonStr = []byte(`{"collection_id":"` + collection.ID.String() + `", "token_id":"` + transferred.ID.String() + `", "receiver_address": "0x18b1ceDC9803096D970f52260D1835F07D7e448C"}`)
+	req, _ = http.NewRequest("POST", "/transfers", bytes.NewBuffer(jsonStr))
+	req.Header.Set(auth.DebugUserHeader, user.ID.String())
+	response = s.executeRequest(req)
+	s.checkResponseCode(http.StatusCreated, response.Code)
+
+	owner, _ := s.imx.Owner(collection.ContractAddress, "43")
+	s.Assertions.Equal("0x18b1cedc9803096d970f52260d1835f07d7e448c", owner)
 }
 
-func (s *UnitTestSuite) TestCreateDepositWithoutParamsShouldFail() {
-	req, _ := http.NewRequest("POST", "/deposits", nil)
-	response := s.executeRequest(req)
-	s.checkResponseCode(http.StatusInternalServerError, response.Code)
+func (s *UnitTestSuite) TestResponsesFormatIMXIDsInDecimal() {
+	// string(id) would have made them a rune
+	s.Assertions.Equal("1234", handlers.NewOrderResponse(1234).OrderID)
+	s.Assertions.Equal("65", handlers.NewTradeResponse(65).TradeID)
 }
 
-func (s *UnitTestSuite) TestCreateTradeWithoutParamsShouldFail() {
-	req, _ := http.NewRequest("POST", "/trades", nil)
+func (s *UnitTestSuite) TestCreateDeposit() {
+	user := s.createIMXUser("test")
+
+	var jsonStr = []byte(`{"amount_wei":"1000000000"}`)
+	req, _ := http.NewRequest("POST", "/deposits", bytes.NewBuffer(jsonStr))
+
+	req.Header.Set(auth.DebugUserHeader, user.ID.String())
 	response := s.executeRequest(req)
-	s.checkResponseCode(http.StatusInternalServerError, response.Code)
+
+	s.checkResponseCode(http.StatusCreated, response.Code)
+
+	objMap := map[string]string{}
+	err := json.Unmarshal(response.Body.Bytes(), &objMap)
+	s.Assertions.Nil(err)
+	s.Assertions.NotEmpty(objMap["tx_hash"])
+}
+
+func (s *UnitTestSuite) confirmDepositTask(user *models.User, deadline int64) *asynq.Task {
+	hash, err := s.imx.CreateEthDeposit(context.Background(), &imx.CreateDepositInformation{User: user, AmountWei: "1000"})
+	s.Assertions.Nil(err)
+	payload, err := json.Marshal(tasks.ConfirmDepositPayload{TransactionHash: hash, AmountWei: "1000", UserID: user.ID, Deadline: deadline})
+	s.Assertions.Nil(err)
+	return asynq.NewTask(tasks.TypeConfirmDeposit, payload)
+}
+
+func (s *UnitTestSuite) TestMintTokenAlreadyMintedOnIMX() {
+	user := s.createIMXUser("test")
+	collection := test.CreateDummyCollection(uuid.New(), user.ID, uuid.New(), "address")
+	err := s.db.CreateCollection(collection)
+	s.Assertions.Nil(err)
+	token := test.CreateDummyToken(uuid.New(), collection.ID, "1")
+	token.Status = models.TokenPending
+	err = s.db.CreateToken(token)
+	s.Assertions.Nil(err)
+
+	// minted by an attempt whose answer was lost
+	s.mintOnIMX(collection, token)
+
+	message, err := tasks.NewMintTokenMessage(context.Background(), token.ID, user.ID)
+	s.Assertions.Nil(err)
+	processor := tasks.NewMintTokenProcessor(s.imx, s.db)
+	err = processor.ProcessTask(context.Background(), asynq.NewTask(message.TaskType, message.Payload))
+	s.Assertions.Nil(err)
+
+	token, err = s.db.GetToken(token.ID)
+	s.Assertions.Nil(err)
+	s.Assertions.Equal(models.TokenMinted, token.Status)
+
+	// its event is saved with the status
+	s.Assertions.Equal([]uuid.UUID{user.ID}, s.publishedTo(events.TokenMinted))
+}
+
+func (s *UnitTestSuite) TestMintTokenMintedElsewhereShouldFail() {
+	user := s.createIMXUser("test")
+	collection := test.CreateDummyCollection(uuid.New(), user.ID, uuid.New(), "address")
+	err := s.db.CreateCollection(collection)
+	s.Assertions.Nil(err)
+	token := test.CreateDummyToken(uuid.New(), collection.ID, "1")
+	token.Status = models.TokenPending
+	err = s.db.CreateToken(token)
+	s.Assertions.Nil(err)
+
+	// an asset with the token ID that the platform no longer holds is not this token
+	s.mintOnIMX(collection, token)
+	err = s.imx.TransferToken(context.Background(), &imx.TransferInformation{ContractAddress: collection.ContractAddress, TokenID: token.TokenID, ReceiverAddress: user.Address})
+	s.Assertions.Nil(err)
+
+	message, err := tasks.NewMintTokenMessage(context.Background(), token.ID, user.ID)
+	s.Assertions.Nil(err)
+	processor := tasks.NewMintTokenProcessor(s.imx, s.db)
+	err = processor.ProcessTask(context.Background(), asynq.NewTask(message.TaskType, message.Payload))
+	s.Assertions.ErrorIs(err, asynq.SkipRetry)
+
+	token, err = s.db.GetToken(token.ID)
+	s.Assertions.Nil(err)
+	s.Assertions.Equal(models.TokenFailed, token.Status)
+}
+
+func (s *UnitTestSuite) TestConfirmDepositPublishesOnce() {
+	user := s.createIMXUser("test")
+	task := s.confirmDepositTask(user, time.Now().Add(time.Hour).UnixMilli())
+
+	// a task retried after saving its event, e.g. when asynq could not mark it done
+	processor := tasks.NewConfirmDepositProcessor(s.imx, s.db)
+	s.Assertions.Nil(processor.ProcessTask(context.Background(), task))
+	s.Assertions.Nil(processor.ProcessTask(context.Background(), task))
+
+	messages, err := s.db.ListUnsentOutboxMessages(10)
+	s.Assertions.Nil(err)
+	s.Assertions.Len(messages, 1)
+	s.Assertions.Equal(events.TypePublishEvent, messages[0].TaskType)
+}
+
+func (s *UnitTestSuite) TestConfirmDepositGivesUpAfterDeadline() {
+	user := s.createIMXUser("test")
+	s.imx.SetConfirmationDelay(time.Hour)
+	task := s.confirmDepositTask(user, time.Now().Add(-time.Second).UnixMilli())
+
+	err := tasks.NewConfirmDepositProcessor(s.imx, s.db).ProcessTask(context.Background(), task)
+	s.Assertions.ErrorIs(err, asynq.SkipRetry)
+
+	messages, err := s.db.ListUnsentOutboxMessages(10)
+	s.Assertions.Nil(err)
+	s.Assertions.Len(messages, 1)
+	var payload events.PublishPayload
+	s.Assertions.Nil(json.Unmarshal(messages[0].Payload, &payload))
+	s.Assertions.Equal(events.DepositFailed, payload.Event.Type)
+}
+
+func (s *UnitTestSuite) TestConfirmDepositWaitsUntilDeadline() {
+	user := s.createIMXUser("test")
+	s.imx.SetConfirmationDelay(time.Hour)
+	task := s.confirmDepositTask(user, time.Now().Add(time.Hour).UnixMilli())
+
+	err := tasks.NewConfirmDepositProcessor(s.imx, s.db).ProcessTask(context.Background(), task)
+	var notConfirmed imx.DepositNotConfirmedError
+	s.Assertions.ErrorAs(err, &notConfirmed)
+
+	messages, err := s.db.ListUnsentOutboxMessages(10)
+	s.Assertions.Nil(err)
+	s.Assertions.Empty(messages)
+}
+
+func (s *UnitTestSuite) TestCreateWithdrawal() {
+	user := s.createIMXUser("test")
+	s.imx.Fund(user.Address, big.NewInt(1000000000))
+
+	var jsonStr = []byte(`{"amount_wei":"1000000000"}`)
+	req, _ := http.NewRequest("POST", "/withdrawals", bytes.NewBuffer(jsonStr))
+
+	req.Header.Set(auth.DebugUserHeader, user.ID.String())
+	response := s.executeRequest(req)
+
+	s.checkResponseCode(http.StatusCreated, response.Code)
+
+	objMap := map[string]string{}
+	err := json.Unmarshal(response.Body.Bytes(), &objMap)
+	s.Assertions.Nil(err)
+	s.Assertions.NotEmpty(objMap["withdrawal_id"])
+	s.Assertions.Equal(0, s.imx.Balance(user.Address).Sign())
+}
+
+func (s *UnitTestSuite) TestCreateWithdrawalRetryAfterSaveFailure() {
+	user := s.createIMXUser("test")
+	s.imx.Fund(user.Address, big.NewInt(2000000000))
+
+	// the IMX ID of withdrawals cannot be saved, the table is dropped with its constraint on reset
+	sqlDB, err := s.db.SQL()
+	s.Assertions.Nil(err)
+	_, err = sqlDB.Exec("ALTER TABLE withdrawals ADD CONSTRAINT reject_withdrawal_ids CHECK (withdrawal_id IS NULL) NOT VALID")
+	s.Assertions.Nil(err)
+
+	newRequest := func() *http.Request {
+		var jsonStr = []byte(`{"amount_wei":"1000000000"}`)
+		req, _ := http.NewRequest("POST", "/withdrawals", bytes.NewBuffer(jsonStr))
+		req.Header.Set(auth.DebugUserHeader, user.ID.String())
+		req.Header.Set(idempotency.Header, "withdrawal-1")
+		return req
+	}
+
+	first := s.executeRequest(newRequest())
+	s.checkResponseCode(http.StatusInternalServerError, first.Code)
+	s.Assertions.Equal(big.NewInt(1000000000), s.imx.Balance(user.Address))
+
+	// the withdrawal is kept as being created, with the message of the task completing it
+	withdrawals, err := s.db.ListPendingWithdrawals(0, 10)
+	s.Assertions.Nil(err)
+	s.Assertions.Len(withdrawals, 1)
+	s.Assertions.Equal(models.WithdrawalCreating, withdrawals[0].Status)
+	s.Assertions.Nil(withdrawals[0].WithdrawalID)
+	messages, err := s.db.ListUnsentOutboxMessages(10)
+	s.Assertions.Nil(err)
+	s.Assertions.Len(messages, 1)
+	s.Assertions.Equal(tasks.TypeCompleteWithdrawal, messages[0].TaskType)
+
+	// a retry does not withdraw again until it is reconciled
+	second := s.executeRequest(newRequest())
+	s.checkResponseCode(http.StatusConflict, second.Code)
+	s.Assertions.Equal(big.NewInt(1000000000), s.imx.Balance(user.Address))
+}
+
+func (s *UnitTestSuite) TestCompleteWithdrawalAlreadyCompleted() {
+	user := s.createIMXUser("test")
+	s.imx.Fund(user.Address, big.NewInt(1000000000))
+
+	var jsonStr = []byte(`{"amount_wei":"1000000000"}`)
+	req, _ := http.NewRequest("POST", "/withdrawals", bytes.NewBuffer(jsonStr))
+	req.Header.Set(auth.DebugUserHeader, user.ID.String())
+	response := s.executeRequest(req)
+	s.checkResponseCode(http.StatusCreated, response.Code)
+
+	objMap := map[string]string{}
+	err := json.Unmarshal(response.Body.Bytes(), &objMap)
+	s.Assertions.Nil(err)
+	withdrawalID, err := strconv.ParseInt(objMap["withdrawal_id"], 10, 32)
+	s.Assertions.Nil(err)
+
+	messages, err := s.db.ListUnsentOutboxMessages(10)
+	s.Assertions.Nil(err)
+	s.Require().Len(messages, 1)
+	task := asynq.NewTask(messages[0].TaskType, messages[0].Payload)
+
+	// completed on L1 by an attempt whose status was not saved
+	err = s.imx.CompleteEthWithdrawal(context.Background(), &imx.CompleteWithdrawalInformation{User: user, WithdrawalID: int32(withdrawalID)})
+	s.Assertions.Nil(err)
+
+	processor := tasks.NewCompleteWithdrawalProcessor(s.imx, s.db)
+	err = processor.ProcessTask(context.Background(), task)
+	s.Assertions.Nil(err)
+
+	withdrawals, err := s.db.ListPendingWithdrawals(0, 10)
+	s.Assertions.Nil(err)
+	s.Assertions.Empty(withdrawals)
+
+	// a retry once the status is saved does not reach IMX nor publish again
+	err = processor.ProcessTask(context.Background(), task)
+	s.Assertions.Nil(err)
+	s.Assertions.Equal([]uuid.UUID{user.ID}, s.publishedTo(events.WithdrawalCompleted))
+}
+
+func (s *UnitTestSuite) TestCreateWithdrawalRefusedByIMX() {
+	user := s.createIMXUser("test")
+
+	var jsonStr = []byte(`{"amount_wei":"1000000000"}`)
+	req, _ := http.NewRequest("POST", "/withdrawals", bytes.NewBuffer(jsonStr))
+	req.Header.Set(auth.DebugUserHeader, user.ID.String())
+	response := s.executeRequest(req)
+	s.checkResponseCode(http.StatusPaymentRequired, response.Code)
+
+	// nothing was withdrawn, the user can withdraw again
+	withdrawals, err := s.db.ListPendingWithdrawals(0, 10)
+	s.Assertions.Nil(err)
+	s.Assertions.Empty(withdrawals)
+	messages, err := s.db.ListUnsentOutboxMessages(10)
+	s.Assertions.Nil(err)
+	s.Assertions.Empty(messages)
+}
+
+func (s *UnitTestSuite) TestCreateTrade() {
+	user := s.createIMXUser("test")
+	s.imx.Fund(user.Address, big.NewInt(1000000))
+	orderID := s.listOnIMX(1000000)
+
+	var jsonStr = []byte(`{"order_id":"` + orderID + `"}`)
+	req, _ := http.NewRequest("POST", "/trades", bytes.NewBuffer(jsonStr))
+
+	req.Header.Set(auth.DebugUserHeader, user.ID.String())
+	response := s.executeRequest(req)
+
+	s.checkResponseCode(http.StatusCreated, response.Code)
+
+	objMap := map[string]string{}
+	err := json.Unmarshal(response.Body.Bytes(), &objMap)
+	s.Assertions.Nil(err)
+	s.Assertions.Equal("1", objMap["trade_id"])
+
+	owner, _ := s.imx.Owner("address", "1")
+	s.Assertions.Equal(strings.ToLower(user.Address), owner)
+}
+
+func (s *UnitTestSuite) TestCreateTradeNotifiesOrderOwner() {
+	seller := s.createIMXUser("seller")
+	buyer := s.createIMXUser("buyer")
+	s.imx.Fund(buyer.Address, big.NewInt(1000000))
+	orderID := s.listOnIMX(1000000)
+	id, err := strconv.ParseInt(orderID, 10, 32)
+	s.Assertions.Nil(err)
+	err = s.db.CreateOrder(&models.Order{ID: uuid.New(), CollectionID: uuid.New(), TokenID: uuid.New(), OrderID: int32(id), Amount: "1000000", Status: models.OrderActive, UserID: seller.ID})
+	s.Assertions.Nil(err)
+
+	var jsonStr = []byte(`{"order_id":"` + orderID + `"}`)
+	req, _ := http.NewRequest("POST", "/trades", bytes.NewBuffer(jsonStr))
+	req.Header.Set(auth.DebugUserHeader, buyer.ID.String())
+	response := s.executeRequest(req)
+	s.checkResponseCode(http.StatusCreated, response.Code)
+
+	s.Assertions.ElementsMatch([]uuid.UUID{buyer.ID, seller.ID}, s.publishedTo(events.TradeCompleted))
+}
+
+func (s *UnitTestSuite) TestCreateTradeWithInsufficientBalanceShouldFail() {
+	user := s.createIMXUser("test")
+	orderID := s.listOnIMX(1000000)
+
+	var jsonStr = []byte(`{"order_id":"` + orderID + `"}`)
+	req, _ := http.NewRequest("POST", "/trades", bytes.NewBuffer(jsonStr))
+	req.Header.Set(auth.DebugUserHeader, user.ID.String())
+	response := s.executeRequest(req)
+	s.checkResponseCode(http.StatusPaymentRequired, response.Code)
+
+	objMap := map[string]interface{}{}
+	err := json.Unmarshal(response.Body.Bytes(), &objMap)
+	s.Assertions.Nil(err)
+	s.Assertions.Equal(float64(handlers.AppCodeIMXInsufficientBalance), objMap["code"])
+}
+
+func (s *UnitTestSuite) TestCreateTradeWithUnknownOrderShouldFail() {
+	user := s.createIMXUser("test")
+
+	var jsonStr = []byte(`{"order_id":"1"}`)
+	req, _ := http.NewRequest("POST", "/trades", bytes.NewBuffer(jsonStr))
+	req.Header.Set(auth.DebugUserHeader, user.ID.String())
+	response := s.executeRequest(req)
+	s.checkResponseCode(http.StatusNotFound, response.Code)
+
+	objMap := map[string]interface{}{}
+	err := json.Unmarshal(response.Body.Bytes(), &objMap)
+	s.Assertions.Nil(err)
+	s.Assertions.Equal(float64(handlers.AppCodeIMXNotFound), objMap["code"])
+}
+
+// signChallenge requests a challenge for the address of the key and returns its ID and signature.
+func (s *UnitTestSuite) signChallenge(privateKey *ecdsa.PrivateKey) (string, string) {
+	address := crypto.PubkeyToAddress(privateKey.PublicKey).Hex()
+	var jsonStr = []byte(`{"address":"` + address + `"}`)
+	req, _ := http.NewRequest("POST", "/users/external/challenges", bytes.NewBuffer(jsonStr))
+	response := s.executeRequest(req)
+	s.checkResponseCode(http.StatusCreated, response.Code)
+
+	challenge := map[string]interface{}{}
+	err := json.Unmarshal(response.Body.Bytes(), &challenge)
+	s.Require().NoError(err)
+
+	signature, err := crypto.Sign(accounts.TextHash([]byte(challenge["message"].(string))), privateKey)
+	s.Require().NoError(err)
+	signature[crypto.RecoveryIDOffset] += 27
+	return challenge["challenge_id"].(string), hexutil.Encode(signature)
+}
+
+func (s *UnitTestSuite) TestCreateExternalUser() {
+	privateKey, err := crypto.GenerateKey()
+	s.Require().NoError(err)
+	address := crypto.PubkeyToAddress(privateKey.PublicKey).Hex()
+	challengeID, signature := s.signChallenge(privateKey)
+
+	var jsonStr = []byte(`{"mail":"external@test.com", "address":"` + address + `", "stark_key":"0x0123", "challenge_id":"` + challengeID + `", "signature":"` + signature + `"}`)
+	req, _ := http.NewRequest("POST", "/users/external", bytes.NewBuffer(jsonStr))
+	response := s.executeRequest(req)
+	s.checkResponseCode(http.StatusCreated, response.Code)
+
+	objMap := map[string]interface{}{}
+	err = json.Unmarshal(response.Body.Bytes(), &objMap)
+	s.Assertions.Nil(err)
+	s.Assertions.NotEmpty(objMap["id"])
+	s.Assertions.NotEmpty(objMap["api_key"])
+	s.Assertions.Equal(address, objMap["address"])
+	s.Assertions.Equal("0x0123", objMap["stark_public_key"])
+	s.Assertions.Equal(true, objMap["external"])
+
+	// a challenge is used once
+	jsonStr = []byte(`{"mail":"other@test.com", "address":"` + address + `", "stark_key":"0x0123", "challenge_id":"` + challengeID + `", "signature":"` + signature + `"}`)
+	req, _ = http.NewRequest("POST", "/users/external", bytes.NewBuffer(jsonStr))
+	response = s.executeRequest(req)
+	s.checkResponseCode(http.StatusUnauthorized, response.Code)
+}
+
+func (s *UnitTestSuite) TestCreateExternalUserForAnotherAddressShouldFail() {
+	privateKey, err := crypto.GenerateKey()
+	s.Require().NoError(err)
+	challengeID, signature := s.signChallenge(privateKey)
+
+	// signed by a key that does not own the registered address
+	var jsonStr = []byte(`{"mail":"external@test.com", "address":"0x18b1ceDC9803096D970f52260D1835F07D7e448C", "stark_key":"0x0123", "challenge_id":"` + challengeID + `", "signature":"` + signature + `"}`)
+	req, _ := http.NewRequest("POST", "/users/external", bytes.NewBuffer(jsonStr))
+	response := s.executeRequest(req)
+	s.checkResponseCode(http.StatusUnauthorized, response.Code)
+}
+
+func (s *UnitTestSuite) TestCreateExternalUserWithoutChallengeShouldFail() {
+	var jsonStr = []byte(`{"mail":"external@test.com", "address":"0x18b1ceDC9803096D970f52260D1835F07D7e448C", "stark_key":"0x0123"}`)
+	req, _ := http.NewRequest("POST", "/users/external", bytes.NewBuffer(jsonStr))
+	response := s.executeRequest(req)
+	s.checkResponseCode(http.StatusBadRequest, response.Code)
+}
+
+func (s *UnitTestSuite) TestCreateExternalUserWithInvalidAddressShouldFail() {
+	var jsonStr = []byte(`{"mail":"external@test.com", "address":"invalid", "stark_key":"0x0123"}`)
+	req, _ := http.NewRequest("POST", "/users/external", bytes.NewBuffer(jsonStr))
+	response := s.executeRequest(req)
+	s.checkResponseCode(http.StatusBadRequest, response.Code)
+}
+
+func (s *UnitTestSuite) TestCreateTradeWithExternalUser() {
+	user := test.CreateDummyExternalUser(uuid.New(), "test")
+	err := s.db.CreateUser(user)
+	s.Assertions.Nil(err)
+	s.imx.Fund(user.Address, big.NewInt(1000000))
+	orderID := s.listOnIMX(1000000)
+
+	var jsonStr = []byte(`{"order_id":"` + orderID + `"}`)
+	req, _ := http.NewRequest("POST", "/trades", bytes.NewBuffer(jsonStr))
+
+	req.Header.Set(auth.DebugUserHeader, user.ID.String())
+	response := s.executeRequest(req)
+
+	s.checkResponseCode(http.StatusAccepted, response.Code)
+
+	objMap := map[string]string{}
+	err = json.Unmarshal(response.Body.Bytes(), &objMap)
+	s.Assertions.Nil(err)
+	s.Assertions.NotEmpty(objMap["request_id"])
+	s.Assertions.NotEmpty(objMap["signable_message"])
+	s.Assertions.NotEmpty(objMap["payload_hash"])
+
+	jsonStr = []byte(`{"eth_signature":"0x01", "stark_signature":"0x02"}`)
+	req, _ = http.NewRequest("POST", "/trades/"+objMap["request_id"]+"/signatures", bytes.NewBuffer(jsonStr))
+	req.Header.Set(auth.DebugUserHeader, user.ID.String())
+	response = s.executeRequest(req)
+	s.checkResponseCode(http.StatusCreated, response.Code)
+
+	// a signature request can only be submitted once
+	req, _ = http.NewRequest("POST", "/trades/"+objMap["request_id"]+"/signatures", bytes.NewBuffer(jsonStr))
+	req.Header.Set(auth.DebugUserHeader, user.ID.String())
+	response = s.executeRequest(req)
+	s.checkResponseCode(http.StatusBadRequest, response.Code)
+}
+
+func (s *UnitTestSuite) TestSubmitExpiredSignatureRequestShouldFail() {
+	user := test.CreateDummyExternalUser(uuid.New(), "test")
+	err := s.db.CreateUser(user)
+	s.Assertions.Nil(err)
+
+	request := &models.SignatureRequest{
+		ID:              uuid.New(),
+		UserID:          user.ID,
+		Type:            models.SignatureRequestTrade,
+		Status:          models.SignatureRequestPending,
+		OrderID:         10,
+		SignableMessage: "message",
+		PayloadHash:     "hash",
+		Payload:         "{}",
+		ExpiresAt:       time.Now().Add(-time.Minute).UnixMilli(),
+	}
+	err = s.db.CreateSignatureRequest(request)
+	s.Assertions.Nil(err)
+
+	var jsonStr = []byte(`{"eth_signature":"0x01", "stark_signature":"0x02"}`)
+	req, _ := http.NewRequest("POST", "/trades/"+request.ID.String()+"/signatures", bytes.NewBuffer(jsonStr))
+	req.Header.Set(auth.DebugUserHeader, user.ID.String())
+	response := s.executeRequest(req)
+	s.checkResponseCode(http.StatusBadRequest, response.Code)
+}
+
+func (s *UnitTestSuite) TestCreateWithdrawalWithExternalUser() {
+	user := test.CreateDummyExternalUser(uuid.New(), "test")
+	err := s.db.CreateUser(user)
+	s.Assertions.Nil(err)
+	s.imx.Fund(user.Address, big.NewInt(1000000000))
+
+	var jsonStr = []byte(`{"amount_wei":"1000000000"}`)
+	req, _ := http.NewRequest("POST", "/withdrawals", bytes.NewBuffer(jsonStr))
+
+	req.Header.Set(auth.DebugUserHeader, user.ID.String())
+	response := s.executeRequest(req)
+
+	s.checkResponseCode(http.StatusAccepted, response.Code)
+
+	objMap := map[string]string{}
+	err = json.Unmarshal(response.Body.Bytes(), &objMap)
+	s.Assertions.Nil(err)
+	s.Assertions.NotEmpty(objMap["request_id"])
+
+	// a withdrawal signature request cannot be submitted as a trade
+	jsonStr = []byte(`{"eth_signature":"0x01", "stark_signature":"0x02"}`)
+	req, _ = http.NewRequest("POST", "/trades/"+objMap["request_id"]+"/signatures", bytes.NewBuffer(jsonStr))
+	req.Header.Set(auth.DebugUserHeader, user.ID.String())
+	response = s.executeRequest(req)
+	s.checkResponseCode(http.StatusNotFound, response.Code)
+
+	req, _ = http.NewRequest("POST", "/withdrawals/"+objMap["request_id"]+"/signatures", bytes.NewBuffer(jsonStr))
+	req.Header.Set(auth.DebugUserHeader, user.ID.String())
+	response = s.executeRequest(req)
+	s.checkResponseCode(http.StatusCreated, response.Code)
+
+	objMap = map[string]string{}
+	err = json.Unmarshal(response.Body.Bytes(), &objMap)
+	s.Assertions.Nil(err)
+	s.Assertions.NotEmpty(objMap["withdrawal_id"])
+
+	// the user completes it on L1, the platform has nothing left to do for it
+	var status, amountWei string
+	sqlDB, err := s.db.SQL()
+	s.Assertions.Nil(err)
+	err = sqlDB.QueryRow("SELECT status, amount_wei FROM withdrawals WHERE withdrawal_id = $1", objMap["withdrawal_id"]).Scan(&status, &amountWei)
+	s.Assertions.Nil(err)
+	s.Assertions.Equal(models.WithdrawalAwaitingUser, status)
+	s.Assertions.Equal("1000000000", amountWei)
+
+	withdrawals, err := s.db.ListPendingWithdrawals(0, 10)
+	s.Assertions.Nil(err)
+	s.Assertions.Empty(withdrawals)
+}
+
+func (s *UnitTestSuite) TestSubmitWithdrawalWithInvalidSignatureShouldFail() {
+	user := test.CreateDummyExternalUser(uuid.New(), "test")
+	err := s.db.CreateUser(user)
+	s.Assertions.Nil(err)
+	s.imx.Fund(user.Address, big.NewInt(10))
+
+	// the payload was signed for another user, IMX rejects the signatures of the caller
+	request := &models.SignatureRequest{
+		ID:              uuid.New(),
+		UserID:          user.ID,
+		Type:            models.SignatureRequestWithdrawal,
+		Status:          models.SignatureRequestPending,
+		SignableMessage: "Withdraw 10 wei",
+		PayloadHash:     "hash",
+		Payload:         `{"amount_wei":"10","user":"0x0000000000000000000000000000000000000001","nonce":"1"}`,
+		ExpiresAt:       time.Now().Add(time.Minute).UnixMilli(),
+	}
+	err = s.db.CreateSignatureRequest(request)
+	s.Assertions.Nil(err)
+
+	var jsonStr = []byte(`{"eth_signature":"0x01", "stark_signature":"0x02"}`)
+	req, _ := http.NewRequest("POST", "/withdrawals/"+request.ID.String()+"/signatures", bytes.NewBuffer(jsonStr))
+	req.Header.Set(auth.DebugUserHeader, user.ID.String())
+	response := s.executeRequest(req)
+	s.checkResponseCode(http.StatusBadRequest, response.Code)
+
+	objMap := map[string]interface{}{}
+	err = json.Unmarshal(response.Body.Bytes(), &objMap)
+	s.Assertions.Nil(err)
+	s.Assertions.Equal(float64(handlers.AppCodeIMXInvalidSignature), objMap["code"])
+	s.Assertions.Equal(big.NewInt(10), s.imx.Balance(user.Address))
+}
+
+func (s *UnitTestSuite) TestCreateDepositWithExternalUserShouldFail() {
+	user := test.CreateDummyExternalUser(uuid.New(), "test")
+	err := s.db.CreateUser(user)
+	s.Assertions.Nil(err)
+
+	var jsonStr = []byte(`{"amount_wei":"1000000000"}`)
+	req, _ := http.NewRequest("POST", "/deposits", bytes.NewBuffer(jsonStr))
+
+	req.Header.Set(auth.DebugUserHeader, user.ID.String())
+	response := s.executeRequest(req)
+
+	s.checkResponseCode(http.StatusBadRequest, response.Code)
+}
+
+func (s *UnitTestSuite) TestCreateTokenWithoutAuthenticationShouldFail() {
+	var jsonStr = []byte(`{"collection_id":"` + uuid.NewString() + `", "token_id": "1", "blueprint": "123456" }`)
+	req, _ := http.NewRequest("POST", "/tokens", bytes.NewBuffer(jsonStr))
+	response := s.executeRequest(req)
+	s.checkResponseCode(http.StatusUnauthorized, response.Code)
+}
+
+func (s *UnitTestSuite) TestCreateTokenWithUnknownDebugUserShouldFail() {
+	var jsonStr = []byte(`{"collection_id":"` + uuid.NewString() + `", "token_id": "1", "blueprint": "123456" }`)
+	req, _ := http.NewRequest("POST", "/tokens", bytes.NewBuffer(jsonStr))
+	req.Header.Set(auth.DebugUserHeader, uuid.NewString())
+	response := s.executeRequest(req)
+	s.checkResponseCode(http.StatusUnauthorized, response.Code)
+}
+
+func (s *UnitTestSuite) TestCreateTokenWithViewerRoleShouldFail() {
+	owner := test.CreateDummyUser(uuid.New(), "owner")
+	err := s.db.CreateUser(owner)
+	s.Assertions.Nil(err)
+	viewer := test.CreateDummyUser(uuid.New(), "viewer")
+	err = s.db.CreateUser(viewer)
+	s.Assertions.Nil(err)
+	organization := test.CreateDummyOrganization(uuid.New())
+	err = s.db.CreateOrganization(organization, owner.ID)
+	s.Assertions.Nil(err)
+	err = s.db.SaveOrganizationMember(&models.OrganizationMember{OrganizationID: organization.ID, UserID: viewer.ID, Role: models.RoleViewer})
+	s.Assertions.Nil(err)
+	collection := test.CreateDummyCollection(uuid.New(), owner.ID, organization.ID, "address")
+	err = s.db.CreateCollection(collection)
+	s.Assertions.Nil(err)
+
+	var jsonStr = []byte(`{"collection_id":"` + collection.ID.String() + `", "token_id": "1", "blueprint": "123456" }`)
+	req, _ := http.NewRequest("POST", "/tokens", bytes.NewBuffer(jsonStr))
+	req.Header.Set(auth.DebugUserHeader, viewer.ID.String())
+	response := s.executeRequest(req)
+	s.checkResponseCode(http.StatusForbidden, response.Code)
+
+	// promoting the viewer to minter allows minting
+	jsonStr = []byte(`{"user_id":"` + viewer.ID.String() + `", "role": "minter"}`)
+	req, _ = http.NewRequest("PUT", "/organizations/"+organization.ID.String()+"/members", bytes.NewBuffer(jsonStr))
+	req.Header.Set(auth.DebugUserHeader, owner.ID.String())
+	response = s.executeRequest(req)
+	s.checkResponseCode(http.StatusOK, response.Code)
+
+	jsonStr = []byte(`{"collection_id":"` + collection.ID.String() + `", "token_id": "1", "blueprint": "123456" }`)
+	req, _ = http.NewRequest("POST", "/tokens", bytes.NewBuffer(jsonStr))
+	req.Header.Set(auth.DebugUserHeader, viewer.ID.String())
+	response = s.executeRequest(req)
+	s.checkResponseCode(http.StatusCreated, response.Code)
+}
+
+func (s *UnitTestSuite) TestCreateOrganization() {
+	user := test.CreateDummyUser(uuid.New(), "test")
+	err := s.db.CreateUser(user)
+	s.Assertions.Nil(err)
+
+	var jsonStr = []byte(`{"name":"studio"}`)
+	req, _ := http.NewRequest("POST", "/organizations", bytes.NewBuffer(jsonStr))
+	req.Header.Set(auth.DebugUserHeader, user.ID.String())
+	response := s.executeRequest(req)
+	s.checkResponseCode(http.StatusCreated, response.Code)
+
+	objMap := map[string]string{}
+	err = json.Unmarshal(response.Body.Bytes(), &objMap)
+	s.Assertions.Nil(err)
+	s.Assertions.NotEmpty(objMap["id"])
+	s.Assertions.Equal("studio", objMap["name"])
+
+	// the last owner cannot leave the organization
+	req, _ = http.NewRequest("DELETE", "/organizations/"+objMap["id"]+"/members/"+user.ID.String(), nil)
+	req.Header.Set(auth.DebugUserHeader, user.ID.String())
+	response = s.executeRequest(req)
+	s.checkResponseCode(http.StatusBadRequest, response.Code)
+}
+
+func (s *UnitTestSuite) TestSaveOrganizationMemberWithoutOwnerRoleShouldFail() {
+	owner := test.CreateDummyUser(uuid.New(), "owner")
+	err := s.db.CreateUser(owner)
+	s.Assertions.Nil(err)
+	minter := test.CreateDummyUser(uuid.New(), "minter")
+	err = s.db.CreateUser(minter)
+	s.Assertions.Nil(err)
+	organization := test.CreateDummyOrganization(uuid.New())
+	err = s.db.CreateOrganization(organization, owner.ID)
+	s.Assertions.Nil(err)
+	err = s.db.SaveOrganizationMember(&models.OrganizationMember{OrganizationID: organization.ID, UserID: minter.ID, Role: models.RoleMinter})
+	s.Assertions.Nil(err)
+
+	var jsonStr = []byte(`{"user_id":"` + minter.ID.String() + `", "role": "owner"}`)
+	req, _ := http.NewRequest("PUT", "/organizations/"+organization.ID.String()+"/members", bytes.NewBuffer(jsonStr))
+	req.Header.Set(auth.DebugUserHeader, minter.ID.String())
+	response := s.executeRequest(req)
+	s.checkResponseCode(http.StatusForbidden, response.Code)
+}
+
+func (s *UnitTestSuite) TestAdminDisableUser() {
+	admin := test.CreateDummyUser(uuid.New(), "admin")
+	admin.Admin = true
+	err := s.db.CreateUser(admin)
+	s.Assertions.Nil(err)
+	user := test.CreateDummyUser(uuid.New(), "test")
+	err = s.db.CreateUser(user)
+	s.Assertions.Nil(err)
+
+	req, _ := http.NewRequest("POST", "/admin/users/"+user.ID.String()+"/disable", nil)
+	req.Header.Set(auth.DebugUserHeader, admin.ID.String())
+	response := s.executeRequest(req)
+	s.checkResponseCode(http.StatusOK, response.Code)
+
+	objMap := map[string]interface{}{}
+	err = json.Unmarshal(response.Body.Bytes(), &objMap)
+	s.Assertions.Nil(err)
+	s.Assertions.Equal(true, objMap["disabled"])
+	s.Assertions.Empty(objMap["api_key"])
+
+	// disabled users cannot authenticate anymore
+	req, _ = http.NewRequest("POST", "/organizations", bytes.NewBuffer([]byte(`{"name":"studio"}`)))
+	req.Header.Set(auth.DebugUserHeader, user.ID.String())
+	response = s.executeRequest(req)
+	s.checkResponseCode(http.StatusUnauthorized, response.Code)
+}
+
+func (s *UnitTestSuite) TestAdminHideCollection() {
+	admin := test.CreateDummyUser(uuid.New(), "admin")
+	admin.Admin = true
+	err := s.db.CreateUser(admin)
+	s.Assertions.Nil(err)
+	user := test.CreateDummyUser(uuid.New(), "test")
+	err = s.db.CreateUser(user)
+	s.Assertions.Nil(err)
+	organization := test.CreateDummyOrganization(uuid.New())
+	err = s.db.CreateOrganization(organization, user.ID)
+	s.Assertions.Nil(err)
+	collection := test.CreateDummyCollection(uuid.New(), user.ID, organization.ID, "address")
+	err = s.db.CreateCollection(collection)
+	s.Assertions.Nil(err)
+
+	req, _ := http.NewRequest("POST", "/admin/collections/"+collection.ID.String()+"/hide", nil)
+	req.Header.Set(auth.DebugUserHeader, admin.ID.String())
+	response := s.executeRequest(req)
+	s.checkResponseCode(http.StatusOK, response.Code)
+
+	// hidden collections cannot be minted into
+	var jsonStr = []byte(`{"collection_id":"` + collection.ID.String() + `", "token_id": "1", "blueprint": "123456" }`)
+	req, _ = http.NewRequest("POST", "/tokens", bytes.NewBuffer(jsonStr))
+	req.Header.Set(auth.DebugUserHeader, user.ID.String())
+	response = s.executeRequest(req)
+	s.checkResponseCode(http.StatusBadRequest, response.Code)
+}
+
+func (s *UnitTestSuite) TestAdminListDiscrepancies() {
+	admin := test.CreateDummyUser(uuid.New(), "admin")
+	admin.Admin = true
+	err := s.db.CreateUser(admin)
+	s.Assertions.Nil(err)
+	collectionID := uuid.New()
+	discrepancies := []models.Discrepancy{
+		{ID: uuid.New(), CollectionID: collectionID, Kind: models.DiscrepancyTokenMissingOnIMX, Reference: "1"},
+		{ID: uuid.New(), CollectionID: uuid.New(), Kind: models.DiscrepancyOrderMissingOnIMX, Reference: "2"},
+	}
+	err = s.db.SaveReconciliation(collectionID, nil, nil, discrepancies[:1])
+	s.Assertions.Nil(err)
+	err = s.db.SaveReconciliation(discrepancies[1].CollectionID, nil, nil, discrepancies[1:])
+	s.Assertions.Nil(err)
+
+	req, _ := http.NewRequest("GET", "/admin/discrepancies?collection_id="+collectionID.String(), nil)
+	req.Header.Set(auth.DebugUserHeader, admin.ID.String())
+	response := s.executeRequest(req)
+	s.checkResponseCode(http.StatusOK, response.Code)
+
+	var list []map[string]interface{}
+	err = json.Unmarshal(response.Body.Bytes(), &list)
+	s.Assertions.Nil(err)
+	s.Assertions.Len(list, 1)
+	s.Assertions.Equal(models.DiscrepancyTokenMissingOnIMX, list[0]["kind"])
+}
+
+func (s *UnitTestSuite) TestAdminListPendingWithdrawals() {
+	admin := test.CreateDummyUser(uuid.New(), "admin")
+	admin.Admin = true
+	err := s.db.CreateUser(admin)
+	s.Assertions.Nil(err)
+	user := test.CreateDummyUser(uuid.New(), "test")
+	err = s.db.CreateUser(user)
+	s.Assertions.Nil(err)
+
+	statuses := []string{models.WithdrawalPending, models.WithdrawalCompleted, models.WithdrawalPending}
+	for i, status := range statuses {
+		withdrawalID := int32(i + 1)
+		withdrawal := &models.Withdrawal{ID: uuid.New(), UserID: user.ID, WithdrawalID: &withdrawalID, AmountWei: "10", Status: status, CreatedAt: int64(i + 1)}
+		err = s.db.CreateWithdrawal(withdrawal)
+		s.Assertions.Nil(err)
+	}
+
+	req, _ := http.NewRequest("GET", "/admin/withdrawals/pending?offset=1&limit=1", nil)
+	req.Header.Set(auth.DebugUserHeader, admin.ID.String())
+	response := s.executeRequest(req)
+	s.checkResponseCode(http.StatusOK, response.Code)
+
+	var list []map[string]interface{}
+	err = json.Unmarshal(response.Body.Bytes(), &list)
+	s.Assertions.Nil(err)
+	s.Assertions.Len(list, 1)
+	s.Assertions.Equal(float64(3), list[0]["withdrawal_id"])
+	s.Assertions.Equal(models.WithdrawalPending, list[0]["status"])
+}
+
+func (s *UnitTestSuite) TestAdminListAuditEvents() {
+	admin := test.CreateDummyUser(uuid.New(), "admin")
+	admin.Admin = true
+	err := s.db.CreateUser(admin)
+	s.Assertions.Nil(err)
+	user := test.CreateDummyUser(uuid.New(), "test")
+	err = s.db.CreateUser(user)
+	s.Assertions.Nil(err)
+
+	req, _ := http.NewRequest("POST", "/organizations", bytes.NewBuffer([]byte(`{"name":"studio"}`)))
+	req.Header.Set(auth.DebugUserHeader, user.ID.String())
+	response := s.executeRequest(req)
+	s.checkResponseCode(http.StatusCreated, response.Code)
+
+	req, _ = http.NewRequest("GET", "/admin/audit?actor_id="+user.ID.String(), nil)
+	req.Header.Set(auth.DebugUserHeader, admin.ID.String())
+	response = s.executeRequest(req)
+	s.checkResponseCode(http.StatusOK, response.Code)
+
+	var list []map[string]interface{}
+	err = json.Unmarshal(response.Body.Bytes(), &list)
+	s.Assertions.Nil(err)
+	s.Assertions.Len(list, 1)
+	s.Assertions.Equal("organization.create", list[0]["action"])
+	s.Assertions.Equal("organization", list[0]["target_type"])
+	s.Assertions.Equal(models.AuditSuccess, list[0]["outcome"])
+	s.Assertions.NotEmpty(list[0]["request_id"])
+
+	req, _ = http.NewRequest("GET", "/admin/audit?from=not-a-time", nil)
+	req.Header.Set(auth.DebugUserHeader, admin.ID.String())
+	response = s.executeRequest(req)
+	s.checkResponseCode(http.StatusBadRequest, response.Code)
+}
+
+func (s *UnitTestSuite) TestAdminWithoutAdminScopeShouldFail() {
+	user := test.CreateDummyUser(uuid.New(), "test")
+	err := s.db.CreateUser(user)
+	s.Assertions.Nil(err)
+
+	req, _ := http.NewRequest("GET", "/admin/users", nil)
+	req.Header.Set(auth.DebugUserHeader, user.ID.String())
+	response := s.executeRequest(req)
+	s.checkResponseCode(http.StatusForbidden, response.Code)
+}
+
+func (s *UnitTestSuite) TestWebhooks() {
+	user := test.CreateDummyUser(uuid.New(), "test")
+	err := s.db.CreateUser(user)
+	s.Assertions.Nil(err)
+
+	var jsonStr = []byte(`{"url":"https://example.com/hook", "events": ["order.created", "token.minted"]}`)
+	req, _ := http.NewRequest("POST", "/webhooks", bytes.NewBuffer(jsonStr))
+	req.Header.Set(auth.DebugUserHeader, user.ID.String())
+	response := s.executeRequest(req)
+	s.checkResponseCode(http.StatusCreated, response.Code)
+
+	created := map[string]interface{}{}
+	err = json.Unmarshal(response.Body.Bytes(), &created)
+	s.Assertions.Nil(err)
+	s.Assertions.NotEmpty(created["secret"])
+	s.Assertions.Equal([]interface{}{"order.created", "token.minted"}, created["events"])
+
+	req, _ = http.NewRequest("GET", "/webhooks", nil)
+	req.Header.Set(auth.DebugUserHeader, user.ID.String())
+	response = s.executeRequest(req)
+	s.checkResponseCode(http.StatusOK, response.Code)
+
+	var list []map[string]interface{}
+	err = json.Unmarshal(response.Body.Bytes(), &list)
+	s.Assertions.Nil(err)
+	s.Assertions.Len(list, 1)
+	s.Assertions.Nil(list[0]["secret"])
+
+	other := test.CreateDummyUser(uuid.New(), "other")
+	err = s.db.CreateUser(other)
+	s.Assertions.Nil(err)
+	req, _ = http.NewRequest("DELETE", "/webhooks/"+created["id"].(string), nil)
+	req.Header.Set(auth.DebugUserHeader, other.ID.String())
+	response = s.executeRequest(req)
+	s.checkResponseCode(http.StatusNotFound, response.Code)
+
+	req.Header.Set(auth.DebugUserHeader, user.ID.String())
+	response = s.executeRequest(req)
+	s.checkResponseCode(http.StatusNoContent, response.Code)
+}
+
+func (s *UnitTestSuite) TestCreateWebhookWithInvalidEventShouldFail() {
+	user := test.CreateDummyUser(uuid.New(), "test")
+	err := s.db.CreateUser(user)
+	s.Assertions.Nil(err)
+
+	var jsonStr = []byte(`{"url":"https://example.com/hook", "events": ["order.deleted"]}`)
+	req, _ := http.NewRequest("POST", "/webhooks", bytes.NewBuffer(jsonStr))
+	req.Header.Set(auth.DebugUserHeader, user.ID.String())
+	response := s.executeRequest(req)
+	s.checkResponseCode(http.StatusBadRequest, response.Code)
+}
+
+func (s *UnitTestSuite) TestCreateOrderPublishesEvent() {
+	user := test.CreateDummyUser(uuid.New(), "test")
+	err := s.db.CreateUser(user)
+	s.Assertions.Nil(err)
+	organization := test.CreateDummyOrganization(uuid.New())
+	err = s.db.CreateOrganization(organization, user.ID)
+	s.Assertions.Nil(err)
+	collection := test.CreateDummyCollection(uuid.New(), user.ID, organization.ID, "address")
+	err = s.db.CreateCollection(collection)
+	s.Assertions.Nil(err)
+	token := test.CreateDummyToken(uuid.New(), collection.ID, "1")
+	err = s.db.CreateToken(token)
+	s.Assertions.Nil(err)
+	s.mintOnIMX(collection, token)
+	err = s.db.CreateWebhook(&models.Webhook{ID: uuid.New(), UserID: user.ID, URL: "https://example.com/hook", Secret: "secret", Events: events.OrderCreated})
+	s.Assertions.Nil(err)
+
+	var jsonStr = []byte(`{"collection_id":"` + collection.ID.String() + `", "token_id":"` + token.ID.String() + `", "amount": "1000000"}`)
+	req, _ := http.NewRequest("POST", "/orders", bytes.NewBuffer(jsonStr))
+	req.Header.Set(auth.DebugUserHeader, user.ID.String())
+	response := s.executeRequest(req)
+	s.checkResponseCode(http.StatusCreated, response.Code)
+
+	messages, err := s.db.ListUnsentOutboxMessages(10)
+	s.Assertions.Nil(err)
+	s.Assertions.Len(messages, 1)
+	s.Assertions.Equal(events.TypePublishEvent, messages[0].TaskType)
+
+	// publishing again, as a retried task does, saves the delivery once
+	stream := events.NewStream(redis.NewClient(&redis.Options{Addr: s.server.config.RedisUrl}))
+	processor := tasks.NewPublishEventProcessor(events.NewPublisher(s.db, stream))
+	task := asynq.NewTask(messages[0].TaskType, messages[0].Payload)
+	s.Assertions.Nil(processor.ProcessTask(context.Background(), task))
+	s.Assertions.Nil(processor.ProcessTask(context.Background(), task))
+
+	err = s.db.SetOutboxMessageSent(messages[0].ID, time.Now().UnixMilli())
+	s.Assertions.Nil(err)
+	messages, err = s.db.ListUnsentOutboxMessages(10)
+	s.Assertions.Nil(err)
+	s.Assertions.Len(messages, 1)
+	s.Assertions.Equal(events.TypeDeliverWebhook, messages[0].TaskType)
+}
+
+func (s *UnitTestSuite) TestStreamEventsResumesFromHistory() {
+	user := test.CreateDummyUser(uuid.New(), "test")
+	err := s.db.CreateUser(user)
+	s.Assertions.Nil(err)
+
+	stream := events.NewStream(redis.NewClient(&redis.Options{Addr: s.server.config.RedisUrl}))
+	err = events.NewPublisher(s.db, stream).Publish(context.Background(), user.ID, events.OrderCreated, events.Order{OrderID: 1})
+	s.Assertions.Nil(err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 500*time.Millisecond)
+	defer cancel()
+	req, _ := http.NewRequestWithContext(ctx, "GET", "/events/stream", nil)
+	req.Header.Set(auth.DebugUserHeader, user.ID.String())
+	req.Header.Set("Last-Event-ID", "0-0")
+	response := s.executeRequest(req)
+
+	s.checkResponseCode(http.StatusOK, response.Code)
+	s.Assertions.Equal("text/event-stream", response.Header().Get("Content-Type"))
+	s.Assertions.Contains(response.Body.String(), "event: order.created")
+}
+
+func (s *UnitTestSuite) TestShutdownEndsEventStreams() {
+	user := test.CreateDummyUser(uuid.New(), "test")
+	err := s.db.CreateUser(user)
+	s.Assertions.Nil(err)
+
+	httpServer := httptest.NewUnstartedServer(s.server.Router)
+	httpServer.Config.RegisterOnShutdown(s.server.CloseStreams)
+	httpServer.Start()
+	defer httpServer.Close()
+
+	req, _ := http.NewRequest("GET", httpServer.URL+"/events/stream", nil)
+	req.Header.Set(auth.DebugUserHeader, user.ID.String())
+	response, err := http.DefaultClient.Do(req)
+	s.Require().Nil(err)
+	defer response.Body.Close()
+	s.checkResponseCode(http.StatusOK, response.StatusCode)
+
+	// the stream is open, shutdown must not wait for its deadline
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	s.Assertions.Nil(httpServer.Config.Shutdown(ctx))
+}
+
+func (s *UnitTestSuite) TestCreateCollectionWithoutParamsShouldFail() {
+	user := test.CreateDummyUser(uuid.New(), "test")
+	err := s.db.CreateUser(user)
+	s.Assertions.Nil(err)
+
+	req, _ := http.NewRequest("POST", "/collections", nil)
+	req.Header.Set(auth.DebugUserHeader, user.ID.String())
+	response := s.executeRequest(req)
+	s.checkResponseCode(http.StatusInternalServerError, response.Code)
+}
+
+func (s *UnitTestSuite) TestCreateTokenWithoutParamsShouldFail() {
+	user := test.CreateDummyUser(uuid.New(), "test")
+	err := s.db.CreateUser(user)
+	s.Assertions.Nil(err)
+
+	req, _ := http.NewRequest("POST", "/tokens", nil)
+	req.Header.Set(auth.DebugUserHeader, user.ID.String())
+	response := s.executeRequest(req)
+	s.checkResponseCode(http.StatusInternalServerError, response.Code)
+}
+
+func (s *UnitTestSuite) TestTransferTokenWithoutParamsShouldFail() {
+	user := test.CreateDummyUser(uuid.New(), "test")
+	err := s.db.CreateUser(user)
+	s.Assertions.Nil(err)
+
+	req, _ := http.NewRequest("POST", "/transfers", nil)
+	req.Header.Set(auth.DebugUserHeader, user.ID.String())
+	response := s.executeRequest(req)
+	s.checkResponseCode(http.StatusInternalServerError, response.Code)
+}
+
+func (s *UnitTestSuite) TestCreateOrderWithoutParamsShouldFail() {
+	user := test.CreateDummyUser(uuid.New(), "test")
+	err := s.db.CreateUser(user)
+	s.Assertions.Nil(err)
+
+	req, _ := http.NewRequest("POST", "/orders", nil)
+	req.Header.Set(auth.DebugUserHeader, user.ID.String())
+	response := s.executeRequest(req)
+	s.checkResponseCode(http.StatusInternalServerError, response.Code)
+}
+
+func (s *UnitTestSuite) TestCreateDepositWithoutParamsShouldFail() {
+	user := test.CreateDummyUser(uuid.New(), "test")
+	err := s.db.CreateUser(user)
+	s.Assertions.Nil(err)
+
+	req, _ := http.NewRequest("POST", "/deposits", nil)
+	req.Header.Set(auth.DebugUserHeader, user.ID.String())
+	response := s.executeRequest(req)
+	s.checkResponseCode(http.StatusInternalServerError, response.Code)
+}
+
+func (s *UnitTestSuite) TestCreateTradeWithoutParamsShouldFail() {
+	user := test.CreateDummyUser(uuid.New(), "test")
+	err := s.db.CreateUser(user)
+	s.Assertions.Nil(err)
+
+	req, _ := http.NewRequest("POST", "/trades", nil)
+	req.Header.Set(auth.DebugUserHeader, user.ID.String())
+	response := s.executeRequest(req)
+	s.checkResponseCode(http.StatusInternalServerError, response.Code)
 }
 
 func (s *UnitTestSuite) TestCreateWithdrawalWithoutParamsShouldFail() {
+	user := test.CreateDummyUser(uuid.New(), "test")
+	err := s.db.CreateUser(user)
+	s.Assertions.Nil(err)
+
 	req, _ := http.NewRequest("POST", "/withdrawals", nil)
+	req.Header.Set(auth.DebugUserHeader, user.ID.String())
 	response := s.executeRequest(req)
 	s.checkResponseCode(http.StatusInternalServerError, response.Code)
 }
@@ -3,31 +3,49 @@ package tasks
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"log"
+	"nft/audit"
 	"nft/db"
+	"nft/events"
 	"nft/imx"
+	"nft/logging"
+	"nft/models"
+	"nft/tracing"
+	"strconv"
+	"time"
 
 	"github.com/google/uuid"
 
 	"github.com/hibiken/asynq"
+	"golang.org/x/exp/slog"
 )
 
 const (
+	// QueueDefault is the asynq queue tasks are enqueued to when no queue option is given.
+	QueueDefault = "default"
+
 	TypeCompleteWithdrawal = "withdrawal:complete"
 )
 
 type CompleteWithdrawalPayload struct {
-	WithdrawalID int32
-	UserID       uuid.UUID
+	ID     uuid.UUID
+	UserID uuid.UUID
 }
 
-func NewCompleteWithdrawalTask(withdrawalID int32, userID uuid.UUID) (*asynq.Task, error) {
-	payload, err := json.Marshal(CompleteWithdrawalPayload{withdrawalID, userID})
+// NewCompleteWithdrawalMessage creates the outbox message of the task completing the withdrawal on L1
+// once it can be processed at processAt. It is saved with the withdrawal, before its IMX ID is known.
+func NewCompleteWithdrawalMessage(ctx context.Context, id uuid.UUID, userID uuid.UUID, processAt time.Time) (*models.OutboxMessage, error) {
+	payload, err := json.Marshal(CompleteWithdrawalPayload{id, userID})
 	if err != nil {
 		return nil, err
 	}
-	return asynq.NewTask(TypeCompleteWithdrawal, payload), nil
+	return &models.OutboxMessage{
+		ID:        uuid.New(),
+		TaskType:  TypeCompleteWithdrawal,
+		Payload:   tracing.InjectPayload(ctx, payload),
+		ProcessAt: processAt.UnixMilli(),
+	}, nil
 }
 
 type CompleteWithdrawalProcessor struct {
@@ -40,9 +58,36 @@ func (processor *CompleteWithdrawalProcessor) ProcessTask(ctx context.Context, t
 	if err := json.Unmarshal(t.Payload(), &p); err != nil {
 		return fmt.Errorf("json.Unmarshal failed: %v: %w", err, asynq.SkipRetry)
 	}
-	log.Printf("withdrawal_id=%d", p.WithdrawalID)
+	ctx = logging.With(ctx, "id", p.ID.String(), "user_id", p.UserID.String())
+	audit.SetActor(ctx, p.UserID)
+
+	withdrawal, err := processor.db.WithContext(ctx).GetWithdrawal(p.ID)
+	if err != nil {
+		return err
+	}
+
+	if withdrawal == nil {
+		// IMX refused to create it
+		return fmt.Errorf("withdrawal not exists: %v: %w", p.ID, asynq.SkipRetry)
+	}
+
+	if withdrawal.WithdrawalID == nil {
+		// its creation timed out, or its IMX ID could not be saved. It is retried until it is reconciled.
+		return fmt.Errorf("withdrawal %v is still being created", p.ID)
+	}
+
+	if withdrawal.Status == models.WithdrawalCompleted {
+		// already processed by a previous attempt
+		return nil
+	}
+
+	withdrawalID := *withdrawal.WithdrawalID
+	ctx = logging.With(ctx, "withdrawal_id", withdrawalID)
+	slog.InfoContext(ctx, "completing withdrawal")
+	audit.Describe(ctx, "withdrawal.complete", "withdrawal", strconv.FormatInt(int64(withdrawalID), 10))
+	audit.SetIMXID(ctx, strconv.FormatInt(int64(withdrawalID), 10))
 
-	user, err := processor.db.GetUser(p.UserID)
+	user, err := processor.db.WithContext(ctx).GetUser(p.UserID)
 	if err != nil {
 		return err
 	}
@@ -53,15 +98,27 @@ func (processor *CompleteWithdrawalProcessor) ProcessTask(ctx context.Context, t
 
 	info := imx.CompleteWithdrawalInformation{
 		User:         user,
-		WithdrawalID: p.WithdrawalID,
+		WithdrawalID: withdrawalID,
 	}
 
 	err = processor.imx.CompleteEthWithdrawal(ctx, &info)
+	if errors.Is(err, imx.ErrConflict) {
+		// completed by a previous attempt whose status was not saved, it is not submitted again
+		slog.InfoContext(ctx, "withdrawal already completed on L1")
+		err = nil
+	}
 	if err != nil {
 		return err
 	}
 
-	return nil
+	message, err := events.NewMessage(ctx, p.UserID, events.WithdrawalCompleted, events.Withdrawal{WithdrawalID: withdrawalID})
+	if err != nil {
+		return fmt.Errorf("%v: %w", err, asynq.SkipRetry)
+	}
+	message.ID = uuid.NewSHA1(uuid.NameSpaceOID, []byte(events.WithdrawalCompleted+":"+withdrawal.ID.String()))
+
+	// saved with its event, which is published once the withdrawal is completed whatever happens to the task
+	return processor.db.WithContext(ctx).SetWithdrawalStatus(withdrawalID, models.WithdrawalCompleted, message)
 }
 
 func NewCompleteWithdrawalProcessor(imx imx.Client, db *db.DB) *CompleteWithdrawalProcessor {
@@ -0,0 +1,101 @@
+package tasks
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"nft/audit"
+	"nft/db"
+	"nft/events"
+	"nft/imx"
+	"nft/logging"
+	"nft/models"
+	"nft/tracing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/hibiken/asynq"
+	"golang.org/x/exp/slog"
+)
+
+const (
+	TypeConfirmDeposit = "deposit:confirm"
+
+	// depositDeadline is how long a deposit transaction may stay unmined, it was most likely dropped after.
+	depositDeadline = 24 * time.Hour
+)
+
+type ConfirmDepositPayload struct {
+	TransactionHash string
+	AmountWei       string
+	UserID          uuid.UUID
+	// Deadline is when the deposit is given up on, in unix milliseconds. Zero waits forever.
+	Deadline int64
+}
+
+// NewConfirmDepositMessage creates the outbox message of the task waiting for the deposit
+// transaction to be mined.
+func NewConfirmDepositMessage(ctx context.Context, transactionHash string, amountWei string, userID uuid.UUID) (*models.OutboxMessage, error) {
+	now := time.Now()
+	payload, err := json.Marshal(ConfirmDepositPayload{transactionHash, amountWei, userID, now.Add(depositDeadline).UnixMilli()})
+	if err != nil {
+		return nil, err
+	}
+	return &models.OutboxMessage{
+		ID:        uuid.New(),
+		TaskType:  TypeConfirmDeposit,
+		Payload:   tracing.InjectPayload(ctx, payload),
+		ProcessAt: now.Add(time.Minute).UnixMilli(),
+	}, nil
+}
+
+type ConfirmDepositProcessor struct {
+	imx imx.Client
+	db  *db.DB
+}
+
+func (processor *ConfirmDepositProcessor) ProcessTask(ctx context.Context, t *asynq.Task) error {
+	var p ConfirmDepositPayload
+	if err := json.Unmarshal(t.Payload(), &p); err != nil {
+		return fmt.Errorf("json.Unmarshal failed: %v: %w", err, asynq.SkipRetry)
+	}
+	ctx = logging.With(ctx, "tx_hash", p.TransactionHash, "user_id", p.UserID.String())
+	slog.InfoContext(ctx, "confirming deposit")
+	audit.Describe(ctx, "deposit.confirm", "deposit", p.TransactionHash)
+	audit.SetActor(ctx, p.UserID)
+	audit.SetIMXID(ctx, p.TransactionHash)
+
+	data := events.Deposit{TransactionHash: p.TransactionHash, AmountWei: p.AmountWei}
+
+	err := processor.imx.ConfirmEthDeposit(ctx, p.TransactionHash)
+	var notConfirmed imx.DepositNotConfirmedError
+	if errors.As(err, &notConfirmed) && p.Deadline > 0 && time.Now().UnixMilli() > p.Deadline {
+		slog.WarnContext(ctx, "deposit not mined before its deadline, giving up")
+		if err = processor.publish(ctx, p.UserID, events.DepositFailed, data); err != nil {
+			return err
+		}
+		return fmt.Errorf("deposit transaction %s not mined in %s: %w", p.TransactionHash, depositDeadline, asynq.SkipRetry)
+	}
+	if err != nil {
+		return err
+	}
+
+	return processor.publish(ctx, p.UserID, events.DepositConfirmed, data)
+}
+
+// publish saves the event of the deposit once, however many times the task is retried after saving it.
+func (processor *ConfirmDepositProcessor) publish(ctx context.Context, userID uuid.UUID, eventType string, data events.Deposit) error {
+	message, err := events.NewMessage(ctx, userID, eventType, data)
+	if err != nil {
+		return fmt.Errorf("%v: %w", err, asynq.SkipRetry)
+	}
+	message.ID = uuid.NewSHA1(uuid.NameSpaceOID, []byte(eventType+":"+data.TransactionHash))
+
+	return processor.db.WithContext(ctx).CreateOutboxMessages(message)
+}
+
+func NewConfirmDepositProcessor(imx imx.Client, db *db.DB) *ConfirmDepositProcessor {
+	return &ConfirmDepositProcessor{imx, db}
+}
@@ -0,0 +1,132 @@
+package tasks
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"nft/db"
+	"nft/events"
+	"nft/logging"
+	"nft/models"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/hibiken/asynq"
+	"golang.org/x/exp/slog"
+)
+
+const (
+	TypeDeliverWebhook = events.TypeDeliverWebhook
+
+	WebhookIDHeader        = "X-Webhook-ID"
+	WebhookEventHeader     = "X-Webhook-Event"
+	WebhookTimestampHeader = "X-Webhook-Timestamp"
+	WebhookSignatureHeader = "X-Webhook-Signature"
+
+	webhookTimeout = 10 * time.Second
+)
+
+// DeliverWebhookProcessor posts an event to a webhook and records the attempt in its delivery log.
+// Failed deliveries are retried by asynq with exponential backoff.
+type DeliverWebhookProcessor struct {
+	db     *db.DB
+	client *http.Client
+}
+
+func (processor *DeliverWebhookProcessor) ProcessTask(ctx context.Context, t *asynq.Task) error {
+	var p events.DeliveryPayload
+	if err := json.Unmarshal(t.Payload(), &p); err != nil {
+		return fmt.Errorf("json.Unmarshal failed: %v: %w", err, asynq.SkipRetry)
+	}
+	ctx = logging.With(ctx, "webhook_id", p.WebhookID.String(), "event_id", p.Event.ID.String())
+	slog.InfoContext(ctx, "delivering webhook")
+
+	webhook, err := processor.db.WithContext(ctx).GetWebhook(p.WebhookID)
+	if err != nil {
+		return err
+	}
+
+	if webhook == nil {
+		// deleted after the event was published
+		return nil
+	}
+
+	delivery := models.WebhookDelivery{
+		ID:        uuid.New(),
+		WebhookID: webhook.ID,
+		EventID:   p.Event.ID,
+		Event:     p.Event.Type,
+		Attempt:   attempt(ctx),
+	}
+
+	deliveryErr := processor.post(ctx, webhook, &p.Event, &delivery)
+	if deliveryErr != nil {
+		delivery.Error = deliveryErr.Error()
+	}
+	delivery.Success = deliveryErr == nil
+
+	if err = processor.db.WithContext(ctx).CreateWebhookDelivery(&delivery); err != nil {
+		slog.ErrorContext(ctx, "error saving webhook delivery", "err", err)
+	}
+
+	return deliveryErr
+}
+
+func (processor *DeliverWebhookProcessor) post(ctx context.Context, webhook *models.Webhook, event *events.Event, delivery *models.WebhookDelivery) error {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("json.Marshal failed: %v: %w", err, asynq.SkipRetry)
+	}
+
+	// only public https endpoints are delivered to, the same rule as when the webhook is saved
+	if err = events.ValidateWebhookURL(webhook.URL); err != nil {
+		return fmt.Errorf("invalid webhook url: %v: %w", err, asynq.SkipRetry)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, webhookTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, webhook.URL, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("invalid webhook url: %v: %w", err, asynq.SkipRetry)
+	}
+
+	timestamp := time.Now().Unix()
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(WebhookIDHeader, event.ID.String())
+	req.Header.Set(WebhookEventHeader, event.Type)
+	req.Header.Set(WebhookTimestampHeader, strconv.FormatInt(timestamp, 10))
+	req.Header.Set(WebhookSignatureHeader, "sha256="+events.Sign(webhook.Secret, timestamp, body))
+
+	resp, err := processor.client.Do(req)
+	if errors.Is(err, events.ErrForbiddenAddress) {
+		return fmt.Errorf("%v: %w", err, asynq.SkipRetry)
+	}
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	delivery.StatusCode = resp.StatusCode
+	// redirects are not followed, they are failures like any other status
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook responded with status %d", resp.StatusCode)
+	}
+
+	return nil
+}
+
+func NewDeliverWebhookProcessor(db *db.DB) *DeliverWebhookProcessor {
+	return &DeliverWebhookProcessor{db, events.NewWebhookClient(webhookTimeout)}
+}
+
+// attempt returns the number of the current attempt, starting at 1.
+func attempt(ctx context.Context) int {
+	retried, _ := asynq.GetRetryCount(ctx)
+	return retried + 1
+}
@@ -0,0 +1,147 @@
+package tasks
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"nft/audit"
+	"nft/db"
+	"nft/events"
+	"nft/imx"
+	"nft/internal/operation"
+	"nft/logging"
+	"nft/models"
+	"nft/tracing"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/hibiken/asynq"
+	"golang.org/x/exp/slog"
+)
+
+const (
+	TypeMintToken = "token:mint"
+
+	// mintRetention keeps finished mint tasks around so their job status can still be queried.
+	mintRetention = 24 * time.Hour
+)
+
+type MintTokenPayload struct {
+	TokenID uuid.UUID
+	UserID  uuid.UUID
+}
+
+// NewMintTokenMessage creates the outbox message of the task minting a pending token. The task ID
+// is the token ID, so the same token can not be enqueued twice.
+func NewMintTokenMessage(ctx context.Context, tokenID uuid.UUID, userID uuid.UUID) (*models.OutboxMessage, error) {
+	payload, err := json.Marshal(MintTokenPayload{tokenID, userID})
+	if err != nil {
+		return nil, err
+	}
+	return &models.OutboxMessage{
+		ID:               uuid.New(),
+		TaskType:         TypeMintToken,
+		Payload:          tracing.InjectPayload(ctx, payload),
+		TaskID:           tokenID.String(),
+		RetentionSeconds: int64(mintRetention.Seconds()),
+	}, nil
+}
+
+type MintTokenProcessor struct {
+	imx imx.Client
+	db  *db.DB
+}
+
+func (processor *MintTokenProcessor) ProcessTask(ctx context.Context, t *asynq.Task) error {
+	var p MintTokenPayload
+	if err := json.Unmarshal(t.Payload(), &p); err != nil {
+		return fmt.Errorf("json.Unmarshal failed: %v: %w", err, asynq.SkipRetry)
+	}
+	ctx = logging.With(ctx, "token_id", p.TokenID.String(), "user_id", p.UserID.String())
+	slog.InfoContext(ctx, "minting token")
+	audit.Describe(ctx, "token.mint", "token", p.TokenID.String())
+	audit.SetActor(ctx, p.UserID)
+
+	token, err := processor.db.WithContext(ctx).GetToken(p.TokenID)
+	if err != nil {
+		return err
+	}
+
+	if token == nil {
+		return fmt.Errorf("token not exists: %v: %w", p.TokenID, asynq.SkipRetry)
+	}
+
+	if token.Status != models.TokenPending {
+		// already processed by a previous attempt
+		return nil
+	}
+
+	collection, err := processor.db.WithContext(ctx).GetCollection(token.CollectionID)
+	if err != nil {
+		return err
+	}
+
+	if collection == nil {
+		return processor.fail(ctx, token, fmt.Errorf("collection not exists: %v: %w", token.CollectionID, asynq.SkipRetry))
+	}
+
+	info := imx.MintInformation{
+		ContractAddress: collection.ContractAddress,
+		TokenID:         token.TokenID,
+		Blueprint:       token.Blueprint,
+	}
+
+	err = processor.imx.CreateToken(ctx, &info)
+	if errors.Is(err, imx.ErrConflict) {
+		if err = processor.checkMinted(ctx, &info); errors.Is(err, asynq.SkipRetry) {
+			return processor.fail(ctx, token, err)
+		}
+	}
+	if err != nil {
+		if operation.IsLastAttempt(ctx) {
+			return processor.fail(ctx, token, err)
+		}
+		return err
+	}
+
+	token.Status = models.TokenMinted
+	message, err := events.NewMessage(ctx, p.UserID, events.TokenMinted, token)
+	if err != nil {
+		return fmt.Errorf("%v: %w", err, asynq.SkipRetry)
+	}
+	message.ID = uuid.NewSHA1(uuid.NameSpaceOID, []byte(events.TokenMinted+":"+token.ID.String()))
+
+	// saved with its event, which is published once the token is minted whatever happens to the task
+	return processor.db.WithContext(ctx).SetTokenStatus(token.ID, models.TokenMinted, message)
+}
+
+// checkMinted accepts a conflicting mint as made by a previous attempt whose answer was lost, as long as the
+// asset is still held by the platform it is minted to. IMX does not return the blueprint of an asset, the owner
+// is what tells it apart from an asset minted for something else.
+func (processor *MintTokenProcessor) checkMinted(ctx context.Context, info *imx.MintInformation) error {
+	asset, err := processor.imx.GetAsset(ctx, info.ContractAddress, info.TokenID)
+	if err != nil {
+		return err
+	}
+
+	if !strings.EqualFold(asset.User, processor.imx.Address()) {
+		return fmt.Errorf("token already minted to %s: %w", asset.User, asynq.SkipRetry)
+	}
+
+	slog.InfoContext(ctx, "token already minted on IMX")
+	return nil
+}
+
+func (processor *MintTokenProcessor) fail(ctx context.Context, token *models.Token, err error) error {
+	if statusErr := processor.db.WithContext(ctx).SetTokenStatus(token.ID, models.TokenFailed); statusErr != nil {
+		return statusErr
+	}
+	return err
+}
+
+func NewMintTokenProcessor(imx imx.Client, db *db.DB) *MintTokenProcessor {
+	return &MintTokenProcessor{imx, db}
+}
@@ -0,0 +1,44 @@
+package tasks
+
+import (
+	"context"
+	"nft/db"
+	"nft/idempotency"
+	"time"
+
+	"github.com/hibiken/asynq"
+	"golang.org/x/exp/slog"
+)
+
+const (
+	// TypePruneIdempotencyKeys is scheduled periodically and deletes the idempotency keys no longer replayed.
+	TypePruneIdempotencyKeys = "idempotency:prune"
+
+	PruneIdempotencyKeysSchedule = "@hourly"
+)
+
+func NewPruneIdempotencyKeysTask() *asynq.Task {
+	return asynq.NewTask(TypePruneIdempotencyKeys, nil, asynq.Unique(scheduleUniqueness))
+}
+
+type PruneIdempotencyKeysProcessor struct {
+	db *db.DB
+}
+
+func (processor *PruneIdempotencyKeysProcessor) ProcessTask(ctx context.Context, t *asynq.Task) error {
+	now := time.Now()
+	deleted, err := processor.db.WithContext(ctx).DeleteExpiredIdempotencyKeys(
+		now.Add(-idempotency.KeyTTL).UnixMilli(),
+		now.Add(-idempotency.KeyLease).UnixMilli(),
+	)
+	if err != nil {
+		return err
+	}
+
+	slog.InfoContext(ctx, "idempotency keys pruned", "deleted", deleted)
+	return nil
+}
+
+func NewPruneIdempotencyKeysProcessor(db *db.DB) *PruneIdempotencyKeysProcessor {
+	return &PruneIdempotencyKeysProcessor{db}
+}
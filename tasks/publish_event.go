@@ -0,0 +1,34 @@
+package tasks
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"nft/events"
+	"nft/logging"
+
+	"github.com/hibiken/asynq"
+	"golang.org/x/exp/slog"
+)
+
+const TypePublishEvent = events.TypePublishEvent
+
+// PublishEventProcessor publishes the events saved in the outbox with the changes they are about.
+type PublishEventProcessor struct {
+	publisher *events.Publisher
+}
+
+func (processor *PublishEventProcessor) ProcessTask(ctx context.Context, t *asynq.Task) error {
+	var p events.PublishPayload
+	if err := json.Unmarshal(t.Payload(), &p); err != nil {
+		return fmt.Errorf("json.Unmarshal failed: %v: %w", err, asynq.SkipRetry)
+	}
+	ctx = logging.With(ctx, "user_id", p.UserID.String(), "event_id", p.Event.ID.String())
+	slog.InfoContext(ctx, "publishing event", "type", p.Event.Type)
+
+	return processor.publisher.Deliver(ctx, p.UserID, &p.Event)
+}
+
+func NewPublishEventProcessor(publisher *events.Publisher) *PublishEventProcessor {
+	return &PublishEventProcessor{publisher}
+}
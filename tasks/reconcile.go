@@ -0,0 +1,228 @@
+package tasks
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"nft/audit"
+	"nft/db"
+	"nft/imx"
+	"nft/logging"
+	"nft/models"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/hibiken/asynq"
+	"golang.org/x/exp/slog"
+)
+
+const (
+	// TypeReconcileCollections is scheduled periodically and starts the reconciliation of every collection.
+	TypeReconcileCollections = "reconcile:collections"
+	TypeReconcileCollection  = "reconcile:collection"
+
+	// reconcileUniqueness avoids reconciling a collection again while a previous run is queued.
+	reconcileUniqueness = 30 * time.Minute
+
+	// scheduleUniqueness keeps the periodic tasks from being enqueued once per worker, as every worker runs
+	// the scheduler.
+	scheduleUniqueness = 5 * time.Minute
+)
+
+type ReconcileCollectionPayload struct {
+	CollectionID uuid.UUID
+}
+
+func NewReconcileCollectionsTask() *asynq.Task {
+	return asynq.NewTask(TypeReconcileCollections, nil, asynq.Unique(scheduleUniqueness))
+}
+
+func NewReconcileCollectionTask(collectionID uuid.UUID) (*asynq.Task, error) {
+	payload, err := json.Marshal(ReconcileCollectionPayload{collectionID})
+	if err != nil {
+		return nil, err
+	}
+	return asynq.NewTask(TypeReconcileCollection, payload, asynq.Unique(reconcileUniqueness)), nil
+}
+
+// ReconcileCollectionsProcessor enqueues the reconciliation of each collection, so a failing
+// collection is retried on its own.
+type ReconcileCollectionsProcessor struct {
+	db          *db.DB
+	asynqClient *asynq.Client
+}
+
+func (processor *ReconcileCollectionsProcessor) ProcessTask(ctx context.Context, t *asynq.Task) error {
+	collections, err := processor.db.WithContext(ctx).ListCollections()
+	if err != nil {
+		return err
+	}
+
+	for _, collection := range collections {
+		task, err := NewReconcileCollectionTask(collection.ID)
+		if err != nil {
+			return err
+		}
+
+		_, err = processor.asynqClient.EnqueueContext(ctx, task)
+		if err != nil && !errors.Is(err, asynq.ErrDuplicateTask) {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func NewReconcileCollectionsProcessor(db *db.DB, asynqClient *asynq.Client) *ReconcileCollectionsProcessor {
+	return &ReconcileCollectionsProcessor{db, asynqClient}
+}
+
+// ReconcileCollectionProcessor compares the tokens and orders of a collection with IMX. Statuses IMX
+// knows better are fixed, everything else is reported as a discrepancy.
+type ReconcileCollectionProcessor struct {
+	imx imx.Client
+	db  *db.DB
+}
+
+func (processor *ReconcileCollectionProcessor) ProcessTask(ctx context.Context, t *asynq.Task) error {
+	var p ReconcileCollectionPayload
+	if err := json.Unmarshal(t.Payload(), &p); err != nil {
+		return fmt.Errorf("json.Unmarshal failed: %v: %w", err, asynq.SkipRetry)
+	}
+	ctx = logging.With(ctx, "collection_id", p.CollectionID.String())
+	slog.InfoContext(ctx, "reconciling collection")
+	audit.Describe(ctx, "collection.reconcile", "collection", p.CollectionID.String())
+
+	collection, err := processor.db.WithContext(ctx).GetCollection(p.CollectionID)
+	if err != nil {
+		return err
+	}
+
+	if collection == nil {
+		return fmt.Errorf("collection not exists: %v: %w", p.CollectionID, asynq.SkipRetry)
+	}
+
+	tokens, err := processor.db.WithContext(ctx).ListTokensByCollection(collection.ID)
+	if err != nil {
+		return err
+	}
+
+	orders, err := processor.db.WithContext(ctx).ListOrdersByCollection(collection.ID)
+	if err != nil {
+		return err
+	}
+
+	assets, err := processor.imx.ListAssets(ctx, collection.ContractAddress)
+	if err != nil {
+		return err
+	}
+
+	imxOrders, err := processor.imx.ListOrders(ctx, collection.ContractAddress)
+	if err != nil {
+		return err
+	}
+
+	result := Reconcile(collection.ID, tokens, orders, assets, imxOrders)
+	slog.InfoContext(ctx, "collection reconciled", "discrepancies", len(result.Discrepancies))
+	audit.SetDetails(ctx, fmt.Sprintf("%d discrepancies", len(result.Discrepancies)))
+
+	return processor.db.WithContext(ctx).SaveReconciliation(collection.ID, result.Tokens, result.Orders, result.Discrepancies)
+}
+
+func NewReconcileCollectionProcessor(imx imx.Client, db *db.DB) *ReconcileCollectionProcessor {
+	return &ReconcileCollectionProcessor{imx, db}
+}
+
+// Reconciliation holds the tokens and orders whose status or owner must be updated, and the discrepancies found.
+type Reconciliation struct {
+	Tokens        []models.Token
+	Orders        []models.Order
+	Discrepancies []models.Discrepancy
+}
+
+func Reconcile(collectionID uuid.UUID, tokens []models.Token, orders []models.Order, assets []imx.AssetInformation, imxOrders []imx.OrderSummary) *Reconciliation {
+	result := &Reconciliation{}
+	report := func(kind string, reference string, details string, resolved bool) {
+		result.Discrepancies = append(result.Discrepancies, models.Discrepancy{
+			ID:           uuid.New(),
+			CollectionID: collectionID,
+			Kind:         kind,
+			Reference:    reference,
+			Details:      details,
+			Resolved:     resolved,
+		})
+	}
+
+	assetsByTokenID := make(map[string]imx.AssetInformation, len(assets))
+	for _, asset := range assets {
+		assetsByTokenID[asset.TokenID] = asset
+	}
+
+	localTokenIDs := make(map[string]bool, len(tokens))
+	for _, token := range tokens {
+		localTokenIDs[token.TokenID] = true
+		asset, onIMX := assetsByTokenID[token.TokenID]
+		changed := false
+
+		switch {
+		case token.Status == models.TokenMinted && !onIMX:
+			report(models.DiscrepancyTokenMissingOnIMX, token.TokenID, "token minted locally but not found on IMX", false)
+		case token.Status != models.TokenMinted && onIMX:
+			report(models.DiscrepancyTokenStatus, token.TokenID, fmt.Sprintf("token %s locally but minted on IMX", token.Status), true)
+			token.Status = models.TokenMinted
+			changed = true
+		}
+
+		// tokens change hands on IMX, through trades or transfers made outside the platform
+		if onIMX && len(asset.User) > 0 && !strings.EqualFold(token.Owner, asset.User) {
+			if len(token.Owner) > 0 {
+				report(models.DiscrepancyTokenOwner, token.TokenID, fmt.Sprintf("token owned by %s locally but by %s on IMX", token.Owner, asset.User), true)
+			}
+			token.Owner = asset.User
+			changed = true
+		}
+
+		if changed {
+			result.Tokens = append(result.Tokens, token)
+		}
+	}
+
+	for _, asset := range assets {
+		if !localTokenIDs[asset.TokenID] {
+			report(models.DiscrepancyTokenMissingLocally, asset.TokenID, fmt.Sprintf("asset %s on IMX owned by %s", asset.Status, asset.User), false)
+		}
+	}
+
+	imxOrdersByID := make(map[int32]imx.OrderSummary, len(imxOrders))
+	for _, order := range imxOrders {
+		imxOrdersByID[order.OrderID] = order
+	}
+
+	localOrderIDs := make(map[int32]bool, len(orders))
+	for _, order := range orders {
+		localOrderIDs[order.OrderID] = true
+		imxOrder, onIMX := imxOrdersByID[order.OrderID]
+		reference := strconv.FormatInt(int64(order.OrderID), 10)
+
+		switch {
+		case !onIMX:
+			report(models.DiscrepancyOrderMissingOnIMX, reference, "order saved locally but not found on IMX", false)
+		case imxOrder.Status != order.Status:
+			report(models.DiscrepancyOrderStatus, reference, fmt.Sprintf("order %s locally but %s on IMX", order.Status, imxOrder.Status), true)
+			order.Status = imxOrder.Status
+			result.Orders = append(result.Orders, order)
+		}
+	}
+
+	for _, order := range imxOrders {
+		if !localOrderIDs[order.OrderID] {
+			report(models.DiscrepancyOrderMissingLocally, strconv.FormatInt(int64(order.OrderID), 10), fmt.Sprintf("order %s on IMX selling token %s", order.Status, order.TokenID), false)
+		}
+	}
+
+	return result
+}
@@ -0,0 +1,108 @@
+package tasks
+
+import (
+	"nft/imx"
+	"nft/models"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/suite"
+)
+
+type UnitTestSuite struct {
+	suite.Suite
+}
+
+func (s *UnitTestSuite) TestReconcileTokens() {
+	collectionID := uuid.New()
+	tokens := []models.Token{
+		{ID: uuid.New(), CollectionID: collectionID, TokenID: "1", Status: models.TokenMinted},
+		{ID: uuid.New(), CollectionID: collectionID, TokenID: "2", Status: models.TokenMinted},
+		{ID: uuid.New(), CollectionID: collectionID, TokenID: "3", Status: models.TokenPending},
+		{ID: uuid.New(), CollectionID: collectionID, TokenID: "4", Status: models.TokenPending},
+	}
+	assets := []imx.AssetInformation{
+		{TokenID: "1", Status: "imx"},
+		{TokenID: "3", Status: "imx"},
+		{TokenID: "5", Status: "imx"},
+	}
+
+	result := Reconcile(collectionID, tokens, nil, assets, nil)
+
+	s.Assertions.Len(result.Tokens, 1)
+	s.Assertions.Equal(tokens[2].ID, result.Tokens[0].ID)
+	s.Assertions.Equal(models.TokenMinted, result.Tokens[0].Status)
+
+	kinds := map[string]string{}
+	for _, discrepancy := range result.Discrepancies {
+		s.Assertions.Equal(collectionID, discrepancy.CollectionID)
+		kinds[discrepancy.Reference] = discrepancy.Kind
+	}
+	s.Assertions.Equal(map[string]string{
+		"2": models.DiscrepancyTokenMissingOnIMX,
+		"3": models.DiscrepancyTokenStatus,
+		"5": models.DiscrepancyTokenMissingLocally,
+	}, kinds)
+}
+
+func (s *UnitTestSuite) TestReconcileTokenOwners() {
+	collectionID := uuid.New()
+	tokens := []models.Token{
+		{ID: uuid.New(), CollectionID: collectionID, TokenID: "1", Status: models.TokenMinted, Owner: "0xabc"},
+		{ID: uuid.New(), CollectionID: collectionID, TokenID: "2", Status: models.TokenMinted, Owner: "0xabc"},
+		{ID: uuid.New(), CollectionID: collectionID, TokenID: "3", Status: models.TokenMinted},
+	}
+	assets := []imx.AssetInformation{
+		{TokenID: "1", Status: "imx", User: "0xABC"},
+		{TokenID: "2", Status: "imx", User: "0xdef"},
+		{TokenID: "3", Status: "imx", User: "0xdef"},
+	}
+
+	result := Reconcile(collectionID, tokens, nil, assets, nil)
+
+	// the owner of token 3 was not known yet, it is not a discrepancy
+	s.Assertions.Len(result.Tokens, 2)
+	s.Assertions.Equal(tokens[1].ID, result.Tokens[0].ID)
+	s.Assertions.Equal("0xdef", result.Tokens[0].Owner)
+	s.Assertions.Equal(tokens[2].ID, result.Tokens[1].ID)
+	s.Assertions.Equal("0xdef", result.Tokens[1].Owner)
+
+	s.Assertions.Len(result.Discrepancies, 1)
+	s.Assertions.Equal(models.DiscrepancyTokenOwner, result.Discrepancies[0].Kind)
+	s.Assertions.Equal("2", result.Discrepancies[0].Reference)
+	s.Assertions.True(result.Discrepancies[0].Resolved)
+}
+
+func (s *UnitTestSuite) TestReconcileOrders() {
+	collectionID := uuid.New()
+	orders := []models.Order{
+		{ID: uuid.New(), CollectionID: collectionID, OrderID: 1, Status: models.OrderActive},
+		{ID: uuid.New(), CollectionID: collectionID, OrderID: 2, Status: models.OrderActive},
+		{ID: uuid.New(), CollectionID: collectionID, OrderID: 3, Status: models.OrderActive},
+	}
+	imxOrders := []imx.OrderSummary{
+		{OrderID: 1, Status: "active"},
+		{OrderID: 2, Status: "filled"},
+		{OrderID: 4, Status: "active"},
+	}
+
+	result := Reconcile(collectionID, nil, orders, nil, imxOrders)
+
+	s.Assertions.Len(result.Orders, 1)
+	s.Assertions.Equal(orders[1].ID, result.Orders[0].ID)
+	s.Assertions.Equal("filled", result.Orders[0].Status)
+
+	kinds := map[string]string{}
+	for _, discrepancy := range result.Discrepancies {
+		kinds[discrepancy.Reference] = discrepancy.Kind
+	}
+	s.Assertions.Equal(map[string]string{
+		"2": models.DiscrepancyOrderStatus,
+		"3": models.DiscrepancyOrderMissingOnIMX,
+		"4": models.DiscrepancyOrderMissingLocally,
+	}, kinds)
+}
+
+func TestUnitTestSuite(t *testing.T) {
+	suite.Run(t, new(UnitTestSuite))
+}
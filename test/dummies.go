@@ -17,10 +17,18 @@ func CreateDummyUser(id uuid.UUID, mail string) *models.User {
 	}
 }
 
-func CreateDummyCollection(id uuid.UUID, userID uuid.UUID, contractAddress string) *models.Collection {
+func CreateDummyOrganization(id uuid.UUID) *models.Organization {
+	return &models.Organization{
+		ID:   id,
+		Name: "organization",
+	}
+}
+
+func CreateDummyCollection(id uuid.UUID, userID uuid.UUID, organizationID uuid.UUID, contractAddress string) *models.Collection {
 	return &models.Collection{
 		ID:              id,
 		UserID:          userID,
+		OrganizationID:  organizationID,
 		ContractAddress: contractAddress,
 	}
 }
@@ -30,5 +38,17 @@ func CreateDummyToken(id uuid.UUID, collectionID uuid.UUID, tokenID string) *mod
 		ID:           id,
 		CollectionID: collectionID,
 		TokenID:      tokenID,
+		Status:       models.TokenMinted,
+	}
+}
+
+func CreateDummyExternalUser(id uuid.UUID, mail string) *models.User {
+	return &models.User{
+		ID:             id,
+		ApiKey:         uuid.NewString(),
+		Mail:           mail,
+		Address:        "0x18b1ceDC9803096D970f52260D1835F07D7e448C",
+		StarkPublicKey: "0x0123",
+		External:       true,
 	}
 }
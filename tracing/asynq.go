@@ -0,0 +1,96 @@
+package tracing
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/hibiken/asynq"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/propagation"
+	semconv "go.opentelemetry.io/otel/semconv/v1.21.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// traceContextField is the payload field carrying the trace. The processors ignore it when decoding the
+// payload, and tasks enqueued before tracing simply start a new trace.
+const traceContextField = "TraceContext"
+
+var taskIDKey = attribute.Key("messaging.message.id")
+
+// InjectPayload adds the trace of ctx to a JSON object payload. Other payloads are returned as they are.
+func InjectPayload(ctx context.Context, payload []byte) []byte {
+	carrier := propagation.MapCarrier{}
+	propagator.Inject(ctx, carrier)
+	if len(carrier) == 0 {
+		return payload
+	}
+
+	fields := map[string]json.RawMessage{}
+	if len(payload) > 0 {
+		if err := json.Unmarshal(payload, &fields); err != nil {
+			return payload
+		}
+	}
+
+	var err error
+	if fields[traceContextField], err = json.Marshal(carrier); err != nil {
+		return payload
+	}
+
+	injected, err := json.Marshal(fields)
+	if err != nil {
+		return payload
+	}
+	return injected
+}
+
+// ExtractPayload returns ctx with the trace carried by the payload, if any.
+func ExtractPayload(ctx context.Context, payload []byte) context.Context {
+	var fields struct {
+		TraceContext propagation.MapCarrier
+	}
+	if err := json.Unmarshal(payload, &fields); err != nil || len(fields.TraceContext) == 0 {
+		return ctx
+	}
+	return propagator.Extract(ctx, fields.TraceContext)
+}
+
+// Enqueue enqueues the task in a span continuing the trace of its payload, or else the one of ctx, and passes
+// the span on to the processing of the task. The task is rebuilt with the new payload: its own options are
+// lost and must be given to Enqueue, and tasks deduplicated by payload with asynq.Unique must be enqueued
+// directly.
+func Enqueue(ctx context.Context, client *asynq.Client, task *asynq.Task, opts ...asynq.Option) (info *asynq.TaskInfo, err error) {
+	ctx = ExtractPayload(ctx, task.Payload())
+	ctx, span := tracer().Start(ctx, "enqueue "+task.Type(),
+		trace.WithSpanKind(trace.SpanKindProducer),
+		trace.WithAttributes(semconv.MessagingSystem("asynq"), semconv.MessagingOperationPublish),
+	)
+	defer func() { end(span, err) }()
+
+	info, err = client.EnqueueContext(ctx, asynq.NewTask(task.Type(), InjectPayload(ctx, task.Payload())), opts...)
+	if info != nil {
+		span.SetAttributes(taskIDKey.String(info.ID), semconv.MessagingDestinationName(info.Queue))
+	}
+	return info, err
+}
+
+// TaskMiddleware processes each task in a span continuing the trace of its payload.
+func TaskMiddleware(next asynq.Handler) asynq.Handler {
+	return asynq.HandlerFunc(func(ctx context.Context, task *asynq.Task) (err error) {
+		ctx = ExtractPayload(ctx, task.Payload())
+		ctx, span := tracer().Start(ctx, "process "+task.Type(),
+			trace.WithSpanKind(trace.SpanKindConsumer),
+			trace.WithAttributes(semconv.MessagingSystem("asynq"), semconv.MessagingOperationProcess),
+		)
+		defer func() { end(span, err) }()
+
+		if taskID, ok := asynq.GetTaskID(ctx); ok {
+			span.SetAttributes(taskIDKey.String(taskID))
+		}
+		if queue, ok := asynq.GetQueueName(ctx); ok {
+			span.SetAttributes(semconv.MessagingDestinationName(queue))
+		}
+
+		return next.ProcessTask(ctx, task)
+	})
+}
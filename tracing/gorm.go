@@ -0,0 +1,75 @@
+package tracing
+
+import (
+	"errors"
+
+	semconv "go.opentelemetry.io/otel/semconv/v1.21.0"
+	"go.opentelemetry.io/otel/trace"
+	"gorm.io/gorm"
+)
+
+const gormSpanKey = "tracing:span"
+
+// GormPlugin starts a span for each query, as a child of the context given with gorm.DB.WithContext.
+// Statements are recorded with their placeholders, never with the values.
+type GormPlugin struct{}
+
+var _ gorm.Plugin = GormPlugin{}
+
+func (GormPlugin) Name() string {
+	return "tracing"
+}
+
+func (GormPlugin) Initialize(db *gorm.DB) (err error) {
+	register := func(registerErr error) {
+		if err == nil {
+			err = registerErr
+		}
+	}
+
+	callbacks := db.Callback()
+	register(callbacks.Create().Before("gorm:create").Register("tracing:before_create", before("INSERT")))
+	register(callbacks.Create().After("gorm:create").Register("tracing:after_create", after))
+	register(callbacks.Query().Before("gorm:query").Register("tracing:before_query", before("SELECT")))
+	register(callbacks.Query().After("gorm:query").Register("tracing:after_query", after))
+	register(callbacks.Update().Before("gorm:update").Register("tracing:before_update", before("UPDATE")))
+	register(callbacks.Update().After("gorm:update").Register("tracing:after_update", after))
+	register(callbacks.Delete().Before("gorm:delete").Register("tracing:before_delete", before("DELETE")))
+	register(callbacks.Delete().After("gorm:delete").Register("tracing:after_delete", after))
+	register(callbacks.Row().Before("gorm:row").Register("tracing:before_row", before("ROW")))
+	register(callbacks.Row().After("gorm:row").Register("tracing:after_row", after))
+	register(callbacks.Raw().Before("gorm:raw").Register("tracing:before_raw", before("RAW")))
+	register(callbacks.Raw().After("gorm:raw").Register("tracing:after_raw", after))
+	return err
+}
+
+func before(operation string) func(*gorm.DB) {
+	return func(db *gorm.DB) {
+		name := operation
+		if len(db.Statement.Table) > 0 {
+			name += " " + db.Statement.Table
+		}
+
+		_, span := tracer().Start(db.Statement.Context, name,
+			trace.WithSpanKind(trace.SpanKindClient),
+			trace.WithAttributes(semconv.DBSystemPostgreSQL, semconv.DBOperation(operation), semconv.DBSQLTable(db.Statement.Table)),
+		)
+		db.InstanceSet(gormSpanKey, span)
+	}
+}
+
+func after(db *gorm.DB) {
+	value, ok := db.InstanceGet(gormSpanKey)
+	if !ok {
+		return
+	}
+	span := value.(trace.Span)
+
+	span.SetAttributes(semconv.DBStatement(db.Statement.SQL.String()))
+	err := db.Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		// the callers handle the missing records, they are not failures of the query
+		err = nil
+	}
+	end(span, err)
+}
@@ -0,0 +1,177 @@
+package tracing
+
+import (
+	"context"
+	"errors"
+	"nft/imx"
+	"nft/models"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var (
+	contractAddressKey = attribute.Key("imx.contract_address")
+	tokenIDKey         = attribute.Key("imx.token_id")
+	withdrawalIDKey    = attribute.Key("imx.withdrawal_id")
+)
+
+// IMXClient is an imx.Client recording a span for each call of another.
+type IMXClient struct {
+	client imx.Client
+}
+
+var _ imx.Client = (*IMXClient)(nil)
+
+// InstrumentIMX wraps the client. Wrapping imx.Resilient gives a span per call, retries included.
+func InstrumentIMX(client imx.Client) *IMXClient {
+	return &IMXClient{client}
+}
+
+func start(ctx context.Context, method string, attributes ...attribute.KeyValue) (context.Context, trace.Span) {
+	return tracer().Start(ctx, "imx."+method, trace.WithSpanKind(trace.SpanKindClient), trace.WithAttributes(attributes...))
+}
+
+func (c *IMXClient) Close() {
+	c.client.Close()
+}
+
+func (c *IMXClient) Address() string {
+	return c.client.Address()
+}
+
+func (c *IMXClient) CreateUser(ctx context.Context, user *models.User) (starkKey string, err error) {
+	ctx, span := start(ctx, "CreateUser")
+	defer func() { end(span, err) }()
+	return c.client.CreateUser(ctx, user)
+}
+
+func (c *IMXClient) CreateCollection(ctx context.Context, info *imx.CollectionInformation) (err error) {
+	ctx, span := start(ctx, "CreateCollection", contractAddressKey.String(info.ContractAddress))
+	defer func() { end(span, err) }()
+	return c.client.CreateCollection(ctx, info)
+}
+
+func (c *IMXClient) CreateMetadata(ctx context.Context, info *imx.MetadataInformation) (err error) {
+	ctx, span := start(ctx, "CreateMetadata", contractAddressKey.String(info.ContractAddress))
+	defer func() { end(span, err) }()
+	return c.client.CreateMetadata(ctx, info)
+}
+
+func (c *IMXClient) CreateToken(ctx context.Context, info *imx.MintInformation) (err error) {
+	ctx, span := start(ctx, "CreateToken", contractAddressKey.String(info.ContractAddress), tokenIDKey.String(info.TokenID))
+	defer func() { end(span, err) }()
+	return c.client.CreateToken(ctx, info)
+}
+
+func (c *IMXClient) TransferToken(ctx context.Context, info *imx.TransferInformation) (err error) {
+	ctx, span := start(ctx, "TransferToken")
+	defer func() { end(span, err) }()
+	return c.client.TransferToken(ctx, info)
+}
+
+func (c *IMXClient) CreateOrder(ctx context.Context, info *imx.OrderInformation) (orderID int32, err error) {
+	ctx, span := start(ctx, "CreateOrder")
+	defer func() { end(span, err) }()
+	return c.client.CreateOrder(ctx, info)
+}
+
+func (c *IMXClient) CreateEthDeposit(ctx context.Context, info *imx.CreateDepositInformation) (hash string, err error) {
+	ctx, span := start(ctx, "CreateEthDeposit")
+	defer func() { end(span, err) }()
+	return c.client.CreateEthDeposit(ctx, info)
+}
+
+// ConfirmEthDeposit does not mark the deposits still waiting for confirmation as failed, they are expected.
+func (c *IMXClient) ConfirmEthDeposit(ctx context.Context, transactionHash string) (err error) {
+	ctx, span := start(ctx, "ConfirmEthDeposit")
+	defer func() {
+		var notConfirmed imx.DepositNotConfirmedError
+		if errors.As(err, &notConfirmed) {
+			end(span, nil)
+			return
+		}
+		end(span, err)
+	}()
+	return c.client.ConfirmEthDeposit(ctx, transactionHash)
+}
+
+func (c *IMXClient) CreateTrade(ctx context.Context, info *imx.CreateTradeInformation) (tradeID int32, err error) {
+	ctx, span := start(ctx, "CreateTrade")
+	defer func() { end(span, err) }()
+	return c.client.CreateTrade(ctx, info)
+}
+
+func (c *IMXClient) CreateEthWithdrawal(ctx context.Context, info *imx.CreateWithdrawalInformation) (withdrawalID int32, err error) {
+	ctx, span := start(ctx, "CreateEthWithdrawal")
+	defer func() {
+		span.SetAttributes(withdrawalIDKey.Int(int(withdrawalID)))
+		end(span, err)
+	}()
+	return c.client.CreateEthWithdrawal(ctx, info)
+}
+
+// CompleteEthWithdrawal does not mark the withdrawals not ready yet as failed, they are expected.
+func (c *IMXClient) CompleteEthWithdrawal(ctx context.Context, info *imx.CompleteWithdrawalInformation) (err error) {
+	ctx, span := start(ctx, "CompleteEthWithdrawal", withdrawalIDKey.Int(int(info.WithdrawalID)))
+	defer func() {
+		var notReady imx.WithdrawalNotReadyError
+		if errors.As(err, &notReady) {
+			end(span, nil)
+			return
+		}
+		end(span, err)
+	}()
+	return c.client.CompleteEthWithdrawal(ctx, info)
+}
+
+func (c *IMXClient) GetSignableTrade(ctx context.Context, info *imx.CreateTradeInformation) (signable *imx.SignableInformation, err error) {
+	ctx, span := start(ctx, "GetSignableTrade")
+	defer func() { end(span, err) }()
+	return c.client.GetSignableTrade(ctx, info)
+}
+
+func (c *IMXClient) SubmitTrade(ctx context.Context, info *imx.SubmitTradeInformation) (tradeID int32, err error) {
+	ctx, span := start(ctx, "SubmitTrade")
+	defer func() { end(span, err) }()
+	return c.client.SubmitTrade(ctx, info)
+}
+
+func (c *IMXClient) GetSignableWithdrawal(ctx context.Context, info *imx.CreateWithdrawalInformation) (signable *imx.SignableInformation, err error) {
+	ctx, span := start(ctx, "GetSignableWithdrawal")
+	defer func() { end(span, err) }()
+	return c.client.GetSignableWithdrawal(ctx, info)
+}
+
+func (c *IMXClient) SubmitWithdrawal(ctx context.Context, info *imx.SubmitWithdrawalInformation) (withdrawalID int32, err error) {
+	ctx, span := start(ctx, "SubmitWithdrawal")
+	defer func() {
+		span.SetAttributes(withdrawalIDKey.Int(int(withdrawalID)))
+		end(span, err)
+	}()
+	return c.client.SubmitWithdrawal(ctx, info)
+}
+
+func (c *IMXClient) GetAsset(ctx context.Context, contractAddress string, tokenID string) (asset *imx.AssetInformation, err error) {
+	ctx, span := start(ctx, "GetAsset", contractAddressKey.String(contractAddress), tokenIDKey.String(tokenID))
+	defer func() { end(span, err) }()
+	return c.client.GetAsset(ctx, contractAddress, tokenID)
+}
+
+func (c *IMXClient) ListAssets(ctx context.Context, contractAddress string) (assets []imx.AssetInformation, err error) {
+	ctx, span := start(ctx, "ListAssets", contractAddressKey.String(contractAddress))
+	defer func() { end(span, err) }()
+	return c.client.ListAssets(ctx, contractAddress)
+}
+
+func (c *IMXClient) ListOrders(ctx context.Context, contractAddress string) (orders []imx.OrderSummary, err error) {
+	ctx, span := start(ctx, "ListOrders", contractAddressKey.String(contractAddress))
+	defer func() { end(span, err) }()
+	return c.client.ListOrders(ctx, contractAddress)
+}
+
+func (c *IMXClient) Ping(ctx context.Context) (err error) {
+	ctx, span := start(ctx, "Ping")
+	defer func() { end(span, err) }()
+	return c.client.Ping(ctx)
+}
@@ -0,0 +1,100 @@
+// Package tracing records OpenTelemetry spans of the HTTP requests, the database queries, the IMX calls and
+// the asynq tasks, following a request into the tasks it enqueues.
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/carlmjohnson/versioninfo"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.21.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	instrumentationName = "nft"
+
+	ExporterNone   = ""
+	ExporterStdout = "stdout"
+	ExporterOTLP   = "otlp"
+)
+
+// Settings selects where the spans are exported. OTLPEndpoint is the host:port of the OTLP/HTTP collector,
+// when empty the exporter reads the standard OTEL_EXPORTER_OTLP_* variables. SampleRatio is the share of the
+// traces started here that are recorded, traces started upstream keep their sampling decision.
+type Settings struct {
+	Exporter     string
+	OTLPEndpoint string
+	SampleRatio  float64
+}
+
+var requestIDKey = attribute.Key("http.request_id")
+
+// propagator carries the W3C trace context and baggage in the HTTP headers and the task payloads.
+var propagator = propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{})
+
+// Setup installs the global tracer provider and returns its shutdown, which flushes the spans left.
+// Without an exporter, spans are not recorded and the instrumentation costs next to nothing.
+func Setup(ctx context.Context, settings Settings) (func(context.Context) error, error) {
+	otel.SetTextMapPropagator(propagator)
+
+	var exporter sdktrace.SpanExporter
+	var err error
+	switch settings.Exporter {
+	case ExporterNone:
+		return func(context.Context) error { return nil }, nil
+	case ExporterStdout:
+		exporter, err = stdouttrace.New(stdouttrace.WithWriter(os.Stdout))
+	case ExporterOTLP:
+		var opts []otlptracehttp.Option
+		if len(settings.OTLPEndpoint) > 0 {
+			opts = append(opts, otlptracehttp.WithEndpoint(settings.OTLPEndpoint))
+		}
+		exporter, err = otlptracehttp.New(ctx, opts...)
+	default:
+		return nil, fmt.Errorf("unknown tracing exporter %q, use %q or %q", settings.Exporter, ExporterStdout, ExporterOTLP)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := resource.Merge(resource.Default(), resource.NewWithAttributes(
+		semconv.SchemaURL,
+		semconv.ServiceName(instrumentationName),
+		semconv.ServiceVersion(versioninfo.Short()),
+	))
+	if err != nil {
+		return nil, err
+	}
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(settings.SampleRatio))),
+	)
+	otel.SetTracerProvider(provider)
+
+	return provider.Shutdown, nil
+}
+
+func tracer() trace.Tracer {
+	return otel.Tracer(instrumentationName)
+}
+
+// end records the error of the operation, if any, and ends its span.
+func end(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
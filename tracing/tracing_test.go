@@ -0,0 +1,133 @@
+package tracing
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"nft/imx"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/hibiken/asynq"
+	"github.com/stretchr/testify/suite"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+type UnitTestSuite struct {
+	suite.Suite
+	recorder *tracetest.SpanRecorder
+}
+
+func (s *UnitTestSuite) SetupTest() {
+	s.recorder = tracetest.NewSpanRecorder()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(s.recorder)))
+}
+
+func (s *UnitTestSuite) TearDownTest() {
+	otel.SetTracerProvider(trace.NewNoopTracerProvider())
+}
+
+type payload struct {
+	WithdrawalID int32
+}
+
+func (s *UnitTestSuite) TestInjectPayloadKeepsTheFields() {
+	ctx, span := tracer().Start(context.Background(), "request")
+	defer span.End()
+
+	raw, err := json.Marshal(payload{42})
+	s.Require().NoError(err)
+	injected := InjectPayload(ctx, raw)
+	s.Assertions.NotEqual(raw, injected)
+
+	var p payload
+	s.Require().NoError(json.Unmarshal(injected, &p))
+	s.Assertions.Equal(int32(42), p.WithdrawalID)
+
+	extracted := trace.SpanContextFromContext(ExtractPayload(context.Background(), injected))
+	s.Assertions.Equal(span.SpanContext().TraceID(), extracted.TraceID())
+	s.Assertions.Equal(span.SpanContext().SpanID(), extracted.SpanID())
+}
+
+func (s *UnitTestSuite) TestInjectPayloadWithoutTrace() {
+	raw, err := json.Marshal(payload{42})
+	s.Require().NoError(err)
+
+	s.Assertions.Equal(raw, InjectPayload(context.Background(), raw))
+	s.Assertions.False(trace.SpanContextFromContext(ExtractPayload(context.Background(), raw)).IsValid())
+}
+
+func (s *UnitTestSuite) TestMiddlewareNamesSpanAfterRoute() {
+	router := chi.NewRouter()
+	router.Use(Middleware)
+	router.Get("/jobs/{jobID}", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/jobs/1", nil)
+	req.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	router.ServeHTTP(httptest.NewRecorder(), req)
+
+	spans := s.recorder.Ended()
+	s.Require().Len(spans, 1)
+	s.Assertions.Equal("GET /jobs/{jobID}", spans[0].Name())
+	s.Assertions.Equal(codes.Error, spans[0].Status().Code)
+	s.Assertions.Equal("4bf92f3577b34da6a3ce929d0e0e4736", spans[0].SpanContext().TraceID().String())
+	s.Assertions.Equal("00f067aa0ba902b7", spans[0].Parent().SpanID().String())
+}
+
+func (s *UnitTestSuite) TestTaskMiddlewareContinuesTheTrace() {
+	ctx, span := tracer().Start(context.Background(), "request")
+	span.End()
+
+	raw, err := json.Marshal(payload{42})
+	s.Require().NoError(err)
+
+	handler := TaskMiddleware(asynq.HandlerFunc(func(ctx context.Context, task *asynq.Task) error {
+		return errors.New("withdrawal not found")
+	}))
+	s.Assertions.Error(handler.ProcessTask(context.Background(), asynq.NewTask("withdrawal:complete", InjectPayload(ctx, raw))))
+
+	spans := s.recorder.Ended()
+	s.Require().Len(spans, 2)
+	s.Assertions.Equal("process withdrawal:complete", spans[1].Name())
+	s.Assertions.Equal(span.SpanContext().TraceID(), spans[1].SpanContext().TraceID())
+	s.Assertions.Equal(span.SpanContext().SpanID(), spans[1].Parent().SpanID())
+	s.Assertions.Equal(codes.Error, spans[1].Status().Code)
+}
+
+// stubClient answers the calls it overrides, the others panic.
+type stubClient struct {
+	imx.Client
+}
+
+func (stubClient) CompleteEthWithdrawal(ctx context.Context, info *imx.CompleteWithdrawalInformation) error {
+	return imx.NewWithdrawalNotReadyError("pending")
+}
+
+func (stubClient) CreateToken(ctx context.Context, info *imx.MintInformation) error {
+	return &imx.RequestError{Kind: imx.ErrConflict}
+}
+
+func (s *UnitTestSuite) TestInstrumentIMX() {
+	client := InstrumentIMX(stubClient{})
+	s.Assertions.Error(client.CompleteEthWithdrawal(context.Background(), &imx.CompleteWithdrawalInformation{WithdrawalID: 7}))
+	s.Assertions.ErrorIs(client.CreateToken(context.Background(), &imx.MintInformation{TokenID: "1"}), imx.ErrConflict)
+
+	spans := s.recorder.Ended()
+	s.Require().Len(spans, 2)
+	s.Assertions.Equal("imx.CompleteEthWithdrawal", spans[0].Name())
+	s.Assertions.Equal(codes.Unset, spans[0].Status().Code)
+	s.Assertions.Equal("imx.CreateToken", spans[1].Name())
+	s.Assertions.Equal(codes.Error, spans[1].Status().Code)
+}
+
+func TestUnitTestSuite(t *testing.T) {
+	suite.Run(t, new(UnitTestSuite))
+}
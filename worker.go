@@ -0,0 +1,141 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"nft/audit"
+	"nft/events"
+	"nft/imx"
+	"nft/logging"
+	"nft/outbox"
+	"nft/server"
+	"nft/tasks"
+	"nft/tracing"
+	"time"
+
+	"github.com/hibiken/asynq"
+	"github.com/redis/go-redis/v9"
+	"golang.org/x/exp/slog"
+)
+
+// worker processes the tasks, schedules the periodic ones and relays the outbox. It serves the metrics and
+// the probes on its own port, so it can be scaled apart from the API.
+type worker struct {
+	app         *app
+	asynqServer *asynq.Server
+	mux         *asynq.ServeMux
+	scheduler   *asynq.Scheduler
+	opsServer   *http.Server
+
+	stopRelay context.CancelFunc
+	relayDone chan struct{}
+}
+
+func newWorker(a *app) *worker {
+	asynqServer := asynq.NewServer(
+		a.redis,
+		asynq.Config{
+			IsFailure: func(err error) bool {
+				// this type of error will not count as an error in asynq and will not affect retry count
+				// the task will be scheduled again for execution.
+				var notReady imx.WithdrawalNotReadyError
+				var notConfirmed imx.DepositNotConfirmedError
+				return !errors.As(err, &notReady) && !errors.As(err, &notConfirmed)
+			},
+		},
+	)
+
+	publisher := events.NewPublisher(a.db, events.NewStream(redis.NewClient(&redis.Options{Addr: a.settings.RedisUrl})))
+
+	mux := asynq.NewServeMux()
+	mux.Use(tracing.TaskMiddleware)
+	mux.Use(logging.TaskMiddleware)
+	mux.Use(a.metrics.TaskMiddleware)
+	mux.Use(audit.NewRecorder(a.db).TaskMiddleware)
+	mux.Handle(tasks.TypeCompleteWithdrawal, tasks.NewCompleteWithdrawalProcessor(a.imx, a.db))
+	mux.Handle(tasks.TypeMintToken, tasks.NewMintTokenProcessor(a.imx, a.db))
+	mux.Handle(tasks.TypeConfirmDeposit, tasks.NewConfirmDepositProcessor(a.imx, a.db))
+	mux.Handle(tasks.TypePublishEvent, tasks.NewPublishEventProcessor(publisher))
+	mux.Handle(tasks.TypeDeliverWebhook, tasks.NewDeliverWebhookProcessor(a.db))
+	mux.Handle(tasks.TypeReconcileCollections, tasks.NewReconcileCollectionsProcessor(a.db, a.asynqClient))
+	mux.Handle(tasks.TypeReconcileCollection, tasks.NewReconcileCollectionProcessor(a.imx, a.db))
+	mux.Handle(tasks.TypePruneIdempotencyKeys, tasks.NewPruneIdempotencyKeysProcessor(a.db))
+
+	scheduler := asynq.NewScheduler(a.redis, &asynq.SchedulerOpts{
+		EnqueueErrorHandler: func(task *asynq.Task, _ []asynq.Option, err error) {
+			// every worker schedules the periodic tasks, the first one enqueues them
+			if !errors.Is(err, asynq.ErrDuplicateTask) {
+				slog.Error("error scheduling task", "type", task.Type(), "err", err)
+			}
+		},
+	})
+
+	opsServer := server.NewServer(a.settings, a.db, a.imx, a.asynqClient, a.metrics)
+	opsServer.ConfigureWorker()
+
+	return &worker{
+		app:         a,
+		asynqServer: asynqServer,
+		mux:         mux,
+		scheduler:   scheduler,
+		opsServer:   &http.Server{Addr: ":" + a.settings.WorkerPort, Handler: opsServer.Router},
+		relayDone:   make(chan struct{}),
+	}
+}
+
+func (w *worker) Start(errs chan<- error) error {
+	if err := w.asynqServer.Start(w.mux); err != nil {
+		return fmt.Errorf("could not run asynq server: %w", err)
+	}
+
+	if len(w.app.settings.ReconcileSchedule) > 0 {
+		if _, err := w.scheduler.Register(w.app.settings.ReconcileSchedule, tasks.NewReconcileCollectionsTask()); err != nil {
+			w.asynqServer.Shutdown()
+			return fmt.Errorf("invalid reconcile schedule: %w", err)
+		}
+	}
+
+	if _, err := w.scheduler.Register(tasks.PruneIdempotencyKeysSchedule, tasks.NewPruneIdempotencyKeysTask()); err != nil {
+		w.asynqServer.Shutdown()
+		return fmt.Errorf("could not schedule the idempotency keys pruning: %w", err)
+	}
+
+	if err := w.scheduler.Start(); err != nil {
+		w.asynqServer.Shutdown()
+		return fmt.Errorf("could not run asynq scheduler: %w", err)
+	}
+
+	var relayCtx context.Context
+	relayCtx, w.stopRelay = context.WithCancel(context.Background())
+	go func() {
+		defer close(w.relayDone)
+		outbox.NewRelay(w.app.db, w.app.asynqClient, time.Second).Run(relayCtx)
+	}()
+
+	go func() {
+		slog.Info("serving the worker metrics and probes", "addr", w.opsServer.Addr)
+		if err := w.opsServer.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			errs <- err
+		}
+	}()
+
+	return nil
+}
+
+// Shutdown stops relaying and scheduling, then waits for the active tasks. asynq bounds the wait with its
+// own timeout, the tasks not done by then are retried.
+func (w *worker) Shutdown(ctx context.Context) error {
+	w.stopRelay()
+	select {
+	case <-w.relayDone:
+	case <-ctx.Done():
+	}
+
+	w.scheduler.Shutdown()
+	w.asynqServer.Stop()
+	w.asynqServer.Shutdown()
+
+	return w.opsServer.Shutdown(ctx)
+}